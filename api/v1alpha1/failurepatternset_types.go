@@ -0,0 +1,161 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FailureSeverity ranks how serious a matched failure is
+// +kubebuilder:validation:Enum=Info;Warning;Critical
+type FailureSeverity string
+
+const (
+	// FailureSeverityInfo is a failure that resolves itself or needs no attention
+	FailureSeverityInfo FailureSeverity = "Info"
+	// FailureSeverityWarning is a failure that is remediated but worth knowing about
+	FailureSeverityWarning FailureSeverity = "Warning"
+	// FailureSeverityCritical is a failure that needs attention
+	FailureSeverityCritical FailureSeverity = "Critical"
+)
+
+// FailurePatternSetSpec is a library of failure patterns shared by monitors
+type FailurePatternSetSpec struct {
+	// Version is a free-form version of the library, e.g. the VolSync release it was written for
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Patterns are the patterns of the library
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Patterns []FailurePattern `json:"patterns"`
+}
+
+// FailurePattern is a named regex that recognises a failure in mover logs
+type FailurePattern struct {
+	// Name identifies the pattern within the set
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Regex is matched case insensitively against each log line and
+	// termination message of the failed mover
+	// +kubebuilder:validation:MinLength=1
+	Regex string `json:"regex"`
+
+	// Description explains the failure the pattern recognises
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// MoverTypes limits the pattern to these movers. Applies to all movers when empty.
+	// +optional
+	MoverTypes []MoverType `json:"moverTypes,omitempty"`
+
+	// Class is the failure class recorded for matches
+	// +kubebuilder:default=StaleLock
+	// +optional
+	Class FailureClass `json:"class,omitempty"`
+
+	// Severity is the severity recorded for matches
+	// +kubebuilder:default=Warning
+	// +optional
+	Severity FailureSeverity `json:"severity,omitempty"`
+
+	// Examples are log lines the pattern is verified against. Patterns
+	// failing their examples are reported in the status and not used.
+	// +optional
+	Examples *FailurePatternExamples `json:"examples,omitempty"`
+}
+
+// FailurePatternExamples are log lines a pattern must or must not match
+type FailurePatternExamples struct {
+	// Match are lines the pattern must match
+	// +optional
+	Match []string `json:"match,omitempty"`
+
+	// NoMatch are lines the pattern must not match
+	// +optional
+	NoMatch []string `json:"noMatch,omitempty"`
+}
+
+// FailurePatternSetStatus reports the verification of the patterns
+type FailurePatternSetStatus struct {
+	// ObservedGeneration is the generation that was verified
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Patterns is the number of patterns in the set
+	// +optional
+	Patterns int32 `json:"patterns,omitempty"`
+
+	// ValidPatterns is the number of patterns that compiled and passed their examples
+	// +optional
+	ValidPatterns int32 `json:"validPatterns,omitempty"`
+
+	// Failures lists the patterns that are not used and why
+	// +optional
+	Failures []FailurePatternFailure `json:"failures,omitempty"`
+
+	// Conditions represent the latest available observations of the set
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// FailurePatternFailure is a pattern that failed verification
+type FailurePatternFailure struct {
+	// Pattern is the name of the pattern
+	Pattern string `json:"pattern"`
+
+	// Message explains the failure
+	Message string `json:"message"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=fps
+//+kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version"
+//+kubebuilder:printcolumn:name="Patterns",type="integer",JSONPath=".status.patterns"
+//+kubebuilder:printcolumn:name="Valid",type="integer",JSONPath=".status.validPatterns"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// FailurePatternSet is the Schema for the failurepatternsets API.
+// Sets are cluster scoped so that monitors in any namespace can reference them.
+type FailurePatternSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FailurePatternSetSpec   `json:"spec,omitempty"`
+	Status FailurePatternSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// FailurePatternSetList contains a list of FailurePatternSet
+type FailurePatternSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FailurePatternSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FailurePatternSet{}, &FailurePatternSetList{})
+}
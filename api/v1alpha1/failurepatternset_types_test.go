@@ -0,0 +1,55 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestFailurePatternSet_DeepCopy(t *testing.T) {
+	original := &FailurePatternSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "restic"},
+		Spec: FailurePatternSetSpec{
+			Version: "1",
+			Patterns: []FailurePattern{{
+				Name:       "stale-lock",
+				Regex:      "repository is already locked",
+				MoverTypes: []MoverType{MoverTypeRestic},
+				Class:      FailureClassStaleLock,
+				Severity:   FailureSeverityWarning,
+				Examples:   &FailurePatternExamples{Match: []string{"repository is already locked by PID 1"}},
+			}},
+		},
+		Status: FailurePatternSetStatus{
+			Failures: []FailurePatternFailure{{Pattern: "stale-lock", Message: "invalid regex"}},
+		},
+	}
+
+	copied := original.DeepCopy()
+
+	original.Spec.Patterns[0].Examples.Match[0] = "changed"
+	if copied.Spec.Patterns[0].Examples.Match[0] != "repository is already locked by PID 1" {
+		t.Errorf("DeepCopy failed: Examples were not deeply copied")
+	}
+
+	original.Status.Failures[0].Message = "changed"
+	if copied.Status.Failures[0].Message != "invalid regex" {
+		t.Errorf("DeepCopy failed: Status was not deeply copied")
+	}
+}
+
+func TestFailurePatternSet_SchemeRegistration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add to scheme: %v", err)
+	}
+
+	obj, err := scheme.New(GroupVersion.WithKind("FailurePatternSet"))
+	if err != nil {
+		t.Errorf("Failed to create FailurePatternSet from scheme: %v", err)
+	}
+	if _, ok := obj.(*FailurePatternSet); !ok {
+		t.Errorf("Created object is not a FailurePatternSet")
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// UnlockRecordSpec describes a failed VolSync job and the remediation chosen for it
+type UnlockRecordSpec struct {
+	// MonitorRef is the VolSyncMonitor that handled the failure
+	MonitorRef MonitorReference `json:"monitorRef"`
+
+	// FailedJob is the VolSync job that failed
+	FailedJob FailedJobReference `json:"failedJob"`
+
+	// AppName is the name of the application
+	// +optional
+	AppName string `json:"appName,omitempty"`
+
+	// ObjectName is the ReplicationSource or ReplicationDestination that ran the failed job
+	// +optional
+	ObjectName string `json:"objectName,omitempty"`
+
+	// Direction tells whether the failed job backed up or restored the volume
+	// +optional
+	Direction VolSyncDirection `json:"direction,omitempty"`
+
+	// MoverType is the VolSync mover that ran the failed job
+	// +optional
+	MoverType MoverType `json:"moverType,omitempty"`
+
+	// LockError is the error text that was detected
+	// +optional
+	LockError string `json:"lockError,omitempty"`
+
+	// Classification is the class of failure that was detected
+	// +optional
+	Classification FailureClass `json:"classification,omitempty"`
+
+	// Pattern is the FailurePatternSet pattern that matched, as set/pattern
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// Severity is the severity of the matched pattern
+	// +optional
+	Severity FailureSeverity `json:"severity,omitempty"`
+
+	// DetectedBy records how the error was recognised
+	// +optional
+	DetectedBy LockErrorSource `json:"detectedBy,omitempty"`
+
+	// ExitCode is the restic exit code reported by the failed job, when known
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Remediation is the action that was taken
+	// +optional
+	Remediation RemediationAction `json:"remediation,omitempty"`
+
+	// UnlockJobName is the name of the unlock job created for the failure
+	// +optional
+	UnlockJobName string `json:"unlockJobName,omitempty"`
+}
+
+// MonitorReference identifies a VolSyncMonitor
+type MonitorReference struct {
+	// Name of the VolSyncMonitor
+	Name string `json:"name"`
+
+	// Namespace of the VolSyncMonitor
+	Namespace string `json:"namespace"`
+}
+
+// FailedJobReference identifies a failed VolSync job
+type FailedJobReference struct {
+	// Name of the job
+	Name string `json:"name"`
+
+	// Namespace of the job
+	Namespace string `json:"namespace"`
+
+	// UID of the job
+	// +optional
+	UID types.UID `json:"uid,omitempty"`
+
+	// FailureTime is when the job was marked as failed
+	// +optional
+	FailureTime *metav1.Time `json:"failureTime,omitempty"`
+}
+
+// UnlockRecordStatus defines the observed outcome of a remediation
+type UnlockRecordStatus struct {
+	// Outcome is the result of the remediation
+	// +optional
+	Outcome UnlockOutcome `json:"outcome,omitempty"`
+
+	// StartTime is when the remediation started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the remediation finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// LogsExcerpt is the tail of the failed job's logs
+	// +optional
+	LogsExcerpt string `json:"logsExcerpt,omitempty"`
+
+	// UnlockLogsExcerpt is the tail of the unlock job's logs
+	// +optional
+	UnlockLogsExcerpt string `json:"unlockLogsExcerpt,omitempty"`
+
+	// RetriggerResult describes how the VolSync job was run again, if it was
+	// +optional
+	RetriggerResult string `json:"retriggerResult,omitempty"`
+
+	// Message is a human readable description of the outcome
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// FailedPod is the state of the failed job's pod captured before the
+	// job was removed
+	// +optional
+	FailedPod *PodSnapshot `json:"failedPod,omitempty"`
+}
+
+// PodSnapshot is the state of a pod of a failed job
+type PodSnapshot struct {
+	// Name is the name of the pod
+	Name string `json:"name"`
+
+	// Phase is the phase of the pod
+	// +optional
+	Phase corev1.PodPhase `json:"phase,omitempty"`
+
+	// Reason and Message are the reason and message of the pod status
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// NodeName is the node the pod ran on
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Containers are the final states of the pod's containers
+	// +optional
+	Containers []ContainerSnapshot `json:"containers,omitempty"`
+
+	// LogsTail is the tail of the pod's logs
+	// +optional
+	LogsTail string `json:"logsTail,omitempty"`
+
+	// ConfigMapName is the ConfigMap that holds the snapshot too, if any
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// CaptureTime is when the snapshot was taken
+	CaptureTime metav1.Time `json:"captureTime"`
+}
+
+// ContainerSnapshot is the state of a container of a failed pod
+type ContainerSnapshot struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+
+	// ExitCode is the exit code of the terminated container
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Reason and Message describe the state of the container
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// RestartCount is the number of times the container restarted
+	// +optional
+	RestartCount int32 `json:"restartCount,omitempty"`
+}
+
+// FailureClass is the class of a detected VolSync job failure
+// +kubebuilder:validation:Enum=StaleLock;Transient;CacheCorruption;Unknown
+type FailureClass string
+
+const (
+	// FailureClassStaleLock is a repository lock left behind by an earlier run
+	FailureClassStaleLock FailureClass = "StaleLock"
+	// FailureClassTransient is a temporary failure that should succeed when retried
+	FailureClassTransient FailureClass = "Transient"
+	// FailureClassCacheCorruption is a restic cache volume that is corrupt or full
+	FailureClassCacheCorruption FailureClass = "CacheCorruption"
+	// FailureClassUnknown is a failure that could not be classified
+	FailureClassUnknown FailureClass = "Unknown"
+)
+
+// UnlockOutcome is the result of a remediation
+// +kubebuilder:validation:Enum=Running;Succeeded;Failed;Skipped
+type UnlockOutcome string
+
+const (
+	// UnlockOutcomeRunning means the unlock job is still running
+	UnlockOutcomeRunning UnlockOutcome = "Running"
+	// UnlockOutcomeSucceeded means the remediation completed successfully
+	UnlockOutcomeSucceeded UnlockOutcome = "Succeeded"
+	// UnlockOutcomeFailed means the remediation failed
+	UnlockOutcomeFailed UnlockOutcome = "Failed"
+	// UnlockOutcomeSkipped means no remediation was attempted
+	UnlockOutcomeSkipped UnlockOutcome = "Skipped"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=ur
+//+kubebuilder:printcolumn:name="Failed Job",type="string",JSONPath=".spec.failedJob.name"
+//+kubebuilder:printcolumn:name="Class",type="string",JSONPath=".spec.classification"
+//+kubebuilder:printcolumn:name="Remediation",type="string",JSONPath=".spec.remediation"
+//+kubebuilder:printcolumn:name="Outcome",type="string",JSONPath=".status.outcome"
+//+kubebuilder:printcolumn:name="Unlock Job",type="string",JSONPath=".spec.unlockJobName",priority=1
+//+kubebuilder:printcolumn:name="Error",type="string",JSONPath=".spec.lockError",priority=1
+//+kubebuilder:printcolumn:name="Started",type="date",JSONPath=".status.startTime"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// UnlockRecord is the Schema for the unlockrecords API.
+// Each record is the durable history of one failed VolSync job and its remediation.
+type UnlockRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UnlockRecordSpec   `json:"spec,omitempty"`
+	Status UnlockRecordStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// UnlockRecordList contains a list of UnlockRecord
+type UnlockRecordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UnlockRecord `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UnlockRecord{}, &UnlockRecordList{})
+}
@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestUnlockRecord_DeepCopy(t *testing.T) {
+	exitCode := int32(11)
+	now := metav1.Now()
+	original := &UnlockRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "volsync-src-app-1700000000",
+			Namespace: testNamespace,
+		},
+		Spec: UnlockRecordSpec{
+			MonitorRef: MonitorReference{Name: "monitor", Namespace: "homelab-assistant-system"},
+			FailedJob: FailedJobReference{
+				Name:        "volsync-src-app",
+				Namespace:   testNamespace,
+				UID:         "1234",
+				FailureTime: &now,
+			},
+			LockError:      "repository is already locked",
+			Classification: FailureClassStaleLock,
+			ExitCode:       &exitCode,
+			Remediation:    RemediationActionUnlock,
+		},
+		Status: UnlockRecordStatus{
+			Outcome:   UnlockOutcomeRunning,
+			StartTime: &now,
+		},
+	}
+
+	copied := original.DeepCopy()
+
+	if copied.Spec.FailedJob.Name != original.Spec.FailedJob.Name {
+		t.Errorf("DeepCopy failed: FailedJob mismatch")
+	}
+
+	*original.Spec.ExitCode = 1
+	if *copied.Spec.ExitCode != 11 {
+		t.Errorf("DeepCopy failed: ExitCode was not deeply copied")
+	}
+
+	original.Status.Outcome = UnlockOutcomeSucceeded
+	if copied.Status.Outcome != UnlockOutcomeRunning {
+		t.Errorf("DeepCopy failed: Status was not copied")
+	}
+}
+
+func TestUnlockRecord_SchemeRegistration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add to scheme: %v", err)
+	}
+
+	obj, err := scheme.New(GroupVersion.WithKind("UnlockRecord"))
+	if err != nil {
+		t.Errorf("Failed to create UnlockRecord from scheme: %v", err)
+	}
+	if _, ok := obj.(*UnlockRecord); !ok {
+		t.Errorf("Created object is not an UnlockRecord")
+	}
+
+	objList, err := scheme.New(GroupVersion.WithKind("UnlockRecordList"))
+	if err != nil {
+		t.Errorf("Failed to create UnlockRecordList from scheme: %v", err)
+	}
+	if _, ok := objList.(*UnlockRecordList); !ok {
+		t.Errorf("Created object is not an UnlockRecordList")
+	}
+}
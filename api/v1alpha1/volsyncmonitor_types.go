@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // VolSyncMonitorSpec defines the desired state of VolSyncMonitor
@@ -42,19 +43,348 @@ type VolSyncMonitorSpec struct {
 	// +optional
 	LockErrorPatterns []string `json:"lockErrorPatterns,omitempty"`
 
+	// PatternSets are the names of FailurePatternSets whose patterns are
+	// matched before the lock error patterns of the mover
+	// +optional
+	PatternSets []string `json:"patternSets,omitempty"`
+
 	// RemoveFailedJobs controls whether to remove failed VolSync jobs after creating unlock jobs
 	// +optional
 	RemoveFailedJobs bool `json:"removeFailedJobs,omitempty"`
 
+	// FailedJobRemoval controls when failed jobs are removed with
+	// removeFailedJobs, and what is kept of them. Failed jobs are removed
+	// right after the unlock job is created when unset.
+	// +optional
+	FailedJobRemoval *FailedJobRemovalSpec `json:"failedJobRemoval,omitempty"`
+
+	// HoldReplication keeps VolSync from starting the mover of the owning
+	// ReplicationSource or ReplicationDestination while its unlock job runs.
+	// The object is restored once the unlock job finished.
+	// +optional
+	// +kubebuilder:default=None
+	HoldReplication ReplicationHoldMode `json:"holdReplication,omitempty"`
+
+	// Escalations configures what happens when the unlock job of a failure
+	// fails, per failure class. Failed unlocks of classes without an entry
+	// are only counted.
+	// +optional
+	// +listType=map
+	// +listMapKey=class
+	Escalations []EscalationPolicy `json:"escalations,omitempty"`
+
+	// StuckMovers detects mover jobs that neither succeed nor fail: jobs that
+	// stay active longer than a threshold, and pods that cannot start because
+	// their volumes do not attach or mount
+	// +optional
+	StuckMovers *StuckMoverSpec `json:"stuckMovers,omitempty"`
+
+	// RecreateCache remediates restic movers that failed on a corrupt or full
+	// cache by deleting their cache volume, once no pod uses it, and removing
+	// the failed job, so that VolSync runs the mover again with a new cache.
+	// Cache failures are only recorded when unset.
+	// +optional
+	RecreateCache bool `json:"recreateCache,omitempty"`
+
+	// Providers lists the backup operators whose mover jobs are monitored.
+	// Jobs of all providers go through the same detection and remediation.
+	// Defaults to VolSync only.
+	// +optional
+	// +listType=set
+	Providers []BackupProvider `json:"providers,omitempty"`
+
 	// JobSelector defines how to identify VolSync jobs to monitor
-	// If not specified, monitors all jobs with "volsync-" prefix
+	// If not specified, monitors all VolSync mover jobs
 	// +optional
 	JobSelector *JobSelector `json:"jobSelector,omitempty"`
+
+	// Movers overrides lock detection and remediation per VolSync mover type
+	// Movers without an entry use the built-in defaults for their type
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Movers []MoverConfig `json:"movers,omitempty"`
+
+	// RecordRetention controls how long UnlockRecords created by this monitor are kept
+	// +optional
+	RecordRetention *RecordRetention `json:"recordRetention,omitempty"`
+
+	// MinLockAge is the minimum age of a lock reported by restic before it is
+	// removed. Younger locks may still be held by a mover and are left alone.
+	// +optional
+	MinLockAge *metav1.Duration `json:"minLockAge,omitempty"`
+
+	// LockSweep periodically lists the locks of every restic repository the
+	// monitor watches and removes stale ones before a backup fails on them
+	// +optional
+	LockSweep *LockSweepSpec `json:"lockSweep,omitempty"`
+
+	// HealthChecks runs restic check against every restic repository the
+	// monitor watches, on a schedule or after repeated unlocks
+	// +optional
+	HealthChecks *HealthCheckSpec `json:"healthChecks,omitempty"`
+
+	// Priority decides which monitor handles failed jobs selected by several
+	// monitors. The highest priority wins; monitors with equal priority
+	// handle a job in the order they claim it.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// FailedJobRemovalSpec configures the removal of failed VolSync jobs
+type FailedJobRemovalSpec struct {
+	// Delay is how long a failed job is kept after its unlock job finished.
+	// Failed jobs are removed once the unlock job finished when unset.
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+
+	// OnlyOnUnlockSuccess keeps failed jobs whose unlock job failed
+	// +optional
+	OnlyOnUnlockSuccess bool `json:"onlyOnUnlockSuccess,omitempty"`
+
+	// LogTailLines is the number of log lines of the failed pod kept before
+	// the job is removed. Defaults to 100.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	LogTailLines int32 `json:"logTailLines,omitempty"`
+
+	// SnapshotConfigMap also stores the status and log tail of the failed pod
+	// in a ConfigMap next to the UnlockRecord, which is removed with the record
+	// +optional
+	SnapshotConfigMap bool `json:"snapshotConfigMap,omitempty"`
+}
+
+// HealthCheckSpec configures repository health checks
+// +kubebuilder:validation:XValidation:rule="has(self.schedule) || has(self.afterUnlocks)",message="schedule or afterUnlocks is required"
+type HealthCheckSpec struct {
+	// Schedule is a cron expression for checking all repositories, for example "0 3 * * 0"
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// AfterUnlocks checks a repository once this many unlocks of it
+	// succeeded since its last check
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	AfterUnlocks int32 `json:"afterUnlocks,omitempty"`
+
+	// ReadDataSubset is passed to restic check --read-data-subset, for
+	// example "5%", "1/10" or "500M". Only metadata is checked when unset.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9]+/[0-9]+|[0-9]+(\.[0-9]+)?%|[0-9]+[KMGT]?)$`
+	ReadDataSubset string `json:"readDataSubset,omitempty"`
+}
+
+// EscalationPolicy is the escalation ladder of a failure class
+type EscalationPolicy struct {
+	// Class is the failure class the ladder applies to
+	Class FailureClass `json:"class"`
+
+	// Steps are tried in order until one of them succeeds.
+	// Defaults to RetryUnlock, Check, RebuildIndex, Notify.
+	// +optional
+	Steps []EscalationStep `json:"steps,omitempty"`
+
+	// MaxAttempts is the number of unlock attempts of the RetryUnlock step (default: 3)
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// InitialBackoff is the delay before the first unlock attempt of the
+	// RetryUnlock step, doubled for every further attempt (default: 1m)
+	// +optional
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff caps the delay between unlock attempts (default: 1h)
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// EscalationStep is a step of an escalation ladder
+// +kubebuilder:validation:Enum=RetryUnlock;Check;RebuildIndex;Notify
+type EscalationStep string
+
+const (
+	// EscalationStepRetryUnlock runs the unlock job again, with exponential backoff
+	EscalationStepRetryUnlock EscalationStep = "RetryUnlock"
+	// EscalationStepCheck runs restic check and unlocks the repository when it passes
+	EscalationStepCheck EscalationStep = "Check"
+	// EscalationStepRebuildIndex rebuilds the repository index, then unlocks it
+	EscalationStepRebuildIndex EscalationStep = "RebuildIndex"
+	// EscalationStepNotify emits a warning event and stops the escalation
+	EscalationStepNotify EscalationStep = "Notify"
+)
+
+// StuckMoverSpec configures the detection of stuck mover jobs
+type StuckMoverSpec struct {
+	// MaxRunDuration is how long a mover job may be active before it is
+	// considered hung. Jobs are not checked for it when unset.
+	// +optional
+	MaxRunDuration *metav1.Duration `json:"maxRunDuration,omitempty"`
+
+	// PendingTimeout is how long a mover pod may stay Pending, including
+	// ContainerCreating, with a warning event of one of the pendingReasons
+	// before it is considered stuck (default: 15m)
+	// +optional
+	PendingTimeout *metav1.Duration `json:"pendingTimeout,omitempty"`
+
+	// PendingReasons are the reasons of the pod warning events that make a
+	// Pending pod stuck. Defaults to FailedAttachVolume, which includes
+	// Multi-Attach errors, and FailedMount.
+	// +optional
+	PendingReasons []string `json:"pendingReasons,omitempty"`
+
+	// Action is taken once a stuck mover is detected
+	// +optional
+	// +kubebuilder:default=Notify
+	Action StuckMoverAction `json:"action,omitempty"`
+}
+
+// StuckMoverAction defines how the controller reacts to a stuck mover
+// +kubebuilder:validation:Enum=Notify;DeletePod;KillAndUnlock
+type StuckMoverAction string
+
+const (
+	// StuckMoverActionNotify only emits a warning event
+	StuckMoverActionNotify StuckMoverAction = "Notify"
+	// StuckMoverActionDeletePod deletes the stuck pods, so that the job
+	// controller starts new ones
+	StuckMoverActionDeletePod StuckMoverAction = "DeletePod"
+	// StuckMoverActionKillAndUnlock suspends the job to stop its pods, unlocks
+	// the repository once they are gone and deletes the job once the unlock
+	// finished, so that VolSync starts the mover again
+	StuckMoverActionKillAndUnlock StuckMoverAction = "KillAndUnlock"
+)
+
+// StuckMoverKind tells how a mover is stuck
+type StuckMoverKind string
+
+const (
+	// StuckMoverKindHung is a job active longer than maxRunDuration
+	StuckMoverKindHung StuckMoverKind = "Hung"
+	// StuckMoverKindPending is a pod that cannot start
+	StuckMoverKindPending StuckMoverKind = "Pending"
+)
+
+// LockSweepSpec configures scheduled lock sweeps
+type LockSweepSpec struct {
+	// Schedule is a cron expression for the sweeps, for example "0 */6 * * *"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// MaxLockAge is the age above which a lock is removed when no mover of its
+	// ReplicationSource is running. Locks are only reported when unset.
+	// +optional
+	MaxLockAge *metav1.Duration `json:"maxLockAge,omitempty"`
+}
+
+// RecordRetention defines the retention of UnlockRecords
+type RecordRetention struct {
+	// MaxAge is how long records are kept (default: 720h)
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+
+	// MaxRecords is the maximum number of records kept per monitor (default: 100)
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRecords int32 `json:"maxRecords,omitempty"`
+}
+
+// MoverType identifies the VolSync data mover that ran a job
+// +kubebuilder:validation:Enum=restic;rclone;rsync;kopia
+type MoverType string
+
+const (
+	// MoverTypeRestic is the restic mover
+	MoverTypeRestic MoverType = "restic"
+	// MoverTypeRclone is the rclone mover
+	MoverTypeRclone MoverType = "rclone"
+	// MoverTypeRsync is the rsync (ssh or tls) mover
+	MoverTypeRsync MoverType = "rsync"
+	// MoverTypeKopia is the kopia mover provided by VolSync forks
+	MoverTypeKopia MoverType = "kopia"
+)
+
+// BackupProvider identifies the backup operator that ran a mover job
+// +kubebuilder:validation:Enum=VolSync;K8up
+type BackupProvider string
+
+const (
+	// BackupProviderVolSync runs movers for ReplicationSources and ReplicationDestinations
+	BackupProviderVolSync BackupProvider = "VolSync"
+	// BackupProviderK8up runs restic jobs for k8up Backups, Checks and Prunes
+	BackupProviderK8up BackupProvider = "K8up"
+)
+
+// VolSyncDirection tells whether a mover job backs up or restores a volume
+// +kubebuilder:validation:Enum=Source;Destination
+type VolSyncDirection string
+
+const (
+	// VolSyncDirectionSource is a job of a ReplicationSource
+	VolSyncDirectionSource VolSyncDirection = "Source"
+	// VolSyncDirectionDestination is a job of a ReplicationDestination
+	VolSyncDirectionDestination VolSyncDirection = "Destination"
+)
+
+// RemediationAction defines how the controller reacts to a detected mover error
+// +kubebuilder:validation:Enum=Unlock;Retry;RecreateCache;None
+type RemediationAction string
+
+const (
+	// RemediationActionUnlock runs the unlock job template against the repository
+	RemediationActionUnlock RemediationAction = "Unlock"
+	// RemediationActionRetry removes the failed job so VolSync runs it again, without unlocking
+	RemediationActionRetry RemediationAction = "Retry"
+	// RemediationActionRecreateCache deletes the restic cache volume of the
+	// mover and removes the failed job, so VolSync runs it again with a new cache
+	RemediationActionRecreateCache RemediationAction = "RecreateCache"
+	// RemediationActionNone only records the error
+	RemediationActionNone RemediationAction = "None"
+)
+
+// ReplicationHoldMode defines how a VolSync object is held during an unlock
+// +kubebuilder:validation:Enum=None;Pause;ManualTrigger
+type ReplicationHoldMode string
+
+const (
+	// ReplicationHoldNone leaves the VolSync object alone
+	ReplicationHoldNone ReplicationHoldMode = "None"
+	// ReplicationHoldPause sets spec.paused on the VolSync object
+	ReplicationHoldPause ReplicationHoldMode = "Pause"
+	// ReplicationHoldManualTrigger replaces the trigger of the VolSync object
+	// with a manual trigger that already ran, so no schedule fires
+	ReplicationHoldManualTrigger ReplicationHoldMode = "ManualTrigger"
+)
+
+// MoverConfig overrides detection and remediation for a single mover type.
+// Kopia has no built-in unlock command: its lock errors are detected, but an
+// Unlock remediation falls back to None until UnlockJobTemplate sets an image
+// and a command, and every such error is reported in an UnlockUnavailable
+// warning event on the monitor.
+type MoverConfig struct {
+	// Type is the mover type this configuration applies to
+	Type MoverType `json:"type"`
+
+	// LockErrorPatterns are regex patterns that indicate an error for this mover
+	// Defaults to spec.lockErrorPatterns, then to the built-in patterns of the mover
+	// +optional
+	LockErrorPatterns []string `json:"lockErrorPatterns,omitempty"`
+
+	// Remediation is the action taken when an error is detected
+	// Defaults to Unlock for restic and Retry for rclone and rsync. Kopia only unlocks
+	// when UnlockJobTemplate sets an image and a command, and defaults to None otherwise
+	// +optional
+	Remediation RemediationAction `json:"remediation,omitempty"`
+
+	// UnlockJobTemplate replaces spec.unlockJobTemplate for this mover type
+	// +optional
+	UnlockJobTemplate *UnlockJobTemplate `json:"unlockJobTemplate,omitempty"`
 }
 
 // JobSelector defines how to select jobs to monitor
 type JobSelector struct {
-	// NamePrefix filters jobs by name prefix (default: "volsync-")
+	// NamePrefix filters jobs by name prefix. VolSync mover jobs are
+	// recognised by their labels, owner and name when unset.
 	// +optional
 	NamePrefix string `json:"namePrefix,omitempty"`
 
@@ -70,7 +400,16 @@ type JobSelector struct {
 // UnlockJobTemplate defines the template for creating unlock jobs
 type UnlockJobTemplate struct {
 	// Image is the container image to use for unlock jobs
-	Image string `json:"image"`
+	// When unset, unlock jobs run the image of the failed mover container, so
+	// that they use the same restic version that wrote the repository
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImageOverrides maps the image of the failed mover, or only its tag, to
+	// the image unlock jobs run instead, e.g. "0.9.1": "quay.io/backube/volsync:0.10.0"
+	// Overrides take precedence over image
+	// +optional
+	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
 
 	// Command is the command to run in the unlock job
 	// +optional
@@ -99,6 +438,21 @@ type VolSyncMonitorStatus struct {
 	// +optional
 	ActiveUnlocks []ActiveUnlock `json:"activeUnlocks,omitempty"`
 
+	// QueuedUnlocks lists the lock errors found in the last reconciliation
+	// whose unlock was deferred
+	// +optional
+	QueuedUnlocks []QueuedUnlock `json:"queuedUnlocks,omitempty"`
+
+	// Escalations tracks the failures whose unlock job failed, and where they
+	// are in their escalation ladder
+	// +optional
+	Escalations []Escalation `json:"escalations,omitempty"`
+
+	// StuckMovers lists the running mover jobs found stuck, and what was done
+	// about them
+	// +optional
+	StuckMovers []StuckMover `json:"stuckMovers,omitempty"`
+
 	// ProcessedJobs tracks jobs that have been processed (failed jobs that were handled)
 	// +optional
 	ProcessedJobs []ProcessedJob `json:"processedJobs,omitempty"`
@@ -142,6 +496,162 @@ type VolSyncMonitorStatus struct {
 	// ObservedGeneration is the last generation observed by the controller
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ConsecutiveErrors is the number of reconciliations that failed in a row
+	// +optional
+	ConsecutiveErrors int32 `json:"consecutiveErrors,omitempty"`
+
+	// LockSweep reports the scheduled lock sweeps
+	// +optional
+	LockSweep *LockSweepStatus `json:"lockSweep,omitempty"`
+
+	// HealthChecks reports the repository health checks
+	// +optional
+	HealthChecks *HealthCheckStatus `json:"healthChecks,omitempty"`
+
+	// Overlaps lists the other enabled monitors watching the same namespaces
+	// and which monitor handles their failed jobs
+	// +optional
+	Overlaps []MonitorOverlap `json:"overlaps,omitempty"`
+}
+
+// MonitorOverlap describes namespaces watched by this and another monitor
+type MonitorOverlap struct {
+	// Monitor is the other monitor
+	Monitor MonitorReference `json:"monitor"`
+
+	// Priority of the other monitor
+	Priority int32 `json:"priority"`
+
+	// Namespaces watched by both monitors, "*" when both watch all namespaces
+	Namespaces []string `json:"namespaces"`
+
+	// Handler is the monitor handling failed jobs in these namespaces
+	Handler OverlapHandler `json:"handler"`
+}
+
+// OverlapHandler tells which of two overlapping monitors handles failed jobs
+// +kubebuilder:validation:Enum=Self;Other;FirstClaim
+type OverlapHandler string
+
+const (
+	// OverlapHandlerSelf means this monitor has the higher priority
+	OverlapHandlerSelf OverlapHandler = "Self"
+	// OverlapHandlerOther means the other monitor has the higher priority
+	OverlapHandlerOther OverlapHandler = "Other"
+	// OverlapHandlerFirstClaim means both have the same priority and the first
+	// monitor to claim a failed job handles it
+	OverlapHandlerFirstClaim OverlapHandler = "FirstClaim"
+)
+
+// HealthCheckStatus reports the repository health checks of a monitor
+type HealthCheckStatus struct {
+	// LastScheduledTime is when the last scheduled check of all repositories started
+	// +optional
+	LastScheduledTime *metav1.Time `json:"lastScheduledTime,omitempty"`
+
+	// NextScheduledTime is when the next scheduled check is due
+	// +optional
+	NextScheduledTime *metav1.Time `json:"nextScheduledTime,omitempty"`
+
+	// Repositories reports the last check per ReplicationSource
+	// +optional
+	Repositories []RepositoryHealth `json:"repositories,omitempty"`
+}
+
+// HealthCheckResult is the outcome of a repository health check
+// +kubebuilder:validation:Enum=Healthy;Unhealthy;Error
+type HealthCheckResult string
+
+const (
+	// HealthCheckResultHealthy means restic check found no errors
+	HealthCheckResultHealthy HealthCheckResult = "Healthy"
+	// HealthCheckResultUnhealthy means restic check found errors in the repository
+	HealthCheckResultUnhealthy HealthCheckResult = "Unhealthy"
+	// HealthCheckResultError means the check could not run, for example because the repository was locked
+	HealthCheckResultError HealthCheckResult = "Error"
+)
+
+// RepositoryHealth reports the health of the repository of a ReplicationSource
+type RepositoryHealth struct {
+	// Namespace is the namespace of the ReplicationSource
+	Namespace string `json:"namespace"`
+
+	// ReplicationSource is the name of the ReplicationSource
+	ReplicationSource string `json:"replicationSource"`
+
+	// CheckJobName is the name of the running check job
+	// +optional
+	CheckJobName string `json:"checkJobName,omitempty"`
+
+	// CheckTime is when the last check finished
+	// +optional
+	CheckTime *metav1.Time `json:"checkTime,omitempty"`
+
+	// Result is the outcome of the last check
+	// +optional
+	Result HealthCheckResult `json:"result,omitempty"`
+
+	// UnlocksSinceCheck is the number of successful unlocks since the last check
+	// +optional
+	UnlocksSinceCheck int32 `json:"unlocksSinceCheck,omitempty"`
+
+	// Pending is set while a due check waits for the jobs using the repository
+	// to finish or for a free check slot
+	// +optional
+	Pending bool `json:"pending,omitempty"`
+
+	// Message describes the result of the last check
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// LockSweepStatus reports the scheduled lock sweeps of a monitor
+type LockSweepStatus struct {
+	// LastSweepTime is when the last sweep started
+	// +optional
+	LastSweepTime *metav1.Time `json:"lastSweepTime,omitempty"`
+
+	// NextSweepTime is when the next sweep is due
+	// +optional
+	NextSweepTime *metav1.Time `json:"nextSweepTime,omitempty"`
+
+	// Repositories lists the locks found per ReplicationSource by the last sweep
+	// +optional
+	Repositories []RepositoryLocks `json:"repositories,omitempty"`
+}
+
+// RepositoryLocks reports the locks of the repository of a ReplicationSource
+type RepositoryLocks struct {
+	// Namespace is the namespace of the ReplicationSource
+	Namespace string `json:"namespace"`
+
+	// ReplicationSource is the name of the ReplicationSource
+	ReplicationSource string `json:"replicationSource"`
+
+	// SweepJobName is the name of the job that lists the locks
+	// +optional
+	SweepJobName string `json:"sweepJobName,omitempty"`
+
+	// CheckTime is when the locks were listed
+	// +optional
+	CheckTime *metav1.Time `json:"checkTime,omitempty"`
+
+	// Locks is the number of locks in the repository
+	// +optional
+	Locks int32 `json:"locks,omitempty"`
+
+	// OldestLockTime is when the oldest lock was created
+	// +optional
+	OldestLockTime *metav1.Time `json:"oldestLockTime,omitempty"`
+
+	// UnlockJobName is the unlock job created for stale locks
+	// +optional
+	UnlockJobName string `json:"unlockJobName,omitempty"`
+
+	// Message describes the result of the sweep
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // ProcessedJob represents a failed job that was processed
@@ -152,6 +662,32 @@ type ProcessedJob struct {
 	// Namespace is the namespace of the failed job
 	Namespace string `json:"namespace"`
 
+	// JobUID is the UID of the failed job
+	// VolSync reuses job names, so the UID identifies a specific failure
+	// +optional
+	JobUID types.UID `json:"jobUID,omitempty"`
+
+	// AppName is the application the failed job belongs to
+	// +optional
+	AppName string `json:"appName,omitempty"`
+
+	// ObjectName is the ReplicationSource or ReplicationDestination that ran the
+	// failed job, or the k8up Backup, Check or Prune
+	// +optional
+	ObjectName string `json:"objectName,omitempty"`
+
+	// Direction tells whether the failed job backed up or restored the volume
+	// +optional
+	Direction VolSyncDirection `json:"direction,omitempty"`
+
+	// Provider is the backup operator that ran the failed job
+	// +optional
+	Provider BackupProvider `json:"provider,omitempty"`
+
+	// FailureTime is when the failed job was marked as failed
+	// +optional
+	FailureTime *metav1.Time `json:"failureTime,omitempty"`
+
 	// ProcessedTime is when the job was processed
 	ProcessedTime metav1.Time `json:"processedTime"`
 
@@ -163,8 +699,66 @@ type ProcessedJob struct {
 
 	// LockError is the lock error that was detected
 	LockError string `json:"lockError"`
+
+	// DetectedBy records how the lock error was recognised
+	// +optional
+	DetectedBy LockErrorSource `json:"detectedBy,omitempty"`
+
+	// ExitCode is the restic exit code reported by the failed job, when known
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// MessageType is the restic JSON message type that carried the lock error
+	// +optional
+	MessageType string `json:"messageType,omitempty"`
+
+	// MoverType is the VolSync mover that ran the failed job
+	// +optional
+	MoverType MoverType `json:"moverType,omitempty"`
+
+	// Remediation is the action that was taken for the failed job
+	// +optional
+	Remediation RemediationAction `json:"remediation,omitempty"`
+
+	// Classification is the class of failure that was detected
+	// +optional
+	Classification FailureClass `json:"classification,omitempty"`
+
+	// Pattern is the FailurePatternSet pattern that matched, as set/pattern
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// Severity is the severity of the matched pattern
+	// +optional
+	Severity FailureSeverity `json:"severity,omitempty"`
+
+	// RecordName is the name of the UnlockRecord created for the failed job
+	// +optional
+	RecordName string `json:"recordName,omitempty"`
+
+	// RemovalPending is set while the failed job waits for its unlock job
+	// and the removal delay before it is removed
+	// +optional
+	RemovalPending bool `json:"removalPending,omitempty"`
 }
 
+// LockErrorSource describes how a lock error was recognised in a failed job
+// +kubebuilder:validation:Enum=json;exitCode;pattern;manual;sweep
+type LockErrorSource string
+
+const (
+	// LockErrorSourceJSON means the error was parsed from restic JSON output
+	LockErrorSourceJSON LockErrorSource = "json"
+	// LockErrorSourceExitCode means the error was inferred from the container exit code
+	LockErrorSourceExitCode LockErrorSource = "exitCode"
+	// LockErrorSourcePattern means the error matched one of the configured regex patterns
+	LockErrorSourcePattern LockErrorSource = "pattern"
+	// LockErrorSourceManual means the unlock was requested by a user
+	LockErrorSourceManual LockErrorSource = "manual"
+	// LockErrorSourceSweep means a scheduled lock sweep found a stale lock
+	LockErrorSourceSweep LockErrorSource = "sweep"
+)
+
 // ActiveUnlock represents an active unlock operation
 type ActiveUnlock struct {
 	// AppName is the name of the application
@@ -176,6 +770,11 @@ type ActiveUnlock struct {
 	// ObjectName is the name of the VolSync object
 	ObjectName string `json:"objectName"`
 
+	// Direction tells whether the VolSync object is a ReplicationSource or
+	// a ReplicationDestination
+	// +optional
+	Direction VolSyncDirection `json:"direction,omitempty"`
+
 	// JobName is the name of the unlock job
 	JobName string `json:"jobName"`
 
@@ -186,6 +785,122 @@ type ActiveUnlock struct {
 	AlertFingerprint string `json:"alertFingerprint"`
 }
 
+// Escalation is the progress of a failure through its escalation ladder
+type Escalation struct {
+	// JobName is the name of the failed VolSync job
+	JobName string `json:"jobName"`
+
+	// Namespace is the namespace of the failed VolSync job
+	Namespace string `json:"namespace"`
+
+	// JobUID is the UID of the failed VolSync job
+	// +optional
+	JobUID types.UID `json:"jobUID,omitempty"`
+
+	// AppName is the application the failed job belongs to
+	// +optional
+	AppName string `json:"appName,omitempty"`
+
+	// ObjectName is the VolSync object the failed job belongs to
+	// +optional
+	ObjectName string `json:"objectName,omitempty"`
+
+	// MoverType is the mover of the failed job
+	// +optional
+	MoverType MoverType `json:"moverType,omitempty"`
+
+	// Class is the failure class whose ladder is followed
+	Class FailureClass `json:"class"`
+
+	// Step is the current step. The escalation stopped once it reached Notify.
+	Step EscalationStep `json:"step"`
+
+	// Attempts is the number of attempts of the current step so far
+	Attempts int32 `json:"attempts"`
+
+	// NextAttemptTime is when the next attempt of the current step starts.
+	// Unset while an attempt runs and once the escalation stopped.
+	// +optional
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+
+	// CurrentJobName is the job of the running attempt
+	// +optional
+	CurrentJobName string `json:"currentJobName,omitempty"`
+
+	// StartTime is when the escalation started
+	StartTime metav1.Time `json:"startTime"`
+
+	// Message describes the outcome of the last attempt
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// StuckMover is a mover job found stuck
+type StuckMover struct {
+	// JobName is the name of the mover job
+	JobName string `json:"jobName"`
+
+	// Namespace is the namespace of the mover job
+	Namespace string `json:"namespace"`
+
+	// JobUID is the UID of the mover job
+	// +optional
+	JobUID types.UID `json:"jobUID,omitempty"`
+
+	// PodName is the stuck pod, for Pending movers
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// AppName is the application the job belongs to
+	// +optional
+	AppName string `json:"appName,omitempty"`
+
+	// ObjectName is the VolSync object the job belongs to
+	// +optional
+	ObjectName string `json:"objectName,omitempty"`
+
+	// Kind tells how the mover is stuck
+	Kind StuckMoverKind `json:"kind"`
+
+	// Reason is the reason of the pod event, or MaxRunDurationExceeded
+	Reason string `json:"reason"`
+
+	// Message describes why the mover is stuck
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// DetectedTime is when the mover was found stuck
+	DetectedTime metav1.Time `json:"detectedTime"`
+
+	// Action is the action taken
+	Action StuckMoverAction `json:"action"`
+
+	// UnlockJobName is the unlock job created after the job was killed
+	// +optional
+	UnlockJobName string `json:"unlockJobName,omitempty"`
+}
+
+// QueuedUnlock is a lock error waiting for its unlock job
+type QueuedUnlock struct {
+	// JobName is the name of the failed job
+	JobName string `json:"jobName"`
+
+	// Namespace is the namespace of the failed job
+	Namespace string `json:"namespace"`
+
+	// ObjectName is the ReplicationSource or ReplicationDestination that ran the
+	// failed job, or the k8up Backup, Check or Prune
+	// +optional
+	ObjectName string `json:"objectName,omitempty"`
+
+	// Direction tells whether the failed job backed up or restored the volume
+	// +optional
+	Direction VolSyncDirection `json:"direction,omitempty"`
+
+	// Reason is why the unlock was deferred
+	Reason string `json:"reason"`
+}
+
 // VolSyncMonitorPhase represents the phase of the monitor
 type VolSyncMonitorPhase string
 
@@ -194,10 +909,30 @@ const (
 	VolSyncMonitorPhaseActive VolSyncMonitorPhase = "Active"
 	// VolSyncMonitorPhasePaused indicates the monitor is paused
 	VolSyncMonitorPhasePaused VolSyncMonitorPhase = "Paused"
+	// VolSyncMonitorPhaseDegraded indicates recent reconciliations failed but the monitor keeps working
+	VolSyncMonitorPhaseDegraded VolSyncMonitorPhase = "Degraded"
 	// VolSyncMonitorPhaseError indicates the monitor has encountered an error
 	VolSyncMonitorPhaseError VolSyncMonitorPhase = "Error"
 )
 
+// Condition types reported on VolSyncMonitor status
+const (
+	// ConditionTypeReady indicates the monitor is enabled and its last reconciliation succeeded
+	ConditionTypeReady = "Ready"
+	// ConditionTypeReconciling indicates unlock operations are in progress
+	ConditionTypeReconciling = "Reconciling"
+	// ConditionTypeDegraded indicates recent reconciliations failed
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypePaused indicates the monitor is disabled
+	ConditionTypePaused = "Paused"
+	// ConditionTypeQueueSaturated indicates MaxConcurrentUnlocks has been reached
+	ConditionTypeQueueSaturated = "QueueSaturated"
+	// ConditionTypeRepositoriesHealthy indicates the last health check of every repository found no errors
+	ConditionTypeRepositoriesHealthy = "RepositoriesHealthy"
+	// ConditionTypePatternSetsFound indicates every FailurePatternSet the monitor references exists
+	ConditionTypePatternSetsFound = "PatternSetsFound"
+)
+
 // ResourceRequirements defines resource requirements
 type RepositoryMount struct {
 	// Type of mount (nfs, pvc, hostPath, etc.)
@@ -299,9 +1034,11 @@ type SecurityContext struct {
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 //+kubebuilder:printcolumn:name="Active Unlocks",type="integer",JSONPath=".status.activeUnlocks"
 //+kubebuilder:printcolumn:name="Total Created",type="integer",JSONPath=".status.totalUnlocksCreated"
 //+kubebuilder:printcolumn:name="Jobs Removed",type="integer",JSONPath=".status.totalFailedJobsRemoved"
+//+kubebuilder:printcolumn:name="Priority",type="integer",JSONPath=".spec.priority",priority=1
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // VolSyncMonitor is the Schema for the volsyncmonitors API
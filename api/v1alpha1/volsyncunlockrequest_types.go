@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolSyncUnlockRequestSpec describes a repository to unlock on demand
+// +kubebuilder:validation:XValidation:rule="has(self.replicationSource) != has(self.secretName)",message="exactly one of replicationSource or secretName must be set"
+type VolSyncUnlockRequestSpec struct {
+	// Namespace of the ReplicationSource or secret. Defaults to the namespace of the request.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ReplicationSource whose repository is unlocked. The repository secret, volumes
+	// and mover type are discovered from its mover job or its spec.
+	// +optional
+	ReplicationSource string `json:"replicationSource,omitempty"`
+
+	// SecretName is a restic repository secret to unlock directly
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// MonitorRef selects the VolSyncMonitor whose unlock job template and limits are used.
+	// Defaults to the only monitor that selects the target namespace.
+	// +optional
+	MonitorRef *MonitorReference `json:"monitorRef,omitempty"`
+
+	// Reason is recorded on the unlock job and in the unlock history
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Force skips the check that no mover job uses the repository, and the
+	// spec.minLockAge check of the monitor
+	// +optional
+	Force bool `json:"force,omitempty"`
+}
+
+// VolSyncUnlockRequestPhase is the progress of an unlock request
+// +kubebuilder:validation:Enum=Pending;Waiting;Running;Succeeded;Failed
+type VolSyncUnlockRequestPhase string
+
+const (
+	// VolSyncUnlockRequestPhasePending means the request has not been processed yet
+	VolSyncUnlockRequestPhasePending VolSyncUnlockRequestPhase = "Pending"
+	// VolSyncUnlockRequestPhaseWaiting means the unlock is held back by a running mover or the concurrency limit
+	VolSyncUnlockRequestPhaseWaiting VolSyncUnlockRequestPhase = "Waiting"
+	// VolSyncUnlockRequestPhaseRunning means the unlock job is running
+	VolSyncUnlockRequestPhaseRunning VolSyncUnlockRequestPhase = "Running"
+	// VolSyncUnlockRequestPhaseSucceeded means the unlock job completed
+	VolSyncUnlockRequestPhaseSucceeded VolSyncUnlockRequestPhase = "Succeeded"
+	// VolSyncUnlockRequestPhaseFailed means the unlock job failed or could not be created
+	VolSyncUnlockRequestPhaseFailed VolSyncUnlockRequestPhase = "Failed"
+)
+
+// VolSyncUnlockRequestStatus reports the result of an unlock request
+type VolSyncUnlockRequestStatus struct {
+	// Phase is the progress of the request
+	// +optional
+	Phase VolSyncUnlockRequestPhase `json:"phase,omitempty"`
+
+	// Message explains the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// MonitorRef is the VolSyncMonitor used for the unlock
+	// +optional
+	MonitorRef *MonitorReference `json:"monitorRef,omitempty"`
+
+	// MoverType is the mover of the ReplicationSource
+	// +optional
+	MoverType MoverType `json:"moverType,omitempty"`
+
+	// LockListJobName is the job listing the locks of the repository before the
+	// unlock, when the monitor sets spec.minLockAge
+	// +optional
+	LockListJobName string `json:"lockListJobName,omitempty"`
+
+	// UnlockJobName is the name of the unlock job
+	// +optional
+	UnlockJobName string `json:"unlockJobName,omitempty"`
+
+	// RecordName is the UnlockRecord that keeps the history of the request
+	// +optional
+	RecordName string `json:"recordName,omitempty"`
+
+	// StartTime is when the unlock job was created
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the request finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// ObservedGeneration is the generation observed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=vur
+//+kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.replicationSource"
+//+kubebuilder:printcolumn:name="Secret",type="string",JSONPath=".spec.secretName"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Unlock Job",type="string",JSONPath=".status.unlockJobName",priority=1
+//+kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VolSyncUnlockRequest is the Schema for the volsyncunlockrequests API.
+// Each request unlocks one repository once, through the same pipeline as the VolSyncMonitor.
+type VolSyncUnlockRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolSyncUnlockRequestSpec   `json:"spec,omitempty"`
+	Status VolSyncUnlockRequestStatus `json:"status,omitempty"`
+}
+
+// TargetNamespace returns the namespace of the repository to unlock
+func (r *VolSyncUnlockRequest) TargetNamespace() string {
+	if r.Spec.Namespace != "" {
+		return r.Spec.Namespace
+	}
+	return r.Namespace
+}
+
+// IsFinished reports whether the request reached a terminal phase
+func (r *VolSyncUnlockRequest) IsFinished() bool {
+	return r.Status.Phase == VolSyncUnlockRequestPhaseSucceeded || r.Status.Phase == VolSyncUnlockRequestPhaseFailed
+}
+
+//+kubebuilder:object:root=true
+
+// VolSyncUnlockRequestList contains a list of VolSyncUnlockRequest
+type VolSyncUnlockRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolSyncUnlockRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolSyncUnlockRequest{}, &VolSyncUnlockRequestList{})
+}
@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestVolSyncUnlockRequest_DeepCopy(t *testing.T) {
+	now := metav1.Now()
+	original := &VolSyncUnlockRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "unlock-app",
+			Namespace: testNamespace,
+		},
+		Spec: VolSyncUnlockRequestSpec{
+			ReplicationSource: "app",
+			MonitorRef:        &MonitorReference{Name: "monitor", Namespace: "homelab-assistant-system"},
+			Reason:            "stale lock after node reboot",
+		},
+		Status: VolSyncUnlockRequestStatus{
+			Phase:     VolSyncUnlockRequestPhaseRunning,
+			StartTime: &now,
+		},
+	}
+
+	copied := original.DeepCopy()
+
+	original.Spec.MonitorRef.Name = "other"
+	if copied.Spec.MonitorRef.Name != "monitor" {
+		t.Errorf("DeepCopy failed: MonitorRef was not deeply copied")
+	}
+
+	original.Status.Phase = VolSyncUnlockRequestPhaseSucceeded
+	if copied.Status.Phase != VolSyncUnlockRequestPhaseRunning {
+		t.Errorf("DeepCopy failed: Status was not copied")
+	}
+}
+
+func TestVolSyncUnlockRequest_TargetNamespace(t *testing.T) {
+	request := &VolSyncUnlockRequest{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace}}
+	if got := request.TargetNamespace(); got != testNamespace {
+		t.Errorf("TargetNamespace() = %q, want %q", got, testNamespace)
+	}
+
+	request.Spec.Namespace = "media"
+	if got := request.TargetNamespace(); got != "media" {
+		t.Errorf("TargetNamespace() = %q, want %q", got, "media")
+	}
+}
+
+func TestVolSyncUnlockRequest_IsFinished(t *testing.T) {
+	tests := []struct {
+		phase VolSyncUnlockRequestPhase
+		want  bool
+	}{
+		{"", false},
+		{VolSyncUnlockRequestPhasePending, false},
+		{VolSyncUnlockRequestPhaseWaiting, false},
+		{VolSyncUnlockRequestPhaseRunning, false},
+		{VolSyncUnlockRequestPhaseSucceeded, true},
+		{VolSyncUnlockRequestPhaseFailed, true},
+	}
+
+	for _, tt := range tests {
+		request := &VolSyncUnlockRequest{Status: VolSyncUnlockRequestStatus{Phase: tt.phase}}
+		if got := request.IsFinished(); got != tt.want {
+			t.Errorf("IsFinished() with phase %q = %v, want %v", tt.phase, got, tt.want)
+		}
+	}
+}
+
+func TestVolSyncUnlockRequest_SchemeRegistration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add to scheme: %v", err)
+	}
+
+	obj, err := scheme.New(GroupVersion.WithKind("VolSyncUnlockRequest"))
+	if err != nil {
+		t.Errorf("Failed to create VolSyncUnlockRequest from scheme: %v", err)
+	}
+	if _, ok := obj.(*VolSyncUnlockRequest); !ok {
+		t.Errorf("Created object is not a VolSyncUnlockRequest")
+	}
+}
@@ -41,6 +41,333 @@ func (in *ActiveUnlock) DeepCopy() *ActiveUnlock {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerSnapshot) DeepCopyInto(out *ContainerSnapshot) {
+	*out = *in
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerSnapshot.
+func (in *ContainerSnapshot) DeepCopy() *ContainerSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Escalation) DeepCopyInto(out *Escalation) {
+	*out = *in
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Escalation.
+func (in *Escalation) DeepCopy() *Escalation {
+	if in == nil {
+		return nil
+	}
+	out := new(Escalation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EscalationPolicy) DeepCopyInto(out *EscalationPolicy) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]EscalationStep, len(*in))
+		copy(*out, *in)
+	}
+	if in.InitialBackoff != nil {
+		in, out := &in.InitialBackoff, &out.InitialBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxBackoff != nil {
+		in, out := &in.MaxBackoff, &out.MaxBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EscalationPolicy.
+func (in *EscalationPolicy) DeepCopy() *EscalationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(EscalationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedJobReference) DeepCopyInto(out *FailedJobReference) {
+	*out = *in
+	if in.FailureTime != nil {
+		in, out := &in.FailureTime, &out.FailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedJobReference.
+func (in *FailedJobReference) DeepCopy() *FailedJobReference {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedJobReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedJobRemovalSpec) DeepCopyInto(out *FailedJobRemovalSpec) {
+	*out = *in
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedJobRemovalSpec.
+func (in *FailedJobRemovalSpec) DeepCopy() *FailedJobRemovalSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedJobRemovalSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePattern) DeepCopyInto(out *FailurePattern) {
+	*out = *in
+	if in.MoverTypes != nil {
+		in, out := &in.MoverTypes, &out.MoverTypes
+		*out = make([]MoverType, len(*in))
+		copy(*out, *in)
+	}
+	if in.Examples != nil {
+		in, out := &in.Examples, &out.Examples
+		*out = new(FailurePatternExamples)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePattern.
+func (in *FailurePattern) DeepCopy() *FailurePattern {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternExamples) DeepCopyInto(out *FailurePatternExamples) {
+	*out = *in
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NoMatch != nil {
+		in, out := &in.NoMatch, &out.NoMatch
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternExamples.
+func (in *FailurePatternExamples) DeepCopy() *FailurePatternExamples {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternExamples)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternFailure) DeepCopyInto(out *FailurePatternFailure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternFailure.
+func (in *FailurePatternFailure) DeepCopy() *FailurePatternFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternSet) DeepCopyInto(out *FailurePatternSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternSet.
+func (in *FailurePatternSet) DeepCopy() *FailurePatternSet {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FailurePatternSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternSetList) DeepCopyInto(out *FailurePatternSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FailurePatternSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternSetList.
+func (in *FailurePatternSetList) DeepCopy() *FailurePatternSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FailurePatternSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternSetSpec) DeepCopyInto(out *FailurePatternSetSpec) {
+	*out = *in
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]FailurePattern, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternSetSpec.
+func (in *FailurePatternSetSpec) DeepCopy() *FailurePatternSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePatternSetStatus) DeepCopyInto(out *FailurePatternSetStatus) {
+	*out = *in
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]FailurePatternFailure, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePatternSetStatus.
+func (in *FailurePatternSetStatus) DeepCopy() *FailurePatternSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePatternSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckSpec) DeepCopyInto(out *HealthCheckSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckSpec.
+func (in *HealthCheckSpec) DeepCopy() *HealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckStatus) DeepCopyInto(out *HealthCheckStatus) {
+	*out = *in
+	if in.LastScheduledTime != nil {
+		in, out := &in.LastScheduledTime, &out.LastScheduledTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduledTime != nil {
+		in, out := &in.NextScheduledTime, &out.NextScheduledTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]RepositoryHealth, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckStatus.
+func (in *HealthCheckStatus) DeepCopy() *HealthCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HostPathMount) DeepCopyInto(out *HostPathMount) {
 	*out = *in
@@ -78,53 +405,273 @@ func (in *JobSelector) DeepCopy() *JobSelector {
 	if in == nil {
 		return nil
 	}
-	out := new(JobSelector)
+	out := new(JobSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockSweepSpec) DeepCopyInto(out *LockSweepSpec) {
+	*out = *in
+	if in.MaxLockAge != nil {
+		in, out := &in.MaxLockAge, &out.MaxLockAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockSweepSpec.
+func (in *LockSweepSpec) DeepCopy() *LockSweepSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LockSweepSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockSweepStatus) DeepCopyInto(out *LockSweepStatus) {
+	*out = *in
+	if in.LastSweepTime != nil {
+		in, out := &in.LastSweepTime, &out.LastSweepTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextSweepTime != nil {
+		in, out := &in.NextSweepTime, &out.NextSweepTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]RepositoryLocks, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockSweepStatus.
+func (in *LockSweepStatus) DeepCopy() *LockSweepStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LockSweepStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitorOverlap) DeepCopyInto(out *MonitorOverlap) {
+	*out = *in
+	out.Monitor = in.Monitor
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitorOverlap.
+func (in *MonitorOverlap) DeepCopy() *MonitorOverlap {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitorOverlap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitorReference) DeepCopyInto(out *MonitorReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitorReference.
+func (in *MonitorReference) DeepCopy() *MonitorReference {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitorReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MoverConfig) DeepCopyInto(out *MoverConfig) {
+	*out = *in
+	if in.LockErrorPatterns != nil {
+		in, out := &in.LockErrorPatterns, &out.LockErrorPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnlockJobTemplate != nil {
+		in, out := &in.UnlockJobTemplate, &out.UnlockJobTemplate
+		*out = new(UnlockJobTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MoverConfig.
+func (in *MoverConfig) DeepCopy() *MoverConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MoverConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSMount) DeepCopyInto(out *NFSMount) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSMount.
+func (in *NFSMount) DeepCopy() *NFSMount {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSMount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCMount) DeepCopyInto(out *PVCMount) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCMount.
+func (in *PVCMount) DeepCopy() *PVCMount {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCMount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodSnapshot) DeepCopyInto(out *PodSnapshot) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.CaptureTime.DeepCopyInto(&out.CaptureTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSnapshot.
+func (in *PodSnapshot) DeepCopy() *PodSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(PodSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProcessedJob) DeepCopyInto(out *ProcessedJob) {
+	*out = *in
+	if in.FailureTime != nil {
+		in, out := &in.FailureTime, &out.FailureTime
+		*out = (*in).DeepCopy()
+	}
+	in.ProcessedTime.DeepCopyInto(&out.ProcessedTime)
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProcessedJob.
+func (in *ProcessedJob) DeepCopy() *ProcessedJob {
+	if in == nil {
+		return nil
+	}
+	out := new(ProcessedJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueuedUnlock) DeepCopyInto(out *QueuedUnlock) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueuedUnlock.
+func (in *QueuedUnlock) DeepCopy() *QueuedUnlock {
+	if in == nil {
+		return nil
+	}
+	out := new(QueuedUnlock)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NFSMount) DeepCopyInto(out *NFSMount) {
+func (in *RecordRetention) DeepCopyInto(out *RecordRetention) {
 	*out = *in
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSMount.
-func (in *NFSMount) DeepCopy() *NFSMount {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordRetention.
+func (in *RecordRetention) DeepCopy() *RecordRetention {
 	if in == nil {
 		return nil
 	}
-	out := new(NFSMount)
+	out := new(RecordRetention)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PVCMount) DeepCopyInto(out *PVCMount) {
+func (in *RepositoryHealth) DeepCopyInto(out *RepositoryHealth) {
 	*out = *in
+	if in.CheckTime != nil {
+		in, out := &in.CheckTime, &out.CheckTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCMount.
-func (in *PVCMount) DeepCopy() *PVCMount {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryHealth.
+func (in *RepositoryHealth) DeepCopy() *RepositoryHealth {
 	if in == nil {
 		return nil
 	}
-	out := new(PVCMount)
+	out := new(RepositoryHealth)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProcessedJob) DeepCopyInto(out *ProcessedJob) {
+func (in *RepositoryLocks) DeepCopyInto(out *RepositoryLocks) {
 	*out = *in
-	in.ProcessedTime.DeepCopyInto(&out.ProcessedTime)
+	if in.CheckTime != nil {
+		in, out := &in.CheckTime, &out.CheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.OldestLockTime != nil {
+		in, out := &in.OldestLockTime, &out.OldestLockTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProcessedJob.
-func (in *ProcessedJob) DeepCopy() *ProcessedJob {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryLocks.
+func (in *RepositoryLocks) DeepCopy() *RepositoryLocks {
 	if in == nil {
 		return nil
 	}
-	out := new(ProcessedJob)
+	out := new(RepositoryLocks)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -218,9 +765,62 @@ func (in *SecurityContext) DeepCopy() *SecurityContext {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StuckMover) DeepCopyInto(out *StuckMover) {
+	*out = *in
+	in.DetectedTime.DeepCopyInto(&out.DetectedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StuckMover.
+func (in *StuckMover) DeepCopy() *StuckMover {
+	if in == nil {
+		return nil
+	}
+	out := new(StuckMover)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StuckMoverSpec) DeepCopyInto(out *StuckMoverSpec) {
+	*out = *in
+	if in.MaxRunDuration != nil {
+		in, out := &in.MaxRunDuration, &out.MaxRunDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PendingTimeout != nil {
+		in, out := &in.PendingTimeout, &out.PendingTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PendingReasons != nil {
+		in, out := &in.PendingReasons, &out.PendingReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StuckMoverSpec.
+func (in *StuckMoverSpec) DeepCopy() *StuckMoverSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StuckMoverSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UnlockJobTemplate) DeepCopyInto(out *UnlockJobTemplate) {
 	*out = *in
+	if in.ImageOverrides != nil {
+		in, out := &in.ImageOverrides, &out.ImageOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Command != nil {
 		in, out := &in.Command, &out.Command
 		*out = make([]string, len(*in))
@@ -253,6 +853,115 @@ func (in *UnlockJobTemplate) DeepCopy() *UnlockJobTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnlockRecord) DeepCopyInto(out *UnlockRecord) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnlockRecord.
+func (in *UnlockRecord) DeepCopy() *UnlockRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(UnlockRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UnlockRecord) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnlockRecordList) DeepCopyInto(out *UnlockRecordList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]UnlockRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnlockRecordList.
+func (in *UnlockRecordList) DeepCopy() *UnlockRecordList {
+	if in == nil {
+		return nil
+	}
+	out := new(UnlockRecordList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UnlockRecordList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnlockRecordSpec) DeepCopyInto(out *UnlockRecordSpec) {
+	*out = *in
+	out.MonitorRef = in.MonitorRef
+	in.FailedJob.DeepCopyInto(&out.FailedJob)
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnlockRecordSpec.
+func (in *UnlockRecordSpec) DeepCopy() *UnlockRecordSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UnlockRecordSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UnlockRecordStatus) DeepCopyInto(out *UnlockRecordStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FailedPod != nil {
+		in, out := &in.FailedPod, &out.FailedPod
+		*out = new(PodSnapshot)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnlockRecordStatus.
+func (in *UnlockRecordStatus) DeepCopy() *UnlockRecordStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UnlockRecordStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolSyncMonitor) DeepCopyInto(out *VolSyncMonitor) {
 	*out = *in
@@ -326,11 +1035,65 @@ func (in *VolSyncMonitorSpec) DeepCopyInto(out *VolSyncMonitorSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PatternSets != nil {
+		in, out := &in.PatternSets, &out.PatternSets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailedJobRemoval != nil {
+		in, out := &in.FailedJobRemoval, &out.FailedJobRemoval
+		*out = new(FailedJobRemovalSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Escalations != nil {
+		in, out := &in.Escalations, &out.Escalations
+		*out = make([]EscalationPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StuckMovers != nil {
+		in, out := &in.StuckMovers, &out.StuckMovers
+		*out = new(StuckMoverSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]BackupProvider, len(*in))
+		copy(*out, *in)
+	}
 	if in.JobSelector != nil {
 		in, out := &in.JobSelector, &out.JobSelector
 		*out = new(JobSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Movers != nil {
+		in, out := &in.Movers, &out.Movers
+		*out = make([]MoverConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RecordRetention != nil {
+		in, out := &in.RecordRetention, &out.RecordRetention
+		*out = new(RecordRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MinLockAge != nil {
+		in, out := &in.MinLockAge, &out.MinLockAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LockSweep != nil {
+		in, out := &in.LockSweep, &out.LockSweep
+		*out = new(LockSweepSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = new(HealthCheckSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncMonitorSpec.
@@ -353,6 +1116,25 @@ func (in *VolSyncMonitorStatus) DeepCopyInto(out *VolSyncMonitorStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.QueuedUnlocks != nil {
+		in, out := &in.QueuedUnlocks, &out.QueuedUnlocks
+		*out = make([]QueuedUnlock, len(*in))
+		copy(*out, *in)
+	}
+	if in.Escalations != nil {
+		in, out := &in.Escalations, &out.Escalations
+		*out = make([]Escalation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StuckMovers != nil {
+		in, out := &in.StuckMovers, &out.StuckMovers
+		*out = make([]StuckMover, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.ProcessedJobs != nil {
 		in, out := &in.ProcessedJobs, &out.ProcessedJobs
 		*out = make([]ProcessedJob, len(*in))
@@ -371,6 +1153,23 @@ func (in *VolSyncMonitorStatus) DeepCopyInto(out *VolSyncMonitorStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LockSweep != nil {
+		in, out := &in.LockSweep, &out.LockSweep
+		*out = new(LockSweepStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = new(HealthCheckStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Overlaps != nil {
+		in, out := &in.Overlaps, &out.Overlaps
+		*out = make([]MonitorOverlap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncMonitorStatus.
@@ -382,3 +1181,110 @@ func (in *VolSyncMonitorStatus) DeepCopy() *VolSyncMonitorStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolSyncUnlockRequest) DeepCopyInto(out *VolSyncUnlockRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncUnlockRequest.
+func (in *VolSyncUnlockRequest) DeepCopy() *VolSyncUnlockRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(VolSyncUnlockRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolSyncUnlockRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolSyncUnlockRequestList) DeepCopyInto(out *VolSyncUnlockRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VolSyncUnlockRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncUnlockRequestList.
+func (in *VolSyncUnlockRequestList) DeepCopy() *VolSyncUnlockRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(VolSyncUnlockRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolSyncUnlockRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolSyncUnlockRequestSpec) DeepCopyInto(out *VolSyncUnlockRequestSpec) {
+	*out = *in
+	if in.MonitorRef != nil {
+		in, out := &in.MonitorRef, &out.MonitorRef
+		*out = new(MonitorReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncUnlockRequestSpec.
+func (in *VolSyncUnlockRequestSpec) DeepCopy() *VolSyncUnlockRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VolSyncUnlockRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolSyncUnlockRequestStatus) DeepCopyInto(out *VolSyncUnlockRequestStatus) {
+	*out = *in
+	if in.MonitorRef != nil {
+		in, out := &in.MonitorRef, &out.MonitorRef
+		*out = new(MonitorReference)
+		**out = **in
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolSyncUnlockRequestStatus.
+func (in *VolSyncUnlockRequestStatus) DeepCopy() *VolSyncUnlockRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolSyncUnlockRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
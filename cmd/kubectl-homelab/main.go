@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-homelab is a kubectl plugin to inspect and drive homelab-assistant.
+// Install it on the PATH and run it as "kubectl homelab".
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/controller"
+)
+
+var (
+	// Version information set by build
+	version   = "dev"
+	buildDate = "unknown"
+
+	scheme = runtime.NewScheme()
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(volsyncv1alpha1.AddToScheme(scheme))
+}
+
+const usage = `Inspect and drive homelab-assistant.
+
+Usage:
+  kubectl homelab volsync <command> [flags]
+  kubectl homelab version
+
+Commands:
+  status  [monitor]            Show VolSyncMonitor status and unlock jobs
+  history                      List the unlock history (UnlockRecords)
+  unlock  <namespace>/<app>    Create an unlock job for an app
+  explain <namespace>/<job>    Show how a failed job is classified
+  pause   <monitor>            Disable a monitor
+  resume  <monitor>            Enable a monitor
+
+Run "kubectl homelab volsync <command> -h" for the flags of a command.
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches the plugin command line
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return nil
+	}
+
+	switch args[0] {
+	case "version", "--version":
+		fmt.Fprintf(out, "kubectl-homelab version %s (built %s)\n", version, buildDate)
+		return nil
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	case "volsync":
+		return runVolSync(ctx, args[1:], out)
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+// newReconciler builds a reconciler against the current kubeconfig so that
+// the plugin runs the same discovery and unlock logic as the controller
+func newReconciler() (*controller.VolSyncMonitorReconciler, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return &controller.VolSyncMonitorReconciler{
+		Client: c,
+		Scheme: scheme,
+	}, nil
+}
+
+// defaultNamespace returns the namespace of the current kubeconfig context
+func defaultNamespace() string {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+	return namespace
+}
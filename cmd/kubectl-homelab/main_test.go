@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/controller"
+)
+
+func TestRunVersion(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"version"}, &out); err != nil {
+		t.Fatalf("version failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "kubectl-homelab version") {
+		t.Errorf("unexpected version output: %q", out.String())
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(context.Background(), []string{"volsync", "frobnicate"}, &out); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	var common commonFlags
+	var dryRun bool
+	fs := newFlagSet("unlock", &common)
+	fs.SetOutput(&bytes.Buffer{})
+	fs.BoolVar(&dryRun, "dry-run", false, "")
+
+	positional, err := parseArgs(fs, []string{"media/plex", "--dry-run", "-n", "downloads"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if len(positional) != 1 || positional[0] != "media/plex" {
+		t.Errorf("unexpected positional arguments: %v", positional)
+	}
+	if !dryRun {
+		t.Error("expected --dry-run after the positional argument to be parsed")
+	}
+	if common.namespace != "downloads" {
+		t.Errorf("expected namespace downloads, got %q", common.namespace)
+	}
+
+	if _, err := parseArgs(fs, []string{"--unknown"}); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+}
+
+func TestParseObjectRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		namespace string
+		name      string
+		wantErr   bool
+	}{
+		{ref: "media/plex", namespace: "media", name: "plex"},
+		{ref: "plex", namespace: "default", name: "plex"},
+		{ref: "media/", wantErr: true},
+		{ref: "a/b/c", wantErr: true},
+		{ref: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		namespace, name, err := parseObjectRef(tt.ref, "default")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseObjectRef(%q): expected an error", tt.ref)
+			}
+			continue
+		}
+		if err != nil || namespace != tt.namespace || name != tt.name {
+			t.Errorf("parseObjectRef(%q) = %q, %q, %v", tt.ref, namespace, name, err)
+		}
+	}
+}
+
+func TestPickAppJob(t *testing.T) {
+	fallback := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex"}}
+	running := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-new"}}
+	failed := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-old"},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+		}},
+	}
+
+	if got := pickAppJob([]batchv1.Job{running, failed}, fallback); got.Name != failed.Name {
+		t.Errorf("expected the failed job, got %s", got.Name)
+	}
+	if got := pickAppJob([]batchv1.Job{running}, fallback); got.Name != running.Name {
+		t.Errorf("expected the newest job, got %s", got.Name)
+	}
+	if got := pickAppJob(nil, fallback); got.Name != fallback.Name {
+		t.Errorf("expected the fallback job, got %s", got.Name)
+	}
+}
+
+func TestFilterRecords(t *testing.T) {
+	now := time.Now()
+	record := func(name, app string, created time.Time) volsyncv1alpha1.UnlockRecord {
+		return volsyncv1alpha1.UnlockRecord{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+			Spec:       volsyncv1alpha1.UnlockRecordSpec{AppName: app},
+		}
+	}
+	records := []volsyncv1alpha1.UnlockRecord{
+		record("old", "plex", now.Add(-2*time.Hour)),
+		record("other", "sonarr", now.Add(-time.Hour)),
+		record("new", "plex", now),
+	}
+
+	filtered := filterRecords(records, "plex", 0)
+	if len(filtered) != 2 || filtered[0].Name != "new" || filtered[1].Name != "old" {
+		t.Errorf("unexpected records for plex: %v", filtered)
+	}
+
+	if limited := filterRecords(records, "", 1); len(limited) != 1 || limited[0].Name != "new" {
+		t.Errorf("expected only the newest record, got %v", limited)
+	}
+}
+
+func TestPrintExplanation(t *testing.T) {
+	monitor := &volsyncv1alpha1.VolSyncMonitor{ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"}}
+	explanation := &controller.Explanation{
+		Job:        batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"}},
+		AppName:    "plex",
+		MoverType:  volsyncv1alpha1.MoverTypeRestic,
+		Failed:     true,
+		Detected:   true,
+		DetectedBy: volsyncv1alpha1.LockErrorSourcePattern,
+		Message:    "unable to create lock in backend: repository is already locked",
+		Patterns: []controller.PatternMatch{
+			{Pattern: "repository is already locked", Lines: []string{"unable to create lock in backend: repository is already locked"}},
+			{Pattern: "unable to create lock"},
+		},
+	}
+
+	var out bytes.Buffer
+	printExplanation(&out, monitor, explanation)
+
+	for _, want := range []string{
+		"media/volsync-src-plex",
+		"Detected By:  pattern",
+		"[x] repository is already locked",
+		"[ ] unable to create lock",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("explanation output is missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,647 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/controller"
+)
+
+const (
+	// defaultUnlockReason is recorded on unlock jobs created by the plugin
+	defaultUnlockReason = "manual unlock requested with kubectl-homelab"
+
+	// maxStatusProcessedJobs is how many processed jobs "status" shows for a monitor
+	maxStatusProcessedJobs = 10
+)
+
+// commonFlags are accepted by every volsync command
+type commonFlags struct {
+	namespace     string
+	allNamespaces bool
+	kubeconfig    string
+}
+
+func newFlagSet(name string, common *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&common.namespace, "namespace", "", "Namespace (defaults to the kubeconfig context namespace)")
+	fs.StringVar(&common.namespace, "n", "", "Shorthand for --namespace")
+	fs.StringVar(&common.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	return fs
+}
+
+// parseArgs parses flags that may appear before or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// setup applies the common flags and connects to the cluster
+func (c *commonFlags) setup() (*controller.VolSyncMonitorReconciler, error) {
+	if c.kubeconfig != "" {
+		// Pod logs are read through the default config, which honours KUBECONFIG
+		if err := os.Setenv("KUBECONFIG", c.kubeconfig); err != nil {
+			return nil, err
+		}
+	}
+	if c.namespace == "" {
+		c.namespace = defaultNamespace()
+	}
+	return newReconciler()
+}
+
+// listNamespace returns the namespace to list in, empty for all namespaces
+func (c *commonFlags) listNamespace() string {
+	if c.allNamespaces {
+		return ""
+	}
+	return c.namespace
+}
+
+// parseObjectRef splits a "<namespace>/<name>" argument
+func parseObjectRef(ref, defaultNamespace string) (string, string, error) {
+	parts := strings.Split(ref, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return defaultNamespace, parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("invalid reference %q, expected <namespace>/<name>", ref)
+	}
+}
+
+func runVolSync(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return nil
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "status":
+		return runStatus(ctx, args, out)
+	case "history":
+		return runHistory(ctx, args, out)
+	case "unlock":
+		return runUnlock(ctx, args, out)
+	case "explain":
+		return runExplain(ctx, args, out)
+	case "pause":
+		return runSetEnabled(ctx, "pause", false, args, out)
+	case "resume":
+		return runSetEnabled(ctx, "resume", true, args, out)
+	default:
+		return fmt.Errorf("unknown volsync command %q\n\n%s", command, usage)
+	}
+}
+
+func runStatus(ctx context.Context, args []string, out io.Writer) error {
+	var common commonFlags
+	fs := newFlagSet("status", &common)
+	fs.BoolVar(&common.allNamespaces, "A", false, "List monitors in all namespaces")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("status takes at most one monitor name")
+	}
+
+	r, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 1 {
+		namespace, name, err := parseObjectRef(positional[0], common.namespace)
+		if err != nil {
+			return err
+		}
+		var monitor volsyncv1alpha1.VolSyncMonitor
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &monitor); err != nil {
+			return fmt.Errorf("failed to get monitor %s/%s: %w", namespace, name, err)
+		}
+		unlockJobs, err := r.ListUnlockJobs(ctx, &monitor)
+		if err != nil {
+			return err
+		}
+		printMonitorDetails(out, &monitor, unlockJobs, time.Now())
+		return nil
+	}
+
+	var monitors volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitors, client.InNamespace(common.listNamespace())); err != nil {
+		return fmt.Errorf("failed to list monitors: %w", err)
+	}
+	if len(monitors.Items) == 0 {
+		fmt.Fprintln(out, "No VolSyncMonitors found.")
+		return nil
+	}
+	printMonitors(out, monitors.Items, time.Now())
+	return nil
+}
+
+func runHistory(ctx context.Context, args []string, out io.Writer) error {
+	var common commonFlags
+	var monitorName, app string
+	var limit int
+	fs := newFlagSet("history", &common)
+	fs.BoolVar(&common.allNamespaces, "A", false, "List records in all namespaces")
+	fs.StringVar(&monitorName, "monitor", "", "Only show records of this monitor")
+	fs.StringVar(&app, "app", "", "Only show records of this app")
+	fs.IntVar(&limit, "limit", 20, "Maximum number of records to show (0 for all)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	r, err := common.setup()
+	if err != nil {
+		return err
+	}
+
+	opts := []client.ListOption{client.InNamespace(common.listNamespace())}
+	if monitorName != "" {
+		opts = append(opts, client.MatchingLabels{controller.MonitorLabel: monitorName})
+	}
+	var records volsyncv1alpha1.UnlockRecordList
+	if err := r.List(ctx, &records, opts...); err != nil {
+		return fmt.Errorf("failed to list unlock records: %w", err)
+	}
+
+	items := filterRecords(records.Items, app, limit)
+	if len(items) == 0 {
+		fmt.Fprintln(out, "No unlock records found.")
+		return nil
+	}
+	printRecords(out, items, time.Now())
+	return nil
+}
+
+func runUnlock(ctx context.Context, args []string, out io.Writer) error {
+	var common commonFlags
+	var monitorRef, jobName, reason string
+	var dryRun bool
+	fs := newFlagSet("unlock", &common)
+	fs.StringVar(&monitorRef, "monitor", "", "Monitor to unlock with, as <namespace>/<name> or <name>")
+	fs.StringVar(&jobName, "job", "", "VolSync job to unlock for (defaults to the newest job of the app)")
+	fs.StringVar(&reason, "reason", defaultUnlockReason, "Reason recorded on the unlock job")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the unlock job instead of creating it")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("unlock takes exactly one <namespace>/<app> argument")
+	}
+
+	r, err := common.setup()
+	if err != nil {
+		return err
+	}
+	namespace, app, err := parseObjectRef(positional[0], common.namespace)
+	if err != nil {
+		return err
+	}
+
+	monitor, job, err := resolveAppJob(ctx, r, monitorRef, namespace, app, jobName)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		unlockJob, err := r.BuildUnlockJob(ctx, monitor, job, reason)
+		if err != nil {
+			return err
+		}
+		unlockJob.APIVersion = batchv1.SchemeGroupVersion.String()
+		unlockJob.Kind = "Job"
+		data, err := yaml.Marshal(unlockJob)
+		if err != nil {
+			return fmt.Errorf("failed to render unlock job: %w", err)
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	unlockJob, record, err := r.ManualUnlock(ctx, monitor, job, reason)
+	if unlockJob != nil {
+		fmt.Fprintf(out, "Created unlock job %s/%s for %s (monitor %s/%s)\n",
+			unlockJob.Namespace, unlockJob.Name, job.Name, monitor.Namespace, monitor.Name)
+	}
+	if record != nil {
+		fmt.Fprintf(out, "Recorded as unlockrecord %s/%s\n", record.Namespace, record.Name)
+	}
+	return err
+}
+
+func runExplain(ctx context.Context, args []string, out io.Writer) error {
+	var common commonFlags
+	var monitorRef string
+	fs := newFlagSet("explain", &common)
+	fs.StringVar(&monitorRef, "monitor", "", "Monitor whose settings are used, as <namespace>/<name> or <name>")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("explain takes exactly one <namespace>/<job> argument")
+	}
+
+	r, err := common.setup()
+	if err != nil {
+		return err
+	}
+	namespace, name, err := parseObjectRef(positional[0], common.namespace)
+	if err != nil {
+		return err
+	}
+
+	var job batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &job); err != nil {
+		return fmt.Errorf("failed to get job %s/%s: %w", namespace, name, err)
+	}
+	monitor, err := resolveMonitor(ctx, r, monitorRef, func(m *volsyncv1alpha1.VolSyncMonitor) bool {
+		return r.MonitorSelectsJob(m, job)
+	})
+	if err != nil {
+		return err
+	}
+
+	explanation, err := r.ExplainJob(ctx, monitor, job)
+	if err != nil {
+		return err
+	}
+	printExplanation(out, monitor, explanation)
+	return nil
+}
+
+func runSetEnabled(ctx context.Context, command string, enabled bool, args []string, out io.Writer) error {
+	var common commonFlags
+	fs := newFlagSet(command, &common)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%s takes exactly one monitor name", command)
+	}
+
+	r, err := common.setup()
+	if err != nil {
+		return err
+	}
+	namespace, name, err := parseObjectRef(positional[0], common.namespace)
+	if err != nil {
+		return err
+	}
+
+	var monitor volsyncv1alpha1.VolSyncMonitor
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &monitor); err != nil {
+		return fmt.Errorf("failed to get monitor %s/%s: %w", namespace, name, err)
+	}
+	if monitor.Spec.Enabled == enabled {
+		fmt.Fprintf(out, "Monitor %s/%s is already %s\n", namespace, name, enabledWord(enabled))
+		return nil
+	}
+	if err := r.SetMonitorEnabled(ctx, &monitor, enabled); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Monitor %s/%s %s\n", namespace, name, enabledWord(enabled))
+	return nil
+}
+
+func enabledWord(enabled bool) string {
+	if enabled {
+		return "resumed"
+	}
+	return "paused"
+}
+
+// resolveMonitor finds the monitor named by ref, or the only monitor accepted by selects
+func resolveMonitor(ctx context.Context, r *controller.VolSyncMonitorReconciler, ref string, selects func(*volsyncv1alpha1.VolSyncMonitor) bool) (*volsyncv1alpha1.VolSyncMonitor, error) {
+	if strings.Contains(ref, "/") {
+		namespace, name, err := parseObjectRef(ref, "")
+		if err != nil {
+			return nil, err
+		}
+		var monitor volsyncv1alpha1.VolSyncMonitor
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &monitor); err != nil {
+			return nil, fmt.Errorf("failed to get monitor %s: %w", ref, err)
+		}
+		return &monitor, nil
+	}
+
+	var monitors volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitors); err != nil {
+		return nil, fmt.Errorf("failed to list monitors: %w", err)
+	}
+
+	var candidates []*volsyncv1alpha1.VolSyncMonitor
+	for i := range monitors.Items {
+		monitor := &monitors.Items[i]
+		if ref != "" && monitor.Name != ref {
+			continue
+		}
+		if ref == "" && !selects(monitor) {
+			continue
+		}
+		candidates = append(candidates, monitor)
+	}
+
+	switch len(candidates) {
+	case 0:
+		if ref != "" {
+			return nil, fmt.Errorf("monitor %q not found", ref)
+		}
+		return nil, fmt.Errorf("no VolSyncMonitor selects this job, use --monitor")
+	case 1:
+		return candidates[0], nil
+	default:
+		var names []string
+		for _, monitor := range candidates {
+			names = append(names, monitor.Namespace+"/"+monitor.Name)
+		}
+		return nil, fmt.Errorf("several monitors match (%s), use --monitor <namespace>/<name>", strings.Join(names, ", "))
+	}
+}
+
+// resolveAppJob finds the monitor and VolSync job to unlock an app with. When
+// the app has no job left, for example because failed jobs are removed, the
+// unlock is built for the job name VolSync uses for a ReplicationSource.
+func resolveAppJob(ctx context.Context, r *controller.VolSyncMonitorReconciler, monitorRef, namespace, app, jobName string) (*volsyncv1alpha1.VolSyncMonitor, batchv1.Job, error) {
+	var job batchv1.Job
+	if jobName != "" {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: jobName}, &job); err != nil {
+			return nil, job, fmt.Errorf("failed to get job %s/%s: %w", namespace, jobName, err)
+		}
+	} else {
+		job = batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-" + app, Namespace: namespace}}
+	}
+
+	appJobs := map[types.UID][]batchv1.Job{}
+	monitor, err := resolveMonitor(ctx, r, monitorRef, func(m *volsyncv1alpha1.VolSyncMonitor) bool {
+		if jobName != "" {
+			return r.MonitorSelectsJob(m, job)
+		}
+		jobs, err := r.FindAppJobs(ctx, m, namespace, app)
+		if err != nil {
+			return false
+		}
+		appJobs[m.UID] = jobs
+		return len(jobs) > 0 || r.MonitorSelectsJob(m, job)
+	})
+	if err != nil {
+		return nil, job, err
+	}
+	if jobName != "" {
+		return monitor, job, nil
+	}
+
+	jobs, found := appJobs[monitor.UID]
+	if !found {
+		if jobs, err = r.FindAppJobs(ctx, monitor, namespace, app); err != nil {
+			return nil, job, err
+		}
+	}
+	return monitor, pickAppJob(jobs, job), nil
+}
+
+// pickAppJob prefers the newest failed job, then the newest job, then fallback
+func pickAppJob(jobs []batchv1.Job, fallback batchv1.Job) batchv1.Job {
+	for _, job := range jobs {
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+				return job
+			}
+		}
+	}
+	if len(jobs) > 0 {
+		return jobs[0]
+	}
+	return fallback
+}
+
+// filterRecords keeps the newest records of an app, at most limit when limit is positive
+func filterRecords(records []volsyncv1alpha1.UnlockRecord, app string, limit int) []volsyncv1alpha1.UnlockRecord {
+	var filtered []volsyncv1alpha1.UnlockRecord
+	for _, record := range records {
+		if app == "" || record.Spec.AppName == app {
+			filtered = append(filtered, record)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[j].CreationTimestamp.Before(&filtered[i].CreationTimestamp)
+	})
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+	return filtered
+}
+
+func age(t metav1.Time, now time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(now.Sub(t.Time))
+}
+
+func conditionStatus(monitor *volsyncv1alpha1.VolSyncMonitor, conditionType string) string {
+	if condition := meta.FindStatusCondition(monitor.Status.Conditions, conditionType); condition != nil {
+		return string(condition.Status)
+	}
+	return "Unknown"
+}
+
+func jobState(job batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return "Succeeded"
+		case batchv1.JobFailed:
+			return "Failed"
+		}
+	}
+	if job.Status.Active > 0 {
+		return "Running"
+	}
+	return "Pending"
+}
+
+func printMonitors(out io.Writer, monitors []volsyncv1alpha1.VolSyncMonitor, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tENABLED\tPHASE\tREADY\tACTIVE\tLOCK ERRORS\tUNLOCKS\tLAST UNLOCK")
+	for i := range monitors {
+		monitor := &monitors[i]
+		lastUnlock := "<none>"
+		if monitor.Status.LastUnlockTime != nil {
+			lastUnlock = age(*monitor.Status.LastUnlockTime, now)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			monitor.Namespace, monitor.Name, monitor.Spec.Enabled, monitor.Status.Phase,
+			conditionStatus(monitor, volsyncv1alpha1.ConditionTypeReady), len(monitor.Status.ActiveUnlocks),
+			monitor.Status.TotalLockErrorsDetected, monitor.Status.TotalUnlocksCreated, lastUnlock)
+	}
+	w.Flush()
+}
+
+func printMonitorDetails(out io.Writer, monitor *volsyncv1alpha1.VolSyncMonitor, unlockJobs []batchv1.Job, now time.Time) {
+	status := monitor.Status
+	fmt.Fprintf(out, "Name:         %s\n", monitor.Name)
+	fmt.Fprintf(out, "Namespace:    %s\n", monitor.Namespace)
+	fmt.Fprintf(out, "Enabled:      %t\n", monitor.Spec.Enabled)
+	fmt.Fprintf(out, "Phase:        %s\n", status.Phase)
+	if status.LastError != "" {
+		fmt.Fprintf(out, "Last Error:   %s\n", status.LastError)
+	}
+	fmt.Fprintf(out, "Lock Errors:  %d\n", status.TotalLockErrorsDetected)
+	fmt.Fprintf(out, "Unlocks:      %d created, %d succeeded, %d failed\n",
+		status.TotalUnlocksCreated, status.TotalUnlocksSucceeded, status.TotalUnlocksFailed)
+	fmt.Fprintf(out, "Jobs Removed: %d\n", status.TotalFailedJobsRemoved)
+
+	fmt.Fprintln(out, "\nConditions:")
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+	for _, condition := range status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\nUnlock Jobs:")
+	if len(unlockJobs) == 0 {
+		fmt.Fprintln(out, "  <none>")
+	} else {
+		w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "  NAMESPACE\tNAME\tFAILED JOB\tMOVER\tSTATUS\tAGE")
+		for _, job := range unlockJobs {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", job.Namespace, job.Name,
+				job.Labels["homelab.rafaribe.com/failed-job"], job.Labels["homelab.rafaribe.com/mover"],
+				jobState(job), age(job.CreationTimestamp, now))
+		}
+		w.Flush()
+	}
+
+	fmt.Fprintln(out, "\nRecently Processed Jobs:")
+	processed := status.ProcessedJobs
+	if len(processed) > maxStatusProcessedJobs {
+		processed = processed[len(processed)-maxStatusProcessedJobs:]
+	}
+	if len(processed) == 0 {
+		fmt.Fprintln(out, "  <none>")
+		return
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NAMESPACE\tJOB\tMOVER\tDETECTED BY\tREMEDIATION\tUNLOCK JOB\tAGE")
+	for i := len(processed) - 1; i >= 0; i-- {
+		job := processed[i]
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", job.Namespace, job.JobName, job.MoverType,
+			job.DetectedBy, job.Remediation, job.UnlockJobName, age(job.ProcessedTime, now))
+	}
+	w.Flush()
+}
+
+func printRecords(out io.Writer, records []volsyncv1alpha1.UnlockRecord, now time.Time) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tAPP\tMOVER\tCLASS\tDETECTED BY\tREMEDIATION\tOUTCOME\tUNLOCK JOB\tAGE")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Namespace, record.Name,
+			record.Spec.AppName, record.Spec.MoverType, record.Spec.Classification, record.Spec.DetectedBy,
+			record.Spec.Remediation, record.Status.Outcome, record.Spec.UnlockJobName,
+			age(record.CreationTimestamp, now))
+	}
+	w.Flush()
+}
+
+func printExplanation(out io.Writer, monitor *volsyncv1alpha1.VolSyncMonitor, explanation *controller.Explanation) {
+	job := explanation.Job
+	fmt.Fprintf(out, "Job:          %s/%s\n", job.Namespace, job.Name)
+	fmt.Fprintf(out, "Monitor:      %s/%s\n", monitor.Namespace, monitor.Name)
+	fmt.Fprintf(out, "App:          %s\n", explanation.AppName)
+	fmt.Fprintf(out, "Mover:        %s\n", explanation.MoverType)
+	fmt.Fprintf(out, "Failed:       %t\n", explanation.Failed)
+	fmt.Fprintf(out, "Processed:    %t\n", explanation.Processed)
+	if explanation.ModeSource != "" {
+		fmt.Fprintf(out, "Mode:         %s (set by %s)\n", explanation.Mode, explanation.ModeSource)
+	} else {
+		fmt.Fprintf(out, "Mode:         %s\n", explanation.Mode)
+	}
+
+	if explanation.Detected {
+		fmt.Fprintf(out, "Lock Error:   %s\n", explanation.Message)
+		fmt.Fprintf(out, "Detected By:  %s\n", explanation.DetectedBy)
+		if explanation.ExitCode != nil {
+			fmt.Fprintf(out, "Exit Code:    %d\n", *explanation.ExitCode)
+		}
+		if explanation.MessageType != "" {
+			fmt.Fprintf(out, "Message Type: %s\n", explanation.MessageType)
+		}
+		fmt.Fprintf(out, "Class:        %s\n", explanation.Class)
+		if explanation.Pattern != "" {
+			fmt.Fprintf(out, "Pattern:      %s (%s)\n", explanation.Pattern, explanation.Severity)
+		}
+		fmt.Fprintf(out, "Remediation:  %s\n", explanation.Remediation)
+	} else {
+		fmt.Fprintln(out, "Lock Error:   <none detected>")
+	}
+
+	fmt.Fprintln(out, "\nPatterns:")
+	for _, pattern := range explanation.Patterns {
+		name := pattern.Pattern
+		if pattern.Name != "" {
+			name = fmt.Sprintf("%s: %s", pattern.Name, pattern.Pattern)
+		}
+		if len(pattern.Lines) == 0 {
+			fmt.Fprintf(out, "  [ ] %s\n", name)
+			continue
+		}
+		fmt.Fprintf(out, "  [x] %s\n", name)
+		for _, line := range pattern.Lines {
+			fmt.Fprintf(out, "        %s\n", line)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -36,7 +37,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
 	"github.com/rafaribe/homelab-assistant/internal/controller"
+	"github.com/rafaribe/homelab-assistant/internal/dashboard"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -63,6 +66,9 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var showVersion bool
+	var configFile string
+	var dashboardAddr string
+	var dashboardTokenFile string
 	
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -74,6 +80,13 @@ func main() {
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.BoolVar(&showVersion, "version", false, "Show version information and exit")
+	flag.StringVar(&configFile, "config", "",
+		"The controller configuration file. Changes to the file are applied without a restart.")
+	flag.StringVar(&dashboardAddr, "dashboard-bind-address", "0",
+		"The address the read-only dashboard binds to. Set this to '0' to disable the dashboard.")
+	flag.StringVar(&dashboardTokenFile, "dashboard-token-file", "",
+		"A file holding the token that grants access to the dashboard. "+
+			"Without it, access is granted to users allowed to list VolSyncMonitors.")
 	
 	opts := zap.Options{
 		Development: true,
@@ -89,6 +102,16 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	controllerConfig := config.Default()
+	if configFile != "" {
+		var err error
+		if controllerConfig, err = config.Load(configFile); err != nil {
+			setupLog.Error(err, "unable to load controller config", "path", configFile)
+			os.Exit(1)
+		}
+	}
+	configStore := config.NewStore(controllerConfig)
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -120,17 +143,13 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "be522e06.homelab.io",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+		// LeaderElectionReleaseOnCancel lets the leader step down as soon as the
+		// Manager stops, so the next leader does not wait for the lease to expire.
+		// This is safe because the program exits right after the Manager stops,
+		// and because unlock jobs are named after the failure they handle: a new
+		// leader that reconciles a failure before the old leader's status update
+		// is visible finds the existing unlock job instead of creating another.
+		LeaderElectionReleaseOnCancel: true,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -138,14 +157,63 @@ func main() {
 	}
 
 	if err = (&controller.VolSyncMonitorReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("volsyncmonitor-controller"),
+		Config:   configStore,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VolSyncMonitor")
+		os.Exit(1)
+	}
+	if err = (&controller.VolSyncUnlockRequestReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Config: configStore,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "VolSyncMonitor")
+		setupLog.Error(err, "unable to create controller", "controller", "VolSyncUnlockRequest")
+		os.Exit(1)
+	}
+	if err = (&controller.FailurePatternSetReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FailurePatternSet")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
 
+	if configFile != "" {
+		if err := mgr.Add(&config.Watcher{
+			Path:  configFile,
+			Store: configStore,
+			Log:   ctrl.Log.WithName("config"),
+		}); err != nil {
+			setupLog.Error(err, "unable to watch controller config")
+			os.Exit(1)
+		}
+	}
+
+	if dashboardAddr != "0" {
+		var authorizer dashboard.Authorizer = &dashboard.SubjectAccessReviewAuthorizer{Client: mgr.GetClient()}
+		if dashboardTokenFile != "" {
+			token, err := os.ReadFile(dashboardTokenFile)
+			if err != nil {
+				setupLog.Error(err, "unable to read dashboard token", "path", dashboardTokenFile)
+				os.Exit(1)
+			}
+			authorizer = &dashboard.TokenAuthorizer{Token: strings.TrimSpace(string(token))}
+		}
+		if err := mgr.Add(&dashboard.Server{
+			Addr:       dashboardAddr,
+			Reader:     mgr.GetCache(),
+			Authorizer: authorizer,
+			Log:        ctrl.Log.WithName("dashboard"),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up dashboard")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
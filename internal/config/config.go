@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the apiVersion of the controller configuration file
+	APIVersion = "config.homelab.rafaribe.com/v1alpha1"
+	// Kind is the kind of the controller configuration file
+	Kind = "ControllerConfig"
+)
+
+// ControllerConfig holds the tunables of the controller manager. It is read
+// from the file passed with --config; unset fields keep their defaults.
+type ControllerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// VolSyncMonitor configures the VolSyncMonitor controller
+	VolSyncMonitor VolSyncMonitorConfig `json:"volsyncMonitor,omitempty"`
+}
+
+// VolSyncMonitorConfig holds the tunables of the VolSyncMonitor controller
+type VolSyncMonitorConfig struct {
+	// RequeueInterval is how often an enabled monitor is reconciled
+	RequeueInterval metav1.Duration `json:"requeueInterval,omitempty"`
+
+	// DisabledRequeueInterval is how often a disabled monitor is reconciled
+	DisabledRequeueInterval metav1.Duration `json:"disabledRequeueInterval,omitempty"`
+
+	// ProcessedJobsHistoryLimit is the number of processed jobs kept in the monitor status
+	ProcessedJobsHistoryLimit int `json:"processedJobsHistoryLimit,omitempty"`
+
+	// UnlockJobBackoffLimit is the backoffLimit of unlock jobs
+	UnlockJobBackoffLimit *int32 `json:"unlockJobBackoffLimit,omitempty"`
+
+	// JobNamePrefix is the prefix of VolSync mover job names, e.g. volsync-src-<name>.
+	// Jobs without VolSync labels or owner are only selected when they are
+	// named like a mover job with this prefix.
+	JobNamePrefix string `json:"jobNamePrefix,omitempty"`
+
+	// LockErrorPatterns replaces the built-in error patterns of a mover type,
+	// keyed by mover type (restic, kopia, rclone, rsync)
+	LockErrorPatterns map[string][]string `json:"lockErrorPatterns,omitempty"`
+
+	// ClaimLeaseDuration is how long a monitor's claim on a failed job keeps
+	// other monitors from handling it
+	ClaimLeaseDuration metav1.Duration `json:"claimLeaseDuration,omitempty"`
+
+	// MaxConcurrentReconciles is the number of monitors reconciled in parallel.
+	// It is read at startup only.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+}
+
+// Default returns the configuration used when no file is given
+func Default() *ControllerConfig {
+	backoffLimit := int32(3)
+	return &ControllerConfig{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		VolSyncMonitor: VolSyncMonitorConfig{
+			RequeueInterval:           metav1.Duration{Duration: 30 * time.Second},
+			DisabledRequeueInterval:   metav1.Duration{Duration: 5 * time.Minute},
+			ProcessedJobsHistoryLimit: 50,
+			UnlockJobBackoffLimit:     &backoffLimit,
+			JobNamePrefix:             "volsync-",
+			ClaimLeaseDuration:        metav1.Duration{Duration: 10 * time.Minute},
+			MaxConcurrentReconciles:   1,
+		},
+	}
+}
+
+// Load reads a configuration file on top of the defaults
+func Load(path string) (*ControllerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a configuration document on top of the defaults and validates it
+func Parse(data []byte) (*ControllerConfig, error) {
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the configuration can be used by the controllers
+func (c *ControllerConfig) Validate() error {
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("unsupported config %s/%s, expected %s/%s", c.APIVersion, c.Kind, APIVersion, Kind)
+	}
+
+	monitor := c.VolSyncMonitor
+	if monitor.RequeueInterval.Duration <= 0 {
+		return fmt.Errorf("volsyncMonitor.requeueInterval must be positive")
+	}
+	if monitor.DisabledRequeueInterval.Duration <= 0 {
+		return fmt.Errorf("volsyncMonitor.disabledRequeueInterval must be positive")
+	}
+	if monitor.ProcessedJobsHistoryLimit < 1 {
+		return fmt.Errorf("volsyncMonitor.processedJobsHistoryLimit must be at least 1")
+	}
+	if monitor.UnlockJobBackoffLimit == nil || *monitor.UnlockJobBackoffLimit < 0 {
+		return fmt.Errorf("volsyncMonitor.unlockJobBackoffLimit must not be negative")
+	}
+	if monitor.JobNamePrefix == "" {
+		return fmt.Errorf("volsyncMonitor.jobNamePrefix must not be empty")
+	}
+	if monitor.ClaimLeaseDuration.Duration <= 0 {
+		return fmt.Errorf("volsyncMonitor.claimLeaseDuration must be positive")
+	}
+	if monitor.MaxConcurrentReconciles < 1 {
+		return fmt.Errorf("volsyncMonitor.maxConcurrentReconciles must be at least 1")
+	}
+	for mover, patterns := range monitor.LockErrorPatterns {
+		for _, pattern := range patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid %s lock error pattern %q: %w", mover, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Store holds the current configuration and is safe for concurrent use.
+// A nil Store returns the defaults.
+type Store struct {
+	mu      sync.RWMutex
+	current *ControllerConfig
+}
+
+// NewStore returns a store holding cfg
+func NewStore(cfg *ControllerConfig) *Store {
+	return &Store{current: cfg}
+}
+
+// Get returns the current configuration. Callers must not modify it.
+func (s *Store) Get() *ControllerConfig {
+	if s == nil {
+		return Default()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Set replaces the current configuration
+func (s *Store) Set(cfg *ControllerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = cfg
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+apiVersion: config.homelab.rafaribe.com/v1alpha1
+kind: ControllerConfig
+volsyncMonitor:
+  requeueInterval: 1m
+  jobNamePrefix: backup-
+  lockErrorPatterns:
+    restic: ["repository is already locked"]
+  maxConcurrentReconciles: 4
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	monitor := cfg.VolSyncMonitor
+	if monitor.RequeueInterval.Duration != time.Minute {
+		t.Errorf("RequeueInterval = %s, want 1m", monitor.RequeueInterval.Duration)
+	}
+	if monitor.JobNamePrefix != "backup-" {
+		t.Errorf("JobNamePrefix = %q, want %q", monitor.JobNamePrefix, "backup-")
+	}
+	if monitor.MaxConcurrentReconciles != 4 {
+		t.Errorf("MaxConcurrentReconciles = %d, want 4", monitor.MaxConcurrentReconciles)
+	}
+	if len(monitor.LockErrorPatterns["restic"]) != 1 {
+		t.Errorf("LockErrorPatterns = %v", monitor.LockErrorPatterns)
+	}
+
+	// Unset fields keep their defaults
+	if monitor.DisabledRequeueInterval.Duration != 5*time.Minute {
+		t.Errorf("DisabledRequeueInterval = %s, want 5m", monitor.DisabledRequeueInterval.Duration)
+	}
+	if monitor.ProcessedJobsHistoryLimit != 50 {
+		t.Errorf("ProcessedJobsHistoryLimit = %d, want 50", monitor.ProcessedJobsHistoryLimit)
+	}
+	if *monitor.UnlockJobBackoffLimit != 3 {
+		t.Errorf("UnlockJobBackoffLimit = %d, want 3", *monitor.UnlockJobBackoffLimit)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "apiVersion: config.homelab.rafaribe.com/v1alpha1\nkind: ControllerConfig\n"
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "wrong kind",
+			data:    "apiVersion: config.homelab.rafaribe.com/v1alpha1\nkind: Other\n",
+			wantErr: "unsupported config",
+		},
+		{
+			name:    "unknown field",
+			data:    header + "volsyncMonitor:\n  requeue: 1m\n",
+			wantErr: "failed to parse config",
+		},
+		{
+			name:    "negative history",
+			data:    header + "volsyncMonitor:\n  processedJobsHistoryLimit: -1\n",
+			wantErr: "processedJobsHistoryLimit",
+		},
+		{
+			name:    "negative backoff limit",
+			data:    header + "volsyncMonitor:\n  unlockJobBackoffLimit: -1\n",
+			wantErr: "unlockJobBackoffLimit",
+		},
+		{
+			name:    "invalid pattern",
+			data:    header + "volsyncMonitor:\n  lockErrorPatterns:\n    kopia: [\"lock(\"]\n",
+			wantErr: "invalid kopia lock error pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() error = %v", err)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var store *Store
+	if got := store.Get().VolSyncMonitor.JobNamePrefix; got != "volsync-" {
+		t.Errorf("nil Store JobNamePrefix = %q, want %q", got, "volsync-")
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	header := "apiVersion: config.homelab.rafaribe.com/v1alpha1\nkind: ControllerConfig\n"
+
+	store := NewStore(Default())
+	watcher := &Watcher{Path: path, Store: store, Log: logr.Discard()}
+
+	write(header + "volsyncMonitor:\n  requeueInterval: 2m\n")
+	watcher.Reload()
+	if got := store.Get().VolSyncMonitor.RequeueInterval.Duration; got != 2*time.Minute {
+		t.Errorf("RequeueInterval after reload = %s, want 2m", got)
+	}
+
+	// Invalid files keep the previous configuration
+	write(header + "volsyncMonitor:\n  requeueInterval: -1m\n")
+	watcher.Reload()
+	if got := store.Get().VolSyncMonitor.RequeueInterval.Duration; got != 2*time.Minute {
+		t.Errorf("RequeueInterval after invalid reload = %s, want 2m", got)
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Watcher reloads a configuration file into a Store when it changes. It
+// watches the directory of the file so that ConfigMap volume updates, which
+// swap a symlink instead of writing the file, are picked up.
+type Watcher struct {
+	Path  string
+	Store *Store
+	Log   logr.Logger
+}
+
+// NeedLeaderElection lets every replica apply the configuration
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the configuration file until ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(w.Path)); err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+	w.Log.Info("Watching config file", "path", w.Path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.Reload()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.Log.Error(err, "Config watcher error")
+		}
+	}
+}
+
+// Reload reads the configuration file and stores it when it changed. Invalid
+// files are reported and the previous configuration is kept.
+func (w *Watcher) Reload() {
+	cfg, err := Load(w.Path)
+	if err != nil {
+		w.Log.Error(err, "Failed to reload config file, keeping the previous configuration", "path", w.Path)
+		return
+	}
+
+	previous := w.Store.Get()
+	if reflect.DeepEqual(previous, cfg) {
+		return
+	}
+	if previous.VolSyncMonitor.MaxConcurrentReconciles != cfg.VolSyncMonitor.MaxConcurrentReconciles {
+		w.Log.Info("maxConcurrentReconciles changed, restart the controller to apply it",
+			"current", previous.VolSyncMonitor.MaxConcurrentReconciles, "configured", cfg.VolSyncMonitor.MaxConcurrentReconciles)
+	}
+	w.Store.Set(cfg)
+	w.Log.Info("Reloaded config file", "path", w.Path)
+}
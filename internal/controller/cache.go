@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Event reasons for cache recreation
+const (
+	reasonCacheRecreated       = "CacheRecreated"
+	reasonCacheRecreateSkipped = "CacheRecreateSkipped"
+)
+
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;delete
+
+// classRemediation returns the remediation of a failure of a class. Cache
+// failures are not fixed by an unlock or a retry, so they are only recorded
+// unless the monitor recreates caches.
+func classRemediation(monitor *volsyncv1alpha1.VolSyncMonitor, remediation volsyncv1alpha1.RemediationAction, class volsyncv1alpha1.FailureClass) volsyncv1alpha1.RemediationAction {
+	if class != volsyncv1alpha1.FailureClassCacheCorruption || remediation == volsyncv1alpha1.RemediationActionNone {
+		return remediation
+	}
+	if monitor.Spec.RecreateCache {
+		return volsyncv1alpha1.RemediationActionRecreateCache
+	}
+	return volsyncv1alpha1.RemediationActionNone
+}
+
+// cacheVolumeName returns the name VolSync gives the cache volume of the mover of an object
+func cacheVolumeName(objectName string, direction volsyncv1alpha1.VolSyncDirection) string {
+	if direction == volsyncv1alpha1.VolSyncDirectionDestination {
+		return "volsync-dst-" + objectName + "-cache"
+	}
+	return "volsync-src-" + objectName + "-cache"
+}
+
+// verifyCacheVolume returns why a volume is not the cache volume of a failed
+// job, or an empty string when it is: the job mounts it, VolSync created it
+// and the VolSync object of the job owns it
+func verifyCacheVolume(pvc corev1.PersistentVolumeClaim, job batchv1.Job, objectName string, direction volsyncv1alpha1.VolSyncDirection) string {
+	mounted := false
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+			mounted = true
+		}
+	}
+	if !mounted {
+		return fmt.Sprintf("job %s does not mount %s", job.Name, pvc.Name)
+	}
+	if pvc.Labels[volsyncCreatedByLabel] != "volsync" {
+		return fmt.Sprintf("%s was not created by VolSync", pvc.Name)
+	}
+	kind := replicationKind(direction)
+	owner := metav1.GetControllerOf(&pvc)
+	if owner == nil || owner.Kind != kind || owner.Name != objectName ||
+		!strings.HasPrefix(owner.APIVersion, volsyncGroupVersion.Group+"/") {
+		return fmt.Sprintf("%s is not owned by %s %s", pvc.Name, kind, objectName)
+	}
+	return ""
+}
+
+// cacheVolumeUser returns a pod that uses a volume and did not terminate, if any
+func (r *VolSyncMonitorReconciler) cacheVolumeUser(ctx context.Context, pvc corev1.PersistentVolumeClaim) (string, error) {
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(pvc.Namespace)); err != nil {
+		return "", fmt.Errorf("failed to list pods in namespace %s: %w", pvc.Namespace, err)
+	}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return pod.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// recreateCache deletes the cache volume of a job that failed on its cache
+// and removes the job, so that VolSync runs the mover again and creates a new
+// cache volume. Volumes that are not verified to be the cache of the job are
+// left alone and the failure is only recorded. It returns why the volume
+// cannot be deleted yet.
+func (r *VolSyncMonitorReconciler) recreateCache(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, processed *volsyncv1alpha1.ProcessedJob) (string, error) {
+	logger := log.FromContext(ctx)
+
+	skip := func(reason string) (string, error) {
+		logger.Info("Not recreating cache volume", "job", job.Name, "namespace", job.Namespace, "reason", reason)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonCacheRecreateSkipped,
+				"Cache of failed job %s/%s not recreated: %s", job.Namespace, job.Name, reason)
+		}
+		processed.Remediation = volsyncv1alpha1.RemediationActionNone
+		return "", nil
+	}
+	if processed.Provider != "" && processed.Provider != volsyncv1alpha1.BackupProviderVolSync {
+		return skip(fmt.Sprintf("%s jobs have no VolSync cache volume", processed.Provider))
+	}
+	if processed.ObjectName == "" {
+		return skip("the VolSync object of the job is unknown")
+	}
+
+	name := cacheVolumeName(processed.ObjectName, processed.Direction)
+	var pvc corev1.PersistentVolumeClaim
+	err := r.Get(ctx, types.NamespacedName{Namespace: job.Namespace, Name: name}, &pvc)
+	switch {
+	case errors.IsNotFound(err):
+		// Nothing to delete, VolSync creates the volume for the next run
+		logger.Info("Cache volume not found", "pvc", name, "namespace", job.Namespace)
+	case err != nil:
+		return "", fmt.Errorf("failed to get cache volume %s: %w", name, err)
+	default:
+		if reason := verifyCacheVolume(pvc, job, processed.ObjectName, processed.Direction); reason != "" {
+			return skip(reason)
+		}
+		user, err := r.cacheVolumeUser(ctx, pvc)
+		if err != nil {
+			return "", err
+		}
+		if user != "" {
+			return fmt.Sprintf("cache volume %s is in use by pod %s", name, user), nil
+		}
+		if err := r.Delete(ctx, &pvc, client.Preconditions{UID: &pvc.UID}); err != nil && !errors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to delete cache volume %s: %w", name, err)
+		}
+		logger.Info("Deleted cache volume", "pvc", name, "namespace", job.Namespace, "job", job.Name)
+	}
+
+	// Removing the failed job makes VolSync run the mover again
+	if err := r.removeFailedJob(ctx, job); err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to remove failed job %s: %w", job.Name, err)
+	}
+	processed.Removed = true
+	if r.Recorder != nil {
+		r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonCacheRecreated,
+			"Deleted cache volume %s and removed failed job %s/%s, VolSync runs the mover again with a new cache", name, job.Namespace, job.Name)
+	}
+	return "", nil
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Cache recreation", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		failedJob *batchv1.Job
+		cache     *corev1.PersistentVolumeClaim
+		processed volsyncv1alpha1.ProcessedJob
+		recorder  *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		controller := true
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+			Spec:       volsyncv1alpha1.VolSyncMonitorSpec{Enabled: true, RecreateCache: true},
+		}
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Volumes: []corev1.Volume{{
+							Name: "cache",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "volsync-src-plex-cache"},
+							},
+						}},
+					},
+				},
+			},
+		}
+		cache = &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "volsync-src-plex-cache",
+				Namespace: "media",
+				UID:       "cache-uid",
+				Labels:    map[string]string{volsyncCreatedByLabel: "volsync"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "volsync.backube/v1alpha1",
+					Kind:       "ReplicationSource",
+					Name:       "plex",
+					UID:        "source-uid",
+					Controller: &controller,
+				}},
+			},
+		}
+		processed = volsyncv1alpha1.ProcessedJob{
+			JobName:     failedJob.Name,
+			Namespace:   "media",
+			ObjectName:  "plex",
+			Direction:   volsyncv1alpha1.VolSyncDirectionSource,
+			Remediation: volsyncv1alpha1.RemediationActionRecreateCache,
+		}
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		r := newFakeReconciler(append(objects, failedJob)...)
+		r.Recorder = recorder
+		return r
+	}
+
+	exists := func(r *VolSyncMonitorReconciler, obj client.Object) bool {
+		err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	It("should only recreate caches when the monitor allows it", func() {
+		Expect(classRemediation(monitor, volsyncv1alpha1.RemediationActionUnlock, volsyncv1alpha1.FailureClassCacheCorruption)).
+			To(Equal(volsyncv1alpha1.RemediationActionRecreateCache))
+		Expect(classRemediation(monitor, volsyncv1alpha1.RemediationActionNone, volsyncv1alpha1.FailureClassCacheCorruption)).
+			To(Equal(volsyncv1alpha1.RemediationActionNone))
+		Expect(classRemediation(monitor, volsyncv1alpha1.RemediationActionUnlock, volsyncv1alpha1.FailureClassStaleLock)).
+			To(Equal(volsyncv1alpha1.RemediationActionUnlock))
+
+		monitor.Spec.RecreateCache = false
+		Expect(classRemediation(monitor, volsyncv1alpha1.RemediationActionUnlock, volsyncv1alpha1.FailureClassCacheCorruption)).
+			To(Equal(volsyncv1alpha1.RemediationActionNone))
+	})
+
+	It("should name the cache volumes like VolSync", func() {
+		Expect(cacheVolumeName("plex", volsyncv1alpha1.VolSyncDirectionSource)).To(Equal("volsync-src-plex-cache"))
+		Expect(cacheVolumeName("plex", volsyncv1alpha1.VolSyncDirectionDestination)).To(Equal("volsync-dst-plex-cache"))
+	})
+
+	It("should delete the cache volume and the failed job", func() {
+		r := newReconciler(cache)
+		reason, err := r.recreateCache(ctx, monitor, *failedJob, &processed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(BeEmpty())
+
+		Expect(exists(r, cache)).To(BeFalse())
+		Expect(exists(r, failedJob)).To(BeFalse())
+		Expect(processed.Removed).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring(reasonCacheRecreated)))
+	})
+
+	It("should wait until no pod uses the cache volume", func() {
+		mover := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-xyz12", Namespace: "media"},
+			Spec:       failedJob.Spec.Template.Spec,
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		r := newReconciler(cache, mover)
+		reason, err := r.recreateCache(ctx, monitor, *failedJob, &processed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(Equal("cache volume volsync-src-plex-cache is in use by pod volsync-src-plex-xyz12"))
+		Expect(exists(r, cache)).To(BeTrue())
+		Expect(exists(r, failedJob)).To(BeTrue())
+	})
+
+	It("should leave volumes that are not the cache of the job alone", func() {
+		cache.OwnerReferences = nil
+		r := newReconciler(cache)
+		reason, err := r.recreateCache(ctx, monitor, *failedJob, &processed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(BeEmpty())
+
+		Expect(exists(r, cache)).To(BeTrue())
+		Expect(exists(r, failedJob)).To(BeTrue())
+		Expect(processed.Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+		Expect(recorder.Events).To(Receive(ContainSubstring("is not owned by ReplicationSource plex")))
+	})
+
+	It("should refuse volumes the job does not mount", func() {
+		failedJob.Spec.Template.Spec.Volumes = nil
+		Expect(verifyCacheVolume(*cache, *failedJob, "plex", volsyncv1alpha1.VolSyncDirectionSource)).
+			To(Equal("job volsync-src-plex does not mount volsync-src-plex-cache"))
+
+		failedJob.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "cache",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "volsync-src-plex-cache"},
+			},
+		}}
+		cache.Labels = nil
+		Expect(verifyCacheVolume(*cache, *failedJob, "plex", volsyncv1alpha1.VolSyncDirectionSource)).
+			To(Equal("volsync-src-plex-cache was not created by VolSync"))
+	})
+})
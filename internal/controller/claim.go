@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Annotations a monitor sets on a failed job it handles. Other monitors
+// leave the job alone until the claim expires.
+const (
+	// ClaimedByAnnotation is the namespace/name of the monitor handling a job
+	ClaimedByAnnotation = "homelab.rafaribe.com/claimed-by"
+	// ClaimExpiresAnnotation is when the claim lapses, in RFC 3339
+	ClaimExpiresAnnotation = "homelab.rafaribe.com/claim-expires"
+)
+
+// monitorKey identifies a monitor in claims and overlaps
+func monitorKey(monitor *volsyncv1alpha1.VolSyncMonitor) string {
+	return monitor.Namespace + "/" + monitor.Name
+}
+
+// listCompetingMonitors returns the other enabled monitors in the cluster
+func (r *VolSyncMonitorReconciler) listCompetingMonitors(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) ([]volsyncv1alpha1.VolSyncMonitor, error) {
+	var monitorList volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitorList); err != nil {
+		return nil, fmt.Errorf("failed to list VolSyncMonitors: %w", err)
+	}
+
+	var others []volsyncv1alpha1.VolSyncMonitor
+	for _, other := range monitorList.Items {
+		if other.Spec.Enabled && monitorKey(&other) != monitorKey(monitor) {
+			others = append(others, other)
+		}
+	}
+	return others, nil
+}
+
+// higherPriorityMonitor returns a competing monitor that selects the job and
+// has a higher priority, if any
+func (r *VolSyncMonitorReconciler) higherPriorityMonitor(monitor *volsyncv1alpha1.VolSyncMonitor, others []volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) *volsyncv1alpha1.VolSyncMonitor {
+	for i := range others {
+		if others[i].Spec.Priority > monitor.Spec.Priority && r.MonitorSelectsJob(&others[i], job) {
+			return &others[i]
+		}
+	}
+	return nil
+}
+
+// ownsNamespace reports whether the monitor handles the repositories of a
+// namespace. Without a job to claim, equal priorities are decided by the
+// monitor key so that exactly one monitor sweeps and checks a repository.
+func ownsNamespace(monitor *volsyncv1alpha1.VolSyncMonitor, others []volsyncv1alpha1.VolSyncMonitor, namespace string) bool {
+	for i := range others {
+		other := &others[i]
+		if !monitorWatchesNamespace(other, namespace) {
+			continue
+		}
+		if other.Spec.Priority > monitor.Spec.Priority ||
+			(other.Spec.Priority == monitor.Spec.Priority && monitorKey(other) < monitorKey(monitor)) {
+			return false
+		}
+	}
+	return true
+}
+
+// jobClaim returns the monitor holding an unexpired claim on a job, if any
+func jobClaim(job batchv1.Job, now time.Time) (string, time.Time) {
+	holder := job.Annotations[ClaimedByAnnotation]
+	expires, err := time.Parse(time.RFC3339, job.Annotations[ClaimExpiresAnnotation])
+	if holder == "" || err != nil || !now.Before(expires) {
+		return "", time.Time{}
+	}
+	return holder, expires
+}
+
+// claimJob records the monitor as the handler of a failed job, or renews its
+// claim once half of the lease has passed. It returns the current holder when
+// another monitor holds an unexpired claim.
+func (r *VolSyncMonitorReconciler) claimJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job *batchv1.Job) (bool, string, error) {
+	key := monitorKey(monitor)
+	now := time.Now()
+	lease := r.settings().ClaimLeaseDuration.Duration
+
+	if holder, expires := jobClaim(*job, now); holder != "" {
+		if holder != key {
+			return false, holder, nil
+		}
+		if expires.Sub(now) > lease/2 {
+			return true, key, nil
+		}
+	}
+
+	// The optimistic lock makes concurrent claims fail for all but one monitor
+	patch := client.MergeFromWithOptions(job.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[ClaimedByAnnotation] = key
+	job.Annotations[ClaimExpiresAnnotation] = now.Add(lease).UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, job, patch); err != nil {
+		if errors.IsConflict(err) {
+			return false, "", nil
+		}
+		return false, "", fmt.Errorf("failed to claim job %s: %w", job.Name, err)
+	}
+	return true, key, nil
+}
+
+// updateOverlaps reports the other monitors watching the namespaces of this monitor
+func updateOverlaps(monitor *volsyncv1alpha1.VolSyncMonitor, others []volsyncv1alpha1.VolSyncMonitor) {
+	var overlaps []volsyncv1alpha1.MonitorOverlap
+	for i := range others {
+		other := &others[i]
+		namespaces := sharedNamespaces(monitor, other)
+		if len(namespaces) == 0 {
+			continue
+		}
+
+		handler := volsyncv1alpha1.OverlapHandlerFirstClaim
+		if monitor.Spec.Priority > other.Spec.Priority {
+			handler = volsyncv1alpha1.OverlapHandlerSelf
+		} else if monitor.Spec.Priority < other.Spec.Priority {
+			handler = volsyncv1alpha1.OverlapHandlerOther
+		}
+		overlaps = append(overlaps, volsyncv1alpha1.MonitorOverlap{
+			Monitor:    volsyncv1alpha1.MonitorReference{Name: other.Name, Namespace: other.Namespace},
+			Priority:   other.Spec.Priority,
+			Namespaces: namespaces,
+			Handler:    handler,
+		})
+	}
+
+	sort.Slice(overlaps, func(i, j int) bool {
+		if overlaps[i].Monitor.Namespace != overlaps[j].Monitor.Namespace {
+			return overlaps[i].Monitor.Namespace < overlaps[j].Monitor.Namespace
+		}
+		return overlaps[i].Monitor.Name < overlaps[j].Monitor.Name
+	})
+	monitor.Status.Overlaps = overlaps
+}
+
+// sharedNamespaces returns the namespaces watched by both monitors, or "*"
+// when both watch every namespace
+func sharedNamespaces(monitor, other *volsyncv1alpha1.VolSyncMonitor) []string {
+	watched := func(m *volsyncv1alpha1.VolSyncMonitor) []string {
+		if m.Spec.JobSelector == nil {
+			return nil
+		}
+		return m.Spec.JobSelector.Namespaces
+	}
+	mine, theirs := watched(monitor), watched(other)
+
+	if len(mine) == 0 && len(theirs) == 0 {
+		return []string{"*"}
+	}
+
+	candidates := mine
+	if len(candidates) == 0 {
+		candidates = theirs
+	}
+
+	var shared []string
+	for _, namespace := range candidates {
+		if monitorWatchesNamespace(monitor, namespace) && monitorWatchesNamespace(other, namespace) {
+			shared = append(shared, namespace)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
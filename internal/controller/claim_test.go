@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Monitor claims", func() {
+	var (
+		ctx                context.Context
+		first, second      *volsyncv1alpha1.VolSyncMonitor
+		failedJob          *batchv1.Job
+		failedJobPod       *corev1.Pod
+		prioritizedMonitor func(name string, priority int32, namespaces ...string) *volsyncv1alpha1.VolSyncMonitor
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		prioritizedMonitor = func(name string, priority int32, namespaces ...string) *volsyncv1alpha1.VolSyncMonitor {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "system", UID: types.UID(name + "-uid")},
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					Enabled:              true,
+					MaxConcurrentUnlocks: 2,
+					Priority:             priority,
+					UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+				},
+			}
+			if len(namespaces) > 0 {
+				monitor.Spec.JobSelector = &volsyncv1alpha1.JobSelector{Namespaces: namespaces}
+			}
+			return monitor
+		}
+		first = prioritizedMonitor("first", 0)
+		second = prioritizedMonitor("second", 0)
+
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		failedJobPod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		return newFakeReconciler(append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, failedJobPod)...)
+	}
+
+	unlockJobs := func(r *VolSyncMonitorReconciler) []batchv1.Job {
+		var jobList batchv1.JobList
+		Expect(r.List(ctx, &jobList, client.HasLabels{MonitorLabel})).To(Succeed())
+		return jobList.Items
+	}
+
+	It("should let the first monitor to claim a job handle it", func() {
+		r := newReconciler(first, second)
+
+		_, err := r.reconcileMonitor(ctx, first)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.Status.ProcessedJobs).To(HaveLen(1))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(failedJob), &job)).To(Succeed())
+		Expect(job.Annotations).To(HaveKeyWithValue(ClaimedByAnnotation, "system/first"))
+		expires, err := time.Parse(time.RFC3339, job.Annotations[ClaimExpiresAnnotation])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expires).To(BeTemporally("~", time.Now().Add(10*time.Minute), time.Minute))
+
+		_, err = r.reconcileMonitor(ctx, second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Status.ProcessedJobs).To(BeEmpty())
+		Expect(unlockJobs(r)).To(HaveLen(1))
+	})
+
+	It("should take over claims that expired", func() {
+		failedJob.Annotations = map[string]string{
+			ClaimedByAnnotation:    "system/first",
+			ClaimExpiresAnnotation: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		}
+		r := newReconciler(second)
+
+		_, err := r.reconcileMonitor(ctx, second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Status.ProcessedJobs).To(HaveLen(1))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(failedJob), &job)).To(Succeed())
+		Expect(job.Annotations).To(HaveKeyWithValue(ClaimedByAnnotation, "system/second"))
+	})
+
+	It("should leave jobs to monitors with a higher priority", func() {
+		second.Spec.Priority = 10
+		r := newReconciler(first, second)
+
+		_, err := r.reconcileMonitor(ctx, first)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.Status.ProcessedJobs).To(BeEmpty())
+		Expect(unlockJobs(r)).To(BeEmpty())
+
+		_, err = r.reconcileMonitor(ctx, second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Status.ProcessedJobs).To(HaveLen(1))
+	})
+
+	It("should renew its claim on processed jobs", func() {
+		r := newReconciler(first)
+		_, err := r.reconcileMonitor(ctx, first)
+		Expect(err).NotTo(HaveOccurred())
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(failedJob), &job)).To(Succeed())
+		patch := client.MergeFrom(job.DeepCopy())
+		job.Annotations[ClaimExpiresAnnotation] = time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+		Expect(r.Patch(ctx, &job, patch)).To(Succeed())
+
+		_, err = r.reconcileMonitor(ctx, first)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(failedJob), &job)).To(Succeed())
+		expires, err := time.Parse(time.RFC3339, job.Annotations[ClaimExpiresAnnotation])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expires).To(BeTemporally(">", time.Now().Add(5*time.Minute)))
+	})
+
+	Describe("Overlaps", func() {
+		It("should report shared namespaces and the handling monitor", func() {
+			monitor := prioritizedMonitor("media", 5, "media", "downloads")
+			others := []volsyncv1alpha1.VolSyncMonitor{
+				*prioritizedMonitor("all", 0),
+				*prioritizedMonitor("downloads", 10, "downloads"),
+				*prioritizedMonitor("photos", 5, "photos"),
+				*prioritizedMonitor("peer", 5, "media"),
+			}
+
+			updateOverlaps(monitor, others)
+
+			overlaps := monitor.Status.Overlaps
+			Expect(overlaps).To(HaveLen(3))
+			Expect(overlaps[0].Monitor.Name).To(Equal("all"))
+			Expect(overlaps[0].Namespaces).To(Equal([]string{"downloads", "media"}))
+			Expect(overlaps[0].Handler).To(Equal(volsyncv1alpha1.OverlapHandlerSelf))
+			Expect(overlaps[1].Monitor.Name).To(Equal("downloads"))
+			Expect(overlaps[1].Namespaces).To(Equal([]string{"downloads"}))
+			Expect(overlaps[1].Handler).To(Equal(volsyncv1alpha1.OverlapHandlerOther))
+			Expect(overlaps[2].Monitor.Name).To(Equal("peer"))
+			Expect(overlaps[2].Handler).To(Equal(volsyncv1alpha1.OverlapHandlerFirstClaim))
+		})
+
+		It("should report monitors that both watch every namespace", func() {
+			monitor := prioritizedMonitor("a", 0)
+			updateOverlaps(monitor, []volsyncv1alpha1.VolSyncMonitor{*prioritizedMonitor("b", 0)})
+			Expect(monitor.Status.Overlaps).To(HaveLen(1))
+			Expect(monitor.Status.Overlaps[0].Namespaces).To(Equal([]string{"*"}))
+		})
+	})
+
+	It("should give each namespace's repositories to exactly one monitor", func() {
+		a, b := prioritizedMonitor("a", 0), prioritizedMonitor("b", 0)
+		Expect(ownsNamespace(a, []volsyncv1alpha1.VolSyncMonitor{*b}, "media")).To(BeTrue())
+		Expect(ownsNamespace(b, []volsyncv1alpha1.VolSyncMonitor{*a}, "media")).To(BeFalse())
+
+		b.Spec.Priority = 1
+		Expect(ownsNamespace(a, []volsyncv1alpha1.VolSyncMonitor{*b}, "media")).To(BeFalse())
+		Expect(ownsNamespace(a, []volsyncv1alpha1.VolSyncMonitor{*prioritizedMonitor("c", 1, "photos")}, "media")).To(BeTrue())
+	})
+})
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+const (
+	// defaultMaxConcurrentUnlocks is used when MaxConcurrentUnlocks is not set
+	defaultMaxConcurrentUnlocks = 3
+
+	// errorPhaseThreshold is the number of consecutive failed reconciliations
+	// after which a Degraded monitor is reported in the Error phase
+	errorPhaseThreshold = 3
+)
+
+// Condition reasons reported on VolSyncMonitor status
+const (
+	reasonDisabled                = "Disabled"
+	reasonEnabled                 = "Enabled"
+	reasonReconcileSucceeded      = "ReconcileSucceeded"
+	reasonReconcileError          = "ReconcileError"
+	reasonUnlocksInProgress       = "UnlocksInProgress"
+	reasonIdle                    = "Idle"
+	reasonMaxConcurrentUnlocks    = "MaxConcurrentUnlocksReached"
+	reasonBelowMaxConcurrentLimit = "BelowLimit"
+)
+
+// maxConcurrentUnlocks returns the effective concurrency limit of a monitor
+func maxConcurrentUnlocks(monitor *volsyncv1alpha1.VolSyncMonitor) int {
+	if monitor.Spec.MaxConcurrentUnlocks > 0 {
+		return int(monitor.Spec.MaxConcurrentUnlocks)
+	}
+	return defaultMaxConcurrentUnlocks
+}
+
+// setPausedStatus marks a disabled monitor as paused
+func setPausedStatus(monitor *volsyncv1alpha1.VolSyncMonitor) {
+	monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhasePaused
+	setCondition(monitor, volsyncv1alpha1.ConditionTypePaused, metav1.ConditionTrue, reasonDisabled, "Monitor is disabled (spec.enabled is false)")
+	setCondition(monitor, volsyncv1alpha1.ConditionTypeReady, metav1.ConditionFalse, reasonDisabled, "Monitor is paused")
+	setCondition(monitor, volsyncv1alpha1.ConditionTypeReconciling, metav1.ConditionFalse, reasonDisabled, "Monitor is paused")
+}
+
+// setReconcileStatus updates the phase and conditions after a reconciliation.
+// A single failure degrades the monitor; it only enters the Error phase when
+// failures persist for errorPhaseThreshold reconciliations in a row.
+func setReconcileStatus(monitor *volsyncv1alpha1.VolSyncMonitor, reconcileErr error) {
+	setCondition(monitor, volsyncv1alpha1.ConditionTypePaused, metav1.ConditionFalse, reasonEnabled, "Monitor is enabled")
+
+	if reconcileErr != nil {
+		monitor.Status.ConsecutiveErrors++
+		monitor.Status.LastError = reconcileErr.Error()
+		monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseDegraded
+		if monitor.Status.ConsecutiveErrors >= errorPhaseThreshold {
+			monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseError
+		}
+		message := fmt.Sprintf("%d consecutive reconciliation errors: %s", monitor.Status.ConsecutiveErrors, reconcileErr.Error())
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeDegraded, metav1.ConditionTrue, reasonReconcileError, message)
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeReady, metav1.ConditionFalse, reasonReconcileError, reconcileErr.Error())
+	} else {
+		monitor.Status.ConsecutiveErrors = 0
+		monitor.Status.LastError = ""
+		monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseActive
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeDegraded, metav1.ConditionFalse, reasonReconcileSucceeded, "Last reconciliation succeeded")
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeReady, metav1.ConditionTrue, reasonReconcileSucceeded, "Monitoring VolSync jobs")
+	}
+
+	active := len(monitor.Status.ActiveUnlocks)
+	if active > 0 {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeReconciling, metav1.ConditionTrue, reasonUnlocksInProgress,
+			fmt.Sprintf("%d unlock job(s) running", active))
+	} else {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeReconciling, metav1.ConditionFalse, reasonIdle, "No unlock jobs running")
+	}
+
+	limit := maxConcurrentUnlocks(monitor)
+	if active >= limit {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeQueueSaturated, metav1.ConditionTrue, reasonMaxConcurrentUnlocks,
+			fmt.Sprintf("%d of %d concurrent unlocks in use", active, limit))
+	} else {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeQueueSaturated, metav1.ConditionFalse, reasonBelowMaxConcurrentLimit,
+			fmt.Sprintf("%d of %d concurrent unlocks in use", active, limit))
+	}
+}
+
+// setCondition sets a condition on the monitor status for its current generation
+func setCondition(monitor *volsyncv1alpha1.VolSyncMonitor, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&monitor.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: monitor.Generation,
+	})
+}
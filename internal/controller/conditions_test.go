@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Monitor conditions", func() {
+	var monitor *volsyncv1alpha1.VolSyncMonitor
+
+	BeforeEach(func() {
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 2,
+			},
+		}
+	})
+
+	It("should report a healthy monitor as Active and Ready", func() {
+		setReconcileStatus(monitor, nil)
+
+		Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhaseActive))
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeDegraded)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePaused)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReconciling)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeQueueSaturated)).To(BeTrue())
+	})
+
+	It("should degrade on a single error and only enter Error when failures persist", func() {
+		reconcileErr := fmt.Errorf("failed to list jobs")
+
+		setReconcileStatus(monitor, reconcileErr)
+		Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhaseDegraded))
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeDegraded)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)).To(BeTrue())
+
+		for i := 1; i < errorPhaseThreshold; i++ {
+			setReconcileStatus(monitor, reconcileErr)
+		}
+		Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhaseError))
+		Expect(monitor.Status.ConsecutiveErrors).To(Equal(int32(errorPhaseThreshold)))
+
+		setReconcileStatus(monitor, nil)
+		Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhaseActive))
+		Expect(monitor.Status.ConsecutiveErrors).To(BeZero())
+		Expect(monitor.Status.LastError).To(BeEmpty())
+	})
+
+	It("should report running unlocks and a saturated queue", func() {
+		monitor.Status.ActiveUnlocks = []volsyncv1alpha1.ActiveUnlock{{JobName: "job1"}, {JobName: "job2"}}
+
+		setReconcileStatus(monitor, nil)
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReconciling)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeQueueSaturated)).To(BeTrue())
+	})
+
+	It("should mark a disabled monitor as Paused", func() {
+		monitor.Spec.Enabled = false
+
+		setPausedStatus(monitor)
+		Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhasePaused))
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePaused)).To(BeTrue())
+		Expect(meta.IsStatusConditionFalse(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)).To(BeTrue())
+	})
+})
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// moverOnlyVolumes are the volumes VolSync adds for the mover itself; they
+// hold the replicated data, not the repository, and are not needed to unlock
+var moverOnlyVolumes = map[string]bool{
+	"data":    true,
+	"cache":   true,
+	"tempdir": true,
+}
+
+// unlockTarget is the repository configuration an unlock job runs against
+type unlockTarget struct {
+	// MoverType is the mover of the repository, when discovered from VolSync objects
+	MoverType volsyncv1alpha1.MoverType
+	// SecretName is the repository secret, when known
+	SecretName   string
+	Env          []corev1.EnvVar
+	EnvFrom      []corev1.EnvFromSource
+	Volumes      []corev1.Volume
+	VolumeMounts []corev1.VolumeMount
+	// Image and ImagePullSecrets are those of the mover container, when known
+	Image            string
+	ImagePullSecrets []corev1.LocalObjectReference
+}
+
+// discoverVolSyncUnlockTarget discovers the repository configuration of a failed VolSync job.
+// Jobs that are not available any more, such as the placeholder jobs used for
+// manual unlocks, are resolved through the ReplicationSource they belong to.
+func (r *VolSyncMonitorReconciler) discoverVolSyncUnlockTarget(ctx context.Context, job batchv1.Job) (*unlockTarget, error) {
+	if len(job.Spec.Template.Spec.Containers) > 0 {
+		return r.targetFromJob(ctx, &job)
+	}
+	if name, ok := r.replicationSourceForJob(job); ok {
+		return r.discoverReplicationSourceTarget(ctx, job.Namespace, name)
+	}
+	return &unlockTarget{}, nil
+}
+
+// discoverReplicationSourceTarget discovers the repository of a ReplicationSource,
+// preferring its mover job, which carries the exact environment VolSync uses
+func (r *VolSyncMonitorReconciler) discoverReplicationSourceTarget(ctx context.Context, namespace, name string) (*unlockTarget, error) {
+	source, err := r.getVolSyncObject(ctx, namespace, "ReplicationSource", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ReplicationSource %s/%s: %w", namespace, name, err)
+	}
+	moverType, settings, _ := moverSection(source)
+
+	var job batchv1.Job
+	err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "volsync-src-" + name}, &job)
+	switch {
+	case err == nil:
+		target, err := r.targetFromJob(ctx, &job)
+		if err != nil {
+			return nil, err
+		}
+		if moverType != "" {
+			target.MoverType = moverType
+		}
+		return target, nil
+	case !errors.IsNotFound(err):
+		return nil, fmt.Errorf("failed to get mover job of ReplicationSource %s/%s: %w", namespace, name, err)
+	}
+
+	target := &unlockTarget{MoverType: moverType}
+	if repository, _, _ := unstructured.NestedString(settings, "repository"); repository != "" {
+		target.SecretName = repository
+		target.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: repository}},
+		}}
+	}
+	target.Volumes, target.VolumeMounts = moverVolumesFromSettings(settings)
+	return target, nil
+}
+
+// discoverSecretTarget builds the repository configuration from a restic repository secret
+func (r *VolSyncMonitorReconciler) discoverSecretTarget(ctx context.Context, namespace, name string) (*unlockTarget, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get repository secret %s/%s: %w", namespace, name, err)
+	}
+	return &unlockTarget{
+		SecretName: name,
+		EnvFrom: []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		}},
+	}, nil
+}
+
+// targetFromJob copies the repository environment and volumes of a mover job
+func (r *VolSyncMonitorReconciler) targetFromJob(ctx context.Context, job *batchv1.Job) (*unlockTarget, error) {
+	volumes, volumeMounts, err := r.discoverVolSyncVolumeConfig(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &unlockTarget{
+		Volumes:      volumes,
+		VolumeMounts: volumeMounts,
+	}
+	if containers := job.Spec.Template.Spec.Containers; len(containers) > 0 {
+		target.Env = append(target.Env, containers[0].Env...)
+		target.EnvFrom = append(target.EnvFrom, containers[0].EnvFrom...)
+		target.Image = containers[0].Image
+		target.ImagePullSecrets = append(target.ImagePullSecrets, job.Spec.Template.Spec.ImagePullSecrets...)
+	}
+	target.SecretName = repositorySecretName(target.Env, target.EnvFrom)
+	return target, nil
+}
+
+// discoverVolSyncVolumeConfig returns the volumes of a VolSync job that the
+// mover container mounts to reach its repository, such as NFS shares, repository
+// PVCs and CA certificates. The data, cache and scratch volumes are left out.
+func (r *VolSyncMonitorReconciler) discoverVolSyncVolumeConfig(ctx context.Context, job *batchv1.Job) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	podSpec := job.Spec.Template.Spec
+	if len(podSpec.Containers) == 0 {
+		return nil, nil, nil
+	}
+
+	volumesByName := map[string]corev1.Volume{}
+	for _, volume := range podSpec.Volumes {
+		volumesByName[volume.Name] = volume
+	}
+
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	added := map[string]bool{}
+	for _, mount := range podSpec.Containers[0].VolumeMounts {
+		volume, found := volumesByName[mount.Name]
+		if !found || moverOnlyVolumes[mount.Name] || volume.EmptyDir != nil {
+			continue
+		}
+		if !added[mount.Name] {
+			volumes = append(volumes, *volume.DeepCopy())
+			added[mount.Name] = true
+		}
+		volumeMounts = append(volumeMounts, *mount.DeepCopy())
+	}
+	return volumes, volumeMounts, nil
+}
+
+// moverVolumesFromSettings converts the moverVolumes of a VolSync mover spec
+// into the volumes VolSync mounts under /mnt in the mover container
+func moverVolumesFromSettings(settings map[string]interface{}) ([]corev1.Volume, []corev1.VolumeMount) {
+	entries, _, _ := unstructured.NestedSlice(settings, "moverVolumes")
+
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mountPath, _, _ := unstructured.NestedString(fields, "mountPath")
+		if mountPath == "" {
+			continue
+		}
+
+		var source corev1.VolumeSource
+		if claimName, _, _ := unstructured.NestedString(fields, "volumeSource", "persistentVolumeClaim", "claimName"); claimName != "" {
+			readOnly, _, _ := unstructured.NestedBool(fields, "volumeSource", "persistentVolumeClaim", "readOnly")
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName, ReadOnly: readOnly}
+		} else if secretName, _, _ := unstructured.NestedString(fields, "volumeSource", "secret", "secretName"); secretName != "" {
+			source.Secret = &corev1.SecretVolumeSource{SecretName: secretName}
+		} else {
+			continue
+		}
+
+		name := "u-" + mountPath
+		volumes = append(volumes, corev1.Volume{Name: name, VolumeSource: source})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: name, MountPath: path.Join("/mnt", mountPath)})
+	}
+	return volumes, volumeMounts
+}
+
+// repositorySecretName returns the first secret the mover environment is read from
+func repositorySecretName(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) string {
+	for _, source := range envFrom {
+		if source.SecretRef != nil {
+			return source.SecretRef.Name
+		}
+	}
+	for _, variable := range env {
+		if variable.ValueFrom != nil && variable.ValueFrom.SecretKeyRef != nil {
+			return variable.ValueFrom.SecretKeyRef.Name
+		}
+	}
+	return ""
+}
+
+// replicationSourceForJob returns the ReplicationSource a source mover job of
+// VolSync belongs to, resolved like the identity of the job
+func (r *VolSyncMonitorReconciler) replicationSourceForJob(job batchv1.Job) (string, bool) {
+	if r.providerOf(&job).name() != volsyncv1alpha1.BackupProviderVolSync {
+		return "", false
+	}
+	identity := r.jobIdentityFromJob(&job)
+	if identity.Direction != volsyncv1alpha1.VolSyncDirectionSource || identity.ObjectName == "" {
+		return "", false
+	}
+	return identity.ObjectName, true
+}
+
+// replicationObjectsUsingSecret returns the ReplicationSources and
+// ReplicationDestinations of a namespace whose mover uses a repository secret
+func (r *VolSyncMonitorReconciler) replicationObjectsUsingSecret(ctx context.Context, namespace, secret string) ([]jobIdentity, error) {
+	var objects []jobIdentity
+	for _, kind := range []string{"ReplicationSource", "ReplicationDestination"} {
+		var list unstructured.UnstructuredList
+		list.SetGroupVersionKind(volsyncGroupVersion.WithKind(kind + "List"))
+		if err := r.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("failed to list %ss in namespace %s: %w", kind, namespace, err)
+		}
+		for i := range list.Items {
+			if _, settings, ok := moverSection(&list.Items[i]); ok && settings["repository"] == secret {
+				objects = append(objects, jobIdentity{ObjectName: list.Items[i].GetName(), Direction: directionOfKind(kind)})
+			}
+		}
+	}
+	return objects, nil
+}
+
+// replicationSourceJob returns a placeholder for the mover job of a
+// ReplicationSource, used where a job is needed but none failed
+func replicationSourceJob(namespace, source string) batchv1.Job {
+	return batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:      "volsync-src-" + source,
+		Namespace: namespace,
+		Labels:    map[string]string{volsyncSourceLabel: source},
+	}}
+}
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// newFakeScheme returns a scheme with the types the controllers read
+func newFakeScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(volsyncv1alpha1.AddToScheme(scheme)).To(Succeed())
+	for _, kind := range []string{"ReplicationSource", "ReplicationDestination"} {
+		scheme.AddKnownTypeWithName(volsyncGroupVersion.WithKind(kind), &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(volsyncGroupVersion.WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+	for _, kind := range []string{"Backup", "Check", "Prune", "Schedule"} {
+		scheme.AddKnownTypeWithName(k8upGroupVersion.WithKind(kind), &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(k8upGroupVersion.WithKind(kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return scheme
+}
+
+// newFakeClient returns a fake client holding the objects. Like the API
+// server it serves the status of the CRDs as a subresource and sets the
+// creation timestamp of created objects, which the record retention needs.
+func newFakeClient(objects ...client.Object) client.WithWatch {
+	return fake.NewClientBuilder().WithScheme(newFakeScheme()).WithObjects(objects...).
+		WithStatusSubresource(&volsyncv1alpha1.VolSyncMonitor{}, &volsyncv1alpha1.UnlockRecord{},
+			&volsyncv1alpha1.VolSyncUnlockRequest{}, &volsyncv1alpha1.FailurePatternSet{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				obj.SetCreationTimestamp(metav1.Now())
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+}
+
+// newFakeReconciler returns a monitor reconciler on a fake client holding the objects
+func newFakeReconciler(objects ...client.Object) *VolSyncMonitorReconciler {
+	c := newFakeClient(objects...)
+	return &VolSyncMonitorReconciler{Client: c, Scheme: c.Scheme()}
+}
+
+// newReplicationSource returns a restic ReplicationSource using the given repository secret
+func newReplicationSource(namespace, name, repository string) *unstructured.Unstructured {
+	source := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"sourcePVC": name,
+			"restic": map[string]interface{}{
+				"repository": repository,
+				"moverVolumes": []interface{}{
+					map[string]interface{}{
+						"mountPath": "repository",
+						"volumeSource": map[string]interface{}{
+							"persistentVolumeClaim": map[string]interface{}{"claimName": "nfs-repository"},
+						},
+					},
+				},
+			},
+		},
+	}}
+	source.SetGroupVersionKind(volsyncGroupVersion.WithKind("ReplicationSource"))
+	source.SetNamespace(namespace)
+	source.SetName(name)
+	return source
+}
+
+var _ = Describe("Repository discovery", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("should copy the repository environment and volumes of a mover job", func() {
+		job := &batchv1.Job{
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Env: []corev1.EnvVar{{
+								Name: "RESTIC_REPOSITORY",
+								ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"},
+									Key:                  "RESTIC_REPOSITORY",
+								}},
+							}},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "data", MountPath: "/data"},
+								{Name: "cache", MountPath: "/cache"},
+								{Name: "u-repository", MountPath: "/mnt/repository"},
+							},
+						}},
+						Volumes: []corev1.Volume{
+							{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "plex"}}},
+							{Name: "cache", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "volsync-src-plex-cache"}}},
+							{Name: "u-repository", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nas", Path: "/volsync"}}},
+						},
+					},
+				},
+			},
+		}
+
+		target, err := newFakeReconciler().targetFromJob(ctx, job)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.SecretName).To(Equal("plex-restic"))
+		Expect(target.Env).To(HaveLen(1))
+		Expect(target.Volumes).To(HaveLen(1))
+		Expect(target.Volumes[0].NFS.Server).To(Equal("nas"))
+		Expect(target.VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: "u-repository", MountPath: "/mnt/repository"}))
+	})
+
+	It("should discover the repository from a ReplicationSource without a mover job", func() {
+		reconciler := newFakeReconciler(newReplicationSource("media", "plex", "plex-restic"))
+
+		target, err := reconciler.discoverReplicationSourceTarget(ctx, "media", "plex")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.MoverType).To(Equal(volsyncv1alpha1.MoverTypeRestic))
+		Expect(target.SecretName).To(Equal("plex-restic"))
+		Expect(target.EnvFrom[0].SecretRef.Name).To(Equal("plex-restic"))
+		Expect(target.Volumes).To(HaveLen(1))
+		Expect(target.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("nfs-repository"))
+		Expect(target.VolumeMounts[0].MountPath).To(Equal("/mnt/repository"))
+	})
+
+	It("should resolve placeholder jobs through their ReplicationSource", func() {
+		reconciler := newFakeReconciler(newReplicationSource("media", "plex", "plex-restic"))
+
+		target, err := reconciler.discoverUnlockTarget(ctx, batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.SecretName).To(Equal("plex-restic"))
+	})
+
+	Describe("Lock verification", func() {
+		running := func(name string) *batchv1.Job {
+			return &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "media", UID: "running"},
+				Status:     batchv1.JobStatus{Active: 1},
+			}
+		}
+
+		It("should not unlock while a mover of the same ReplicationSource runs", func() {
+			reconciler := newFakeReconciler(running("volsync-src-plex"))
+			monitor := &volsyncv1alpha1.VolSyncMonitor{}
+			failed := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "failed"}}
+
+			reason, err := reconciler.verifyStaleLock(ctx, monitor, failed, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(Equal("job volsync-src-plex is using the repository"))
+
+			other := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-sonarr", Namespace: "media", UID: "failed"}}
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, other, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(BeEmpty())
+		})
+
+		It("should find the running jobs of every mover and direction", func() {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{}
+			source := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "failed"}}
+			destination := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-rclone-dst-plex", Namespace: "media", UID: "failed"}}
+
+			reconciler := newFakeReconciler(running("volsync-rclone-dst-plex"))
+			reason, err := reconciler.verifyStaleLock(ctx, monitor, destination, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(Equal("job volsync-rclone-dst-plex is using the repository"))
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, source, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(BeEmpty())
+
+			By("matching jobs by their VolSync labels")
+			labelled := running("backup-plex")
+			labelled.Labels = map[string]string{volsyncSourceLabel: "plex"}
+			reconciler = newFakeReconciler(labelled)
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, source, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(Equal("job backup-plex is using the repository"))
+
+			By("counting the repository jobs of the monitor")
+			check := running("health-check-plex-1700000000")
+			check.Labels = map[string]string{healthCheckLabel: "monitor", repositorySourceLabel: "plex"}
+			reconciler = newFakeReconciler(check)
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, source, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(Equal("job health-check-plex-1700000000 is using the repository"))
+		})
+
+		It("should leave locks younger than minLockAge alone", func() {
+			reconciler := newFakeReconciler()
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{MinLockAge: &metav1.Duration{Duration: time.Hour}},
+			}
+			job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"}}
+
+			young := 5 * time.Minute
+			reason, err := reconciler.verifyStaleLock(ctx, monitor, job, &lockErrorMatch{LockAge: &young})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(ContainSubstring("younger than minLockAge"))
+
+			old := 2 * time.Hour
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, job, &lockErrorMatch{LockAge: &old})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(BeEmpty())
+
+			reason, err = reconciler.verifyStaleLock(ctx, monitor, job, &lockErrorMatch{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reason).To(BeEmpty())
+		})
+	})
+})
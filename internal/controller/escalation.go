@@ -0,0 +1,369 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+const (
+	// escalationAnnotation is set to the failed VolSync job, as namespace/name,
+	// on unlock jobs whose failure was escalated and on the jobs of escalation
+	// steps, so that their failure is not escalated again
+	escalationAnnotation = "homelab.rafaribe.com/escalation"
+	// escalationStepLabel is set on the jobs of escalation steps to their step
+	escalationStepLabel = "homelab.rafaribe.com/escalation-step"
+
+	// Defaults of escalation policies
+	defaultEscalationAttempts       = 3
+	defaultEscalationInitialBackoff = time.Minute
+	defaultEscalationMaxBackoff     = time.Hour
+)
+
+// Event reasons for escalations
+const (
+	reasonEscalated           = "Escalated"
+	reasonEscalationResolved  = "EscalationResolved"
+	reasonEscalationExhausted = "EscalationExhausted"
+)
+
+// defaultEscalationSteps is the ladder of policies that do not list their steps
+var defaultEscalationSteps = []volsyncv1alpha1.EscalationStep{
+	volsyncv1alpha1.EscalationStepRetryUnlock,
+	volsyncv1alpha1.EscalationStepCheck,
+	volsyncv1alpha1.EscalationStepRebuildIndex,
+	volsyncv1alpha1.EscalationStepNotify,
+}
+
+// escalationPolicy returns the escalation ladder the monitor configures for a failure class
+func escalationPolicy(monitor *volsyncv1alpha1.VolSyncMonitor, class volsyncv1alpha1.FailureClass) (volsyncv1alpha1.EscalationPolicy, bool) {
+	for _, policy := range monitor.Spec.Escalations {
+		if policy.Class == class {
+			return policy, true
+		}
+	}
+	return volsyncv1alpha1.EscalationPolicy{Class: class}, false
+}
+
+// escalationSteps returns the steps of a policy. Every ladder ends with Notify.
+func escalationSteps(policy volsyncv1alpha1.EscalationPolicy) []volsyncv1alpha1.EscalationStep {
+	configured := policy.Steps
+	if len(configured) == 0 {
+		configured = defaultEscalationSteps
+	}
+	var steps []volsyncv1alpha1.EscalationStep
+	for _, step := range configured {
+		steps = append(steps, step)
+		if step == volsyncv1alpha1.EscalationStepNotify {
+			return steps
+		}
+	}
+	return append(steps, volsyncv1alpha1.EscalationStepNotify)
+}
+
+// escalationAttempts returns the number of attempts of the RetryUnlock step
+func escalationAttempts(policy volsyncv1alpha1.EscalationPolicy) int32 {
+	if policy.MaxAttempts > 0 {
+		return policy.MaxAttempts
+	}
+	return defaultEscalationAttempts
+}
+
+// escalationBackoff returns the delay before an attempt of the RetryUnlock
+// step, given the number of attempts made so far
+func escalationBackoff(policy volsyncv1alpha1.EscalationPolicy, attempts int32) time.Duration {
+	backoff := defaultEscalationInitialBackoff
+	if policy.InitialBackoff != nil {
+		backoff = policy.InitialBackoff.Duration
+	}
+	maxBackoff := defaultEscalationMaxBackoff
+	if policy.MaxBackoff != nil {
+		maxBackoff = policy.MaxBackoff.Duration
+	}
+	for i := int32(0); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// stepSupported reports whether a step can run against the repository of a mover.
+// Check and RebuildIndex run restic commands.
+func stepSupported(step volsyncv1alpha1.EscalationStep, moverType volsyncv1alpha1.MoverType) bool {
+	switch step {
+	case volsyncv1alpha1.EscalationStepCheck, volsyncv1alpha1.EscalationStepRebuildIndex:
+		return moverType == "" || moverType == volsyncv1alpha1.MoverTypeRestic
+	}
+	return true
+}
+
+// findEscalation returns the escalation of a failed VolSync job, if any
+func findEscalation(monitor *volsyncv1alpha1.VolSyncMonitor, namespace, jobName string, jobUID types.UID) *volsyncv1alpha1.Escalation {
+	for i := range monitor.Status.Escalations {
+		escalation := &monitor.Status.Escalations[i]
+		if escalation.Namespace == namespace && escalation.JobName == jobName && escalation.JobUID == jobUID {
+			return escalation
+		}
+	}
+	return nil
+}
+
+// startEscalation escalates the failure of an unlock job created for a failed
+// VolSync job, when the monitor configures a ladder for its failure class
+func (r *VolSyncMonitorReconciler) startEscalation(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, unlockJob batchv1.Job) error {
+	if _, escalated := unlockJob.Annotations[escalationAnnotation]; escalated {
+		return nil
+	}
+
+	var processed *volsyncv1alpha1.ProcessedJob
+	for i := range monitor.Status.ProcessedJobs {
+		candidate := &monitor.Status.ProcessedJobs[i]
+		if candidate.Namespace == unlockJob.Namespace && candidate.UnlockJobName == unlockJob.Name {
+			processed = candidate
+		}
+	}
+	if processed == nil {
+		return nil
+	}
+	policy, ok := escalationPolicy(monitor, processed.Classification)
+	if !ok {
+		return nil
+	}
+
+	if findEscalation(monitor, processed.Namespace, processed.JobName, processed.JobUID) == nil {
+		escalation := volsyncv1alpha1.Escalation{
+			JobName:    processed.JobName,
+			Namespace:  processed.Namespace,
+			JobUID:     processed.JobUID,
+			AppName:    processed.AppName,
+			ObjectName: processed.ObjectName,
+			MoverType:  processed.MoverType,
+			Class:      processed.Classification,
+			StartTime:  metav1.Now(),
+			Message:    fmt.Sprintf("Unlock job %s failed", unlockJob.Name),
+		}
+		r.enterEscalationStep(monitor, &escalation, policy, escalationSteps(policy)[0])
+		monitor.Status.Escalations = append(monitor.Status.Escalations, escalation)
+	}
+
+	// Mark the unlock job, so that its failure is escalated once
+	patch := client.MergeFrom(unlockJob.DeepCopy())
+	if unlockJob.Annotations == nil {
+		unlockJob.Annotations = map[string]string{}
+	}
+	unlockJob.Annotations[escalationAnnotation] = processed.Namespace + "/" + processed.JobName
+	if err := r.Patch(ctx, &unlockJob, patch); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to mark unlock job %s as escalated: %w", unlockJob.Name, err)
+	}
+	return nil
+}
+
+// enterEscalationStep moves an escalation to a step, skipping steps the mover
+// does not support. Reaching Notify publishes a warning event and stops the
+// escalation.
+func (r *VolSyncMonitorReconciler) enterEscalationStep(monitor *volsyncv1alpha1.VolSyncMonitor, escalation *volsyncv1alpha1.Escalation, policy volsyncv1alpha1.EscalationPolicy, step volsyncv1alpha1.EscalationStep) {
+	for !stepSupported(step, escalation.MoverType) {
+		step = nextEscalationStep(policy, step)
+	}
+
+	escalation.Step = step
+	escalation.Attempts = 0
+	escalation.CurrentJobName = ""
+	escalation.NextAttemptTime = nil
+	helpers.RecordEscalationStep(escalation.Namespace, escalation.AppName, escalation.ObjectName, string(step))
+
+	failedJob := escalation.Namespace + "/" + escalation.JobName
+	if step == volsyncv1alpha1.EscalationStepNotify {
+		if r.Recorder != nil {
+			r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonEscalationExhausted,
+				"Remediation of failed job %s (%s) exhausted its escalation ladder and needs manual intervention: %s", failedJob, escalation.Class, escalation.Message)
+		}
+		return
+	}
+
+	next := time.Now()
+	if step == volsyncv1alpha1.EscalationStepRetryUnlock {
+		next = next.Add(escalationBackoff(policy, 0))
+	}
+	escalation.NextAttemptTime = &metav1.Time{Time: next}
+	if r.Recorder != nil {
+		r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonEscalated,
+			"Escalated failed job %s to %s: %s", failedJob, step, escalation.Message)
+	}
+}
+
+// nextEscalationStep returns the step following a step of a policy
+func nextEscalationStep(policy volsyncv1alpha1.EscalationPolicy, step volsyncv1alpha1.EscalationStep) volsyncv1alpha1.EscalationStep {
+	steps := escalationSteps(policy)
+	for i, candidate := range steps[:len(steps)-1] {
+		if candidate == step {
+			return steps[i+1]
+		}
+	}
+	return volsyncv1alpha1.EscalationStepNotify
+}
+
+// reconcileEscalations collects the jobs of running escalation steps and
+// starts the attempts that are due. It returns the time until the next
+// attempt is due, or zero when none is waiting.
+func (r *VolSyncMonitorReconciler) reconcileEscalations(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) time.Duration {
+	logger := log.FromContext(ctx)
+	now := time.Now()
+	var requeueAfter time.Duration
+
+	escalations := monitor.Status.Escalations[:0]
+	for _, escalation := range monitor.Status.Escalations {
+		policy, _ := escalationPolicy(monitor, escalation.Class)
+
+		if escalation.Step == volsyncv1alpha1.EscalationStepNotify {
+			// Stopped escalations are kept as long as the unlock history
+			if now.Sub(escalation.StartTime.Time) < recordMaxAge(monitor) {
+				escalations = append(escalations, escalation)
+			}
+			continue
+		}
+
+		if escalation.CurrentJobName != "" {
+			resolved, err := r.collectEscalationJob(ctx, monitor, &escalation, policy)
+			if err != nil {
+				logger.Error(err, "Failed to collect escalation job", "job", escalation.CurrentJobName, "namespace", escalation.Namespace)
+			}
+			if resolved {
+				continue
+			}
+		}
+
+		if escalation.NextAttemptTime != nil {
+			if wait := time.Until(escalation.NextAttemptTime.Time); wait > 0 {
+				if requeueAfter == 0 || wait < requeueAfter {
+					requeueAfter = wait
+				}
+			} else if r.canCreateUnlockJob(*monitor) {
+				job, err := r.createEscalationJob(ctx, monitor, escalation)
+				if err != nil {
+					logger.Error(err, "Failed to create escalation job", "job", escalation.JobName, "namespace", escalation.Namespace, "step", escalation.Step)
+					escalation.Message = fmt.Sprintf("Failed to start %s: %v", escalation.Step, err)
+				} else {
+					escalation.Attempts++
+					escalation.CurrentJobName = job.Name
+					escalation.NextAttemptTime = nil
+					escalation.Message = fmt.Sprintf("%s attempt %d running in job %s", escalation.Step, escalation.Attempts, job.Name)
+					monitor.Status.ActiveUnlocks = append(monitor.Status.ActiveUnlocks, volsyncv1alpha1.ActiveUnlock{
+						AppName:          escalation.AppName,
+						Namespace:        job.Namespace,
+						ObjectName:       escalation.ObjectName,
+						JobName:          job.Name,
+						StartTime:        metav1.Now(),
+						AlertFingerprint: fmt.Sprintf("%s-%s", job.Namespace, job.Name),
+					})
+				}
+			}
+		}
+		escalations = append(escalations, escalation)
+	}
+	monitor.Status.Escalations = escalations
+	return requeueAfter
+}
+
+// collectEscalationJob applies the outcome of the job of the current step
+// once it finished. It reports whether the escalation is resolved.
+func (r *VolSyncMonitorReconciler) collectEscalationJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, escalation *volsyncv1alpha1.Escalation, policy volsyncv1alpha1.EscalationPolicy) (bool, error) {
+	jobName := escalation.CurrentJobName
+	var job batchv1.Job
+	err := r.Get(ctx, types.NamespacedName{Namespace: escalation.Namespace, Name: jobName}, &job)
+	switch {
+	case errors.IsNotFound(err):
+		escalation.Message = fmt.Sprintf("%s job %s was deleted before it finished", escalation.Step, jobName)
+	case err != nil:
+		return false, err
+	case r.isJobSucceeded(job):
+		log.FromContext(ctx).Info("Escalation resolved", "job", escalation.JobName, "namespace", escalation.Namespace, "step", escalation.Step, "escalationJob", jobName)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonEscalationResolved,
+				"Failed job %s/%s remediated by %s job %s", escalation.Namespace, escalation.JobName, escalation.Step, jobName)
+		}
+		return true, nil
+	case r.isJobFailed(&job):
+		escalation.Message = fmt.Sprintf("%s job %s failed", escalation.Step, jobName)
+		if logs, err := r.latestJobPodLogs(ctx, job); err == nil && logs != "" {
+			escalation.Message += ": " + helpers.TailLines(logs, checkMessageLines, checkMessageBytes)
+		}
+	default:
+		return false, nil
+	}
+
+	escalation.CurrentJobName = ""
+	if escalation.Step == volsyncv1alpha1.EscalationStepRetryUnlock && escalation.Attempts < escalationAttempts(policy) {
+		escalation.NextAttemptTime = &metav1.Time{Time: time.Now().Add(escalationBackoff(policy, escalation.Attempts))}
+		return false, nil
+	}
+	r.enterEscalationStep(monitor, escalation, policy, nextEscalationStep(policy, escalation.Step))
+	return false, nil
+}
+
+// createEscalationJob creates the job of the next attempt of an escalation.
+// Jobs are named after the failed job, step and attempt, so that a job that
+// was created but not recorded is found again.
+func (r *VolSyncMonitorReconciler) createEscalationJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, escalation volsyncv1alpha1.Escalation) (*batchv1.Job, error) {
+	// The failed job may have been removed since; its repository is then
+	// resolved through its ReplicationSource
+	failedJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: escalation.JobName, Namespace: escalation.Namespace}}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(&failedJob), &failedJob); err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get failed job: %w", err)
+	}
+
+	mover := r.resolveMoverSettings(monitor, escalation.MoverType)
+	switch escalation.Step {
+	case volsyncv1alpha1.EscalationStepCheck:
+		mover.Template.Command = []string{"/bin/sh"}
+		mover.Template.Args = []string{"-c", restic.CheckWithoutLockScript() + " && restic unlock"}
+	case volsyncv1alpha1.EscalationStepRebuildIndex:
+		mover.Template.Command = []string{"/bin/sh"}
+		mover.Template.Args = []string{"-c", restic.RepairIndexScript() + " && restic unlock"}
+	}
+
+	target, err := r.discoverUnlockTarget(ctx, failedJob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover repository of job %s: %w", failedJob.Name, err)
+	}
+	identity := r.resolveJobIdentity(ctx, &failedJob)
+	reason := fmt.Sprintf("%s attempt %d of the escalation of failed job %s: %s", escalation.Step, escalation.Attempts+1, escalation.JobName, escalation.Message)
+	key := types.UID(fmt.Sprintf("%s/%s/%s/%s/%d", escalation.JobUID, escalation.Namespace, escalation.JobName, escalation.Step, escalation.Attempts+1))
+	job, err := r.newUnlockJob(monitor, failedJob, identity, reason, mover, target, key)
+	if err != nil {
+		return nil, err
+	}
+	job.Labels[escalationStepLabel] = string(escalation.Step)
+	job.Annotations[escalationAnnotation] = escalation.Namespace + "/" + escalation.JobName
+
+	if err := r.holdReplication(ctx, monitor, identity, job.Namespace, job.Name); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to hold VolSync object during escalation", "job", job.Name, "namespace", job.Namespace)
+	}
+	if err := r.Create(ctx, job); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create escalation job: %w", err)
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(job), job); err != nil {
+			return nil, fmt.Errorf("failed to get existing escalation job: %w", err)
+		}
+		return job, nil
+	}
+
+	log.FromContext(ctx).Info("Created escalation job", "job", job.Name, "namespace", job.Namespace, "step", escalation.Step, "failedJob", escalation.JobName)
+	helpers.RecordUnlockJobCreated(job.Namespace, identity.App, identity.ObjectName)
+	return job, nil
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Escalations", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		failedJob *batchv1.Job
+		unlockJob *batchv1.Job
+		recorder  *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 5,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+				Escalations: []volsyncv1alpha1.EscalationPolicy{{
+					Class:       volsyncv1alpha1.FailureClassStaleLock,
+					MaxAttempts: 2,
+				}},
+			},
+			Status: volsyncv1alpha1.VolSyncMonitorStatus{
+				ProcessedJobs: []volsyncv1alpha1.ProcessedJob{{
+					JobName:        "volsync-src-plex",
+					Namespace:      "media",
+					JobUID:         "job-uid",
+					AppName:        "plex",
+					ObjectName:     "plex",
+					MoverType:      volsyncv1alpha1.MoverTypeRestic,
+					Classification: volsyncv1alpha1.FailureClassStaleLock,
+					UnlockJobName:  "volsync-unlock-volsync-src-plex",
+				}},
+			},
+		}
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+		}
+		unlockJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "volsync-unlock-volsync-src-plex",
+				Namespace: "media",
+				Labels:    map[string]string{MonitorLabel: "monitor"},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		recorder = record.NewFakeRecorder(20)
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		r := newFakeReconciler(append(objects, failedJob, unlockJob)...)
+		r.Recorder = recorder
+		return r
+	}
+
+	// finishJob marks the job of the running attempt as finished
+	finishJob := func(r *VolSyncMonitorReconciler, escalation volsyncv1alpha1.Escalation, condition batchv1.JobConditionType) *batchv1.Job {
+		var job batchv1.Job
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "media", Name: escalation.CurrentJobName}, &job)).To(Succeed())
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &job)).To(Succeed())
+		return &job
+	}
+
+	// dueNow makes the next attempt of the escalation due
+	dueNow := func() {
+		monitor.Status.Escalations[0].NextAttemptTime = &metav1.Time{Time: time.Now().Add(-time.Second)}
+	}
+
+	It("should order the default ladder and back off exponentially", func() {
+		policy := volsyncv1alpha1.EscalationPolicy{
+			InitialBackoff: &metav1.Duration{Duration: time.Minute},
+			MaxBackoff:     &metav1.Duration{Duration: 5 * time.Minute},
+		}
+		Expect(escalationSteps(policy)).To(Equal(defaultEscalationSteps))
+		Expect(escalationBackoff(policy, 0)).To(Equal(time.Minute))
+		Expect(escalationBackoff(policy, 2)).To(Equal(4 * time.Minute))
+		Expect(escalationBackoff(policy, 3)).To(Equal(5 * time.Minute))
+
+		policy.Steps = []volsyncv1alpha1.EscalationStep{volsyncv1alpha1.EscalationStepCheck}
+		Expect(escalationSteps(policy)).To(Equal([]volsyncv1alpha1.EscalationStep{
+			volsyncv1alpha1.EscalationStepCheck, volsyncv1alpha1.EscalationStepNotify,
+		}))
+	})
+
+	It("should climb the ladder until it notifies and stops", func() {
+		r := newReconciler()
+		Expect(r.updateActiveUnlocks(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.Escalations).To(HaveLen(1))
+		escalation := monitor.Status.Escalations[0]
+		Expect(escalation.Step).To(Equal(volsyncv1alpha1.EscalationStepRetryUnlock))
+		Expect(escalation.NextAttemptTime.Time).To(BeTemporally("~", time.Now().Add(time.Minute), 5*time.Second))
+
+		By("escalating the failed unlock only once")
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(unlockJob), unlockJob)).To(Succeed())
+		Expect(unlockJob.Annotations).To(HaveKeyWithValue(escalationAnnotation, "media/volsync-src-plex"))
+		Expect(r.updateActiveUnlocks(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.Escalations).To(HaveLen(1))
+
+		By("waiting for the backoff")
+		Expect(r.reconcileEscalations(ctx, monitor)).To(BeNumerically("~", time.Minute, 5*time.Second))
+		Expect(monitor.Status.Escalations[0].CurrentJobName).To(BeEmpty())
+
+		By("retrying the unlock")
+		dueNow()
+		r.reconcileEscalations(ctx, monitor)
+		escalation = monitor.Status.Escalations[0]
+		Expect(escalation.Attempts).To(Equal(int32(1)))
+		retry := finishJob(r, escalation, batchv1.JobFailed)
+		Expect(retry.Annotations).To(HaveKey(escalationAnnotation))
+		Expect(retry.Labels).To(HaveKeyWithValue(escalationStepLabel, "RetryUnlock"))
+
+		r.reconcileEscalations(ctx, monitor)
+		escalation = monitor.Status.Escalations[0]
+		Expect(escalation.CurrentJobName).To(BeEmpty())
+		Expect(escalation.NextAttemptTime.Time).To(BeTemporally("~", time.Now().Add(2*time.Minute), 5*time.Second))
+		dueNow()
+		r.reconcileEscalations(ctx, monitor)
+		finishJob(r, monitor.Status.Escalations[0], batchv1.JobFailed)
+
+		By("checking the repository once the retries are exhausted")
+		r.reconcileEscalations(ctx, monitor)
+		escalation = monitor.Status.Escalations[0]
+		Expect(escalation.Step).To(Equal(volsyncv1alpha1.EscalationStepCheck))
+		Expect(escalation.Attempts).To(Equal(int32(1)))
+		check := finishJob(r, escalation, batchv1.JobFailed)
+		Expect(check.Spec.Template.Spec.Containers[0].Args[1]).To(ContainSubstring("--no-lock --no-cache check"))
+
+		By("rebuilding the index")
+		r.reconcileEscalations(ctx, monitor)
+		escalation = monitor.Status.Escalations[0]
+		Expect(escalation.Step).To(Equal(volsyncv1alpha1.EscalationStepRebuildIndex))
+		rebuild := finishJob(r, escalation, batchv1.JobFailed)
+		Expect(rebuild.Spec.Template.Spec.Containers[0].Args[1]).To(ContainSubstring("rebuild-index"))
+
+		By("notifying and stopping")
+		Expect(r.reconcileEscalations(ctx, monitor)).To(BeZero())
+		escalation = monitor.Status.Escalations[0]
+		Expect(escalation.Step).To(Equal(volsyncv1alpha1.EscalationStepNotify))
+		Expect(escalation.NextAttemptTime).To(BeNil())
+		Expect(escalation.Message).To(ContainSubstring("RebuildIndex job"))
+
+		close(recorder.Events)
+		var events []string
+		for event := range recorder.Events {
+			events = append(events, event)
+		}
+		Expect(events).To(ContainElement(ContainSubstring(reasonEscalationExhausted)))
+	})
+
+	It("should resolve the escalation when an attempt succeeds", func() {
+		r := newReconciler()
+		Expect(r.updateActiveUnlocks(ctx, monitor)).To(Succeed())
+		dueNow()
+		r.reconcileEscalations(ctx, monitor)
+		finishJob(r, monitor.Status.Escalations[0], batchv1.JobComplete)
+
+		r.reconcileEscalations(ctx, monitor)
+		Expect(monitor.Status.Escalations).To(BeEmpty())
+	})
+
+	It("should skip the restic steps for other movers", func() {
+		monitor.Status.ProcessedJobs[0].MoverType = volsyncv1alpha1.MoverTypeKopia
+		monitor.Spec.Escalations[0].Steps = []volsyncv1alpha1.EscalationStep{volsyncv1alpha1.EscalationStepCheck}
+		r := newReconciler()
+		Expect(r.updateActiveUnlocks(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.Escalations[0].Step).To(Equal(volsyncv1alpha1.EscalationStepNotify))
+	})
+
+	It("should only count failed unlocks of classes without a ladder", func() {
+		monitor.Spec.Escalations = nil
+		r := newReconciler()
+		Expect(r.updateActiveUnlocks(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.Escalations).To(BeEmpty())
+		Expect(monitor.Status.TotalUnlocksFailed).To(Equal(int32(1)))
+	})
+})
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Condition reasons reported on FailurePatternSet status
+const (
+	reasonPatternsVerified          = "PatternsVerified"
+	reasonPatternVerificationFailed = "PatternVerificationFailed"
+)
+
+// FailurePatternSetReconciler verifies the patterns of a FailurePatternSet
+// against their examples and reports the result in its status
+type FailurePatternSetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=failurepatternsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=failurepatternsets/status,verbs=get;update;patch
+
+func (r *FailurePatternSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var set volsyncv1alpha1.FailurePatternSet
+	if err := r.Get(ctx, req.NamespacedName, &set); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get FailurePatternSet")
+		return ctrl.Result{}, err
+	}
+
+	original := set.DeepCopy()
+	setPatternSetStatus(&set, patternSets.get(&set))
+	if err := r.Status().Patch(ctx, &set, client.MergeFrom(original)); err != nil {
+		logger.Error(err, "Failed to update FailurePatternSet status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// setPatternSetStatus reports the verification of a compiled set
+func setPatternSetStatus(set *volsyncv1alpha1.FailurePatternSet, compiled compiledPatternSet) {
+	set.Status.ObservedGeneration = set.Generation
+	set.Status.Patterns = int32(len(set.Spec.Patterns))
+	set.Status.ValidPatterns = int32(len(compiled.Patterns))
+	set.Status.Failures = compiled.Failures
+
+	condition := metav1.Condition{
+		Type:               volsyncv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonPatternsVerified,
+		Message:            fmt.Sprintf("All %d patterns verified", len(compiled.Patterns)),
+		ObservedGeneration: set.Generation,
+	}
+	if len(compiled.Failures) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonPatternVerificationFailed
+		condition.Message = fmt.Sprintf("%d of %d patterns failed verification and are not used", len(compiled.Failures), len(set.Spec.Patterns))
+	}
+	meta.SetStatusCondition(&set.Status.Conditions, condition)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FailurePatternSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&volsyncv1alpha1.FailurePatternSet{}).
+		Complete(r)
+}
+
+// findVolSyncMonitorsForPatternSet finds the monitors referencing a pattern set
+func (r *VolSyncMonitorReconciler) findVolSyncMonitorsForPatternSet(ctx context.Context, obj client.Object) []ctrl.Request {
+	var monitorList volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitorList); err != nil {
+		return nil
+	}
+
+	var requests []ctrl.Request
+	for _, monitor := range monitorList.Items {
+		for _, name := range monitor.Spec.PatternSets {
+			if name == obj.GetName() {
+				requests = append(requests, ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: monitor.Name, Namespace: monitor.Namespace},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
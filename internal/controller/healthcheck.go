@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+const (
+	// healthCheckLabel is set on health check jobs to the name of their monitor
+	healthCheckLabel = "homelab.rafaribe.com/health-check"
+
+	// checkMessageLines and checkMessageBytes bound the check output kept in status
+	checkMessageLines = 5
+	checkMessageBytes = 1024
+
+	// unlockSettleTime is how long a check waits after an unlock for the
+	// ReplicationSource to sync, so that it does not take the lock of the mover
+	// VolSync starts again once the failed job is removed
+	unlockSettleTime = 15 * time.Minute
+)
+
+// Event and condition reasons for repository health checks
+const (
+	reasonRepositoryHealthy      = "RepositoryHealthy"
+	reasonRepositoryUnhealthy    = "RepositoryUnhealthy"
+	reasonRepositoryCheckFailed  = "RepositoryCheckFailed"
+	reasonAllRepositoriesHealthy = "AllRepositoriesHealthy"
+)
+
+// reconcileHealthChecks collects finished health check jobs and starts checks
+// of the repositories that are due, by schedule or by unlock count
+func (r *VolSyncMonitorReconciler) reconcileHealthChecks(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	spec := monitor.Spec.HealthChecks
+	if spec == nil {
+		monitor.Status.HealthChecks = nil
+		meta.RemoveStatusCondition(&monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeRepositoriesHealthy)
+		return nil
+	}
+
+	var schedule cron.Schedule
+	if spec.Schedule != "" {
+		var err error
+		if schedule, err = cron.ParseStandard(spec.Schedule); err != nil {
+			return fmt.Errorf("invalid health check schedule %q: %w", spec.Schedule, err)
+		}
+	}
+	if monitor.Status.HealthChecks == nil {
+		monitor.Status.HealthChecks = &volsyncv1alpha1.HealthCheckStatus{}
+	}
+	status := monitor.Status.HealthChecks
+
+	if err := r.collectHealthChecks(ctx, monitor); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	scheduled := false
+	if schedule != nil {
+		last := monitor.CreationTimestamp.Time
+		if status.LastScheduledTime != nil {
+			last = status.LastScheduledTime.Time
+		}
+		next := schedule.Next(last)
+		if !now.Before(next) {
+			scheduled = true
+			status.LastScheduledTime = &metav1.Time{Time: now}
+			next = schedule.Next(now)
+		}
+		status.NextScheduledTime = &metav1.Time{Time: next}
+	} else {
+		status.LastScheduledTime = nil
+		status.NextScheduledTime = nil
+	}
+
+	sources, err := r.listResticReplicationSources(ctx, monitor)
+	if err != nil {
+		return err
+	}
+	var unlocks map[string][]time.Time
+	if spec.AfterUnlocks > 0 {
+		if unlocks, err = r.succeededUnlocksBySource(ctx, monitor); err != nil {
+			return err
+		}
+	}
+
+	// Checks take an exclusive lock; they are limited like unlocks
+	running := 0
+	for _, entry := range status.Repositories {
+		if entry.CheckJobName != "" {
+			running++
+		}
+	}
+
+	var repositories []volsyncv1alpha1.RepositoryHealth
+	for i := range sources {
+		source := &sources[i]
+		entry := volsyncv1alpha1.RepositoryHealth{Namespace: source.GetNamespace(), ReplicationSource: source.GetName()}
+		if previous := findRepositoryHealth(status, entry.Namespace, entry.ReplicationSource); previous != nil {
+			entry = *previous
+		}
+
+		entry.UnlocksSinceCheck = 0
+		for _, completion := range unlocks[entry.Namespace+"/"+entry.ReplicationSource] {
+			if entry.CheckTime == nil || completion.After(entry.CheckTime.Time) {
+				entry.UnlocksSinceCheck++
+			}
+		}
+
+		due := scheduled || (spec.AfterUnlocks > 0 && entry.UnlocksSinceCheck >= spec.AfterUnlocks)
+		if entry.CheckJobName != "" {
+			entry.Pending = false
+		} else if due || entry.Pending {
+			reason, err := r.deferHealthCheck(ctx, monitor, source, unlocks[entry.Namespace+"/"+entry.ReplicationSource], running)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				entry.Pending = true
+				entry.Message = fmt.Sprintf("Check deferred: %s", reason)
+			} else {
+				entry.Pending = false
+				r.startHealthCheck(ctx, monitor, &entry)
+				if entry.CheckJobName != "" {
+					running++
+				}
+			}
+		}
+		repositories = append(repositories, entry)
+	}
+	status.Repositories = repositories
+
+	setRepositoriesHealthyCondition(monitor)
+	return nil
+}
+
+// succeededUnlocksBySource returns the completion times of the successful
+// unlocks recorded by the monitor, keyed by namespace/ReplicationSource
+func (r *VolSyncMonitorReconciler) succeededUnlocksBySource(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) (map[string][]time.Time, error) {
+	var records volsyncv1alpha1.UnlockRecordList
+	if err := r.List(ctx, &records, client.MatchingLabels{
+		recordMonitorLabel:          monitor.Name,
+		recordMonitorNamespaceLabel: monitor.Namespace,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list unlock records: %w", err)
+	}
+
+	unlocks := map[string][]time.Time{}
+	for _, record := range records.Items {
+		if record.Spec.Remediation != volsyncv1alpha1.RemediationActionUnlock ||
+			record.Status.Outcome != volsyncv1alpha1.UnlockOutcomeSucceeded ||
+			record.Status.CompletionTime == nil {
+			continue
+		}
+		source, direction := record.Spec.ObjectName, record.Spec.Direction
+		if source == "" {
+			// Records created before the object was kept on them
+			identity := r.jobIdentityFromJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: record.Spec.FailedJob.Name}})
+			source, direction = identity.ObjectName, identity.Direction
+		}
+		if direction == volsyncv1alpha1.VolSyncDirectionSource && source != "" {
+			key := record.Spec.FailedJob.Namespace + "/" + source
+			unlocks[key] = append(unlocks[key], record.Status.CompletionTime.Time)
+		}
+	}
+	return unlocks, nil
+}
+
+// deferHealthCheck returns why a due check of a repository has to wait: a
+// job of the ReplicationSource is running, the source has not synced since
+// a recent unlock, or spec.maxConcurrentUnlocks checks are running already.
+func (r *VolSyncMonitorReconciler) deferHealthCheck(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, source *unstructured.Unstructured, unlocks []time.Time, running int) (string, error) {
+	if limit := maxConcurrentUnlocks(monitor); running >= limit {
+		return fmt.Sprintf("%d of %d concurrent checks running", running, limit), nil
+	}
+
+	moverRunning, err := r.moverJobRunning(ctx, source.GetNamespace(), source.GetName(), "")
+	if err != nil {
+		return "", err
+	}
+	if moverRunning {
+		return "a job of the ReplicationSource is running", nil
+	}
+
+	var lastUnlock time.Time
+	for _, completion := range unlocks {
+		if completion.After(lastUnlock) {
+			lastUnlock = completion
+		}
+	}
+	if time.Since(lastUnlock) < unlockSettleTime {
+		value, _, _ := unstructured.NestedString(source.Object, "status", "lastSyncTime")
+		if lastSync, err := time.Parse(time.RFC3339, value); err != nil || !lastSync.After(lastUnlock) {
+			return "waiting for the ReplicationSource to sync after its last unlock", nil
+		}
+	}
+	return "", nil
+}
+
+// startHealthCheck creates the restic check job for a repository
+func (r *VolSyncMonitorReconciler) startHealthCheck(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, entry *volsyncv1alpha1.RepositoryHealth) {
+	logger := log.FromContext(ctx)
+
+	target, err := r.discoverReplicationSourceTarget(ctx, entry.Namespace, entry.ReplicationSource)
+	if err != nil {
+		entry.Message = fmt.Sprintf("Failed to discover repository: %v", err)
+		return
+	}
+	script := restic.CheckScript(monitor.Spec.HealthChecks.ReadDataSubset)
+	checkJob, err := r.newRepositoryJob(monitor, healthCheckJobKind, entry.Namespace, entry.ReplicationSource, target, script)
+	if err == nil {
+		err = r.Create(ctx, checkJob)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to create health check job", "replicationSource", entry.ReplicationSource, "namespace", entry.Namespace)
+		entry.Message = fmt.Sprintf("Failed to create health check job: %v", err)
+		return
+	}
+
+	logger.Info("Created health check job", "job", checkJob.Name, "namespace", checkJob.Namespace)
+	entry.CheckJobName = checkJob.Name
+	entry.Message = fmt.Sprintf("Checking repository with job %s", checkJob.Name)
+}
+
+// collectHealthChecks records the results of finished health check jobs and deletes the jobs
+func (r *VolSyncMonitorReconciler) collectHealthChecks(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	logger := log.FromContext(ctx)
+
+	jobs, err := r.listRepositoryJobs(ctx, monitor, healthCheckJobKind)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, job := range jobs {
+		existing[job.Namespace+"/"+job.Name] = true
+
+		succeeded := r.isJobSucceeded(job)
+		if !succeeded && !r.isJobFailed(&job) {
+			continue
+		}
+
+		entry := findRepositoryHealth(monitor.Status.HealthChecks, job.Namespace, job.Labels[repositorySourceLabel])
+		if entry != nil && entry.CheckJobName == job.Name {
+			// Without logs a failed check is reported as an error
+			output, _ := r.latestJobPodLogs(ctx, job)
+			r.applyHealthCheckResult(monitor, entry, job.Name, succeeded, output)
+		}
+
+		if err := r.deleteRepositoryJob(ctx, job); err != nil {
+			logger.Error(err, "Failed to delete health check job", "job", job.Name, "namespace", job.Namespace)
+		}
+	}
+
+	// Jobs removed before their result was collected, for example by their TTL
+	for i := range monitor.Status.HealthChecks.Repositories {
+		entry := &monitor.Status.HealthChecks.Repositories[i]
+		if entry.CheckJobName != "" && !existing[entry.Namespace+"/"+entry.CheckJobName] {
+			jobName := entry.CheckJobName
+			r.applyHealthCheckResult(monitor, entry, jobName, false, "")
+			entry.Message = fmt.Sprintf("Health check job %s was deleted before it finished", jobName)
+		}
+	}
+
+	return nil
+}
+
+// applyHealthCheckResult records the outcome of a health check job in status,
+// metrics and events
+func (r *VolSyncMonitorReconciler) applyHealthCheckResult(monitor *volsyncv1alpha1.VolSyncMonitor, entry *volsyncv1alpha1.RepositoryHealth, jobName string, succeeded bool, output string) {
+	entry.CheckJobName = ""
+	entry.CheckTime = &metav1.Time{Time: time.Now()}
+	entry.UnlocksSinceCheck = 0
+
+	tail := helpers.TailLines(output, checkMessageLines, checkMessageBytes)
+	switch {
+	case succeeded:
+		entry.Result = volsyncv1alpha1.HealthCheckResultHealthy
+		entry.Message = "No errors were found"
+		helpers.SetRepositoryHealthy(entry.Namespace, entry.ReplicationSource, true)
+	case restic.CheckFoundErrors(output):
+		entry.Result = volsyncv1alpha1.HealthCheckResultUnhealthy
+		entry.Message = fmt.Sprintf("restic check found errors: %s", tail)
+		helpers.SetRepositoryHealthy(entry.Namespace, entry.ReplicationSource, false)
+	default:
+		entry.Result = volsyncv1alpha1.HealthCheckResultError
+		entry.Message = fmt.Sprintf("Health check job %s failed", jobName)
+		if tail != "" {
+			entry.Message += ": " + tail
+		}
+	}
+	helpers.RecordRepositoryCheck(entry.Namespace, entry.ReplicationSource, string(entry.Result))
+
+	if r.Recorder == nil {
+		return
+	}
+	source := fmt.Sprintf("%s/%s", entry.Namespace, entry.ReplicationSource)
+	switch entry.Result {
+	case volsyncv1alpha1.HealthCheckResultHealthy:
+		r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonRepositoryHealthy,
+			"Repository of ReplicationSource %s is healthy", source)
+	case volsyncv1alpha1.HealthCheckResultUnhealthy:
+		r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonRepositoryUnhealthy,
+			"Repository of ReplicationSource %s contains errors: %s", source, tail)
+	default:
+		r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonRepositoryCheckFailed,
+			"Health check of the repository of ReplicationSource %s failed: %s", source, entry.Message)
+	}
+}
+
+// setRepositoriesHealthyCondition summarizes the last check of every repository
+func setRepositoriesHealthyCondition(monitor *volsyncv1alpha1.VolSyncMonitor) {
+	var unhealthy []string
+	for _, entry := range monitor.Status.HealthChecks.Repositories {
+		if entry.Result == volsyncv1alpha1.HealthCheckResultUnhealthy {
+			unhealthy = append(unhealthy, entry.Namespace+"/"+entry.ReplicationSource)
+		}
+	}
+
+	if len(unhealthy) > 0 {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeRepositoriesHealthy, metav1.ConditionFalse, reasonRepositoryUnhealthy,
+			fmt.Sprintf("Repositories with errors: %s", strings.Join(unhealthy, ", ")))
+		return
+	}
+	setCondition(monitor, volsyncv1alpha1.ConditionTypeRepositoriesHealthy, metav1.ConditionTrue, reasonAllRepositoriesHealthy,
+		"No repository check found errors")
+}
+
+// findRepositoryHealth returns the status entry of a ReplicationSource, if any
+func findRepositoryHealth(status *volsyncv1alpha1.HealthCheckStatus, namespace, source string) *volsyncv1alpha1.RepositoryHealth {
+	if status == nil {
+		return nil
+	}
+	for i := range status.Repositories {
+		if status.Repositories[i].Namespace == namespace && status.Repositories[i].ReplicationSource == source {
+			return &status.Repositories[i]
+		}
+	}
+	return nil
+}
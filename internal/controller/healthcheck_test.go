@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+// succeededUnlockRecord returns a successful unlock record of a mover job
+func succeededUnlockRecord(name, namespace, failedJob string, completion time.Time) *volsyncv1alpha1.UnlockRecord {
+	return &volsyncv1alpha1.UnlockRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				recordMonitorLabel:          "monitor",
+				recordMonitorNamespaceLabel: "system",
+			},
+		},
+		Spec: volsyncv1alpha1.UnlockRecordSpec{
+			FailedJob:   volsyncv1alpha1.FailedJobReference{Name: failedJob, Namespace: namespace},
+			Remediation: volsyncv1alpha1.RemediationActionUnlock,
+		},
+		Status: volsyncv1alpha1.UnlockRecordStatus{
+			Outcome:        volsyncv1alpha1.UnlockOutcomeSucceeded,
+			CompletionTime: &metav1.Time{Time: completion},
+		},
+	}
+}
+
+var _ = Describe("Repository health checks", func() {
+	var (
+		ctx      context.Context
+		monitor  *volsyncv1alpha1.VolSyncMonitor
+		recorder *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:           true,
+				UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		r := newFakeReconciler(objects...)
+		r.Recorder = recorder
+		return r
+	}
+
+	checkJobs := func(r *VolSyncMonitorReconciler) []batchv1.Job {
+		var jobList batchv1.JobList
+		Expect(r.List(ctx, &jobList, client.MatchingLabels{healthCheckLabel: "monitor"})).To(Succeed())
+		return jobList.Items
+	}
+
+	It("should check every restic repository when the schedule is due", func() {
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{Schedule: "0 3 * * 0", ReadDataSubset: "5%"}
+		r := newReconciler(
+			newReplicationSource("media", "plex", "plex-restic"),
+			newReplicationSource("downloads", "sonarr", "sonarr-restic"),
+		)
+
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+
+		jobs := checkJobs(r)
+		Expect(jobs).To(HaveLen(2))
+		for _, job := range jobs {
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"-c", restic.CheckScript("5%")}))
+			Expect(*job.Spec.BackoffLimit).To(BeZero())
+		}
+
+		status := monitor.Status.HealthChecks
+		Expect(status.LastScheduledTime).NotTo(BeNil())
+		Expect(status.NextScheduledTime.Time).To(BeTemporally(">", time.Now()))
+		Expect(status.Repositories).To(HaveLen(2))
+		Expect(status.Repositories[0].CheckJobName).NotTo(BeEmpty())
+		Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeRepositoriesHealthy)).To(BeTrue())
+
+		By("not starting another check before the next run")
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(HaveLen(2))
+	})
+
+	It("should check a repository after the configured number of unlocks", func() {
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{AfterUnlocks: 2}
+		lastCheck := time.Now().Add(-24 * time.Hour)
+		monitor.Status.HealthChecks = &volsyncv1alpha1.HealthCheckStatus{
+			Repositories: []volsyncv1alpha1.RepositoryHealth{
+				{Namespace: "media", ReplicationSource: "plex", CheckTime: &metav1.Time{Time: lastCheck}},
+			},
+		}
+		r := newReconciler(
+			newReplicationSource("media", "plex", "plex-restic"),
+			newReplicationSource("downloads", "sonarr", "sonarr-restic"),
+			succeededUnlockRecord("plex-1", "media", "volsync-src-plex", lastCheck.Add(-time.Hour)),
+			succeededUnlockRecord("plex-2", "media", "volsync-src-plex", lastCheck.Add(time.Hour)),
+			succeededUnlockRecord("sonarr-1", "downloads", "volsync-src-sonarr", lastCheck),
+		)
+
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(BeEmpty())
+		Expect(monitor.Status.HealthChecks.NextScheduledTime).To(BeNil())
+		Expect(monitor.Status.HealthChecks.Repositories[0].UnlocksSinceCheck).To(Equal(int32(1)))
+		Expect(monitor.Status.HealthChecks.Repositories[1].UnlocksSinceCheck).To(Equal(int32(1)))
+
+		Expect(r.Create(ctx, succeededUnlockRecord("plex-3", "media", "volsync-src-plex", time.Now().Add(-time.Hour)))).To(Succeed())
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+
+		jobs := checkJobs(r)
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].Labels).To(HaveKeyWithValue(repositorySourceLabel, "plex"))
+		Expect(monitor.Status.HealthChecks.Repositories[1].UnlocksSinceCheck).To(Equal(int32(2)))
+	})
+
+	It("should defer checks while a job uses the repository", func() {
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{Schedule: "0 3 * * 0"}
+		mover := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"},
+			Status:     batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(newReplicationSource("media", "plex", "plex-restic"), mover)
+
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(BeEmpty())
+		entry := monitor.Status.HealthChecks.Repositories[0]
+		Expect(entry.Pending).To(BeTrue())
+		Expect(entry.Message).To(ContainSubstring("a job of the ReplicationSource is running"))
+
+		By("starting the check once the mover finished")
+		mover.Status.Active = 0
+		mover.Status.Succeeded = 1
+		Expect(r.Status().Update(ctx, mover)).To(Succeed())
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(HaveLen(1))
+		Expect(monitor.Status.HealthChecks.Repositories[0].Pending).To(BeFalse())
+	})
+
+	It("should run at most maxConcurrentUnlocks checks at once", func() {
+		monitor.Spec.MaxConcurrentUnlocks = 1
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{Schedule: "0 3 * * 0"}
+		r := newReconciler(
+			newReplicationSource("media", "plex", "plex-restic"),
+			newReplicationSource("downloads", "sonarr", "sonarr-restic"),
+		)
+
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(HaveLen(1))
+		Expect(monitor.Status.HealthChecks.Repositories[1].Pending).To(BeTrue())
+		Expect(monitor.Status.HealthChecks.Repositories[1].Message).To(ContainSubstring("1 of 1 concurrent checks running"))
+	})
+
+	It("should wait for the ReplicationSource to sync after an unlock", func() {
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{AfterUnlocks: 1}
+		unlocked := time.Now().Add(-time.Minute)
+		source := newReplicationSource("media", "plex", "plex-restic")
+		r := newReconciler(source, succeededUnlockRecord("plex-1", "media", "volsync-src-plex", unlocked))
+
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(BeEmpty())
+		Expect(monitor.Status.HealthChecks.Repositories[0].Message).To(ContainSubstring("sync after its last unlock"))
+
+		Expect(unstructured.SetNestedField(source.Object, time.Now().Format(time.RFC3339), "status", "lastSyncTime")).To(Succeed())
+		Expect(r.Update(ctx, source)).To(Succeed())
+		Expect(r.reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(checkJobs(r)).To(HaveLen(1))
+	})
+
+	It("should reject an invalid schedule", func() {
+		monitor.Spec.HealthChecks = &volsyncv1alpha1.HealthCheckSpec{Schedule: "weekly"}
+		Expect(newReconciler().reconcileHealthChecks(ctx, monitor)).To(MatchError(ContainSubstring("invalid health check schedule")))
+	})
+
+	It("should clear the status when health checks are disabled", func() {
+		monitor.Status.HealthChecks = &volsyncv1alpha1.HealthCheckStatus{}
+		setCondition(monitor, volsyncv1alpha1.ConditionTypeRepositoriesHealthy, metav1.ConditionTrue, reasonAllRepositoriesHealthy, "")
+
+		Expect(newReconciler().reconcileHealthChecks(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.HealthChecks).To(BeNil())
+		Expect(meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeRepositoriesHealthy)).To(BeNil())
+	})
+
+	Describe("Check results", func() {
+		var entry *volsyncv1alpha1.RepositoryHealth
+
+		BeforeEach(func() {
+			monitor.Status.HealthChecks = &volsyncv1alpha1.HealthCheckStatus{
+				Repositories: []volsyncv1alpha1.RepositoryHealth{
+					{Namespace: "media", ReplicationSource: "plex", CheckJobName: "health-check-plex-1", UnlocksSinceCheck: 3},
+				},
+			}
+			entry = &monitor.Status.HealthChecks.Repositories[0]
+		})
+
+		It("should report healthy repositories", func() {
+			newReconciler().applyHealthCheckResult(monitor, entry, "health-check-plex-1", true, "no errors were found\n")
+
+			Expect(entry.Result).To(Equal(volsyncv1alpha1.HealthCheckResultHealthy))
+			Expect(entry.CheckJobName).To(BeEmpty())
+			Expect(entry.CheckTime).NotTo(BeNil())
+			Expect(entry.UnlocksSinceCheck).To(BeZero())
+			Expect(recorder.Events).To(Receive(ContainSubstring("Normal RepositoryHealthy")))
+		})
+
+		It("should report repositories with errors", func() {
+			output := "check snapshots, trees and blobs\nerror for tree 9c8d7e6f: not found\nFatal: repository contains errors\n"
+			newReconciler().applyHealthCheckResult(monitor, entry, "health-check-plex-1", false, output)
+
+			Expect(entry.Result).To(Equal(volsyncv1alpha1.HealthCheckResultUnhealthy))
+			Expect(entry.Message).To(ContainSubstring("error for tree 9c8d7e6f"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Warning RepositoryUnhealthy")))
+
+			setRepositoriesHealthyCondition(monitor)
+			condition := meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeRepositoriesHealthy)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("media/plex"))
+		})
+
+		It("should report checks that could not run", func() {
+			output := "unable to create lock in backend: repository is already locked exclusively by PID 7\n"
+			newReconciler().applyHealthCheckResult(monitor, entry, "health-check-plex-1", false, output)
+
+			Expect(entry.Result).To(Equal(volsyncv1alpha1.HealthCheckResultError))
+			Expect(entry.Message).To(ContainSubstring("already locked"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Warning RepositoryCheckFailed")))
+		})
+
+		It("should report check jobs deleted before they finished", func() {
+			Expect(newReconciler().collectHealthChecks(ctx, monitor)).To(Succeed())
+
+			Expect(entry.Result).To(Equal(volsyncv1alpha1.HealthCheckResultError))
+			Expect(entry.Message).To(ContainSubstring("deleted before it finished"))
+		})
+	})
+})
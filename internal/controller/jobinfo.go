@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+const (
+	// volsyncCreatedByLabel is set to "volsync" on the objects VolSync creates
+	volsyncCreatedByLabel = "app.kubernetes.io/created-by"
+
+	// appLabel names the app of a job when set
+	appLabel = "app"
+
+	// appNameLabel and appInstanceLabel are the recommended Kubernetes app labels
+	appNameLabel     = "app.kubernetes.io/name"
+	appInstanceLabel = "app.kubernetes.io/instance"
+
+	// volsyncSourceLabel and volsyncDestinationLabel name the
+	// ReplicationSource or ReplicationDestination of a job
+	volsyncSourceLabel      = "volsync.backube/replicationsource"
+	volsyncDestinationLabel = "volsync.backube/replicationdestination"
+
+	// appAnnotation, objectAnnotation and directionAnnotation keep the
+	// identity of the failed job on its unlock job
+	appAnnotation       = "homelab.rafaribe.com/app"
+	objectAnnotation    = "homelab.rafaribe.com/volsync-object"
+	directionAnnotation = "homelab.rafaribe.com/direction"
+)
+
+// appLabels are the labels naming the app of an object, in order of preference
+var appLabels = []string{appNameLabel, appInstanceLabel, appLabel}
+
+// jobIdentity is the app a mover job belongs to and the object that ran it:
+// a ReplicationSource or ReplicationDestination, or a k8up Backup, Check or Prune
+type jobIdentity struct {
+	App        string
+	ObjectName string
+	Direction  volsyncv1alpha1.VolSyncDirection
+	// Provider is the backup operator that ran the job, empty for VolSync
+	Provider volsyncv1alpha1.BackupProvider
+}
+
+// moverJobNamePrefixes are the segments VolSync puts between the job name
+// prefix and the direction, e.g. volsync-rclone-src-app. Restic jobs have none.
+var moverJobNamePrefixes = []string{"rsync-tls-", "rsync-", "rclone-", "kopia-", "syncthing-"}
+
+// appNameSuffixes are common suffixes of ReplicationSource names that are
+// not part of the app name, e.g. prowlarr-nfs or plex-backup
+var appNameSuffixes = map[string]bool{
+	"nfs": true, "pvc": true, "backup": true, "restore": true, "volsync": true,
+	"restic": true, "kopia": true, "rclone": true, "rsync": true,
+	"s3": true, "r2": true, "b2": true, "minio": true, "gcs": true, "azure": true,
+	"local": true, "remote": true, "repo": true, "data": true, "config": true,
+}
+
+// isVolSyncJob reports whether a job is a VolSync mover job: it carries the
+// VolSync created-by label or names its VolSync object in a label, is owned
+// by a ReplicationSource or ReplicationDestination, or is named like a mover
+// job with the configured job name prefix. The prefix only selects jobs
+// recognised by their name.
+func (r *VolSyncMonitorReconciler) isVolSyncJob(job *batchv1.Job) bool {
+	if job.Labels[volsyncCreatedByLabel] == "volsync" {
+		return true
+	}
+	if job.Labels[volsyncSourceLabel] != "" || job.Labels[volsyncDestinationLabel] != "" {
+		return true
+	}
+	if _, ok := volsyncOwner(job); ok {
+		return true
+	}
+	_, _, ok := r.parseMoverJobName(job.Name)
+	return ok
+}
+
+// isJobFailed reports whether a job failed: it has the Failed condition, or
+// more of its pods failed than its backoff limit allows and none is running,
+// which covers the time before the job controller sets the condition
+func (r *VolSyncMonitorReconciler) isJobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobFailed:
+			return true
+		case batchv1.JobComplete:
+			return false
+		}
+	}
+
+	backoffLimit := int32(0)
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+	return job.Status.Failed > backoffLimit && job.Status.Active == 0 && job.Status.Succeeded == 0
+}
+
+// resolveVolSyncJobIdentity resolves the app, VolSync object and direction
+// of a VolSync job. The owning ReplicationSource or ReplicationDestination is read for its
+// app labels, so that the app of an object named plex-nfs is taken from the
+// object rather than guessed from its name.
+func (r *VolSyncMonitorReconciler) resolveVolSyncJobIdentity(ctx context.Context, job *batchv1.Job) jobIdentity {
+	identity := r.jobIdentityFromJob(job)
+
+	owner, ok := volsyncOwner(job)
+	if !ok || appFromLabels(job.Labels) != "" {
+		return identity
+	}
+	obj, err := r.getVolSyncObject(ctx, job.Namespace, owner.Kind, owner.Name)
+	if err != nil {
+		log.FromContext(ctx).V(1).Info("Failed to read owner of job", "job", job.Name, "owner", owner.Name, "error", err.Error())
+		return identity
+	}
+	if app := appFromLabels(obj.GetLabels()); app != "" {
+		identity.App = app
+	}
+	return identity
+}
+
+// jobIdentityFromJob resolves the identity of a job from the job alone: the
+// owner reference first, then the app.kubernetes.io and volsync.backube
+// labels, and only then the job name
+func (r *VolSyncMonitorReconciler) jobIdentityFromJob(job *batchv1.Job) jobIdentity {
+	var identity jobIdentity
+	if owner, ok := volsyncOwner(job); ok {
+		identity.ObjectName = owner.Name
+		identity.Direction = directionOfKind(owner.Kind)
+	} else if name := job.Labels[volsyncSourceLabel]; name != "" {
+		identity.ObjectName = name
+		identity.Direction = volsyncv1alpha1.VolSyncDirectionSource
+	} else if name := job.Labels[volsyncDestinationLabel]; name != "" {
+		identity.ObjectName = name
+		identity.Direction = volsyncv1alpha1.VolSyncDirectionDestination
+	} else if direction, objectName, ok := r.parseMoverJobName(job.Name); ok {
+		identity.ObjectName = objectName
+		identity.Direction = directionOfName(direction)
+	} else {
+		identity.ObjectName = r.objectNameFromJobName(job.Name)
+	}
+
+	// The direction is known from the name even when the object is not
+	if identity.Direction == "" {
+		if direction, _, ok := r.parseMoverJobName(job.Name); ok {
+			identity.Direction = directionOfName(direction)
+		}
+	}
+
+	identity.App = appFromLabels(job.Labels)
+	if identity.App == "" {
+		identity.App = r.guessAppNameFromObjectName(identity.ObjectName)
+	}
+	return identity
+}
+
+// extractAppInfoFromJob returns the app of a VolSync job and the name of its
+// ReplicationSource or ReplicationDestination. The owner reference and the
+// labels are preferred over the job name.
+func (r *VolSyncMonitorReconciler) extractAppInfoFromJob(job *batchv1.Job) (string, string) {
+	identity := r.jobIdentityFromJob(job)
+	return identity.App, identity.ObjectName
+}
+
+// unlockJobIdentity returns the identity of the failed job an unlock job
+// handles. Unlock jobs created before the identity was kept on them fall back
+// to the name of the failed job.
+func (r *VolSyncMonitorReconciler) unlockJobIdentity(unlockJob batchv1.Job, failedJobName string) jobIdentity {
+	identity := jobIdentity{
+		App:        unlockJob.Annotations[appAnnotation],
+		ObjectName: unlockJob.Annotations[objectAnnotation],
+		Direction:  volsyncv1alpha1.VolSyncDirection(unlockJob.Annotations[directionAnnotation]),
+	}
+	if identity.App == "" {
+		// The failed-job annotation holds namespace/name
+		failedJobName = failedJobName[strings.LastIndex(failedJobName, "/")+1:]
+		identity = r.jobIdentityFromJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: failedJobName}})
+	}
+	return identity
+}
+
+// appFromLabels returns the app named by the labels of an object. Values that
+// name VolSync itself rather than an app are skipped.
+func appFromLabels(labels map[string]string) string {
+	for _, label := range appLabels {
+		if value := labels[label]; value != "" && value != "volsync" {
+			return value
+		}
+	}
+	return ""
+}
+
+// directionOfKind returns the direction of jobs run by a VolSync object kind
+func directionOfKind(kind string) volsyncv1alpha1.VolSyncDirection {
+	if kind == "ReplicationDestination" {
+		return volsyncv1alpha1.VolSyncDirectionDestination
+	}
+	return volsyncv1alpha1.VolSyncDirectionSource
+}
+
+// directionOfName returns the direction of a "src" or "dst" job name segment
+func directionOfName(direction string) volsyncv1alpha1.VolSyncDirection {
+	if direction == "dst" {
+		return volsyncv1alpha1.VolSyncDirectionDestination
+	}
+	return volsyncv1alpha1.VolSyncDirectionSource
+}
+
+// extractAppName returns the app of a VolSync job known only by its name
+func (r *VolSyncMonitorReconciler) extractAppName(jobName string) string {
+	return r.guessAppNameFromObjectName(r.objectNameFromJobName(jobName))
+}
+
+// guessAppNameFromObjectName strips common suffixes such as -nfs or -backup
+// from the name of a ReplicationSource or ReplicationDestination
+func (r *VolSyncMonitorReconciler) guessAppNameFromObjectName(objectName string) string {
+	parts := strings.Split(objectName, "-")
+	for len(parts) > 1 && appNameSuffixes[parts[len(parts)-1]] {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, "-")
+}
+
+// objectNameFromJobName returns the ReplicationSource or ReplicationDestination
+// name in a VolSync job name, or the job name without the prefix when it does
+// not follow the mover job naming
+func (r *VolSyncMonitorReconciler) objectNameFromJobName(jobName string) string {
+	if _, objectName, ok := r.parseMoverJobName(jobName); ok {
+		return objectName
+	}
+	if name := strings.TrimPrefix(jobName, r.settings().JobNamePrefix); name != "" {
+		return name
+	}
+	return jobName
+}
+
+// parseMoverJobName splits a VolSync mover job name such as volsync-src-app or
+// volsync-rclone-dst-app into its direction, "src" or "dst", and object name
+func (r *VolSyncMonitorReconciler) parseMoverJobName(jobName string) (string, string, bool) {
+	rest := strings.TrimPrefix(jobName, r.settings().JobNamePrefix)
+	if rest == jobName {
+		return "", "", false
+	}
+	for _, prefix := range moverJobNamePrefixes {
+		if trimmed := strings.TrimPrefix(rest, prefix); trimmed != rest {
+			rest = trimmed
+			break
+		}
+	}
+	for _, direction := range []string{"src", "dst"} {
+		if objectName := strings.TrimPrefix(rest, direction+"-"); objectName != rest && objectName != "" {
+			return direction, objectName, true
+		}
+	}
+	return "", "", false
+}
+
+// volsyncOwner returns the ReplicationSource or ReplicationDestination owning a job
+func volsyncOwner(job *batchv1.Job) (metav1.OwnerReference, bool) {
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind != "ReplicationSource" && owner.Kind != "ReplicationDestination" {
+			continue
+		}
+		if strings.HasPrefix(owner.APIVersion, volsyncGroupVersion.Group+"/") {
+			return owner, true
+		}
+	}
+	return metav1.OwnerReference{}, false
+}
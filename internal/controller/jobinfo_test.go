@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+)
+
+var _ = Describe("VolSync job info", func() {
+	var reconciler *VolSyncMonitorReconciler
+
+	BeforeEach(func() {
+		reconciler = &VolSyncMonitorReconciler{}
+	})
+
+	It("should parse the job names of all movers", func() {
+		for name, want := range map[string][2]string{
+			"volsync-src-plex":                     {"src", "plex"},
+			"volsync-dst-plex-restore":             {"dst", "plex-restore"},
+			"volsync-rclone-src-sonarr":            {"src", "sonarr"},
+			"volsync-rsync-tls-dst-radarr":         {"dst", "radarr"},
+			"volsync-kopia-src-home-assistant-nfs": {"src", "home-assistant-nfs"},
+		} {
+			direction, objectName, ok := reconciler.parseMoverJobName(name)
+			Expect(ok).To(BeTrue(), name)
+			Expect([2]string{direction, objectName}).To(Equal(want), name)
+		}
+
+		for _, name := range []string{"volsync-unlock-plex-1234", "volsync-src-", "backup-src-plex"} {
+			_, _, ok := reconciler.parseMoverJobName(name)
+			Expect(ok).To(BeFalse(), name)
+		}
+	})
+
+	It("should only select unlabelled jobs by the configured prefix", func() {
+		cfg := config.Default()
+		cfg.VolSyncMonitor.JobNamePrefix = "backup-"
+		reconciler.Config = config.NewStore(cfg)
+
+		Expect(reconciler.isVolSyncJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "backup-src-plex"}})).To(BeTrue())
+		Expect(reconciler.isVolSyncJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex"}})).To(BeFalse())
+		Expect(reconciler.isVolSyncJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:   "volsync-src-plex",
+			Labels: map[string]string{volsyncCreatedByLabel: "volsync"},
+		}})).To(BeTrue())
+	})
+
+	It("should not take unlock jobs for VolSync jobs", func() {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-unlock-volsync-src-plex-3f2a9c1b7e"}}
+		Expect(reconciler.isVolSyncJob(job)).To(BeFalse())
+	})
+
+	It("should prefer the owning ReplicationSource over the job name", func() {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "mover-job",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "volsync.backube/v1alpha1", Kind: "ReplicationSource", Name: "home-assistant-nfs",
+			}},
+		}}
+		Expect(reconciler.isVolSyncJob(job)).To(BeTrue())
+		appName, objectName := reconciler.extractAppInfoFromJob(job)
+		Expect(appName).To(Equal("home-assistant"))
+		Expect(objectName).To(Equal("home-assistant-nfs"))
+	})
+
+	It("should resolve the direction from the owner, the labels and the name", func() {
+		for _, test := range []struct {
+			job  *batchv1.Job
+			want jobIdentity
+		}{{
+			job:  &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-prowlarr-nfs"}},
+			want: jobIdentity{App: "prowlarr", ObjectName: "prowlarr-nfs", Direction: volsyncv1alpha1.VolSyncDirectionSource},
+		}, {
+			job: &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name: "volsync-dst-prowlarr-bootstrap",
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "volsync.backube/v1alpha1", Kind: "ReplicationDestination", Name: "prowlarr-dst",
+				}},
+			}},
+			want: jobIdentity{App: "prowlarr-dst", ObjectName: "prowlarr-dst", Direction: volsyncv1alpha1.VolSyncDirectionDestination},
+		}, {
+			job: &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name: "restore-job",
+				Labels: map[string]string{
+					volsyncDestinationLabel:  "radarr-restore",
+					"app.kubernetes.io/name": "radarr",
+				},
+			}},
+			want: jobIdentity{App: "radarr", ObjectName: "radarr-restore", Direction: volsyncv1alpha1.VolSyncDirectionDestination},
+		}, {
+			job: &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Name:   "volsync-src-plex",
+				Labels: map[string]string{"app.kubernetes.io/name": "volsync", "app.kubernetes.io/instance": "plex-server"},
+			}},
+			want: jobIdentity{App: "plex-server", ObjectName: "plex", Direction: volsyncv1alpha1.VolSyncDirectionSource},
+		}} {
+			Expect(reconciler.jobIdentityFromJob(test.job)).To(Equal(test.want), test.job.Name)
+		}
+	})
+
+	It("should take the app from the labels of the owning ReplicationSource", func() {
+		source := newReplicationSource("media", "config-nfs", "plex-restic")
+		source.SetLabels(map[string]string{"app.kubernetes.io/name": "plex"})
+		reconciler.Client = newFakeClient(source)
+
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      "volsync-src-config-nfs",
+			Namespace: "media",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "volsync.backube/v1alpha1", Kind: "ReplicationSource", Name: "config-nfs",
+			}},
+		}}
+		identity := reconciler.resolveJobIdentity(context.Background(), job)
+		Expect(identity).To(Equal(jobIdentity{App: "plex", ObjectName: "config-nfs", Direction: volsyncv1alpha1.VolSyncDirectionSource}))
+
+		By("keeping the identity on the unlock job")
+		unlockJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			appAnnotation: identity.App, objectAnnotation: identity.ObjectName, directionAnnotation: string(identity.Direction),
+		}}}
+		Expect(reconciler.unlockJobIdentity(unlockJob, "media/volsync-src-config-nfs")).To(Equal(identity))
+		Expect(reconciler.unlockJobIdentity(batchv1.Job{}, "media/volsync-src-config-nfs").App).To(Equal("config"))
+	})
+
+	It("should keep the app name of jobs that do not follow the mover naming", func() {
+		Expect(reconciler.extractAppName("volsync-prowlarr-backup")).To(Equal("prowlarr"))
+	})
+
+	It("should not treat failed pods that will be retried as a failed job", func() {
+		job := &batchv1.Job{
+			Spec:   batchv1.JobSpec{BackoffLimit: helpers.Int32Ptr(2)},
+			Status: batchv1.JobStatus{Failed: 2},
+		}
+		Expect(reconciler.isJobFailed(job)).To(BeFalse())
+
+		job.Status.Failed = 3
+		Expect(reconciler.isJobFailed(job)).To(BeTrue())
+
+		job.Status.Active = 1
+		Expect(reconciler.isJobFailed(job)).To(BeFalse())
+	})
+})
@@ -0,0 +1,433 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// k8upGroupVersion is the API group of the k8up objects, which are read as
+// unstructured objects so that k8up does not need to be installed
+var k8upGroupVersion = schema.GroupVersion{Group: "k8up.io", Version: "v1"}
+
+const (
+	// k8upTypeLabel names the kind of k8up object a job runs, e.g. "backup"
+	k8upTypeLabel = "k8up.io/type"
+	// k8upJobLabel is set to "true" on jobs of older k8up releases
+	k8upJobLabel = "k8upjob"
+)
+
+// k8upJobKinds are the k8up objects whose restic jobs are monitored, by the
+// value of the type label of their jobs
+var k8upJobKinds = map[string]string{
+	"backup": "Backup",
+	"check":  "Check",
+	"prune":  "Prune",
+}
+
+// k8upBackendSecrets are the secret references of the k8up backends and the
+// restic environment variables k8up sets from them
+var k8upBackendSecrets = []struct {
+	Backend string
+	Field   string
+	Env     string
+}{
+	{"s3", "accessKeyIDSecretRef", "AWS_ACCESS_KEY_ID"},
+	{"s3", "secretAccessKeySecretRef", "AWS_SECRET_ACCESS_KEY"},
+	{"b2", "accountIDSecretRef", "B2_ACCOUNT_ID"},
+	{"b2", "accountKeySecretRef", "B2_ACCOUNT_KEY"},
+	{"azure", "accountNameSecretRef", "AZURE_ACCOUNT_NAME"},
+	{"azure", "accountKeySecretRef", "AZURE_ACCOUNT_KEY"},
+	{"gcs", "projectIDSecretRef", "GOOGLE_PROJECT_ID"},
+	{"gcs", "accessTokenSecretRef", "GOOGLE_ACCESS_TOKEN"},
+	{"rest", "userSecretRef", "USER"},
+	// k8up spells the field this way
+	{"rest", "passwordSecretReg", "PASSWORD"},
+}
+
+//+kubebuilder:rbac:groups=k8up.io,resources=backups;checks;prunes;schedules,verbs=get;list;watch
+
+// k8upProvider handles the restic jobs k8up runs for Backups, Checks and Prunes
+type k8upProvider struct {
+	r *VolSyncMonitorReconciler
+}
+
+func (p k8upProvider) name() volsyncv1alpha1.BackupProvider {
+	return volsyncv1alpha1.BackupProviderK8up
+}
+
+// ownsJob reports whether a job is owned by a k8up Backup, Check or Prune,
+// or carries the k8up job labels
+func (p k8upProvider) ownsJob(job *batchv1.Job) bool {
+	if _, ok := k8upOwner(job); ok {
+		return true
+	}
+	if _, ok := k8upJobKinds[job.Labels[k8upTypeLabel]]; ok {
+		return true
+	}
+	return job.Labels[k8upJobLabel] == "true"
+}
+
+// jobIdentity resolves the app of a k8up job from the labels of the job, of
+// its k8up object or the name of the Schedule that created the object
+func (p k8upProvider) jobIdentity(ctx context.Context, job *batchv1.Job) jobIdentity {
+	identity := jobIdentity{
+		ObjectName: job.Name,
+		Direction:  volsyncv1alpha1.VolSyncDirectionSource,
+		Provider:   volsyncv1alpha1.BackupProviderK8up,
+		App:        appFromLabels(job.Labels),
+	}
+
+	owner, ok := k8upOwner(job)
+	if !ok {
+		if identity.App == "" {
+			identity.App = p.r.guessAppNameFromObjectName(identity.ObjectName)
+		}
+		return identity
+	}
+	identity.ObjectName = owner.Name
+	if identity.App != "" {
+		return identity
+	}
+
+	obj, err := p.r.getK8upObject(ctx, job.Namespace, owner.Kind, owner.Name)
+	if err != nil {
+		log.FromContext(ctx).V(1).Info("Failed to read k8up owner of job", "job", job.Name, "owner", owner.Name, "error", err.Error())
+	} else if app := appFromLabels(obj.GetLabels()); app != "" {
+		identity.App = app
+	} else if schedule, ok := k8upScheduleOf(obj); ok {
+		identity.App = schedule
+	}
+	if identity.App == "" {
+		identity.App = p.r.guessAppNameFromObjectName(identity.ObjectName)
+	}
+	return identity
+}
+
+// moverType returns restic, the only mover k8up runs
+func (p k8upProvider) moverType(ctx context.Context, job batchv1.Job) volsyncv1alpha1.MoverType {
+	return volsyncv1alpha1.MoverTypeRestic
+}
+
+// unlockTarget builds the repository configuration of a k8up job from the
+// backend of its Backup, Check or Prune, or of the Schedule that created it.
+// k8up has no unlock object of its own, so restic unlock runs with the
+// environment k8up gives its jobs. The environment of the job itself is used
+// when no backend names the repository, for example when it comes from the
+// defaults of the k8up operator.
+func (p k8upProvider) unlockTarget(ctx context.Context, job batchv1.Job) (*unlockTarget, error) {
+	var backend map[string]interface{}
+	if owner, ok := k8upOwner(&job); ok {
+		var err error
+		if backend, err = p.r.k8upBackend(ctx, job.Namespace, owner.Kind, owner.Name); err != nil {
+			return nil, err
+		}
+	}
+	target := &unlockTarget{MoverType: volsyncv1alpha1.MoverTypeRestic}
+	if backend != nil {
+		target = k8upBackendTarget(backend)
+	}
+
+	containers := job.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return target, nil
+	}
+	if backend == nil {
+		target.Env = append(target.Env, containers[0].Env...)
+		target.EnvFrom = append(target.EnvFrom, containers[0].EnvFrom...)
+		target.SecretName = repositorySecretName(target.Env, target.EnvFrom)
+	}
+	target.Volumes, target.VolumeMounts = k8upRepositoryVolumes(job)
+	target.Image = containers[0].Image
+	target.ImagePullSecrets = append(target.ImagePullSecrets, job.Spec.Template.Spec.ImagePullSecrets...)
+	return target, nil
+}
+
+// runningJobs lists the running k8up jobs of the namespace that use the
+// repository of a job. Jobs whose repository is not in their environment
+// may use any repository and are always included.
+func (p k8upProvider) runningJobs(ctx context.Context, job batchv1.Job) ([]batchv1.Job, error) {
+	var jobList batchv1.JobList
+	if err := p.r.List(ctx, &jobList, client.InNamespace(job.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list jobs in namespace %s: %w", job.Namespace, err)
+	}
+
+	repository := k8upJobRepository(job)
+	var running []batchv1.Job
+	for _, other := range jobList.Items {
+		if (job.UID != "" && other.UID == job.UID) || !p.r.isJobActive(other) || !p.ownsJob(&other) {
+			continue
+		}
+		if otherRepository := k8upJobRepository(other); repository != "" && otherRepository != "" && otherRepository != repository {
+			continue
+		}
+		running = append(running, other)
+	}
+	return running, nil
+}
+
+// k8upJobRepository returns the restic repository in the environment of a k8up job
+func k8upJobRepository(job batchv1.Job) string {
+	for _, container := range job.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "RESTIC_REPOSITORY" {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// k8upOwner returns the k8up Backup, Check or Prune owning a job
+func k8upOwner(job *batchv1.Job) (metav1.OwnerReference, bool) {
+	for _, owner := range job.OwnerReferences {
+		if !strings.HasPrefix(owner.APIVersion, k8upGroupVersion.Group+"/") {
+			continue
+		}
+		for _, kind := range k8upJobKinds {
+			if owner.Kind == kind {
+				return owner, true
+			}
+		}
+	}
+	return metav1.OwnerReference{}, false
+}
+
+// k8upScheduleOf returns the k8up Schedule that created an object
+func k8upScheduleOf(obj *unstructured.Unstructured) (string, bool) {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Kind == "Schedule" && strings.HasPrefix(owner.APIVersion, k8upGroupVersion.Group+"/") {
+			return owner.Name, true
+		}
+	}
+	return "", false
+}
+
+// getK8upObject reads a k8up object without depending on the k8up API types
+func (r *VolSyncMonitorReconciler) getK8upObject(ctx context.Context, namespace, kind, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(k8upGroupVersion.WithKind(kind))
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// k8upBackend returns the backend of a k8up object, or of the Schedule that
+// created it when the object names no repository. It returns nil when
+// neither does or the objects are gone.
+func (r *VolSyncMonitorReconciler) k8upBackend(ctx context.Context, namespace, kind, name string) (map[string]interface{}, error) {
+	obj, err := r.getK8upObject(ctx, namespace, kind, name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if backend, found, _ := unstructured.NestedMap(obj.Object, "spec", "backend"); found && k8upRepository(backend) != "" {
+		return backend, nil
+	}
+
+	schedule, ok := k8upScheduleOf(obj)
+	if !ok {
+		return nil, nil
+	}
+	obj, err = r.getK8upObject(ctx, namespace, "Schedule", schedule)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if backend, found, _ := unstructured.NestedMap(obj.Object, "spec", "backend"); found && k8upRepository(backend) != "" {
+		return backend, nil
+	}
+	return nil, nil
+}
+
+// k8upRepository returns the restic repository of a k8up backend the way
+// k8up builds it, or an empty string when the backend does not name one
+func k8upRepository(backend map[string]interface{}) string {
+	field := func(fields ...string) string {
+		value, _, _ := unstructured.NestedString(backend, fields...)
+		return value
+	}
+
+	switch {
+	case field("s3", "endpoint") != "" && field("s3", "bucket") != "":
+		return "s3:" + strings.TrimSuffix(field("s3", "endpoint"), "/") + "/" + field("s3", "bucket")
+	case field("b2", "bucket") != "":
+		return "b2:" + field("b2", "bucket") + ":" + field("b2", "path")
+	case field("azure", "container") != "":
+		return "azure:" + field("azure", "container") + ":/"
+	case field("gcs", "bucket") != "":
+		return "gs:" + field("gcs", "bucket") + ":/"
+	case field("swift", "container") != "":
+		return "swift:" + field("swift", "container") + ":" + field("swift", "path")
+	case field("rest", "url") != "":
+		url := field("rest", "url")
+		if _, found, _ := unstructured.NestedMap(backend, "rest", "userSecretRef"); found {
+			// k8up passes the credentials in the URL, expanded from the environment
+			if scheme, host, ok := strings.Cut(url, "://"); ok {
+				url = scheme + "://$(USER):$(PASSWORD)@" + host
+			}
+		}
+		return "rest:" + url
+	case field("local", "mountPath") != "":
+		return field("local", "mountPath")
+	}
+	return ""
+}
+
+// k8upBackendTarget builds the restic environment k8up sets from a backend
+func k8upBackendTarget(backend map[string]interface{}) *unlockTarget {
+	target := &unlockTarget{MoverType: volsyncv1alpha1.MoverTypeRestic}
+
+	// The secret references come first, so that the repository can expand them
+	addSecret := func(env string, fields ...string) {
+		name, _, _ := unstructured.NestedString(backend, append(fields, "name")...)
+		key, _, _ := unstructured.NestedString(backend, append(fields, "key")...)
+		if name == "" || key == "" {
+			return
+		}
+		target.Env = append(target.Env, corev1.EnvVar{
+			Name: env,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	addSecret("RESTIC_PASSWORD", "repoPasswordSecretRef")
+	for _, secret := range k8upBackendSecrets {
+		if _, found, _ := unstructured.NestedMap(backend, secret.Backend); found {
+			addSecret(secret.Env, secret.Backend, secret.Field)
+		}
+	}
+	target.Env = append(target.Env, corev1.EnvVar{Name: "RESTIC_REPOSITORY", Value: k8upRepository(backend)})
+
+	envFrom, _, _ := unstructured.NestedSlice(backend, "envFrom")
+	for _, entry := range envFrom {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(fields, "secretRef", "name"); name != "" {
+			target.EnvFrom = append(target.EnvFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+			})
+		} else if name, _, _ := unstructured.NestedString(fields, "configMapRef", "name"); name != "" {
+			target.EnvFrom = append(target.EnvFrom, corev1.EnvFromSource{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+			})
+		}
+	}
+
+	target.SecretName, _, _ = unstructured.NestedString(backend, "repoPasswordSecretRef", "name")
+	return target
+}
+
+// k8upRepositoryVolumes returns the volumes a k8up job mounts to reach its
+// repository, such as a local backend or CA certificates. The volumes k8up
+// backs up are mounted under /data and are left out.
+func k8upRepositoryVolumes(job batchv1.Job) ([]corev1.Volume, []corev1.VolumeMount) {
+	podSpec := job.Spec.Template.Spec
+	volumesByName := map[string]corev1.Volume{}
+	for _, volume := range podSpec.Volumes {
+		volumesByName[volume.Name] = volume
+	}
+
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	added := map[string]bool{}
+	for _, mount := range podSpec.Containers[0].VolumeMounts {
+		volume, found := volumesByName[mount.Name]
+		if !found || volume.EmptyDir != nil || mount.MountPath == "/data" || strings.HasPrefix(mount.MountPath, "/data/") {
+			continue
+		}
+		if !added[mount.Name] {
+			volumes = append(volumes, *volume.DeepCopy())
+			added[mount.Name] = true
+		}
+		volumeMounts = append(volumeMounts, *mount.DeepCopy())
+	}
+	return volumes, volumeMounts
+}
+
+// k8upObjectFailed reports whether a k8up object finished unsuccessfully,
+// from its Completed condition or the failed flag of older k8up releases
+func k8upObjectFailed(obj *unstructured.Unstructured) bool {
+	if failed, _, _ := unstructured.NestedBool(obj.Object, "status", "failed"); failed {
+		return true
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, entry := range conditions {
+		condition, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Completed" && condition["status"] == string(metav1.ConditionTrue) && condition["reason"] == "Failed" {
+			return true
+		}
+	}
+	return false
+}
+
+// k8upWatchKinds returns the k8up kinds whose objects can be watched, which
+// are none when k8up is not installed
+func k8upWatchKinds(mgr ctrl.Manager) []schema.GroupVersionKind {
+	var kinds []schema.GroupVersionKind
+	for _, kind := range []string{"Backup", "Check", "Prune"} {
+		gvk := k8upGroupVersion.WithKind(kind)
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			mgr.GetLogger().Info("Not watching k8up objects, k8up is not installed", "kind", kind)
+			continue
+		}
+		kinds = append(kinds, gvk)
+	}
+	return kinds
+}
+
+// findVolSyncMonitorsForK8upObject finds the monitors that handle k8up jobs
+// in the namespace of a k8up object that failed
+func (r *VolSyncMonitorReconciler) findVolSyncMonitorsForK8upObject(ctx context.Context, obj client.Object) []ctrl.Request {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || !k8upObjectFailed(u) {
+		return nil
+	}
+
+	var monitorList volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitorList); err != nil {
+		return nil
+	}
+
+	var requests []ctrl.Request
+	for i := range monitorList.Items {
+		monitor := &monitorList.Items[i]
+		if !monitor.Spec.Enabled || !handlesProvider(monitor, volsyncv1alpha1.BackupProviderK8up) {
+			continue
+		}
+		if !monitorWatchesNamespace(monitor, obj.GetNamespace()) {
+			continue
+		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: monitor.Name, Namespace: monitor.Namespace},
+		})
+	}
+	return requests
+}
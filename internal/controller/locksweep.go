@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+// lockSweepLabel is set on lock sweep jobs to the name of their monitor
+const lockSweepLabel = "homelab.rafaribe.com/lock-sweep"
+
+// reconcileLockSweep collects the results of finished lock sweep jobs and
+// starts a new sweep when the schedule is due
+func (r *VolSyncMonitorReconciler) reconcileLockSweep(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	if monitor.Spec.LockSweep == nil {
+		monitor.Status.LockSweep = nil
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(monitor.Spec.LockSweep.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid lock sweep schedule %q: %w", monitor.Spec.LockSweep.Schedule, err)
+	}
+	if monitor.Status.LockSweep == nil {
+		monitor.Status.LockSweep = &volsyncv1alpha1.LockSweepStatus{}
+	}
+	status := monitor.Status.LockSweep
+
+	if err := r.collectLockSweeps(ctx, monitor); err != nil {
+		return err
+	}
+
+	// The next sweep is derived from the last one so that schedule changes apply immediately
+	last := monitor.CreationTimestamp.Time
+	if status.LastSweepTime != nil {
+		last = status.LastSweepTime.Time
+	}
+	now := time.Now()
+	next := schedule.Next(last)
+	if now.Before(next) {
+		status.NextSweepTime = &metav1.Time{Time: next}
+		return nil
+	}
+
+	if err := r.startLockSweep(ctx, monitor); err != nil {
+		return err
+	}
+	status.LastSweepTime = &metav1.Time{Time: now}
+	status.NextSweepTime = &metav1.Time{Time: schedule.Next(now)}
+	return nil
+}
+
+// startLockSweep creates a lock sweep job for every restic repository the
+// monitor watches. Repositories whose previous sweep is still running are skipped.
+func (r *VolSyncMonitorReconciler) startLockSweep(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	logger := log.FromContext(ctx)
+
+	sources, err := r.listResticReplicationSources(ctx, monitor)
+	if err != nil {
+		return err
+	}
+
+	var repositories []volsyncv1alpha1.RepositoryLocks
+	for _, source := range sources {
+		entry := volsyncv1alpha1.RepositoryLocks{Namespace: source.GetNamespace(), ReplicationSource: source.GetName()}
+		if previous := findRepositoryLocks(monitor.Status.LockSweep, entry.Namespace, entry.ReplicationSource); previous != nil {
+			entry = *previous
+		}
+		if entry.SweepJobName != "" {
+			repositories = append(repositories, entry)
+			continue
+		}
+
+		target, err := r.discoverReplicationSourceTarget(ctx, entry.Namespace, entry.ReplicationSource)
+		if err != nil {
+			entry.Message = fmt.Sprintf("Failed to discover repository: %v", err)
+			repositories = append(repositories, entry)
+			continue
+		}
+		sweepJob, err := r.newRepositoryJob(monitor, lockSweepJobKind, entry.Namespace, entry.ReplicationSource, target, restic.ListLocksScript)
+		if err == nil {
+			err = r.Create(ctx, sweepJob)
+		}
+		if err != nil {
+			logger.Error(err, "Failed to create lock sweep job", "replicationSource", entry.ReplicationSource, "namespace", entry.Namespace)
+			entry.Message = fmt.Sprintf("Failed to create lock sweep job: %v", err)
+			repositories = append(repositories, entry)
+			continue
+		}
+
+		logger.Info("Created lock sweep job", "job", sweepJob.Name, "namespace", sweepJob.Namespace)
+		entry.SweepJobName = sweepJob.Name
+		repositories = append(repositories, entry)
+	}
+
+	monitor.Status.LockSweep.Repositories = repositories
+	return nil
+}
+
+// collectLockSweeps records the results of finished lock sweep jobs and deletes the jobs
+func (r *VolSyncMonitorReconciler) collectLockSweeps(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	logger := log.FromContext(ctx)
+
+	jobs, err := r.listRepositoryJobs(ctx, monitor, lockSweepJobKind)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, job := range jobs {
+		existing[job.Namespace+"/"+job.Name] = true
+
+		succeeded := r.isJobSucceeded(job)
+		if !succeeded && !r.isJobFailed(&job) {
+			continue
+		}
+
+		entry := findRepositoryLocks(monitor.Status.LockSweep, job.Namespace, job.Labels[repositorySourceLabel])
+		if entry != nil && entry.SweepJobName == job.Name {
+			entry.SweepJobName = ""
+			if succeeded {
+				output, err := r.latestJobPodLogs(ctx, job)
+				if err != nil {
+					r.failLockSweep(entry, fmt.Sprintf("Failed to read lock sweep logs: %v", err))
+				} else {
+					r.applyLockSweepResult(ctx, monitor, entry, output)
+				}
+			} else {
+				r.failLockSweep(entry, fmt.Sprintf("Lock sweep job %s failed", job.Name))
+			}
+		}
+
+		if err := r.deleteRepositoryJob(ctx, job); err != nil {
+			logger.Error(err, "Failed to delete lock sweep job", "job", job.Name, "namespace", job.Namespace)
+		}
+	}
+
+	// Jobs removed before their result was collected, for example by their TTL
+	for i := range monitor.Status.LockSweep.Repositories {
+		entry := &monitor.Status.LockSweep.Repositories[i]
+		if entry.SweepJobName != "" && !existing[entry.Namespace+"/"+entry.SweepJobName] {
+			r.failLockSweep(entry, fmt.Sprintf("Lock sweep job %s was deleted before it finished", entry.SweepJobName))
+			entry.SweepJobName = ""
+		}
+	}
+
+	return nil
+}
+
+// failLockSweep records a sweep that did not list the locks of a repository
+func (r *VolSyncMonitorReconciler) failLockSweep(entry *volsyncv1alpha1.RepositoryLocks, message string) {
+	entry.Message = message
+	helpers.RecordLockSweep(entry.Namespace, entry.ReplicationSource, "failed")
+}
+
+// applyLockSweepResult records the locks listed by a sweep job and removes
+// stale locks when spec.lockSweep.maxLockAge is set
+func (r *VolSyncMonitorReconciler) applyLockSweepResult(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, entry *volsyncv1alpha1.RepositoryLocks, output string) {
+	locks, err := restic.ParseLocks(output)
+	if err != nil {
+		r.failLockSweep(entry, fmt.Sprintf("Failed to list locks: %v", err))
+		return
+	}
+
+	now := time.Now()
+	entry.CheckTime = &metav1.Time{Time: now}
+	entry.Locks = int32(len(locks))
+	entry.OldestLockTime = nil
+	entry.UnlockJobName = ""
+	var oldestAge time.Duration
+	if len(locks) > 0 {
+		entry.OldestLockTime = &metav1.Time{Time: locks[0].Time}
+		oldestAge = now.Sub(locks[0].Time)
+	}
+	helpers.RecordRepositoryLocks(entry.Namespace, entry.ReplicationSource, len(locks), oldestAge)
+	helpers.RecordLockSweep(entry.Namespace, entry.ReplicationSource, "success")
+
+	entry.Message = fmt.Sprintf("%d locks", len(locks))
+	maxAge := monitor.Spec.LockSweep.MaxLockAge
+	if maxAge == nil || len(locks) == 0 {
+		return
+	}
+
+	stale := 0
+	for _, lock := range locks {
+		if now.Sub(lock.Time) > maxAge.Duration {
+			stale++
+		}
+	}
+	if stale == 0 {
+		entry.Message = fmt.Sprintf("%d locks, none older than %s", len(locks), maxAge.Duration)
+		return
+	}
+
+	reason, err := r.removeStaleLocks(ctx, monitor, entry, stale, oldestAge)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to remove stale locks", "replicationSource", entry.ReplicationSource, "namespace", entry.Namespace)
+		reason = err.Error()
+	}
+	entry.Message = fmt.Sprintf("%d locks, %d older than %s: %s", len(locks), stale, maxAge.Duration, reason)
+}
+
+// removeStaleLocks creates an unlock job for a repository with stale locks
+// unless a mover may still hold them. It returns what was done.
+func (r *VolSyncMonitorReconciler) removeStaleLocks(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, entry *volsyncv1alpha1.RepositoryLocks, stale int, oldestAge time.Duration) (string, error) {
+	moverJob := replicationSourceJob(entry.Namespace, entry.ReplicationSource)
+
+	running, err := r.moverJobRunning(ctx, entry.Namespace, entry.ReplicationSource, "")
+	if err != nil {
+		return "", err
+	}
+	if running {
+		return "a mover job is running, not unlocking", nil
+	}
+	for _, active := range monitor.Status.ActiveUnlocks {
+		if active.Namespace == entry.Namespace && active.ObjectName == entry.ReplicationSource &&
+			active.Direction != volsyncv1alpha1.VolSyncDirectionDestination {
+			return fmt.Sprintf("unlock job %s is already running", active.JobName), nil
+		}
+	}
+	if !r.canCreateUnlockJob(*monitor) {
+		return "maximum concurrent unlocks reached, deferring unlock", nil
+	}
+	policy, err := r.resolvePolicy(ctx, entry.Namespace, entry.ReplicationSource, nil)
+	if err != nil {
+		return "", err
+	}
+	if policy.Mode != MonitorModeEnforce {
+		return fmt.Sprintf("monitoring mode is %s, not unlocking", policy.Mode), nil
+	}
+	mover, err := policy.applyToMover(r.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic))
+	if err != nil {
+		return "", err
+	}
+	if mover.Remediation != volsyncv1alpha1.RemediationActionUnlock {
+		return fmt.Sprintf("remediation is %s, not unlocking", mover.Remediation), nil
+	}
+
+	lockError := fmt.Sprintf("Lock sweep found %d stale locks in the repository of ReplicationSource %s", stale, entry.ReplicationSource)
+	unlockJob, err := r.createUnlockJob(ctx, monitor, moverJob, lockError, mover, "")
+	if err != nil {
+		return "", err
+	}
+	entry.UnlockJobName = unlockJob.Name
+	identity := r.resolveJobIdentity(ctx, &moverJob)
+	monitor.Status.ActiveUnlocks = append(monitor.Status.ActiveUnlocks, volsyncv1alpha1.ActiveUnlock{
+		AppName:          identity.App,
+		Namespace:        unlockJob.Namespace,
+		ObjectName:       identity.ObjectName,
+		Direction:        identity.Direction,
+		JobName:          unlockJob.Name,
+		StartTime:        metav1.Now(),
+		AlertFingerprint: fmt.Sprintf("%s-%s", unlockJob.Namespace, unlockJob.Name),
+	})
+	monitor.Status.TotalUnlocksCreated++
+	monitor.Status.LastUnlockTime = &metav1.Time{Time: time.Now()}
+
+	processed := volsyncv1alpha1.ProcessedJob{
+		JobName:        moverJob.Name,
+		Namespace:      moverJob.Namespace,
+		AppName:        identity.App,
+		ObjectName:     identity.ObjectName,
+		Direction:      identity.Direction,
+		ProcessedTime:  metav1.Now(),
+		LockError:      lockError,
+		DetectedBy:     volsyncv1alpha1.LockErrorSourceSweep,
+		MoverType:      mover.Type,
+		Remediation:    volsyncv1alpha1.RemediationActionUnlock,
+		Classification: mover.Class,
+		UnlockJobName:  unlockJob.Name,
+	}
+	match := &lockErrorMatch{Message: lockError, DetectedBy: volsyncv1alpha1.LockErrorSourceSweep, LockAge: &oldestAge}
+	if _, err := r.createUnlockRecord(ctx, monitor, moverJob, match, processed); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record unlock history", "job", moverJob.Name)
+	}
+
+	return fmt.Sprintf("created unlock job %s", unlockJob.Name), nil
+}
+
+// findRepositoryLocks returns the status entry of a ReplicationSource, if any
+func findRepositoryLocks(status *volsyncv1alpha1.LockSweepStatus, namespace, source string) *volsyncv1alpha1.RepositoryLocks {
+	if status == nil {
+		return nil
+	}
+	for i := range status.Repositories {
+		if status.Repositories[i].Namespace == namespace && status.Repositories[i].ReplicationSource == source {
+			return &status.Repositories[i]
+		}
+	}
+	return nil
+}
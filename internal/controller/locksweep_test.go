@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+// lockSweepOutput returns the sweep job output for locks of the given ages
+func lockSweepOutput(ages ...time.Duration) string {
+	output := ""
+	for i, age := range ages {
+		output += fmt.Sprintf("lock %d {\"time\":%q,\"hostname\":\"volsync-src-plex\",\"pid\":1}\n", i, time.Now().Add(-age).Format(time.RFC3339Nano))
+	}
+	return output + "locks listed\n"
+}
+
+var _ = Describe("Lock sweeps", func() {
+	var (
+		ctx     context.Context
+		monitor *volsyncv1alpha1.VolSyncMonitor
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 2,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+				LockSweep:            &volsyncv1alpha1.LockSweepSpec{Schedule: "0 */6 * * *"},
+			},
+		}
+	})
+
+	sweepJobs := func(r *VolSyncMonitorReconciler) []batchv1.Job {
+		var jobList batchv1.JobList
+		Expect(r.List(ctx, &jobList, client.MatchingLabels{lockSweepLabel: "monitor"})).To(Succeed())
+		return jobList.Items
+	}
+
+	It("should start a sweep job per restic repository when the schedule is due", func() {
+		rclone := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"rclone": map[string]interface{}{"rcloneConfig": "rclone-secret"}},
+		}}
+		rclone.SetGroupVersionKind(volsyncGroupVersion.WithKind("ReplicationSource"))
+		rclone.SetNamespace("media")
+		rclone.SetName("photos")
+		r := newFakeReconciler(
+			newReplicationSource("media", "plex", "plex-restic"),
+			newReplicationSource("downloads", "sonarr", "sonarr-restic"),
+			rclone,
+		)
+
+		Expect(r.reconcileLockSweep(ctx, monitor)).To(Succeed())
+
+		jobs := sweepJobs(r)
+		Expect(jobs).To(HaveLen(2))
+		for _, job := range jobs {
+			Expect(job.Labels).To(HaveKey(repositorySourceLabel))
+			Expect(job.Labels).To(HaveKeyWithValue(recordMonitorNamespaceLabel, "system"))
+			Expect(job.Labels).NotTo(HaveKey(MonitorLabel))
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"-c", restic.ListLocksScript}))
+			Expect(job.Spec.Template.Spec.Containers[0].EnvFrom).NotTo(BeEmpty())
+		}
+
+		status := monitor.Status.LockSweep
+		Expect(status.LastSweepTime).NotTo(BeNil())
+		Expect(status.NextSweepTime.Time).To(BeTemporally(">", status.LastSweepTime.Time))
+		Expect(status.Repositories).To(HaveLen(2))
+		Expect(status.Repositories[0].ReplicationSource).To(Equal("sonarr"))
+		Expect(status.Repositories[1].SweepJobName).NotTo(BeEmpty())
+
+		By("not starting another sweep before the next run")
+		Expect(r.reconcileLockSweep(ctx, monitor)).To(Succeed())
+		Expect(sweepJobs(r)).To(HaveLen(2))
+	})
+
+	It("should keep the job names of long ReplicationSource names within 63 characters", func() {
+		r := newFakeReconciler()
+		source := "a-very-long-replication-source-name-for-an-app-with-a-suffix"
+		job, err := r.newRepositoryJob(monitor, lockSweepJobKind, "media", source, nil, "restic list locks")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(job.Name)).To(BeNumerically("<=", 63))
+		Expect(job.Name).To(HavePrefix("lock-sweep-a-very-long"))
+		Expect(job.Labels).To(HaveKeyWithValue(repositorySourceLabel, source))
+	})
+
+	It("should only sweep the namespaces the monitor watches", func() {
+		monitor.Spec.JobSelector = &volsyncv1alpha1.JobSelector{Namespaces: []string{"media"}}
+		r := newFakeReconciler(
+			newReplicationSource("media", "plex", "plex-restic"),
+			newReplicationSource("downloads", "sonarr", "sonarr-restic"),
+		)
+
+		Expect(r.reconcileLockSweep(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.LockSweep.Repositories).To(HaveLen(1))
+		Expect(monitor.Status.LockSweep.Repositories[0].ReplicationSource).To(Equal("plex"))
+	})
+
+	It("should reject an invalid schedule", func() {
+		monitor.Spec.LockSweep.Schedule = "every day"
+		Expect(newFakeReconciler().reconcileLockSweep(ctx, monitor)).To(MatchError(ContainSubstring("invalid lock sweep schedule")))
+	})
+
+	It("should clear the status when sweeps are disabled", func() {
+		monitor.Spec.LockSweep = nil
+		monitor.Status.LockSweep = &volsyncv1alpha1.LockSweepStatus{}
+		Expect(newFakeReconciler().reconcileLockSweep(ctx, monitor)).To(Succeed())
+		Expect(monitor.Status.LockSweep).To(BeNil())
+	})
+
+	It("should record failed and vanished sweep jobs", func() {
+		failed := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "lock-sweep-plex-1",
+				Namespace: "media",
+				Labels:    map[string]string{lockSweepLabel: "monitor", repositorySourceLabel: "plex"},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		r := newFakeReconciler(failed)
+		monitor.Status.LockSweep = &volsyncv1alpha1.LockSweepStatus{
+			Repositories: []volsyncv1alpha1.RepositoryLocks{
+				{Namespace: "media", ReplicationSource: "plex", SweepJobName: "lock-sweep-plex-1"},
+				{Namespace: "media", ReplicationSource: "sonarr", SweepJobName: "lock-sweep-sonarr-1"},
+			},
+		}
+
+		Expect(r.collectLockSweeps(ctx, monitor)).To(Succeed())
+
+		repositories := monitor.Status.LockSweep.Repositories
+		Expect(repositories[0].SweepJobName).To(BeEmpty())
+		Expect(repositories[0].Message).To(ContainSubstring("failed"))
+		Expect(repositories[1].SweepJobName).To(BeEmpty())
+		Expect(repositories[1].Message).To(ContainSubstring("deleted before it finished"))
+		Expect(sweepJobs(r)).To(BeEmpty())
+	})
+
+	Describe("Stale lock removal", func() {
+		var entry *volsyncv1alpha1.RepositoryLocks
+
+		BeforeEach(func() {
+			monitor.Spec.LockSweep.MaxLockAge = &metav1.Duration{Duration: time.Hour}
+			monitor.Status.LockSweep = &volsyncv1alpha1.LockSweepStatus{
+				Repositories: []volsyncv1alpha1.RepositoryLocks{{Namespace: "media", ReplicationSource: "plex"}},
+			}
+			entry = &monitor.Status.LockSweep.Repositories[0]
+		})
+
+		It("should unlock repositories with locks older than maxLockAge", func() {
+			r := newFakeReconciler(monitor, newReplicationSource("media", "plex", "plex-restic"))
+
+			r.applyLockSweepResult(ctx, monitor, entry, lockSweepOutput(3*time.Hour, 5*time.Minute))
+
+			Expect(entry.Locks).To(Equal(int32(2)))
+			Expect(entry.CheckTime).NotTo(BeNil())
+			Expect(time.Since(entry.OldestLockTime.Time)).To(BeNumerically("~", 3*time.Hour, time.Minute))
+			Expect(entry.UnlockJobName).NotTo(BeEmpty())
+			Expect(entry.Message).To(ContainSubstring("1 older than 1h0m0s"))
+			Expect(monitor.Status.ActiveUnlocks).To(HaveLen(1))
+			Expect(monitor.Status.TotalUnlocksCreated).To(Equal(int32(1)))
+
+			var records volsyncv1alpha1.UnlockRecordList
+			Expect(r.List(ctx, &records)).To(Succeed())
+			Expect(records.Items).To(HaveLen(1))
+			Expect(records.Items[0].Spec.DetectedBy).To(Equal(volsyncv1alpha1.LockErrorSourceSweep))
+			Expect(records.Items[0].Spec.UnlockJobName).To(Equal(entry.UnlockJobName))
+		})
+
+		It("should not unlock while a mover job is running", func() {
+			mover := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "mover"},
+				Status:     batchv1.JobStatus{Active: 1},
+			}
+			r := newFakeReconciler(monitor, mover, newReplicationSource("media", "plex", "plex-restic"))
+
+			r.applyLockSweepResult(ctx, monitor, entry, lockSweepOutput(3*time.Hour))
+
+			Expect(entry.UnlockJobName).To(BeEmpty())
+			Expect(entry.Message).To(ContainSubstring("a mover job is running"))
+		})
+
+		It("should not start another unlock while one is active", func() {
+			r := newFakeReconciler(monitor, newReplicationSource("media", "plex", "plex-restic"))
+
+			r.applyLockSweepResult(ctx, monitor, entry, lockSweepOutput(3*time.Hour))
+			first := entry.UnlockJobName
+			Expect(first).NotTo(BeEmpty())
+			Expect(monitor.Status.ActiveUnlocks).To(HaveLen(1))
+
+			r.applyLockSweepResult(ctx, monitor, entry, lockSweepOutput(3*time.Hour))
+			Expect(entry.Message).To(ContainSubstring(fmt.Sprintf("unlock job %s is already running", first)))
+			Expect(monitor.Status.ActiveUnlocks).To(HaveLen(1))
+
+			var unlockJobs batchv1.JobList
+			Expect(r.List(ctx, &unlockJobs, client.MatchingLabels{MonitorLabel: "monitor"})).To(Succeed())
+			Expect(unlockJobs.Items).To(HaveLen(1))
+		})
+
+		It("should only report locks younger than maxLockAge", func() {
+			r := newFakeReconciler(monitor)
+
+			r.applyLockSweepResult(ctx, monitor, entry, lockSweepOutput(10*time.Minute))
+
+			Expect(entry.Locks).To(Equal(int32(1)))
+			Expect(entry.UnlockJobName).To(BeEmpty())
+			Expect(entry.Message).To(ContainSubstring("none older than"))
+		})
+
+		It("should record incomplete lock listings", func() {
+			r := newFakeReconciler(monitor)
+			entry.Locks = 3
+
+			r.applyLockSweepResult(ctx, monitor, entry, "Fatal: wrong password or no key found\n")
+
+			Expect(entry.Locks).To(Equal(int32(3)))
+			Expect(entry.Message).To(ContainSubstring("Failed to list locks"))
+		})
+	})
+})
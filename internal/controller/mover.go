@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// volsyncGroupVersion is the API group version of the VolSync custom resources
+var volsyncGroupVersion = schema.GroupVersion{Group: "volsync.backube", Version: "v1alpha1"}
+
+// reasonUnlockUnavailable is the event reason of failures of movers that cannot
+// be unlocked without their own unlock job template
+const reasonUnlockUnavailable = "UnlockUnavailable"
+
+// moverDefaults holds the built-in detection and remediation settings of a mover type
+type moverDefaults struct {
+	// Patterns are the regex patterns that indicate an actionable error
+	Patterns []string
+	// Remediation is the default action for a detected error
+	Remediation volsyncv1alpha1.RemediationAction
+	// Class is the failure class of errors matched by the patterns
+	Class volsyncv1alpha1.FailureClass
+	// CachePatterns are the regex patterns that indicate a corrupt or full
+	// cache volume, independent of the configured patterns
+	CachePatterns []string
+	// Command and Args are the default unlock command
+	Command []string
+	Args    []string
+	// OwnImage is set for movers without a safe default unlock command. They only
+	// unlock with a per mover template that sets its own image and command.
+	OwnImage bool
+}
+
+// defaultMovers contains the built-in settings for every supported mover type
+var defaultMovers = map[volsyncv1alpha1.MoverType]moverDefaults{
+	volsyncv1alpha1.MoverTypeRestic: {
+		Patterns: []string{
+			"repository is already locked",
+			"unable to create lock",
+			"repository.*locked",
+			"lock.*already exists",
+		},
+		Remediation: volsyncv1alpha1.RemediationActionUnlock,
+		Class:       volsyncv1alpha1.FailureClassStaleLock,
+		CachePatterns: []string{
+			"unable to open cache",
+			"/cache/.*no space left on device",
+		},
+		Command: []string{"/bin/sh"},
+		Args:    []string{"-c", "restic unlock"},
+	},
+	volsyncv1alpha1.MoverTypeKopia: {
+		Patterns: []string{
+			"unable to acquire lock",
+			"lock.*held by",
+			"maintenance.*already (running|in progress)",
+			"repository.*locked",
+		},
+		Remediation: volsyncv1alpha1.RemediationActionUnlock,
+		Class:       volsyncv1alpha1.FailureClassStaleLock,
+		OwnImage:    true,
+	},
+	volsyncv1alpha1.MoverTypeRclone: {
+		Patterns: []string{
+			"failed to copy",
+			"couldn't connect",
+			"i/o timeout",
+			"too many requests",
+			"rate.?limit",
+		},
+		Remediation: volsyncv1alpha1.RemediationActionRetry,
+		Class:       volsyncv1alpha1.FailureClassTransient,
+	},
+	volsyncv1alpha1.MoverTypeRsync: {
+		Patterns: []string{
+			"connection refused",
+			"connection unexpectedly closed",
+			"connection reset by peer",
+			"no route to host",
+		},
+		Remediation: volsyncv1alpha1.RemediationActionRetry,
+		Class:       volsyncv1alpha1.FailureClassTransient,
+	},
+}
+
+// moverSettings is the effective configuration for a failed job's mover
+type moverSettings struct {
+	Type        volsyncv1alpha1.MoverType
+	Patterns    []string
+	Remediation volsyncv1alpha1.RemediationAction
+	Class       volsyncv1alpha1.FailureClass
+	Template    volsyncv1alpha1.UnlockJobTemplate
+	// CachePatterns recognise a corrupt or full cache volume
+	CachePatterns []string
+	// PatternSets are the patterns of the FailurePatternSets the monitor references
+	PatternSets []failurePattern
+	// Monitor is the monitor generation whose compiled patterns are reused
+	Monitor monitorGeneration
+	// UnlockUnavailable tells why an Unlock remediation was replaced by None
+	UnlockUnavailable string
+}
+
+// resolveMoverSettings merges the monitor configuration with the defaults of a mover type.
+// Patterns fall back from the mover override to spec.lockErrorPatterns (restic only, for
+// compatibility), then to the patterns of the controller config and finally to the
+// built-in patterns.
+func (r *VolSyncMonitorReconciler) resolveMoverSettings(monitor *volsyncv1alpha1.VolSyncMonitor, moverType volsyncv1alpha1.MoverType) moverSettings {
+	defaults, ok := defaultMovers[moverType]
+	if !ok {
+		moverType = volsyncv1alpha1.MoverTypeRestic
+		defaults = defaultMovers[moverType]
+	}
+
+	patterns := defaults.Patterns
+	if configured := r.settings().LockErrorPatterns[string(moverType)]; len(configured) > 0 {
+		patterns = configured
+	}
+
+	settings := moverSettings{
+		Type:          moverType,
+		Patterns:      patterns,
+		Remediation:   defaults.Remediation,
+		Class:         defaults.Class,
+		Template:      monitor.Spec.UnlockJobTemplate,
+		CachePatterns: defaults.CachePatterns,
+		Monitor:       newMonitorGeneration(monitor),
+	}
+	if moverType == volsyncv1alpha1.MoverTypeRestic && len(monitor.Spec.LockErrorPatterns) > 0 {
+		settings.Patterns = monitor.Spec.LockErrorPatterns
+	}
+
+	// spec.unlockJobTemplate commands are restic commands; other movers only inherit
+	// the rest of the template and run their own default command
+	if moverType != volsyncv1alpha1.MoverTypeRestic {
+		settings.Template.Command = defaults.Command
+		settings.Template.Args = defaults.Args
+	}
+
+	ownImage := false
+	for _, override := range monitor.Spec.Movers {
+		if override.Type != moverType {
+			continue
+		}
+		if len(override.LockErrorPatterns) > 0 {
+			settings.Patterns = override.LockErrorPatterns
+		}
+		if override.Remediation != "" {
+			settings.Remediation = override.Remediation
+		}
+		if override.UnlockJobTemplate != nil {
+			settings.Template = *override.UnlockJobTemplate
+			ownImage = override.UnlockJobTemplate.Image != ""
+		}
+	}
+
+	// Fill the command from the mover defaults when the template does not set one
+	if len(settings.Template.Command) == 0 {
+		settings.Template.Command = defaults.Command
+	}
+	if len(settings.Template.Args) == 0 {
+		settings.Template.Args = defaults.Args
+	}
+
+	// The unlock job would otherwise run the restic image, or no command at all,
+	// against a repository it never connected to
+	if defaults.OwnImage && (!ownImage || len(settings.Template.Command) == 0) &&
+		settings.Remediation == volsyncv1alpha1.RemediationActionUnlock {
+		settings.Remediation = volsyncv1alpha1.RemediationActionNone
+		settings.UnlockUnavailable = fmt.Sprintf("the %s mover only unlocks with an image and a command in its spec.movers unlockJobTemplate", moverType)
+	}
+
+	return settings
+}
+
+// detectVolSyncMoverType determines which VolSync mover ran a job. The owning
+// ReplicationSource or ReplicationDestination spec is authoritative; the job
+// name and container settings are used when it cannot be read.
+func (r *VolSyncMonitorReconciler) detectVolSyncMoverType(ctx context.Context, job batchv1.Job) volsyncv1alpha1.MoverType {
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind != "ReplicationSource" && owner.Kind != "ReplicationDestination" {
+			continue
+		}
+		if !strings.HasPrefix(owner.APIVersion, volsyncGroupVersion.Group+"/") {
+			continue
+		}
+		if moverType, ok := r.moverTypeFromOwner(ctx, job.Namespace, owner.Kind, owner.Name); ok {
+			return moverType
+		}
+	}
+
+	return moverTypeFromJob(job)
+}
+
+// moverTypeFromOwner reads the mover section of a VolSync replication object
+func (r *VolSyncMonitorReconciler) moverTypeFromOwner(ctx context.Context, namespace, kind, name string) (volsyncv1alpha1.MoverType, bool) {
+	owner, err := r.getVolSyncObject(ctx, namespace, kind, name)
+	if err != nil {
+		return "", false
+	}
+	moverType, _, ok := moverSection(owner)
+	return moverType, ok
+}
+
+// getVolSyncObject reads a VolSync replication object without depending on the VolSync API types
+func (r *VolSyncMonitorReconciler) getVolSyncObject(ctx context.Context, namespace, kind, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(volsyncGroupVersion.WithKind(kind))
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// moverSection returns the mover type and mover settings of a VolSync replication object
+func moverSection(obj *unstructured.Unstructured) (volsyncv1alpha1.MoverType, map[string]interface{}, bool) {
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	for _, candidate := range []struct {
+		field     string
+		moverType volsyncv1alpha1.MoverType
+	}{
+		{"restic", volsyncv1alpha1.MoverTypeRestic},
+		{"kopia", volsyncv1alpha1.MoverTypeKopia},
+		{"rclone", volsyncv1alpha1.MoverTypeRclone},
+		{"rsync", volsyncv1alpha1.MoverTypeRsync},
+		{"rsyncTLS", volsyncv1alpha1.MoverTypeRsync},
+	} {
+		if section, found := spec[candidate.field]; found {
+			settings, _ := section.(map[string]interface{})
+			return candidate.moverType, settings, true
+		}
+	}
+	return "", nil, false
+}
+
+// moverTypeFromJob guesses the mover from the job name, container commands and environment
+func moverTypeFromJob(job batchv1.Job) volsyncv1alpha1.MoverType {
+	// VolSync prefixes non-restic mover jobs with the mover name (e.g. volsync-rclone-src-app)
+	for _, moverType := range []volsyncv1alpha1.MoverType{
+		volsyncv1alpha1.MoverTypeKopia,
+		volsyncv1alpha1.MoverTypeRclone,
+		volsyncv1alpha1.MoverTypeRsync,
+	} {
+		if strings.HasPrefix(job.Name, "volsync-"+string(moverType)+"-") {
+			return moverType
+		}
+	}
+
+	for _, container := range job.Spec.Template.Spec.Containers {
+		command := strings.Join(append(append([]string{}, container.Command...), container.Args...), " ")
+		for _, moverType := range []volsyncv1alpha1.MoverType{
+			volsyncv1alpha1.MoverTypeKopia,
+			volsyncv1alpha1.MoverTypeRclone,
+			volsyncv1alpha1.MoverTypeRsync,
+			volsyncv1alpha1.MoverTypeRestic,
+		} {
+			if strings.Contains(command, "mover-"+string(moverType)) {
+				return moverType
+			}
+		}
+		for _, env := range container.Env {
+			switch {
+			case strings.HasPrefix(env.Name, "KOPIA_"):
+				return volsyncv1alpha1.MoverTypeKopia
+			case strings.HasPrefix(env.Name, "RCLONE_"):
+				return volsyncv1alpha1.MoverTypeRclone
+			}
+		}
+	}
+
+	return volsyncv1alpha1.MoverTypeRestic
+}
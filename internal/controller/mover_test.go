@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
+)
+
+var _ = Describe("Mover types", func() {
+	var reconciler *VolSyncMonitorReconciler
+
+	BeforeEach(func() {
+		reconciler = &VolSyncMonitorReconciler{}
+	})
+
+	Describe("moverTypeFromJob", func() {
+		It("should detect movers from the job name", func() {
+			job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-rclone-src-photos"}}
+			Expect(moverTypeFromJob(job)).To(Equal(volsyncv1alpha1.MoverTypeRclone))
+
+			job.Name = "volsync-kopia-src-photos"
+			Expect(moverTypeFromJob(job)).To(Equal(volsyncv1alpha1.MoverTypeKopia))
+
+			job.Name = "volsync-rsync-tls-dst-photos"
+			Expect(moverTypeFromJob(job)).To(Equal(volsyncv1alpha1.MoverTypeRsync))
+		})
+
+		It("should detect movers from the container command", func() {
+			job := batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-photos"},
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{Command: []string{"/bin/bash", "-c", "/mover-kopia/entry.sh"}},
+							},
+						},
+					},
+				},
+			}
+			Expect(moverTypeFromJob(job)).To(Equal(volsyncv1alpha1.MoverTypeKopia))
+		})
+
+		It("should default to restic", func() {
+			job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-photos"}}
+			Expect(moverTypeFromJob(job)).To(Equal(volsyncv1alpha1.MoverTypeRestic))
+		})
+	})
+
+	Describe("resolveMoverSettings", func() {
+		It("should use the monitor template and patterns for restic", func() {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					LockErrorPatterns: []string{"custom lock"},
+					UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{
+						Image:   "restic/restic:latest",
+						Command: []string{"restic"},
+						Args:    []string{"unlock", "--remove-all"},
+					},
+				},
+			}
+
+			settings := reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic)
+			Expect(settings.Patterns).To(Equal([]string{"custom lock"}))
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionUnlock))
+			Expect(settings.Template.Command).To(Equal([]string{"restic"}))
+			Expect(settings.Template.Args).To(Equal([]string{"unlock", "--remove-all"}))
+		})
+
+		It("should not unlock kopia repositories with the restic image", func() {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{
+						Image:   "quay.io/backube/volsync:latest",
+						Command: []string{"restic"},
+						Args:    []string{"unlock"},
+					},
+				},
+			}
+
+			settings := reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeKopia)
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+			Expect(settings.UnlockUnavailable).To(ContainSubstring("kopia mover"))
+
+			Expect(settings.Template.Command).To(BeEmpty())
+
+			By("requiring a command along with the kopia image")
+			template := &volsyncv1alpha1.UnlockJobTemplate{Image: "kopia/kopia:latest"}
+			monitor.Spec.Movers = []volsyncv1alpha1.MoverConfig{{Type: volsyncv1alpha1.MoverTypeKopia, UnlockJobTemplate: template}}
+			settings = reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeKopia)
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+
+			By("unlocking once the kopia template sets its own image and command")
+			template.Command = []string{"/bin/sh"}
+			template.Args = []string{"-c", "/scripts/kopia-unlock.sh"}
+			settings = reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeKopia)
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionUnlock))
+			Expect(settings.Template.Image).To(Equal("kopia/kopia:latest"))
+			Expect(settings.UnlockUnavailable).To(BeEmpty())
+		})
+
+		It("should report kopia lock errors it cannot unlock", func() {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					Enabled:           true,
+					UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+				},
+			}
+			failedJob := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "volsync-kopia-src-photos", Namespace: "media", UID: "job-uid"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+				},
+			}
+			jobPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "volsync-kopia-src-photos-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodFailed,
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name: "kopia",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: 1,
+							Message:  "unable to acquire lock on the repository",
+						}},
+					}},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := newFakeReconciler(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, jobPod)
+			r.Recorder = recorder
+
+			_, err := r.reconcileMonitor(context.Background(), monitor)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(monitor.Status.ProcessedJobs).To(HaveLen(1))
+			Expect(monitor.Status.ProcessedJobs[0].Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+			Expect(recorder.Events).To(Receive(And(ContainSubstring(reasonUnlockUnavailable), ContainSubstring("kopia mover"))))
+		})
+
+		It("should retry rclone jobs without unlocking", func() {
+			settings := reconciler.resolveMoverSettings(&volsyncv1alpha1.VolSyncMonitor{}, volsyncv1alpha1.MoverTypeRclone)
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionRetry))
+		})
+
+		It("should apply per mover overrides", func() {
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					Movers: []volsyncv1alpha1.MoverConfig{
+						{
+							Type:              volsyncv1alpha1.MoverTypeKopia,
+							LockErrorPatterns: []string{"kopia lock"},
+							Remediation:       volsyncv1alpha1.RemediationActionNone,
+							UnlockJobTemplate: &volsyncv1alpha1.UnlockJobTemplate{
+								Image:   "kopia/kopia:latest",
+								Command: []string{"/bin/sh"},
+								Args:    []string{"-c", "/scripts/kopia-unlock.sh"},
+							},
+						},
+					},
+				},
+			}
+
+			settings := reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeKopia)
+			Expect(settings.Patterns).To(Equal([]string{"kopia lock"}))
+			Expect(settings.Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+			Expect(settings.Template.Image).To(Equal("kopia/kopia:latest"))
+			Expect(settings.Template.Command).To(Equal([]string{"/bin/sh"}))
+			Expect(settings.Template.Args).To(Equal([]string{"-c", "/scripts/kopia-unlock.sh"}))
+		})
+
+		It("should use the patterns of the controller config over the built-in ones", func() {
+			cfg := config.Default()
+			cfg.VolSyncMonitor.LockErrorPatterns = map[string][]string{"rsync": {"rsync error"}}
+			reconciler.Config = config.NewStore(cfg)
+
+			settings := reconciler.resolveMoverSettings(&volsyncv1alpha1.VolSyncMonitor{}, volsyncv1alpha1.MoverTypeRsync)
+			Expect(settings.Patterns).To(Equal([]string{"rsync error"}))
+
+			monitor := &volsyncv1alpha1.VolSyncMonitor{
+				Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+					Movers: []volsyncv1alpha1.MoverConfig{{Type: volsyncv1alpha1.MoverTypeRsync, LockErrorPatterns: []string{"monitor pattern"}}},
+				},
+			}
+			settings = reconciler.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRsync)
+			Expect(settings.Patterns).To(Equal([]string{"monitor pattern"}))
+		})
+	})
+})
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// The functions in this file expose the controller pipeline to the
+// kubectl-homelab plugin so that both share detection and unlock logic.
+
+// MonitorLabel is set on unlock jobs and UnlockRecords created for a monitor
+const MonitorLabel = "homelab.rafaribe.com/monitor"
+
+// maxExplainLines caps the matching log lines reported per pattern
+const maxExplainLines = 5
+
+// PatternMatch lists the log lines of a job that match a pattern
+type PatternMatch struct {
+	Pattern string
+	// Name is the FailurePatternSet pattern, as set/pattern, when the pattern comes from a set
+	Name  string
+	Lines []string
+}
+
+// Explanation describes how the controller classifies a failed job
+type Explanation struct {
+	Job         batchv1.Job
+	AppName     string
+	MoverType   volsyncv1alpha1.MoverType
+	Remediation volsyncv1alpha1.RemediationAction
+	Class       volsyncv1alpha1.FailureClass
+	Pattern     string
+	Severity    volsyncv1alpha1.FailureSeverity
+	Failed      bool
+	Processed   bool
+
+	// Mode is the monitoring mode of the job and ModeSource the object that set it
+	Mode       MonitorMode
+	ModeSource string
+
+	// Patterns has one entry per configured pattern, including those without matches
+	Patterns []PatternMatch
+
+	// Detected is set when the controller would treat the job as a lock error
+	Detected    bool
+	DetectedBy  volsyncv1alpha1.LockErrorSource
+	Message     string
+	ExitCode    *int32
+	MessageType string
+}
+
+// MonitorSelectsJob reports whether a monitor is configured to handle a job,
+// regardless of whether the monitor is enabled
+func (r *VolSyncMonitorReconciler) MonitorSelectsJob(monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) bool {
+	return monitorWatchesNamespace(monitor, job.Namespace) && r.matchesJobSelector(job, monitor.Spec.JobSelector, monitorProviders(monitor))
+}
+
+// monitorWatchesNamespace reports whether a monitor looks for jobs in a namespace
+func monitorWatchesNamespace(monitor *volsyncv1alpha1.VolSyncMonitor, namespace string) bool {
+	if monitor.Spec.JobSelector == nil || len(monitor.Spec.JobSelector.Namespaces) == 0 {
+		return true
+	}
+	for _, candidate := range monitor.Spec.JobSelector.Namespaces {
+		if candidate == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAppJobs returns the VolSync jobs of an app in a namespace that the
+// monitor selects, newest first
+func (r *VolSyncMonitorReconciler) FindAppJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, namespace, app string) ([]batchv1.Job, error) {
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list jobs in namespace %s: %w", namespace, err)
+	}
+
+	var jobs []batchv1.Job
+	for _, job := range jobList.Items {
+		if _, isUnlock := job.Labels[MonitorLabel]; isUnlock {
+			continue
+		}
+		if !r.MonitorSelectsJob(monitor, job) {
+			continue
+		}
+		if jobApp, _ := r.extractAppInfoFromJob(&job); jobApp == app {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp)
+	})
+	return jobs, nil
+}
+
+// ListUnlockJobs returns the unlock jobs created for a monitor, newest first
+func (r *VolSyncMonitorReconciler) ListUnlockJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) ([]batchv1.Job, error) {
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.MatchingLabels{MonitorLabel: monitor.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list unlock jobs: %w", err)
+	}
+
+	jobs := jobList.Items
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp)
+	})
+	return jobs, nil
+}
+
+// ExplainJob runs the lock error detection for a job and reports which of
+// the monitor's patterns match its logs
+func (r *VolSyncMonitorReconciler) ExplainJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) (*Explanation, error) {
+	mover, policy, err := r.jobMoverSettings(ctx, monitor, job)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Mode == MonitorModeObserve {
+		mover.Remediation = volsyncv1alpha1.RemediationActionNone
+	}
+
+	appName, _ := r.extractAppInfoFromJob(&job)
+	explanation := &Explanation{
+		Job:         job,
+		AppName:     appName,
+		MoverType:   mover.Type,
+		Remediation: mover.Remediation,
+		Class:       mover.Class,
+		Failed:      r.isJobFailed(&job),
+		Processed:   r.isJobAlreadyProcessed(monitor, job),
+		Mode:        policy.Mode,
+		ModeSource:  policy.ModeSource,
+	}
+
+	match, err := r.checkJobForLockErrors(ctx, job, mover)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		explanation.Detected = true
+		explanation.DetectedBy = match.DetectedBy
+		explanation.Message = match.Message
+		explanation.Class = match.Class
+		explanation.Remediation = classRemediation(monitor, mover.Remediation, match.Class)
+		explanation.Pattern = match.Pattern
+		explanation.Severity = match.Severity
+		explanation.ExitCode = match.ExitCode
+		explanation.MessageType = match.MessageType
+	}
+
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list pods for job %s: %w", job.Name, err)
+	}
+	var lines []string
+	for _, pod := range podList.Items {
+		logs, err := r.podLogs(ctx, pod)
+		if err == nil {
+			lines = append(lines, strings.Split(logs, "\n")...)
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil && status.State.Terminated.Message != "" {
+				lines = append(lines, strings.Split(status.State.Terminated.Message, "\n")...)
+			}
+		}
+	}
+
+	for _, pattern := range mover.failurePatterns(ctx) {
+		patternMatch := PatternMatch{Pattern: pattern.Source, Name: pattern.Name}
+		for _, line := range lines {
+			if pattern.Regex.MatchString(line) && len(patternMatch.Lines) < maxExplainLines {
+				patternMatch.Lines = append(patternMatch.Lines, strings.TrimSpace(line))
+			}
+		}
+		explanation.Patterns = append(explanation.Patterns, patternMatch)
+	}
+
+	return explanation, nil
+}
+
+// BuildUnlockJob returns the unlock job the monitor would create for a job
+func (r *VolSyncMonitorReconciler) BuildUnlockJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, reason string) (*batchv1.Job, error) {
+	mover, _, err := r.jobMoverSettings(ctx, monitor, job)
+	if err != nil {
+		return nil, err
+	}
+	if mover.Remediation != volsyncv1alpha1.RemediationActionUnlock {
+		return nil, fmt.Errorf("%s mover does not support unlocking", mover.Type)
+	}
+	target, err := r.discoverUnlockTarget(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover repository of job %s: %w", job.Name, err)
+	}
+	return r.newUnlockJob(monitor, job, r.resolveJobIdentity(ctx, &job), reason, mover, target, job.UID)
+}
+
+// ManualUnlock creates an unlock job for a job on behalf of a user and
+// records it in the unlock history. The controller tracks the job like any
+// other unlock job of the monitor.
+func (r *VolSyncMonitorReconciler) ManualUnlock(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, reason string) (*batchv1.Job, *volsyncv1alpha1.UnlockRecord, error) {
+	mover, _, err := r.jobMoverSettings(ctx, monitor, job)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mover.Remediation != volsyncv1alpha1.RemediationActionUnlock {
+		return nil, nil, fmt.Errorf("%s mover does not support unlocking", mover.Type)
+	}
+
+	unlockJob, err := r.createUnlockJob(ctx, monitor, job, reason, mover, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identity := r.resolveJobIdentity(ctx, &job)
+	processed := volsyncv1alpha1.ProcessedJob{
+		JobName:        job.Name,
+		Namespace:      job.Namespace,
+		JobUID:         job.UID,
+		AppName:        identity.App,
+		ObjectName:     identity.ObjectName,
+		Direction:      identity.Direction,
+		ProcessedTime:  metav1.Now(),
+		LockError:      reason,
+		DetectedBy:     volsyncv1alpha1.LockErrorSourceManual,
+		MoverType:      mover.Type,
+		Remediation:    volsyncv1alpha1.RemediationActionUnlock,
+		Classification: mover.Class,
+		UnlockJobName:  unlockJob.Name,
+	}
+	match := &lockErrorMatch{Message: reason, DetectedBy: volsyncv1alpha1.LockErrorSourceManual}
+	record, err := r.createUnlockRecord(ctx, monitor, job, match, processed)
+	if err != nil {
+		return unlockJob, record, err
+	}
+
+	return unlockJob, record, nil
+}
+
+// SetMonitorEnabled pauses or resumes a monitor
+func (r *VolSyncMonitorReconciler) SetMonitorEnabled(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, enabled bool) error {
+	original := monitor.DeepCopy()
+	monitor.Spec.Enabled = enabled
+	if err := r.Patch(ctx, monitor, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to update monitor %s/%s: %w", monitor.Namespace, monitor.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Plugin operations", func() {
+	var (
+		reconciler *VolSyncMonitorReconciler
+		monitor    *volsyncv1alpha1.VolSyncMonitor
+	)
+
+	BeforeEach(func() {
+		reconciler = newFakeReconciler()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled: true,
+				JobSelector: &volsyncv1alpha1.JobSelector{
+					Namespaces: []string{"media"},
+				},
+				UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+	})
+
+	It("should only select jobs in the monitored namespaces", func() {
+		job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"}}
+		Expect(reconciler.MonitorSelectsJob(monitor, job)).To(BeTrue())
+
+		job.Namespace = "downloads"
+		Expect(reconciler.MonitorSelectsJob(monitor, job)).To(BeFalse())
+
+		monitor.Spec.Enabled = false
+		job.Namespace = "media"
+		Expect(reconciler.MonitorSelectsJob(monitor, job)).To(BeTrue())
+	})
+
+	moverJob := func(namespace string) batchv1.Job {
+		return batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: namespace},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	It("should build the same unlock job as the controller", func() {
+		job := moverJob("media")
+
+		unlockJob, err := reconciler.BuildUnlockJob(context.Background(), monitor, job, "manual")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unlockJob.Namespace).To(Equal("media"))
+		Expect(unlockJob.Labels).To(HaveKeyWithValue(MonitorLabel, "monitor"))
+		Expect(unlockJob.Labels).To(HaveKeyWithValue("homelab.rafaribe.com/mover", "restic"))
+		Expect(unlockJob.Labels).To(HaveKeyWithValue("homelab.rafaribe.com/monitor-namespace", "system"))
+		Expect(unlockJob.OwnerReferences).To(BeEmpty())
+
+		container := unlockJob.Spec.Template.Spec.Containers[0]
+		Expect(container.Image).To(Equal("restic/restic:latest"))
+		Expect(container.Args).To(Equal([]string{"-c", "restic unlock"}))
+		Expect(container.Env).To(ContainElement(HaveField("Value", "manual")))
+		Expect(container.EnvFrom).To(HaveLen(1))
+		Expect(container.EnvFrom[0].SecretRef.Name).To(Equal("plex-restic"))
+	})
+
+	It("should refuse to unlock movers without an unlock remediation", func() {
+		job := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-rclone-src-plex", Namespace: "media"}}
+
+		_, err := reconciler.BuildUnlockJob(context.Background(), monitor, job, "manual")
+		Expect(err).To(MatchError(ContainSubstring("rclone mover does not support unlocking")))
+	})
+
+	It("should own unlock jobs in the monitor namespace", func() {
+		job := moverJob("system")
+
+		unlockJob, err := reconciler.BuildUnlockJob(context.Background(), monitor, job, "manual")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unlockJob.OwnerReferences).To(HaveLen(1))
+		Expect(unlockJob.OwnerReferences[0].Name).To(Equal("monitor"))
+	})
+})
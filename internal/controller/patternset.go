@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Reasons of the PatternSetsFound condition; PatternSetNotFound is also
+// the reason of the event recorded when a referenced set goes missing
+const (
+	reasonPatternSetNotFound = "PatternSetNotFound"
+	reasonPatternSetsFound   = "PatternSetsFound"
+)
+
+// failurePattern is a compiled pattern that recognises a failure in mover logs
+type failurePattern struct {
+	// Name is set/pattern for patterns of a FailurePatternSet and empty for
+	// the lock error patterns of a mover
+	Name       string
+	Source     string
+	Regex      *regexp.Regexp
+	MoverTypes []volsyncv1alpha1.MoverType
+	Class      volsyncv1alpha1.FailureClass
+	Severity   volsyncv1alpha1.FailureSeverity
+}
+
+// appliesTo reports whether the pattern applies to a mover type
+func (p failurePattern) appliesTo(moverType volsyncv1alpha1.MoverType) bool {
+	if len(p.MoverTypes) == 0 {
+		return true
+	}
+	for _, candidate := range p.MoverTypes {
+		if candidate == moverType {
+			return true
+		}
+	}
+	return false
+}
+
+// failurePatterns returns the patterns matched against the logs of a failed
+// job of the mover: those of the referenced pattern sets, the cache patterns,
+// then the lock error patterns of the mover. Invalid lock error patterns are
+// skipped so that the others still apply.
+func (m moverSettings) failurePatterns(ctx context.Context) []failurePattern {
+	patterns := append([]failurePattern(nil), m.PatternSets...)
+
+	for _, source := range m.CachePatterns {
+		regex, err := patternSets.compile(m.Monitor, source)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Ignoring invalid cache pattern", "pattern", source)
+			continue
+		}
+		patterns = append(patterns, failurePattern{Source: source, Regex: regex, Class: volsyncv1alpha1.FailureClassCacheCorruption})
+	}
+
+	sources := m.Patterns
+	if len(sources) == 0 {
+		sources = defaultMovers[volsyncv1alpha1.MoverTypeRestic].Patterns
+	}
+	for _, source := range sources {
+		regex, err := patternSets.compile(m.Monitor, source)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Ignoring invalid lock error pattern", "pattern", source)
+			continue
+		}
+		patterns = append(patterns, failurePattern{Source: source, Regex: regex, Class: m.Class})
+	}
+	return patterns
+}
+
+// compiledPatternSet is a FailurePatternSet compiled and verified at one generation
+type compiledPatternSet struct {
+	UID        types.UID
+	Generation int64
+	// Patterns are the patterns that compiled and passed their examples
+	Patterns []failurePattern
+	// Failures are the patterns that did not
+	Failures []volsyncv1alpha1.FailurePatternFailure
+}
+
+// maxMonitorRegexes bounds the regexes cached for a monitor. Patterns of
+// per-object annotations are added as jobs are processed, so the cache of
+// a monitor starts over once it is full.
+const maxMonitorRegexes = 256
+
+// monitorGeneration identifies a monitor at one generation
+type monitorGeneration struct {
+	Key        string
+	UID        types.UID
+	Generation int64
+}
+
+// newMonitorGeneration returns the current generation of a monitor
+func newMonitorGeneration(monitor *volsyncv1alpha1.VolSyncMonitor) monitorGeneration {
+	return monitorGeneration{Key: monitorKey(monitor), UID: monitor.UID, Generation: monitor.Generation}
+}
+
+// monitorRegexes are the lock error and cache patterns compiled for a monitor generation
+type monitorRegexes struct {
+	monitorGeneration
+	regexes map[string]*regexp.Regexp
+}
+
+// patternSetCache holds the compiled pattern sets, keyed by name, and the
+// compiled patterns of each monitor, keyed by monitor
+type patternSetCache struct {
+	mu       sync.Mutex
+	sets     map[string]compiledPatternSet
+	monitors map[string]*monitorRegexes
+}
+
+// patternSets is shared by the monitor and pattern set controllers, so a set
+// is compiled once per generation
+var patternSets = &patternSetCache{sets: map[string]compiledPatternSet{}, monitors: map[string]*monitorRegexes{}}
+
+// compile returns a lock error or cache pattern of a monitor compiled case
+// insensitively. Regexes are kept until the monitor changes, so that they
+// are not compiled again for every job.
+func (c *patternSetCache) compile(monitor monitorGeneration, pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := c.monitors[monitor.Key]
+	if cached == nil || cached.monitorGeneration != monitor || len(cached.regexes) >= maxMonitorRegexes {
+		cached = &monitorRegexes{monitorGeneration: monitor, regexes: map[string]*regexp.Regexp{}}
+		c.monitors[monitor.Key] = cached
+	}
+	if regex, ok := cached.regexes[pattern]; ok {
+		return regex, nil
+	}
+	regex, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+	cached.regexes[pattern] = regex
+	return regex, nil
+}
+
+// forget drops the compiled patterns of a deleted monitor
+func (c *patternSetCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.monitors, key)
+}
+
+// get returns the compiled patterns of a set, compiling them when the set
+// changed since it was last compiled
+func (c *patternSetCache) get(set *volsyncv1alpha1.FailurePatternSet) compiledPatternSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.sets[set.Name]; ok && cached.UID == set.UID && cached.Generation == set.Generation {
+		return cached
+	}
+	compiled := compilePatternSet(set)
+	c.sets[set.Name] = compiled
+	return compiled
+}
+
+// compilePatternSet compiles the patterns of a set and verifies them against their examples
+func compilePatternSet(set *volsyncv1alpha1.FailurePatternSet) compiledPatternSet {
+	compiled := compiledPatternSet{UID: set.UID, Generation: set.Generation}
+	for _, pattern := range set.Spec.Patterns {
+		regex, err := regexp.Compile("(?i)" + pattern.Regex)
+		if err != nil {
+			compiled.Failures = append(compiled.Failures, volsyncv1alpha1.FailurePatternFailure{
+				Pattern: pattern.Name,
+				Message: fmt.Sprintf("invalid regex: %v", err),
+			})
+			continue
+		}
+		if message := verifyPatternExamples(regex, pattern.Examples); message != "" {
+			compiled.Failures = append(compiled.Failures, volsyncv1alpha1.FailurePatternFailure{Pattern: pattern.Name, Message: message})
+			continue
+		}
+
+		class := pattern.Class
+		if class == "" {
+			class = volsyncv1alpha1.FailureClassStaleLock
+		}
+		severity := pattern.Severity
+		if severity == "" {
+			severity = volsyncv1alpha1.FailureSeverityWarning
+		}
+		compiled.Patterns = append(compiled.Patterns, failurePattern{
+			Name:       set.Name + "/" + pattern.Name,
+			Source:     pattern.Regex,
+			Regex:      regex,
+			MoverTypes: pattern.MoverTypes,
+			Class:      class,
+			Severity:   severity,
+		})
+	}
+	return compiled
+}
+
+// verifyPatternExamples returns why a regex fails its examples, or "" when it passes them
+func verifyPatternExamples(regex *regexp.Regexp, examples *volsyncv1alpha1.FailurePatternExamples) string {
+	if examples == nil {
+		return ""
+	}
+	for _, line := range examples.Match {
+		if !regex.MatchString(line) {
+			return fmt.Sprintf("does not match example %q", line)
+		}
+	}
+	for _, line := range examples.NoMatch {
+		if regex.MatchString(line) {
+			return fmt.Sprintf("matches counter-example %q", line)
+		}
+	}
+	return ""
+}
+
+// updatePatternSetsCondition reports the FailurePatternSets a monitor
+// references that do not exist in the PatternSetsFound condition. An event
+// is only recorded when a set goes missing, not for every reconcile.
+func (r *VolSyncMonitorReconciler) updatePatternSetsCondition(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	if len(monitor.Spec.PatternSets) == 0 {
+		meta.RemoveStatusCondition(&monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePatternSetsFound)
+		return nil
+	}
+
+	var missing []string
+	for _, name := range monitor.Spec.PatternSets {
+		var set volsyncv1alpha1.FailurePatternSet
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &set); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get FailurePatternSet %s: %w", name, err)
+			}
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		setCondition(monitor, volsyncv1alpha1.ConditionTypePatternSetsFound, metav1.ConditionTrue, reasonPatternSetsFound,
+			"All referenced FailurePatternSets exist")
+		return nil
+	}
+
+	message := fmt.Sprintf("FailurePatternSet %s does not exist", strings.Join(missing, ", "))
+	previous := meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePatternSetsFound)
+	if previous == nil || previous.Status != metav1.ConditionFalse || previous.Message != message {
+		log.FromContext(ctx).Info("Referenced FailurePatternSets do not exist", "patternSets", missing)
+		if r.Recorder != nil {
+			r.Recorder.Event(monitor, corev1.EventTypeWarning, reasonPatternSetNotFound, message)
+		}
+	}
+	setCondition(monitor, volsyncv1alpha1.ConditionTypePatternSetsFound, metav1.ConditionFalse, reasonPatternSetNotFound, message)
+	return nil
+}
+
+// monitorPatternSets returns the verified patterns of the sets a monitor
+// references that apply to a mover. Missing sets are skipped; they are
+// reported by updatePatternSetsCondition.
+func (r *VolSyncMonitorReconciler) monitorPatternSets(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, moverType volsyncv1alpha1.MoverType) ([]failurePattern, error) {
+	var patterns []failurePattern
+	for _, name := range monitor.Spec.PatternSets {
+		var set volsyncv1alpha1.FailurePatternSet
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &set); err != nil {
+			if errors.IsNotFound(err) {
+				log.FromContext(ctx).V(1).Info("Referenced FailurePatternSet does not exist", "patternSet", name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to get FailurePatternSet %s: %w", name, err)
+		}
+		for _, pattern := range patternSets.get(&set).Patterns {
+			if pattern.appliesTo(moverType) {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns, nil
+}
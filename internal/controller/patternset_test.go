@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Failure pattern sets", func() {
+	var (
+		ctx context.Context
+		set *volsyncv1alpha1.FailurePatternSet
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		set = &volsyncv1alpha1.FailurePatternSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "restic", UID: "set-uid", Generation: 1},
+			Spec: volsyncv1alpha1.FailurePatternSetSpec{
+				Patterns: []volsyncv1alpha1.FailurePattern{{
+					Name:     "stale-lock",
+					Regex:    "already locked by PID",
+					Class:    volsyncv1alpha1.FailureClassStaleLock,
+					Severity: volsyncv1alpha1.FailureSeverityCritical,
+					Examples: &volsyncv1alpha1.FailurePatternExamples{
+						Match:   []string{"repository is already locked by PID 1"},
+						NoMatch: []string{"successfully removed 1 locks"},
+					},
+				}, {
+					Name:  "too-broad",
+					Regex: "lock",
+					Examples: &volsyncv1alpha1.FailurePatternExamples{
+						NoMatch: []string{"successfully removed 1 locks"},
+					},
+				}, {
+					Name:  "invalid",
+					Regex: "lock(",
+				}, {
+					Name:       "kopia-only",
+					Regex:      "unable to acquire lock",
+					MoverTypes: []volsyncv1alpha1.MoverType{volsyncv1alpha1.MoverTypeKopia},
+				}},
+			},
+		}
+	})
+
+	It("should use only the patterns that pass their examples", func() {
+		compiled := compilePatternSet(set)
+		Expect(compiled.Patterns).To(HaveLen(2))
+		Expect(compiled.Patterns[0].Name).To(Equal("restic/stale-lock"))
+		Expect(compiled.Patterns[1].Class).To(Equal(volsyncv1alpha1.FailureClassStaleLock))
+		Expect(compiled.Patterns[1].Severity).To(Equal(volsyncv1alpha1.FailureSeverityWarning))
+
+		Expect(compiled.Failures).To(HaveLen(2))
+		Expect(compiled.Failures[0].Pattern).To(Equal("too-broad"))
+		Expect(compiled.Failures[0].Message).To(ContainSubstring("successfully removed 1 locks"))
+		Expect(compiled.Failures[1].Pattern).To(Equal("invalid"))
+	})
+
+	It("should compile a set once per generation", func() {
+		cache := &patternSetCache{sets: map[string]compiledPatternSet{}}
+		first := cache.get(set)
+
+		set.Spec.Patterns = set.Spec.Patterns[:1]
+		Expect(cache.get(set).Patterns).To(HaveLen(len(first.Patterns)))
+
+		set.Generation = 2
+		Expect(cache.get(set).Patterns).To(HaveLen(1))
+	})
+
+	It("should keep the compiled patterns of a monitor until it changes", func() {
+		cache := &patternSetCache{sets: map[string]compiledPatternSet{}, monitors: map[string]*monitorRegexes{}}
+		monitor := monitorGeneration{Key: "system/monitor", UID: "monitor-uid", Generation: 1}
+		first, err := cache.compile(monitor, "already locked")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.compile(monitor, "already locked")).To(BeIdenticalTo(first))
+
+		monitor.Generation = 2
+		Expect(cache.compile(monitor, "already locked")).NotTo(BeIdenticalTo(first))
+
+		By("bounding the patterns of per-object annotations")
+		for i := 0; i <= maxMonitorRegexes; i++ {
+			_, err := cache.compile(monitor, fmt.Sprintf("pattern %d", i))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(len(cache.monitors[monitor.Key].regexes)).To(BeNumerically("<=", maxMonitorRegexes))
+
+		cache.forget(monitor.Key)
+		Expect(cache.monitors).To(BeEmpty())
+	})
+
+	It("should report missing sets once in a monitor condition", func() {
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+			Spec:       volsyncv1alpha1.VolSyncMonitorSpec{PatternSets: []string{"missing", set.Name}},
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := newFakeReconciler(set)
+		r.Recorder = recorder
+
+		Expect(r.updatePatternSetsCondition(ctx, monitor)).To(Succeed())
+		Expect(r.updatePatternSetsCondition(ctx, monitor)).To(Succeed())
+		found := meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePatternSetsFound)
+		Expect(found).NotTo(BeNil())
+		Expect(found.Status).To(Equal(metav1.ConditionFalse))
+		Expect(found.Message).To(Equal("FailurePatternSet missing does not exist"))
+		Expect(recorder.Events).To(HaveLen(1))
+
+		Expect(r.Create(ctx, &volsyncv1alpha1.FailurePatternSet{ObjectMeta: metav1.ObjectMeta{Name: "missing"}})).To(Succeed())
+		Expect(r.updatePatternSetsCondition(ctx, monitor)).To(Succeed())
+		found = meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypePatternSetsFound)
+		Expect(found.Status).To(Equal(metav1.ConditionTrue))
+	})
+
+	It("should report the verification in the status", func() {
+		c := newFakeClient(set)
+		r := &FailurePatternSetReconciler{Client: c, Scheme: c.Scheme()}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: set.Name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var updated volsyncv1alpha1.FailurePatternSet
+		Expect(r.Get(ctx, types.NamespacedName{Name: set.Name}, &updated)).To(Succeed())
+		Expect(updated.Status.Patterns).To(Equal(int32(4)))
+		Expect(updated.Status.ValidPatterns).To(Equal(int32(2)))
+		Expect(updated.Status.Failures).To(HaveLen(2))
+		ready := meta.FindStatusCondition(updated.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(reasonPatternVerificationFailed))
+	})
+
+	It("should classify failures with the patterns of referenced sets", func() {
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:           true,
+				PatternSets:       []string{"missing", set.Name},
+				UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+		failedJob := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		jobPod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						Message: "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+		r := newFakeReconciler(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, jobPod, set)
+
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.ProcessedJobs).To(HaveLen(1))
+		processed := monitor.Status.ProcessedJobs[0]
+		Expect(processed.DetectedBy).To(Equal(volsyncv1alpha1.LockErrorSourcePattern))
+		Expect(processed.Pattern).To(Equal("restic/stale-lock"))
+		Expect(processed.Severity).To(Equal(volsyncv1alpha1.FailureSeverityCritical))
+		Expect(processed.Classification).To(Equal(volsyncv1alpha1.FailureClassStaleLock))
+
+		By("mapping set changes to the monitors referencing them")
+		Expect(r.Create(ctx, monitor.DeepCopy())).To(Succeed())
+		Expect(r.findVolSyncMonitorsForPatternSet(ctx, set)).To(ConsistOf(ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: monitor.Name, Namespace: monitor.Namespace},
+		}))
+	})
+})
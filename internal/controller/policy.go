@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Annotations on Namespaces, ReplicationSources and Jobs that change how
+// monitors treat the jobs below them. The most specific object wins:
+// Job over ReplicationSource over Namespace over the monitor spec.
+const (
+	// MonitorModeAnnotation sets the MonitorMode
+	MonitorModeAnnotation = "homelab.rafaribe.com/volsync-monitor"
+	// LockErrorPatternsAnnotation replaces the error patterns, one regex per line
+	LockErrorPatternsAnnotation = "homelab.rafaribe.com/lock-error-patterns"
+	// UnlockJobTemplateAnnotation overrides fields of the unlock job template, as JSON
+	UnlockJobTemplateAnnotation = "homelab.rafaribe.com/unlock-job-template"
+)
+
+// reasonObserveOnly is the event reason of failures left alone in observe mode
+const reasonObserveOnly = "ObserveOnly"
+
+// MonitorMode controls what monitors do with the failed jobs of an object
+type MonitorMode string
+
+const (
+	// MonitorModeDisabled ignores failed jobs entirely
+	MonitorModeDisabled MonitorMode = "disabled"
+	// MonitorModeObserve detects and records failures without remediating them
+	MonitorModeObserve MonitorMode = "observe"
+	// MonitorModeEnforce detects and remediates failures, the default
+	MonitorModeEnforce MonitorMode = "enforce"
+)
+
+// objectPolicy is the combined effect of the annotations above a job
+type objectPolicy struct {
+	Mode MonitorMode
+	// ModeSource names the object that set the mode, empty for the default
+	ModeSource string
+
+	// Patterns replace the patterns of the mover when set
+	Patterns []string
+	// TemplateOverrides are JSON documents applied to the unlock job template in order
+	TemplateOverrides []string
+}
+
+// parseMonitorMode validates the value of MonitorModeAnnotation
+func parseMonitorMode(value string) (MonitorMode, error) {
+	switch mode := MonitorMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case MonitorModeDisabled, MonitorModeObserve, MonitorModeEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid %s annotation %q, expected disabled, observe or enforce", MonitorModeAnnotation, value)
+	}
+}
+
+// jobDisabled reports whether a job opts out of monitoring with its own annotation
+func jobDisabled(job batchv1.Job) bool {
+	mode, err := parseMonitorMode(job.Annotations[MonitorModeAnnotation])
+	return err == nil && mode == MonitorModeDisabled
+}
+
+// resolveJobPolicy combines the annotations of a job's Namespace, ReplicationSource and the job itself
+func (r *VolSyncMonitorReconciler) resolveJobPolicy(ctx context.Context, job batchv1.Job) (objectPolicy, error) {
+	source, _ := r.replicationSourceForJob(job)
+	return r.resolvePolicy(ctx, job.Namespace, source, &job)
+}
+
+// resolvePolicy combines the annotations of a Namespace, an optional
+// ReplicationSource and an optional job, most specific last
+func (r *VolSyncMonitorReconciler) resolvePolicy(ctx context.Context, namespace, source string, job *batchv1.Job) (objectPolicy, error) {
+	policy := objectPolicy{Mode: MonitorModeEnforce}
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		if !errors.IsNotFound(err) {
+			return policy, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+		}
+	} else if err := policy.apply("Namespace", &ns); err != nil {
+		return policy, err
+	}
+
+	if source != "" {
+		replicationSource, err := r.getVolSyncObject(ctx, namespace, "ReplicationSource", source)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return policy, fmt.Errorf("failed to get ReplicationSource %s/%s: %w", namespace, source, err)
+			}
+		} else if err := policy.apply("ReplicationSource", replicationSource); err != nil {
+			return policy, err
+		}
+	}
+
+	if job != nil {
+		if err := policy.apply("Job", job); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+// apply layers the annotations of a more specific object on the policy
+func (p *objectPolicy) apply(kind string, obj client.Object) error {
+	annotations := obj.GetAnnotations()
+	name := fmt.Sprintf("%s %s", kind, obj.GetName())
+	if obj.GetNamespace() != "" {
+		name = fmt.Sprintf("%s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	}
+
+	if value, ok := annotations[MonitorModeAnnotation]; ok {
+		mode, err := parseMonitorMode(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		p.Mode = mode
+		p.ModeSource = name
+	}
+
+	if value, ok := annotations[LockErrorPatternsAnnotation]; ok {
+		var patterns []string
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if _, err := regexp.Compile(line); err != nil {
+				return fmt.Errorf("%s: invalid %s pattern %q: %w", name, LockErrorPatternsAnnotation, line, err)
+			}
+			patterns = append(patterns, line)
+		}
+		if len(patterns) > 0 {
+			p.Patterns = patterns
+		}
+	}
+
+	if value, ok := annotations[UnlockJobTemplateAnnotation]; ok {
+		var template volsyncv1alpha1.UnlockJobTemplate
+		if err := json.Unmarshal([]byte(value), &template); err != nil {
+			return fmt.Errorf("%s: invalid %s annotation: %w", name, UnlockJobTemplateAnnotation, err)
+		}
+		p.TemplateOverrides = append(p.TemplateOverrides, value)
+	}
+	return nil
+}
+
+// applyToMover returns the mover settings with the pattern and template overrides
+// applied. Only the fields set in a template override replace the template's.
+func (p objectPolicy) applyToMover(mover moverSettings) (moverSettings, error) {
+	if len(p.Patterns) > 0 {
+		mover.Patterns = p.Patterns
+	}
+	if len(p.TemplateOverrides) == 0 {
+		return mover, nil
+	}
+
+	// Round-trip through JSON so that fields missing from an override are kept
+	data, err := json.Marshal(mover.Template)
+	if err != nil {
+		return mover, fmt.Errorf("failed to encode unlock job template: %w", err)
+	}
+	template := map[string]interface{}{}
+	if err := json.Unmarshal(data, &template); err != nil {
+		return mover, fmt.Errorf("failed to decode unlock job template: %w", err)
+	}
+	for _, override := range p.TemplateOverrides {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(override), &fields); err != nil {
+			return mover, fmt.Errorf("failed to decode unlock job template override: %w", err)
+		}
+		for key, value := range fields {
+			template[key] = value
+		}
+	}
+	if data, err = json.Marshal(template); err != nil {
+		return mover, fmt.Errorf("failed to encode unlock job template: %w", err)
+	}
+	var merged volsyncv1alpha1.UnlockJobTemplate
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return mover, fmt.Errorf("failed to decode unlock job template: %w", err)
+	}
+	mover.Template = merged
+	return mover, nil
+}
+
+// jobMoverSettings resolves the mover settings of a job including the
+// overrides of the objects above it, along with the policy
+func (r *VolSyncMonitorReconciler) jobMoverSettings(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) (moverSettings, objectPolicy, error) {
+	mover := r.resolveMoverSettings(monitor, r.detectMoverType(ctx, job))
+	patterns, err := r.monitorPatternSets(ctx, monitor, mover.Type)
+	if err != nil {
+		return mover, objectPolicy{}, err
+	}
+	mover.PatternSets = patterns
+
+	policy, err := r.resolveJobPolicy(ctx, job)
+	if err != nil {
+		return mover, policy, err
+	}
+	mover, err = policy.applyToMover(mover)
+	return mover, policy, err
+}
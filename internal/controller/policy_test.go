@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Monitoring policy annotations", func() {
+	var (
+		ctx     context.Context
+		monitor *volsyncv1alpha1.VolSyncMonitor
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 2,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+	})
+
+	namespace := func(name string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+
+	lockedJob := func(annotations map[string]string) (*batchv1.Job, *corev1.Pod) {
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-postgres", Namespace: "databases", UID: "job-uid", Annotations: annotations},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-postgres-abcde", Namespace: "databases", Labels: map[string]string{"job-name": job.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+		return job, pod
+	}
+
+	Describe("resolvePolicy", func() {
+		It("should let the most specific object win", func() {
+			source := newReplicationSource("databases", "postgres", "postgres-restic")
+			source.SetAnnotations(map[string]string{MonitorModeAnnotation: "observe"})
+			r := newFakeReconciler(namespace("databases", map[string]string{MonitorModeAnnotation: "disabled"}), source)
+
+			policy, err := r.resolvePolicy(ctx, "databases", "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Mode).To(Equal(MonitorModeDisabled))
+			Expect(policy.ModeSource).To(Equal("Namespace databases"))
+
+			policy, err = r.resolvePolicy(ctx, "databases", "postgres", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Mode).To(Equal(MonitorModeObserve))
+			Expect(policy.ModeSource).To(Equal("ReplicationSource databases/postgres"))
+
+			job, _ := lockedJob(map[string]string{MonitorModeAnnotation: "Enforce"})
+			policy, err = r.resolveJobPolicy(ctx, *job)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Mode).To(Equal(MonitorModeEnforce))
+			Expect(policy.ModeSource).To(Equal("Job databases/volsync-src-postgres"))
+		})
+
+		It("should default to enforce", func() {
+			policy, err := newFakeReconciler().resolvePolicy(ctx, "media", "plex", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Mode).To(Equal(MonitorModeEnforce))
+			Expect(policy.ModeSource).To(BeEmpty())
+		})
+
+		It("should reject invalid annotations", func() {
+			r := newFakeReconciler(namespace("databases", map[string]string{MonitorModeAnnotation: "off"}))
+			_, err := r.resolvePolicy(ctx, "databases", "", nil)
+			Expect(err).To(MatchError(ContainSubstring("expected disabled, observe or enforce")))
+
+			r = newFakeReconciler(namespace("databases", map[string]string{LockErrorPatternsAnnotation: "lock("}))
+			_, err = r.resolvePolicy(ctx, "databases", "", nil)
+			Expect(err).To(MatchError(ContainSubstring("invalid " + LockErrorPatternsAnnotation + " pattern")))
+		})
+	})
+
+	Describe("applyToMover", func() {
+		It("should replace patterns and merge template overrides", func() {
+			r := newFakeReconciler()
+			monitor.Spec.UnlockJobTemplate.Resources = &volsyncv1alpha1.ResourceRequirements{
+				Limits: map[string]string{"memory": "256Mi"},
+			}
+			mover := r.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic)
+
+			policy := objectPolicy{Mode: MonitorModeEnforce}
+			ns := namespace("databases", map[string]string{
+				LockErrorPatternsAnnotation: "pg lock held\n\nrepository is already locked\n",
+				UnlockJobTemplateAnnotation: `{"image":"restic/restic:0.16.0","serviceAccount":"unlock"}`,
+			})
+			Expect(policy.apply("Namespace", ns)).To(Succeed())
+			job, _ := lockedJob(map[string]string{UnlockJobTemplateAnnotation: `{"serviceAccount":"postgres-unlock"}`})
+			Expect(policy.apply("Job", job)).To(Succeed())
+
+			mover, err := policy.applyToMover(mover)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mover.Patterns).To(Equal([]string{"pg lock held", "repository is already locked"}))
+			Expect(mover.Template.Image).To(Equal("restic/restic:0.16.0"))
+			Expect(mover.Template.ServiceAccount).To(Equal("postgres-unlock"))
+			Expect(mover.Template.Resources.Limits).To(HaveKeyWithValue("memory", "256Mi"))
+			Expect(mover.Template.Args).To(Equal([]string{"-c", "restic unlock"}))
+		})
+	})
+
+	It("should not select jobs that opt out themselves", func() {
+		job, _ := lockedJob(map[string]string{MonitorModeAnnotation: "disabled"})
+		Expect(newFakeReconciler().matchesJobSelector(*job, nil, nil)).To(BeFalse())
+	})
+
+	It("should ignore failed jobs in disabled namespaces", func() {
+		job, pod := lockedJob(nil)
+		r := newFakeReconciler(monitor, namespace("databases", map[string]string{MonitorModeAnnotation: "disabled"}), job, pod)
+
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.ProcessedJobs).To(BeEmpty())
+		Expect(monitor.Status.TotalLockErrorsDetected).To(BeZero())
+	})
+
+	It("should record but not remediate lock errors in observe mode", func() {
+		job, pod := lockedJob(nil)
+		r := newFakeReconciler(monitor, namespace("databases", map[string]string{MonitorModeAnnotation: "observe"}), job, pod)
+
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.ProcessedJobs).To(HaveLen(1))
+		Expect(monitor.Status.ProcessedJobs[0].Remediation).To(Equal(volsyncv1alpha1.RemediationActionNone))
+		Expect(monitor.Status.ProcessedJobs[0].UnlockJobName).To(BeEmpty())
+		Expect(monitor.Status.TotalUnlocksCreated).To(BeZero())
+
+		var jobs batchv1.JobList
+		Expect(r.List(ctx, &jobs, client.MatchingLabels{MonitorLabel: "monitor"})).To(Succeed())
+		Expect(jobs.Items).To(BeEmpty())
+	})
+})
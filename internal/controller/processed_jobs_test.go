@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
+)
+
+var _ = Describe("Processed job tracking", func() {
+	var (
+		reconciler  *VolSyncMonitorReconciler
+		created     time.Time
+		failureTime metav1.Time
+	)
+
+	failedJob := func(uid types.UID, createdAt time.Time, failedAt metav1.Time) batchv1.Job {
+		return batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "volsync-src-prowlarr-nfs",
+				Namespace:         "downloads",
+				UID:               uid,
+				CreationTimestamp: metav1.NewTime(createdAt),
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{
+						Type:               batchv1.JobFailed,
+						Status:             corev1.ConditionTrue,
+						LastTransitionTime: failedAt,
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		reconciler = &VolSyncMonitorReconciler{}
+		created = time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+		failureTime = metav1.NewTime(created.Add(10 * time.Minute))
+	})
+
+	It("should skip a failure that was already processed", func() {
+		job := failedJob("uid-1", created, failureTime)
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			Status: volsyncv1alpha1.VolSyncMonitorStatus{
+				ProcessedJobs: []volsyncv1alpha1.ProcessedJob{
+					{
+						JobName:       job.Name,
+						Namespace:     job.Namespace,
+						JobUID:        "uid-1",
+						FailureTime:   &failureTime,
+						ProcessedTime: metav1.NewTime(failureTime.Add(time.Minute)),
+					},
+				},
+			},
+		}
+
+		Expect(reconciler.isJobAlreadyProcessed(monitor, job)).To(BeTrue())
+	})
+
+	It("should handle a recreated job with a reused name", func() {
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			Status: volsyncv1alpha1.VolSyncMonitorStatus{
+				ProcessedJobs: []volsyncv1alpha1.ProcessedJob{
+					{
+						JobName:       "volsync-src-prowlarr-nfs",
+						Namespace:     "downloads",
+						JobUID:        "uid-1",
+						FailureTime:   &failureTime,
+						ProcessedTime: metav1.NewTime(failureTime.Add(time.Minute)),
+					},
+				},
+			},
+		}
+
+		recreated := failedJob("uid-2", created.Add(time.Hour), metav1.NewTime(created.Add(70*time.Minute)))
+		Expect(reconciler.isJobAlreadyProcessed(monitor, recreated)).To(BeFalse())
+	})
+
+	It("should not match jobs with the same name in another namespace", func() {
+		job := failedJob("uid-1", created, failureTime)
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			Status: volsyncv1alpha1.VolSyncMonitorStatus{
+				ProcessedJobs: []volsyncv1alpha1.ProcessedJob{
+					{JobName: job.Name, Namespace: "media", JobUID: "uid-1"},
+				},
+			},
+		}
+
+		Expect(reconciler.isJobAlreadyProcessed(monitor, job)).To(BeFalse())
+	})
+
+	It("should match entries recorded without a UID only for jobs that existed at the time", func() {
+		monitor := &volsyncv1alpha1.VolSyncMonitor{
+			Status: volsyncv1alpha1.VolSyncMonitorStatus{
+				ProcessedJobs: []volsyncv1alpha1.ProcessedJob{
+					{
+						JobName:       "volsync-src-prowlarr-nfs",
+						Namespace:     "downloads",
+						ProcessedTime: metav1.NewTime(created.Add(30 * time.Minute)),
+					},
+				},
+			},
+		}
+
+		original := failedJob("uid-1", created, failureTime)
+		Expect(reconciler.isJobAlreadyProcessed(monitor, original)).To(BeTrue())
+
+		recreated := failedJob("uid-2", created.Add(time.Hour), metav1.NewTime(created.Add(70*time.Minute)))
+		Expect(reconciler.isJobAlreadyProcessed(monitor, recreated)).To(BeFalse())
+	})
+
+	It("should keep the configured number of processed jobs", func() {
+		cfg := config.Default()
+		cfg.VolSyncMonitor.ProcessedJobsHistoryLimit = 2
+		reconciler.Config = config.NewStore(cfg)
+
+		monitor := &volsyncv1alpha1.VolSyncMonitor{}
+		for _, name := range []string{"volsync-src-a", "volsync-src-b", "volsync-src-c"} {
+			monitor.Status.ProcessedJobs = append(monitor.Status.ProcessedJobs, volsyncv1alpha1.ProcessedJob{JobName: name})
+		}
+
+		reconciler.cleanupProcessedJobs(monitor)
+		Expect(monitor.Status.ProcessedJobs).To(HaveLen(2))
+		Expect(monitor.Status.ProcessedJobs[0].JobName).To(Equal("volsync-src-b"))
+	})
+})
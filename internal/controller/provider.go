@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// backupProvider is a backup operator whose mover jobs a monitor handles.
+// Detection and remediation are shared by all providers; a provider tells
+// which jobs are its own, what they belong to, which mover ran them and how
+// their repository is reached.
+type backupProvider interface {
+	// name identifies the provider in the monitor spec and status
+	name() volsyncv1alpha1.BackupProvider
+	// ownsJob reports whether a job is a mover job of the provider
+	ownsJob(job *batchv1.Job) bool
+	// jobIdentity resolves the app and backup object of a job
+	jobIdentity(ctx context.Context, job *batchv1.Job) jobIdentity
+	// moverType returns the mover that ran a job
+	moverType(ctx context.Context, job batchv1.Job) volsyncv1alpha1.MoverType
+	// unlockTarget discovers the repository configuration of a job
+	unlockTarget(ctx context.Context, job batchv1.Job) (*unlockTarget, error)
+	// runningJobs lists the running jobs, other than the job itself, that use
+	// the repository of a job and would hold its lock
+	runningJobs(ctx context.Context, job batchv1.Job) ([]batchv1.Job, error)
+}
+
+// volsyncProvider handles the mover jobs of ReplicationSources and ReplicationDestinations
+type volsyncProvider struct {
+	r *VolSyncMonitorReconciler
+}
+
+func (p volsyncProvider) name() volsyncv1alpha1.BackupProvider {
+	return volsyncv1alpha1.BackupProviderVolSync
+}
+
+func (p volsyncProvider) ownsJob(job *batchv1.Job) bool {
+	return p.r.isVolSyncJob(job)
+}
+
+func (p volsyncProvider) jobIdentity(ctx context.Context, job *batchv1.Job) jobIdentity {
+	return p.r.resolveVolSyncJobIdentity(ctx, job)
+}
+
+func (p volsyncProvider) moverType(ctx context.Context, job batchv1.Job) volsyncv1alpha1.MoverType {
+	return p.r.detectVolSyncMoverType(ctx, job)
+}
+
+func (p volsyncProvider) unlockTarget(ctx context.Context, job batchv1.Job) (*unlockTarget, error) {
+	return p.r.discoverVolSyncUnlockTarget(ctx, job)
+}
+
+func (p volsyncProvider) runningJobs(ctx context.Context, job batchv1.Job) ([]batchv1.Job, error) {
+	identity := p.r.jobIdentityFromJob(&job)
+	return p.r.runningVolSyncJobs(ctx, job.Namespace, identity.ObjectName, identity.Direction, job.UID)
+}
+
+// backupProviders returns all providers, in the order jobs are matched against them
+func (r *VolSyncMonitorReconciler) backupProviders() []backupProvider {
+	return []backupProvider{volsyncProvider{r: r}, k8upProvider{r: r}}
+}
+
+// monitorProviders returns the providers a monitor handles the jobs of
+func monitorProviders(monitor *volsyncv1alpha1.VolSyncMonitor) []volsyncv1alpha1.BackupProvider {
+	if len(monitor.Spec.Providers) == 0 {
+		return []volsyncv1alpha1.BackupProvider{volsyncv1alpha1.BackupProviderVolSync}
+	}
+	return monitor.Spec.Providers
+}
+
+// handlesProvider reports whether a monitor handles the jobs of a provider
+func handlesProvider(monitor *volsyncv1alpha1.VolSyncMonitor, provider volsyncv1alpha1.BackupProvider) bool {
+	for _, name := range monitorProviders(monitor) {
+		if name == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// jobProvider returns the provider of a job among the given providers, or
+// nil when none of them ran it. All providers are matched when none are given.
+func (r *VolSyncMonitorReconciler) jobProvider(job *batchv1.Job, names []volsyncv1alpha1.BackupProvider) backupProvider {
+	for _, provider := range r.backupProviders() {
+		if !provider.ownsJob(job) {
+			continue
+		}
+		if len(names) == 0 {
+			return provider
+		}
+		for _, name := range names {
+			if provider.name() == name {
+				return provider
+			}
+		}
+	}
+	return nil
+}
+
+// providerOf returns the provider that ran a job. Jobs no provider claims,
+// such as the placeholder jobs of manual unlocks, are treated as VolSync jobs.
+func (r *VolSyncMonitorReconciler) providerOf(job *batchv1.Job) backupProvider {
+	if provider := r.jobProvider(job, nil); provider != nil {
+		return provider
+	}
+	return volsyncProvider{r: r}
+}
+
+// resolveJobIdentity resolves the app, backup object and direction of a job
+func (r *VolSyncMonitorReconciler) resolveJobIdentity(ctx context.Context, job *batchv1.Job) jobIdentity {
+	return r.providerOf(job).jobIdentity(ctx, job)
+}
+
+// detectMoverType determines which mover ran a job
+func (r *VolSyncMonitorReconciler) detectMoverType(ctx context.Context, job batchv1.Job) volsyncv1alpha1.MoverType {
+	return r.providerOf(&job).moverType(ctx, job)
+}
+
+// discoverUnlockTarget discovers the repository configuration of a failed job
+func (r *VolSyncMonitorReconciler) discoverUnlockTarget(ctx context.Context, job batchv1.Job) (*unlockTarget, error) {
+	return r.providerOf(&job).unlockTarget(ctx, job)
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+)
+
+const (
+	// defaultSnapshotLogLines is the number of log lines kept of a failed pod
+	defaultSnapshotLogLines = 100
+	// snapshotLogBytes bounds the log tail kept in a snapshot ConfigMap, and
+	// recordSnapshotLogBytes the one kept in the UnlockRecord status
+	snapshotLogBytes       = 256 * 1024
+	recordSnapshotLogBytes = 16 * 1024
+
+	// snapshotRecordLabel is set on snapshot ConfigMaps to the name of their UnlockRecord
+	snapshotRecordLabel = "homelab.rafaribe.com/unlock-record"
+
+	// reasonFailedJobKept is the event reason for failed jobs kept after a failed unlock
+	reasonFailedJobKept = "FailedJobKept"
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=create
+
+// removesFailedJob reports whether a failed job handled with the remediation is removed
+func removesFailedJob(monitor *volsyncv1alpha1.VolSyncMonitor, remediation volsyncv1alpha1.RemediationAction) bool {
+	switch remediation {
+	case volsyncv1alpha1.RemediationActionUnlock:
+		return monitor.Spec.RemoveFailedJobs
+	case volsyncv1alpha1.RemediationActionRetry, volsyncv1alpha1.RemediationActionRecreateCache:
+		return true
+	}
+	return false
+}
+
+// snapshotFailedPod captures the status and log tail of the most recent pod of
+// a failed job, so that they outlive the job. It returns nil when the job has
+// no pods.
+func (r *VolSyncMonitorReconciler) snapshotFailedPod(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) *volsyncv1alpha1.PodSnapshot {
+	logger := log.FromContext(ctx)
+
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name},
+	); err != nil {
+		logger.Error(err, "Failed to list pods of failed job", "job", job.Name, "namespace", job.Namespace)
+		return nil
+	}
+	if len(podList.Items) == 0 {
+		return nil
+	}
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[j].CreationTimestamp.Before(&podList.Items[i].CreationTimestamp)
+	})
+	pod := podList.Items[0]
+
+	snapshot := &volsyncv1alpha1.PodSnapshot{
+		Name:        pod.Name,
+		Phase:       pod.Status.Phase,
+		Reason:      pod.Status.Reason,
+		Message:     pod.Status.Message,
+		NodeName:    pod.Spec.NodeName,
+		CaptureTime: metav1.Now(),
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		container := volsyncv1alpha1.ContainerSnapshot{Name: status.Name, RestartCount: status.RestartCount}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		switch {
+		case terminated != nil:
+			exitCode := terminated.ExitCode
+			container.ExitCode = &exitCode
+			container.Reason = terminated.Reason
+			container.Message = terminated.Message
+		case status.State.Waiting != nil:
+			container.Reason = status.State.Waiting.Reason
+			container.Message = status.State.Waiting.Message
+		}
+		snapshot.Containers = append(snapshot.Containers, container)
+	}
+
+	lines := defaultSnapshotLogLines
+	if removal := monitor.Spec.FailedJobRemoval; removal != nil && removal.LogTailLines > 0 {
+		lines = int(removal.LogTailLines)
+	}
+	logs, err := r.podLogs(ctx, pod)
+	if err != nil {
+		logger.V(1).Info("Could not read logs of failed pod", "pod", pod.Name, "namespace", pod.Namespace, "error", err.Error())
+	} else {
+		snapshot.LogsTail = helpers.TailLines(logs, lines, snapshotLogBytes)
+	}
+
+	return snapshot
+}
+
+// recordFailedPod stores the snapshot of a failed pod in the UnlockRecord of
+// the failure, and in a ConfigMap owned by the record when configured
+func (r *VolSyncMonitorReconciler) recordFailedPod(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, record *volsyncv1alpha1.UnlockRecord, snapshot *volsyncv1alpha1.PodSnapshot) error {
+	if removal := monitor.Spec.FailedJobRemoval; removal != nil && removal.SnapshotConfigMap {
+		configMap, err := r.createSnapshotConfigMap(ctx, record, snapshot)
+		if err != nil {
+			return err
+		}
+		snapshot.ConfigMapName = configMap.Name
+	}
+
+	status := snapshot.DeepCopy()
+	if len(status.LogsTail) > recordSnapshotLogBytes {
+		status.LogsTail = status.LogsTail[len(status.LogsTail)-recordSnapshotLogBytes:]
+	}
+	record.Status.FailedPod = status
+	if err := r.Status().Update(ctx, record); err != nil {
+		return fmt.Errorf("failed to store failed pod snapshot in unlock record %s: %w", record.Name, err)
+	}
+	return nil
+}
+
+// createSnapshotConfigMap stores a pod snapshot in a ConfigMap named after its
+// UnlockRecord. The ConfigMap is owned by the record and pruned with it.
+func (r *VolSyncMonitorReconciler) createSnapshotConfigMap(ctx context.Context, record *volsyncv1alpha1.UnlockRecord, snapshot *volsyncv1alpha1.PodSnapshot) (*corev1.ConfigMap, error) {
+	status := snapshot.DeepCopy()
+	status.LogsTail = ""
+	podJSON, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pod snapshot: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      record.Name,
+			Namespace: record.Namespace,
+			Labels: map[string]string{
+				recordMonitorLabel:          record.Labels[recordMonitorLabel],
+				recordMonitorNamespaceLabel: record.Labels[recordMonitorNamespaceLabel],
+				recordFailedJobLabel:        record.Labels[recordFailedJobLabel],
+				snapshotRecordLabel:         record.Name,
+			},
+		},
+		Data: map[string]string{
+			"pod.json": string(podJSON),
+			"logs":     snapshot.LogsTail,
+		},
+	}
+	if err := controllerutil.SetOwnerReference(record, configMap, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set owner reference on snapshot ConfigMap: %w", err)
+	}
+	if err := r.Create(ctx, configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create snapshot ConfigMap %s: %w", configMap.Name, err)
+	}
+	return configMap, nil
+}
+
+// removePendingFailedJobs removes the failed jobs that waited for their unlock
+// job to finish and for the removal delay to pass. It returns how long until
+// the next pending removal is due, or zero when none is waiting on the delay.
+func (r *VolSyncMonitorReconciler) removePendingFailedJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	var delay time.Duration
+	onlyOnSuccess := false
+	if removal := monitor.Spec.FailedJobRemoval; removal != nil {
+		if removal.Delay != nil {
+			delay = removal.Delay.Duration
+		}
+		onlyOnSuccess = removal.OnlyOnUnlockSuccess
+	}
+
+	var next time.Duration
+	for i := range monitor.Status.ProcessedJobs {
+		processed := &monitor.Status.ProcessedJobs[i]
+		if !processed.RemovalPending {
+			continue
+		}
+
+		outcome, finished, err := r.unlockOutcome(ctx, *processed)
+		if err != nil {
+			return next, err
+		}
+		switch outcome {
+		case volsyncv1alpha1.UnlockOutcomeRunning:
+			continue
+		case volsyncv1alpha1.UnlockOutcomeSucceeded:
+		case volsyncv1alpha1.UnlockOutcomeFailed:
+			if onlyOnSuccess {
+				processed.RemovalPending = false
+				logger.Info("Keeping failed job, its unlock job failed", "job", processed.JobName, "namespace", processed.Namespace)
+				if r.Recorder != nil {
+					r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonFailedJobKept,
+						"Kept failed job %s/%s because unlock job %s failed", processed.Namespace, processed.JobName, processed.UnlockJobName)
+				}
+				continue
+			}
+		default:
+			// Without a record or unlock job the outcome is lost; keep the job
+			processed.RemovalPending = false
+			logger.Info("Keeping failed job, the outcome of its unlock is unknown", "job", processed.JobName, "namespace", processed.Namespace)
+			continue
+		}
+
+		if wait := time.Until(finished.Add(delay)); wait > 0 {
+			if next == 0 || wait < next {
+				next = wait
+			}
+			continue
+		}
+
+		var job batchv1.Job
+		if err := r.Get(ctx, types.NamespacedName{Name: processed.JobName, Namespace: processed.Namespace}, &job); err != nil {
+			if !errors.IsNotFound(err) {
+				return next, fmt.Errorf("failed to get failed job %s: %w", processed.JobName, err)
+			}
+			processed.RemovalPending = false
+			continue
+		}
+		// VolSync reuses job names; a job with another UID is a later run
+		if processed.JobUID != "" && job.UID != processed.JobUID {
+			processed.RemovalPending = false
+			continue
+		}
+
+		if err := r.removeFailedJob(ctx, job); err != nil && !errors.IsNotFound(err) {
+			return next, fmt.Errorf("failed to remove failed job %s: %w", job.Name, err)
+		}
+		processed.RemovalPending = false
+		processed.Removed = true
+		monitor.Status.TotalFailedJobsRemoved++
+		logger.Info("Removed failed job", "job", job.Name, "namespace", job.Namespace, "unlockOutcome", outcome)
+		if processed.RecordName != "" {
+			if err := r.recordFailedJobRemoved(ctx, processed.Namespace, processed.RecordName); err != nil {
+				logger.Error(err, "Failed to record the removal of the failed job", "job", job.Name, "record", processed.RecordName)
+			}
+		}
+	}
+
+	return next, nil
+}
+
+// unlockOutcome returns the outcome of the unlock of a processed job and when
+// it finished, from its UnlockRecord once the record is finished or else from
+// the unlock job itself. The outcome is empty when neither knows it anymore.
+func (r *VolSyncMonitorReconciler) unlockOutcome(ctx context.Context, processed volsyncv1alpha1.ProcessedJob) (volsyncv1alpha1.UnlockOutcome, time.Time, error) {
+	if processed.RecordName != "" {
+		var record volsyncv1alpha1.UnlockRecord
+		err := r.Get(ctx, types.NamespacedName{Name: processed.RecordName, Namespace: processed.Namespace}, &record)
+		if err == nil && record.Status.CompletionTime != nil {
+			return record.Status.Outcome, record.Status.CompletionTime.Time, nil
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return "", time.Time{}, fmt.Errorf("failed to get unlock record %s: %w", processed.RecordName, err)
+		}
+	}
+
+	var unlockJob batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Name: processed.UnlockJobName, Namespace: processed.Namespace}, &unlockJob); err != nil {
+		if errors.IsNotFound(err) {
+			return "", time.Time{}, nil
+		}
+		return "", time.Time{}, fmt.Errorf("failed to get unlock job %s: %w", processed.UnlockJobName, err)
+	}
+	for _, condition := range unlockJob.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return volsyncv1alpha1.UnlockOutcomeSucceeded, condition.LastTransitionTime.Time, nil
+		case batchv1.JobFailed:
+			return volsyncv1alpha1.UnlockOutcomeFailed, condition.LastTransitionTime.Time, nil
+		}
+	}
+	return volsyncv1alpha1.UnlockOutcomeRunning, time.Time{}, nil
+}
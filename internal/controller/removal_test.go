@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Failed job removal", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		failedJob *batchv1.Job
+		jobPod    *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:           true,
+				RemoveFailedJobs:  true,
+				UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		jobPod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "restic",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Reason:   "Error",
+						Message:  "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		return newFakeReconciler(append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, jobPod)...)
+	}
+
+	failedJobExists := func(r *VolSyncMonitorReconciler) bool {
+		err := r.Get(ctx, types.NamespacedName{Name: failedJob.Name, Namespace: failedJob.Namespace}, &batchv1.Job{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	// finishUnlock marks the unlock job of the processed failure as finished an hour ago
+	finishUnlock := func(r *VolSyncMonitorReconciler, condition batchv1.JobConditionType) {
+		var unlockJob batchv1.Job
+		name := monitor.Status.ProcessedJobs[0].UnlockJobName
+		Expect(r.Get(ctx, types.NamespacedName{Name: name, Namespace: "media"}, &unlockJob)).To(Succeed())
+		finished := metav1.NewTime(time.Now().Add(-time.Hour))
+		unlockJob.Status.CompletionTime = &finished
+		unlockJob.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue, LastTransitionTime: finished}}
+		Expect(r.Status().Update(ctx, &unlockJob)).To(Succeed())
+	}
+
+	It("should remove the failed job right away and keep a snapshot of its pod", func() {
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(failedJobExists(r)).To(BeFalse())
+		Expect(monitor.Status.ProcessedJobs).To(HaveLen(1))
+		Expect(monitor.Status.ProcessedJobs[0].Removed).To(BeTrue())
+
+		var record volsyncv1alpha1.UnlockRecord
+		Expect(r.Get(ctx, types.NamespacedName{Name: monitor.Status.ProcessedJobs[0].RecordName, Namespace: "media"}, &record)).To(Succeed())
+		Expect(record.Status.FailedPod).NotTo(BeNil())
+		Expect(record.Status.FailedPod.Name).To(Equal(jobPod.Name))
+		Expect(record.Status.FailedPod.Phase).To(Equal(corev1.PodFailed))
+		Expect(record.Status.FailedPod.NodeName).To(Equal("node-1"))
+		Expect(record.Status.FailedPod.Containers).To(HaveLen(1))
+		Expect(*record.Status.FailedPod.Containers[0].ExitCode).To(Equal(int32(1)))
+		Expect(record.Status.FailedPod.ConfigMapName).To(BeEmpty())
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerFailedJobRemoved))
+	})
+
+	It("should wait for the unlock job and the delay before removing the failed job", func() {
+		monitor.Spec.FailedJobRemoval = &volsyncv1alpha1.FailedJobRemovalSpec{
+			Delay:             &metav1.Duration{Duration: 30 * time.Minute},
+			SnapshotConfigMap: true,
+		}
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(failedJobExists(r)).To(BeTrue())
+		Expect(monitor.Status.ProcessedJobs[0].RemovalPending).To(BeTrue())
+
+		By("storing the snapshot in a ConfigMap owned by the record")
+		recordName := monitor.Status.ProcessedJobs[0].RecordName
+		var configMap corev1.ConfigMap
+		Expect(r.Get(ctx, types.NamespacedName{Name: recordName, Namespace: "media"}, &configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKey("pod.json"))
+		Expect(configMap.OwnerReferences).To(HaveLen(1))
+		Expect(configMap.OwnerReferences[0].Kind).To(Equal("UnlockRecord"))
+
+		By("keeping the job while the unlock job runs")
+		_, err = r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failedJobExists(r)).To(BeTrue())
+
+		By("removing the job once the delay after the unlock passed")
+		finishUnlock(r, batchv1.JobComplete)
+		_, err = r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failedJobExists(r)).To(BeFalse())
+		Expect(monitor.Status.ProcessedJobs[0].RemovalPending).To(BeFalse())
+		Expect(monitor.Status.ProcessedJobs[0].Removed).To(BeTrue())
+		Expect(monitor.Status.TotalFailedJobsRemoved).To(Equal(int32(1)))
+
+		var record volsyncv1alpha1.UnlockRecord
+		Expect(r.Get(ctx, types.NamespacedName{Name: recordName, Namespace: "media"}, &record)).To(Succeed())
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerFailedJobRemoved))
+	})
+
+	It("should requeue when the removal delay ends", func() {
+		monitor.Spec.FailedJobRemoval = &volsyncv1alpha1.FailedJobRemovalSpec{
+			Delay: &metav1.Duration{Duration: 61 * time.Minute},
+		}
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		finishUnlock(r, batchv1.JobComplete)
+		next, err := r.removePendingFailedJobs(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(BeNumerically("~", time.Minute, 10*time.Second))
+		Expect(failedJobExists(r)).To(BeTrue())
+	})
+
+	It("should keep the failed job when the unlock failed", func() {
+		monitor.Spec.FailedJobRemoval = &volsyncv1alpha1.FailedJobRemovalSpec{OnlyOnUnlockSuccess: true}
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		finishUnlock(r, batchv1.JobFailed)
+		_, err = r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failedJobExists(r)).To(BeTrue())
+		Expect(monitor.Status.ProcessedJobs[0].RemovalPending).To(BeFalse())
+		Expect(monitor.Status.ProcessedJobs[0].Removed).To(BeFalse())
+
+		var record volsyncv1alpha1.UnlockRecord
+		Expect(r.Get(ctx, types.NamespacedName{Name: monitor.Status.ProcessedJobs[0].RecordName, Namespace: "media"}, &record)).To(Succeed())
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerUnlockFailed))
+	})
+
+	It("should leave a later run of the same job alone", func() {
+		monitor.Spec.FailedJobRemoval = &volsyncv1alpha1.FailedJobRemovalSpec{}
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("replacing the failed job with a new run")
+		Expect(r.Delete(ctx, failedJob.DeepCopy())).To(Succeed())
+		rerun := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: failedJob.Name, Namespace: failedJob.Namespace, UID: "rerun-uid"}}
+		Expect(r.Create(ctx, rerun)).To(Succeed())
+
+		finishUnlock(r, batchv1.JobComplete)
+		_, err = r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failedJobExists(r)).To(BeTrue())
+		Expect(monitor.Status.ProcessedJobs[0].RemovalPending).To(BeFalse())
+		Expect(monitor.Status.ProcessedJobs[0].Removed).To(BeFalse())
+	})
+})
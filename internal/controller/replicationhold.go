@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// Metadata a monitor sets on the VolSync objects it holds during an unlock.
+// The original state is kept on the object itself, so that it is restored
+// after a restart of the controller.
+const (
+	// ReplicationHoldAnnotation records the unlock job an object is held for
+	// and the state that was changed to hold it
+	ReplicationHoldAnnotation = "homelab.rafaribe.com/unlock-hold"
+	// ReplicationHeldByLabel is the name of the monitor holding an object
+	ReplicationHeldByLabel = "homelab.rafaribe.com/held-by"
+	// ReplicationHeldByNamespaceLabel is the namespace of the monitor holding an object
+	ReplicationHeldByNamespaceLabel = "homelab.rafaribe.com/held-by-namespace"
+
+	// replicationHoldFinalizer keeps a monitor until the objects it holds are released
+	replicationHoldFinalizer = "homelab.rafaribe.com/release-holds"
+)
+
+// Event reasons for held VolSync objects
+const (
+	reasonReplicationHeld     = "ReplicationHeld"
+	reasonReplicationReleased = "ReplicationReleased"
+)
+
+// replicationHold is the content of the hold annotation
+type replicationHold struct {
+	// UnlockJob is the unlock job in the namespace of the object
+	UnlockJob string `json:"unlockJob"`
+	// Mode is how the object is held
+	Mode volsyncv1alpha1.ReplicationHoldMode `json:"mode"`
+	// Paused is the original spec.paused, nil when it was unset
+	Paused *bool `json:"paused,omitempty"`
+	// Trigger is the original spec.trigger, nil when it was unset
+	Trigger map[string]interface{} `json:"trigger,omitempty"`
+}
+
+// replicationKind returns the kind of the VolSync object of a direction
+func replicationKind(direction volsyncv1alpha1.VolSyncDirection) string {
+	if direction == volsyncv1alpha1.VolSyncDirectionDestination {
+		return "ReplicationDestination"
+	}
+	return "ReplicationSource"
+}
+
+// holdsReplication reports whether the monitor holds VolSync objects during unlocks
+func holdsReplication(monitor *volsyncv1alpha1.VolSyncMonitor) bool {
+	mode := monitor.Spec.HoldReplication
+	return mode != "" && mode != volsyncv1alpha1.ReplicationHoldNone
+}
+
+// holdReplication keeps VolSync from starting the mover of the object a
+// failed job belongs to until the unlock job finished. Objects that are
+// already held, or that cannot be found, are left alone.
+func (r *VolSyncMonitorReconciler) holdReplication(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, identity jobIdentity, namespace, unlockJobName string) error {
+	if !holdsReplication(monitor) || identity.ObjectName == "" {
+		return nil
+	}
+	// Jobs of other providers have no VolSync object to hold
+	if identity.Provider != "" && identity.Provider != volsyncv1alpha1.BackupProviderVolSync {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	kind := replicationKind(identity.Direction)
+	obj, err := r.getVolSyncObject(ctx, namespace, kind, identity.ObjectName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			logger.V(1).Info("VolSync object of unlock not found, not holding it", "kind", kind, "name", identity.ObjectName, "namespace", namespace)
+			return nil
+		}
+		return fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, identity.ObjectName, err)
+	}
+	if held, ok := obj.GetAnnotations()[ReplicationHoldAnnotation]; ok {
+		logger.Info("VolSync object is already held", "kind", kind, "name", obj.GetName(), "namespace", namespace, "hold", held)
+		return nil
+	}
+
+	hold := replicationHold{UnlockJob: unlockJobName, Mode: monitor.Spec.HoldReplication}
+	if hold.Mode == volsyncv1alpha1.ReplicationHoldManualTrigger {
+		// A manual trigger only holds the object when it equals the last
+		// manual sync; any other value starts a sync right away
+		lastManualSync, _, _ := unstructured.NestedString(obj.Object, "status", "lastManualSync")
+		if lastManualSync == "" {
+			logger.Info("VolSync object never ran a manual sync, pausing it instead", "kind", kind, "name", obj.GetName(), "namespace", namespace)
+			hold.Mode = volsyncv1alpha1.ReplicationHoldPause
+		} else {
+			if trigger, found, _ := unstructured.NestedMap(obj.Object, "spec", "trigger"); found {
+				hold.Trigger = trigger
+			}
+			if err := unstructured.SetNestedMap(obj.Object, map[string]interface{}{"manual": lastManualSync}, "spec", "trigger"); err != nil {
+				return fmt.Errorf("failed to set trigger of %s %s/%s: %w", kind, namespace, obj.GetName(), err)
+			}
+		}
+	}
+	if hold.Mode == volsyncv1alpha1.ReplicationHoldPause {
+		if paused, found, _ := unstructured.NestedBool(obj.Object, "spec", "paused"); found {
+			hold.Paused = &paused
+		}
+		if err := unstructured.SetNestedField(obj.Object, true, "spec", "paused"); err != nil {
+			return fmt.Errorf("failed to pause %s %s/%s: %w", kind, namespace, obj.GetName(), err)
+		}
+	}
+
+	annotation, err := json.Marshal(hold)
+	if err != nil {
+		return fmt.Errorf("failed to encode hold: %w", err)
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ReplicationHoldAnnotation] = string(annotation)
+	obj.SetAnnotations(annotations)
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ReplicationHeldByLabel] = monitor.Name
+	labels[ReplicationHeldByNamespaceLabel] = monitor.Namespace
+	obj.SetLabels(labels)
+	if err := r.Update(ctx, obj); err != nil {
+		return fmt.Errorf("failed to hold %s %s/%s: %w", kind, namespace, obj.GetName(), err)
+	}
+
+	logger.Info("Held VolSync object during unlock", "kind", kind, "name", obj.GetName(), "namespace", namespace, "mode", hold.Mode, "unlockJob", unlockJobName)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonReplicationHeld,
+			"%s %s/%s held (%s) while unlock job %s runs", kind, namespace, obj.GetName(), hold.Mode, unlockJobName)
+	}
+	return nil
+}
+
+// releaseReplicationHolds restores the VolSync objects held by the monitor
+// whose unlock job finished or no longer exists. A monitor being deleted
+// releases all of its objects right away.
+func (r *VolSyncMonitorReconciler) releaseReplicationHolds(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	var errs []error
+	for _, kind := range []string{"ReplicationSource", "ReplicationDestination"} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(volsyncGroupVersion.WithKind(kind + "List"))
+		if err := r.List(ctx, list, client.MatchingLabels{ReplicationHeldByLabel: monitor.Name}); err != nil {
+			if meta.IsNoMatchError(err) {
+				// VolSync is not installed, so nothing can be held
+				return nil
+			}
+			return fmt.Errorf("failed to list held %ss: %w", kind, err)
+		}
+		for i := range list.Items {
+			// Objects held before the namespace label existed belong to any
+			// monitor of that name
+			if namespace := list.Items[i].GetLabels()[ReplicationHeldByNamespaceLabel]; namespace != "" && namespace != monitor.Namespace {
+				continue
+			}
+			if err := r.releaseReplicationHold(ctx, monitor, &list.Items[i]); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to release held VolSync object", "kind", kind, "name", list.Items[i].GetName(), "namespace", list.Items[i].GetNamespace())
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// finalizeMonitor releases the VolSync objects held by a deleted monitor
+// and removes its finalizer
+func (r *VolSyncMonitorReconciler) finalizeMonitor(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	if !controllerutil.ContainsFinalizer(monitor, replicationHoldFinalizer) {
+		return nil
+	}
+	if err := r.releaseReplicationHolds(ctx, monitor); err != nil {
+		return fmt.Errorf("failed to release held VolSync objects: %w", err)
+	}
+	controllerutil.RemoveFinalizer(monitor, replicationHoldFinalizer)
+	return r.Update(ctx, monitor)
+}
+
+// releaseReplicationHold restores a held VolSync object once its unlock job finished
+func (r *VolSyncMonitorReconciler) releaseReplicationHold(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, obj *unstructured.Unstructured) error {
+	var hold replicationHold
+	if err := json.Unmarshal([]byte(obj.GetAnnotations()[ReplicationHoldAnnotation]), &hold); err != nil {
+		return fmt.Errorf("invalid %s annotation: %w", ReplicationHoldAnnotation, err)
+	}
+
+	var unlockJob batchv1.Job
+	err := r.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: hold.UnlockJob}, &unlockJob)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get unlock job %s: %w", hold.UnlockJob, err)
+	}
+	// The unlock jobs of a deleted monitor are garbage collected with it
+	if err == nil && monitor.DeletionTimestamp.IsZero() && !r.isJobSucceeded(unlockJob) && !r.isJobFailed(&unlockJob) {
+		return nil
+	}
+
+	switch hold.Mode {
+	case volsyncv1alpha1.ReplicationHoldPause:
+		if hold.Paused != nil {
+			if err := unstructured.SetNestedField(obj.Object, *hold.Paused, "spec", "paused"); err != nil {
+				return err
+			}
+		} else {
+			unstructured.RemoveNestedField(obj.Object, "spec", "paused")
+		}
+	case volsyncv1alpha1.ReplicationHoldManualTrigger:
+		if hold.Trigger != nil {
+			if err := unstructured.SetNestedMap(obj.Object, hold.Trigger, "spec", "trigger"); err != nil {
+				return err
+			}
+		} else {
+			unstructured.RemoveNestedField(obj.Object, "spec", "trigger")
+		}
+	}
+
+	annotations := obj.GetAnnotations()
+	delete(annotations, ReplicationHoldAnnotation)
+	obj.SetAnnotations(annotations)
+	labels := obj.GetLabels()
+	delete(labels, ReplicationHeldByLabel)
+	delete(labels, ReplicationHeldByNamespaceLabel)
+	obj.SetLabels(labels)
+	if err := r.Update(ctx, obj); err != nil {
+		return fmt.Errorf("failed to restore %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	log.FromContext(ctx).Info("Released VolSync object after unlock", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(), "unlockJob", hold.UnlockJob)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonReplicationReleased,
+			"%s %s/%s restored after unlock job %s", obj.GetKind(), obj.GetNamespace(), obj.GetName(), hold.UnlockJob)
+	}
+	return nil
+}
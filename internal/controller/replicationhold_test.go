@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Replication holds", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		source    *unstructured.Unstructured
+		unlockJob *batchv1.Job
+		identity  jobIdentity
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+			Spec:       volsyncv1alpha1.VolSyncMonitorSpec{Enabled: true, HoldReplication: volsyncv1alpha1.ReplicationHoldPause},
+		}
+		source = newReplicationSource("media", "plex", "plex-restic")
+		Expect(unstructured.SetNestedMap(source.Object, map[string]interface{}{"schedule": "0 2 * * *"}, "spec", "trigger")).To(Succeed())
+		unlockJob = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-unlock-plex", Namespace: "media"}}
+		identity = jobIdentity{App: "plex", ObjectName: "plex", Direction: volsyncv1alpha1.VolSyncDirectionSource}
+	})
+
+	getSource := func(r *VolSyncMonitorReconciler) *unstructured.Unstructured {
+		obj, err := r.getVolSyncObject(ctx, "media", "ReplicationSource", "plex")
+		Expect(err).NotTo(HaveOccurred())
+		return obj
+	}
+
+	finishUnlockJob := func(r *VolSyncMonitorReconciler) {
+		unlockJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, unlockJob)).To(Succeed())
+	}
+
+	It("should pause the source until the unlock job finished", func() {
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+
+		held := getSource(r)
+		paused, _, _ := unstructured.NestedBool(held.Object, "spec", "paused")
+		Expect(paused).To(BeTrue())
+		Expect(held.GetLabels()).To(HaveKeyWithValue(ReplicationHeldByLabel, "monitor"))
+		Expect(held.GetLabels()).To(HaveKeyWithValue(ReplicationHeldByNamespaceLabel, "system"))
+		Expect(held.GetAnnotations()[ReplicationHoldAnnotation]).To(MatchJSON(`{"unlockJob":"volsync-unlock-plex","mode":"Pause"}`))
+
+		By("keeping the hold while the unlock job runs")
+		Expect(r.releaseReplicationHolds(ctx, monitor)).To(Succeed())
+		Expect(getSource(r).GetAnnotations()).To(HaveKey(ReplicationHoldAnnotation))
+
+		By("restoring the source once it finished")
+		finishUnlockJob(r)
+		Expect(r.releaseReplicationHolds(ctx, monitor)).To(Succeed())
+		released := getSource(r)
+		_, found, _ := unstructured.NestedBool(released.Object, "spec", "paused")
+		Expect(found).To(BeFalse())
+		Expect(released.GetAnnotations()).NotTo(HaveKey(ReplicationHoldAnnotation))
+		Expect(released.GetLabels()).NotTo(HaveKey(ReplicationHeldByLabel))
+		Expect(released.GetLabels()).NotTo(HaveKey(ReplicationHeldByNamespaceLabel))
+	})
+
+	It("should hold the manual trigger at the last manual sync", func() {
+		monitor.Spec.HoldReplication = volsyncv1alpha1.ReplicationHoldManualTrigger
+		Expect(unstructured.SetNestedField(source.Object, "before-upgrade", "status", "lastManualSync")).To(Succeed())
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+
+		trigger, _, _ := unstructured.NestedMap(getSource(r).Object, "spec", "trigger")
+		Expect(trigger).To(Equal(map[string]interface{}{"manual": "before-upgrade"}))
+
+		finishUnlockJob(r)
+		Expect(r.releaseReplicationHolds(ctx, monitor)).To(Succeed())
+		trigger, _, _ = unstructured.NestedMap(getSource(r).Object, "spec", "trigger")
+		Expect(trigger).To(Equal(map[string]interface{}{"schedule": "0 2 * * *"}))
+	})
+
+	It("should pause sources that never ran a manual sync", func() {
+		monitor.Spec.HoldReplication = volsyncv1alpha1.ReplicationHoldManualTrigger
+		Expect(unstructured.SetNestedField(source.Object, false, "spec", "paused")).To(Succeed())
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+
+		held := getSource(r)
+		paused, _, _ := unstructured.NestedBool(held.Object, "spec", "paused")
+		Expect(paused).To(BeTrue())
+		trigger, _, _ := unstructured.NestedMap(held.Object, "spec", "trigger")
+		Expect(trigger).To(HaveKey("schedule"))
+
+		By("restoring the original value when the unlock job is gone")
+		Expect(r.Delete(ctx, unlockJob)).To(Succeed())
+		Expect(r.releaseReplicationHolds(ctx, monitor)).To(Succeed())
+		paused, found, _ := unstructured.NestedBool(getSource(r).Object, "spec", "paused")
+		Expect(found).To(BeTrue())
+		Expect(paused).To(BeFalse())
+	})
+
+	It("should not hold a source twice", func() {
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+		Expect(r.holdReplication(ctx, monitor, identity, "media", "volsync-unlock-other")).To(Succeed())
+		Expect(getSource(r).GetAnnotations()[ReplicationHoldAnnotation]).To(ContainSubstring(unlockJob.Name))
+	})
+
+	It("should leave sources alone by default", func() {
+		monitor.Spec.HoldReplication = ""
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+		Expect(getSource(r).GetAnnotations()).NotTo(HaveKey(ReplicationHoldAnnotation))
+	})
+
+	It("should not release the holds of a same-named monitor in another namespace", func() {
+		r := newFakeReconciler(source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+		finishUnlockJob(r)
+
+		other := monitor.DeepCopy()
+		other.Namespace = "other"
+		Expect(r.releaseReplicationHolds(ctx, other)).To(Succeed())
+		Expect(getSource(r).GetAnnotations()).To(HaveKey(ReplicationHoldAnnotation))
+
+		Expect(r.releaseReplicationHolds(ctx, monitor)).To(Succeed())
+		Expect(getSource(r).GetAnnotations()).NotTo(HaveKey(ReplicationHoldAnnotation))
+	})
+
+	It("should release the holds of a deleted monitor", func() {
+		monitor.Finalizers = []string{replicationHoldFinalizer}
+		r := newFakeReconciler(monitor, source, unlockJob)
+		Expect(r.holdReplication(ctx, monitor, identity, "media", unlockJob.Name)).To(Succeed())
+
+		By("keeping the monitor until the source is released")
+		Expect(r.Delete(ctx, monitor)).To(Succeed())
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(monitor)})
+		Expect(err).NotTo(HaveOccurred())
+
+		released := getSource(r)
+		_, found, _ := unstructured.NestedBool(released.Object, "spec", "paused")
+		Expect(found).To(BeFalse())
+		Expect(released.GetLabels()).NotTo(HaveKey(ReplicationHeldByLabel))
+		err = r.Get(ctx, client.ObjectKeyFromObject(monitor), &volsyncv1alpha1.VolSyncMonitor{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should add the finalizer to monitors holding replication", func() {
+		r := newFakeReconciler(monitor)
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(monitor), monitor)).To(Succeed())
+		_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(monitor)})
+
+		var updated volsyncv1alpha1.VolSyncMonitor
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(monitor), &updated)).To(Succeed())
+		Expect(updated.Finalizers).To(ContainElement(replicationHoldFinalizer))
+	})
+})
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+)
+
+// repositorySourceLabel is set on repository jobs to their ReplicationSource
+const repositorySourceLabel = "homelab.rafaribe.com/replication-source"
+
+// repositoryJobKind describes a job the monitor runs against the repository
+// of a ReplicationSource, outside of the failed job path
+type repositoryJobKind struct {
+	// prefix starts the job names
+	prefix string
+	// component is the app.kubernetes.io/component label of the jobs
+	component string
+	// label is set to the name of the monitor that owns the jobs
+	label string
+	// backoffLimit is the number of retries of the job
+	backoffLimit int32
+}
+
+var (
+	lockSweepJobKind = repositoryJobKind{
+		prefix:       "lock-sweep",
+		component:    "volsync-lock-sweep",
+		label:        lockSweepLabel,
+		backoffLimit: 1,
+	}
+	lockListJobKind = repositoryJobKind{
+		prefix:       "lock-list",
+		component:    "volsync-lock-list",
+		label:        lockListLabel,
+		backoffLimit: 1,
+	}
+	healthCheckJobKind = repositoryJobKind{
+		prefix:    "health-check",
+		component: "volsync-health-check",
+		label:     healthCheckLabel,
+	}
+)
+
+// listResticReplicationSources returns the restic ReplicationSources in the
+// namespaces the monitor watches, sorted by namespace and name
+func (r *VolSyncMonitorReconciler) listResticReplicationSources(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) ([]unstructured.Unstructured, error) {
+	var sourceList unstructured.UnstructuredList
+	sourceList.SetGroupVersionKind(volsyncGroupVersion.WithKind("ReplicationSourceList"))
+	if err := r.List(ctx, &sourceList); err != nil {
+		return nil, fmt.Errorf("failed to list ReplicationSources: %w", err)
+	}
+
+	others, err := r.listCompetingMonitors(ctx, monitor)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []unstructured.Unstructured
+	for i := range sourceList.Items {
+		source := sourceList.Items[i]
+		if !monitorWatchesNamespace(monitor, source.GetNamespace()) || !ownsNamespace(monitor, others, source.GetNamespace()) {
+			continue
+		}
+		policy, err := r.resolvePolicy(ctx, source.GetNamespace(), source.GetName(), nil)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to resolve monitoring policy, skipping", "replicationSource", source.GetName(), "namespace", source.GetNamespace())
+			continue
+		}
+		if policy.Mode == MonitorModeDisabled {
+			continue
+		}
+		if moverType, _, ok := moverSection(&source); ok && moverType == volsyncv1alpha1.MoverTypeRestic {
+			sources = append(sources, source)
+		}
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].GetNamespace() != sources[j].GetNamespace() {
+			return sources[i].GetNamespace() < sources[j].GetNamespace()
+		}
+		return sources[i].GetName() < sources[j].GetName()
+	})
+	return sources, nil
+}
+
+// newRepositoryJob builds a job that runs a restic script against the
+// repository of a ReplicationSource with the restic unlock job template of the
+// monitor. The source is empty for repositories named by their secret.
+func (r *VolSyncMonitorReconciler) newRepositoryJob(monitor *volsyncv1alpha1.VolSyncMonitor, kind repositoryJobKind, namespace, source string, target *unlockTarget, script string) (*batchv1.Job, error) {
+	name := fmt.Sprintf("%s-%d", kind.prefix, time.Now().Unix())
+	if source != "" {
+		name = boundedName(kind.prefix+"-", source, nameSuffix(""))
+	}
+
+	template := r.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic).Template
+	template.Command = []string{"/bin/sh"}
+	template.Args = []string{"-c", script}
+	image, err := resolveUnlockImage(template, target)
+	if err != nil {
+		return nil, err
+	}
+	template.Image = image.Image
+
+	moverJob := replicationSourceJob(namespace, source)
+	jobSpec := r.buildUnlockJobSpec(monitor, template, moverJob, name, "", target)
+	jobSpec.Template.Spec.ImagePullSecrets = image.PullSecrets
+	jobSpec.BackoffLimit = helpers.Int32Ptr(kind.backoffLimit)
+	jobSpec.Template.Labels = map[string]string{
+		"app.kubernetes.io/name":      "homelab-assistant",
+		"app.kubernetes.io/component": kind.component,
+		kind.label:                    monitor.Name,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "homelab-assistant",
+				"app.kubernetes.io/component":  kind.component,
+				"app.kubernetes.io/created-by": "volsync-monitor",
+				kind.label:                     monitor.Name,
+				repositorySourceLabel:          source,
+			},
+			Annotations: map[string]string{
+				unlockImageAnnotation:       image.Image,
+				unlockImageSourceAnnotation: image.Source,
+			},
+		},
+		Spec: *jobSpec,
+	}
+	if source == "" {
+		delete(job.Labels, repositorySourceLabel)
+	}
+	if err := r.setMonitorOwner(monitor, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// listRepositoryJobs returns the jobs of a kind created by the monitor
+func (r *VolSyncMonitorReconciler) listRepositoryJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, kind repositoryJobKind) ([]batchv1.Job, error) {
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.MatchingLabels{kind.label: monitor.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list %s jobs: %w", kind.prefix, err)
+	}
+
+	var jobs []batchv1.Job
+	for _, job := range jobList.Items {
+		if job.Labels[recordMonitorNamespaceLabel] != "" && job.Labels[recordMonitorNamespaceLabel] != monitor.Namespace {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// deleteRepositoryJob removes a repository job once its result was collected
+func (r *VolSyncMonitorReconciler) deleteRepositoryJob(ctx context.Context, job batchv1.Job) error {
+	deletePolicy := metav1.DeletePropagationBackground
+	if err := r.Delete(ctx, &job, &client.DeleteOptions{PropagationPolicy: &deletePolicy}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// canCreateUnlockJob reports whether the monitor is below its concurrency limit
+func (r *VolSyncMonitorReconciler) canCreateUnlockJob(monitor volsyncv1alpha1.VolSyncMonitor) bool {
+	return len(monitor.Status.ActiveUnlocks) < maxConcurrentUnlocks(&monitor)
+}
+
+// findUnlockJob returns an unlock job of the monitor for a failed job, if any
+func (r *VolSyncMonitorReconciler) findUnlockJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, failedJob batchv1.Job) (*batchv1.Job, error) {
+	if failedJob.UID == "" {
+		return nil, nil
+	}
+
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(failedJob.Namespace),
+		client.MatchingLabels{MonitorLabel: monitor.Name, failedJobUIDLabel: string(failedJob.UID)}); err != nil {
+		return nil, fmt.Errorf("failed to list unlock jobs: %w", err)
+	}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if namespace := job.Labels[recordMonitorNamespaceLabel]; namespace != "" && namespace != monitor.Namespace {
+			continue
+		}
+		return job, nil
+	}
+	return nil, nil
+}
+
+// queueUnlock records a lock error whose unlock was deferred
+func queueUnlock(monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, identity jobIdentity, reason string) {
+	monitor.Status.QueuedUnlocks = append(monitor.Status.QueuedUnlocks, volsyncv1alpha1.QueuedUnlock{
+		JobName:    job.Name,
+		Namespace:  job.Namespace,
+		ObjectName: identity.ObjectName,
+		Direction:  identity.Direction,
+		Reason:     reason,
+	})
+}
+
+// countActiveUnlockJobs counts the running unlock jobs of a monitor
+func (r *VolSyncMonitorReconciler) countActiveUnlockJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) (int, error) {
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.MatchingLabels{MonitorLabel: monitor.Name}); err != nil {
+		return 0, fmt.Errorf("failed to list unlock jobs: %w", err)
+	}
+
+	active := 0
+	for _, job := range jobList.Items {
+		if job.Labels[recordMonitorNamespaceLabel] != "" && job.Labels[recordMonitorNamespaceLabel] != monitor.Namespace {
+			continue
+		}
+		if r.isJobActive(job) || (!r.isJobSucceeded(job) && !r.isJobFailed(&job)) {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// moverJobRunning reports whether a mover job or a repository job of the
+// ReplicationSource is running. A running job holds the repository lock, so
+// it must not be removed.
+func (r *VolSyncMonitorReconciler) moverJobRunning(ctx context.Context, namespace, replicationSource string, exclude types.UID) (bool, error) {
+	running, err := r.runningVolSyncJobs(ctx, namespace, replicationSource, volsyncv1alpha1.VolSyncDirectionSource, exclude)
+	return len(running) > 0, err
+}
+
+// runningVolSyncJobs lists the running mover jobs of a ReplicationSource or
+// ReplicationDestination, together with the lock sweeps and health checks
+// the monitor runs against the repository of a ReplicationSource. Unlock
+// jobs are left out; they are what the callers are about to create.
+func (r *VolSyncMonitorReconciler) runningVolSyncJobs(ctx context.Context, namespace, objectName string, direction volsyncv1alpha1.VolSyncDirection, exclude types.UID) ([]batchv1.Job, error) {
+	if objectName == "" {
+		return nil, nil
+	}
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list jobs in namespace %s: %w", namespace, err)
+	}
+
+	var running []batchv1.Job
+	for _, job := range jobList.Items {
+		if (exclude != "" && job.UID == exclude) || !r.isJobActive(job) {
+			continue
+		}
+		if source, ok := job.Labels[repositorySourceLabel]; ok {
+			if direction == volsyncv1alpha1.VolSyncDirectionSource && source == objectName {
+				running = append(running, job)
+			}
+			continue
+		}
+		if _, isUnlock := job.Labels[MonitorLabel]; isUnlock || !r.isVolSyncJob(&job) {
+			continue
+		}
+		if identity := r.jobIdentityFromJob(&job); identity.ObjectName == objectName && identity.Direction == direction {
+			running = append(running, job)
+		}
+	}
+	return running, nil
+}
+
+// verifyStaleLock checks that the lock reported by a failed job can be removed
+// safely: no other job may be using the repository, and the lock has to be
+// older than spec.minLockAge when restic reported its age. An empty reason
+// means the lock is stale.
+func (r *VolSyncMonitorReconciler) verifyStaleLock(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, match *lockErrorMatch) (string, error) {
+	running, err := r.providerOf(&job).runningJobs(ctx, job)
+	if err != nil {
+		return "", err
+	}
+	if len(running) > 0 {
+		return fmt.Sprintf("job %s is using the repository", running[0].Name), nil
+	}
+
+	if monitor.Spec.MinLockAge != nil && match != nil && match.LockAge != nil {
+		// The age was reported when the job failed; the lock has aged since
+		age := *match.LockAge
+		if failureTime := jobFailureTime(job); failureTime != nil {
+			age += time.Since(failureTime.Time)
+		}
+		if age < monitor.Spec.MinLockAge.Duration {
+			return fmt.Sprintf("lock is %s old, younger than minLockAge %s", age.Round(time.Second), monitor.Spec.MinLockAge.Duration), nil
+		}
+	}
+
+	return "", nil
+}
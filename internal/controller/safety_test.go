@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Queued unlocks", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		failedJob *batchv1.Job
+		jobPod    *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 1,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		jobPod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		return newFakeReconciler(append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, jobPod)...)
+	}
+
+	It("should queue unlocks while the concurrency limit is reached", func() {
+		running := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-unlock-sonarr", Namespace: "media", Labels: map[string]string{MonitorLabel: monitor.Name}},
+			Status:     batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(running)
+
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.ProcessedJobs).To(BeEmpty())
+		Expect(monitor.Status.QueuedUnlocks).To(ConsistOf(volsyncv1alpha1.QueuedUnlock{
+			JobName:    failedJob.Name,
+			Namespace:  failedJob.Namespace,
+			ObjectName: "plex",
+			Direction:  volsyncv1alpha1.VolSyncDirectionSource,
+			Reason:     "all 1 concurrent unlocks in use",
+		}))
+
+		// The queue is rebuilt once the running unlock finishes
+		Expect(r.Delete(ctx, running)).To(Succeed())
+		_, err = r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.QueuedUnlocks).To(BeEmpty())
+		Expect(monitor.Status.ProcessedJobs).To(HaveLen(1))
+	})
+
+	It("should queue unlocks while a mover of the same source is running", func() {
+		mover := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-retry", Namespace: "media", OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "volsync.backube/v1alpha1", Kind: "ReplicationSource", Name: "plex", UID: "source-uid",
+			}}},
+			Status: batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(mover)
+
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.QueuedUnlocks).To(HaveLen(1))
+		Expect(monitor.Status.QueuedUnlocks[0].Reason).To(ContainSubstring("job volsync-src-plex-retry is using the repository"))
+	})
+})
+
+var _ = Describe("Unlock job names", func() {
+	It("should derive names from the key", func() {
+		name := unlockJobName("volsync-src-plex", "job-uid")
+		Expect(name).To(HavePrefix("volsync-unlock-volsync-src-plex-"))
+		Expect(unlockJobName("volsync-src-plex", "job-uid")).To(Equal(name))
+		Expect(unlockJobName("volsync-src-plex", "other-uid")).NotTo(Equal(name))
+		Expect(unlockJobName("volsync-src-plex", "")).To(MatchRegexp(`^volsync-unlock-volsync-src-plex-\d+$`))
+	})
+
+	It("should keep names short enough for pod labels", func() {
+		name := unlockJobName("volsync-src-a-very-long-application-name-with-a-long-suffix", "job-uid")
+		Expect(len(name)).To(BeNumerically("<=", 63))
+		Expect(name).To(MatchRegexp(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`))
+	})
+})
+
+var _ = Describe("Crash-safe unlocks", func() {
+	var (
+		ctx       context.Context
+		monitor   *volsyncv1alpha1.VolSyncMonitor
+		failedJob *batchv1.Job
+		jobPod    *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 1,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+		failedJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+			},
+		}
+		jobPod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex-abcde", Namespace: "media", Labels: map[string]string{"job-name": failedJob.Name}},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "unable to create lock in backend: repository is already locked by PID 1",
+					}},
+				}},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		return newFakeReconciler(append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "media"}}, failedJob, jobPod)...)
+	}
+
+	unlockJobs := func(r *VolSyncMonitorReconciler) []batchv1.Job {
+		var jobList batchv1.JobList
+		Expect(r.List(ctx, &jobList, client.HasLabels{MonitorLabel})).To(Succeed())
+		return jobList.Items
+	}
+
+	It("should label unlock jobs with the failure they handle", func() {
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+
+		jobs := unlockJobs(r)
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].Name).To(Equal(unlockJobName(failedJob.Name, failedJob.UID)))
+		Expect(jobs[0].Labels).To(HaveKeyWithValue(failedJobUIDLabel, "job-uid"))
+	})
+
+	It("should recover an unlock job that was created but not recorded", func() {
+		r := newReconciler()
+		_, err := r.reconcileMonitor(ctx, monitor)
+		Expect(err).NotTo(HaveOccurred())
+		jobs := unlockJobs(r)
+		Expect(jobs).To(HaveLen(1))
+		jobs[0].Status.Active = 1
+		Expect(r.Status().Update(ctx, &jobs[0])).To(Succeed())
+
+		By("reconciling with the status before the unlock job was created")
+		restarted := &volsyncv1alpha1.VolSyncMonitor{ObjectMeta: monitor.ObjectMeta, Spec: monitor.Spec}
+		_, err = r.reconcileMonitor(ctx, restarted)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(unlockJobs(r)).To(HaveLen(1))
+		Expect(restarted.Status.QueuedUnlocks).To(BeEmpty())
+		Expect(restarted.Status.ActiveUnlocks).To(HaveLen(1))
+		Expect(restarted.Status.ProcessedJobs).To(HaveLen(1))
+		Expect(restarted.Status.ProcessedJobs[0].UnlockJobName).To(Equal(jobs[0].Name))
+	})
+
+	It("should treat an existing unlock job as created", func() {
+		r := newReconciler()
+		mover := r.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic)
+
+		first, err := r.createUnlockJob(ctx, monitor, *failedJob, "locked", mover, failedJob.UID)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := r.createUnlockJob(ctx, monitor, *failedJob, "locked", mover, failedJob.UID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Name).To(Equal(first.Name))
+		Expect(second.UID).To(Equal(first.UID))
+		Expect(unlockJobs(r)).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/yaml"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// updateGolden rewrites the expected results of the scenarios instead of
+// comparing them: go test ./internal/controller -args -update
+var updateGolden = flag.Bool("update", false, "rewrite the expected results of the scenario tests")
+
+// scenariosDir holds one directory per scenario with:
+//   - objects.yaml: the VolSyncMonitor, the failed Job, its Pods with their
+//     status and any other object the reconciler reads
+//   - logs/<pod>.log: the logs of a pod; pods without a file have no logs
+//   - expected.yaml: the golden result
+const scenariosDir = "testdata/scenarios"
+
+// scenarioResult is what a reconciliation of a scenario is compared on
+type scenarioResult struct {
+	ProcessedJobs          []scenarioProcessedJob         `json:"processedJobs,omitempty"`
+	QueuedUnlocks          []volsyncv1alpha1.QueuedUnlock `json:"queuedUnlocks,omitempty"`
+	UnlockJobs             []scenarioUnlockJob            `json:"unlockJobs,omitempty"`
+	RemovedJobs            []string                       `json:"removedJobs,omitempty"`
+	Events                 []string                       `json:"events,omitempty"`
+	TotalUnlocksCreated    int32                          `json:"totalUnlocksCreated"`
+	TotalFailedJobsRemoved int32                          `json:"totalFailedJobsRemoved"`
+	ActiveUnlocks          int                            `json:"activeUnlocks"`
+}
+
+// scenarioProcessedJob is a processed job without its timestamps and record name
+type scenarioProcessedJob struct {
+	Job            string                            `json:"job"`
+	App            string                            `json:"app,omitempty"`
+	ObjectName     string                            `json:"objectName,omitempty"`
+	Direction      volsyncv1alpha1.VolSyncDirection  `json:"direction,omitempty"`
+	Provider       volsyncv1alpha1.BackupProvider    `json:"provider,omitempty"`
+	MoverType      volsyncv1alpha1.MoverType         `json:"moverType,omitempty"`
+	LockError      string                            `json:"lockError"`
+	DetectedBy     volsyncv1alpha1.LockErrorSource   `json:"detectedBy,omitempty"`
+	ExitCode       *int32                            `json:"exitCode,omitempty"`
+	MessageType    string                            `json:"messageType,omitempty"`
+	Classification volsyncv1alpha1.FailureClass      `json:"classification,omitempty"`
+	Pattern        string                            `json:"pattern,omitempty"`
+	Severity       volsyncv1alpha1.FailureSeverity   `json:"severity,omitempty"`
+	Remediation    volsyncv1alpha1.RemediationAction `json:"remediation,omitempty"`
+	UnlockJob      string                            `json:"unlockJob,omitempty"`
+	Removed        bool                              `json:"removed,omitempty"`
+	RemovalPending bool                              `json:"removalPending,omitempty"`
+}
+
+// scenarioUnlockJob is the part of an unlock job spec the scenarios check
+type scenarioUnlockJob struct {
+	Name             string   `json:"name"`
+	Namespace        string   `json:"namespace"`
+	Image            string   `json:"image"`
+	ImageSource      string   `json:"imageSource,omitempty"`
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+	Command          []string `json:"command,omitempty"`
+	Args             []string `json:"args,omitempty"`
+	Env              []string `json:"env,omitempty"`
+	EnvFrom          []string `json:"envFrom,omitempty"`
+	VolumeMounts     []string `json:"volumeMounts,omitempty"`
+}
+
+// scenarioLogs serves pod logs from the logs directory of a scenario
+type scenarioLogs struct {
+	dir string
+}
+
+func (s scenarioLogs) GetPodLogs(_ context.Context, namespace, podName, _ string) (string, error) {
+	logs, err := os.ReadFile(filepath.Join(s.dir, "logs", podName+".log"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("no logs for pod %s/%s", namespace, podName)
+	}
+	return string(logs), err
+}
+
+var _ = Describe("Golden scenarios", func() {
+	entries, err := os.ReadDir(scenariosDir)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read %s: %v", scenariosDir, err))
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(scenariosDir, entry.Name())
+		It("should handle "+entry.Name(), func() {
+			result := runScenario(dir)
+
+			actual, err := yaml.Marshal(result)
+			Expect(err).NotTo(HaveOccurred())
+			golden := filepath.Join(dir, "expected.yaml")
+			if *updateGolden {
+				Expect(os.WriteFile(golden, actual, 0o644)).To(Succeed())
+				return
+			}
+			expected, err := os.ReadFile(golden)
+			Expect(err).NotTo(HaveOccurred(), "run the tests with -args -update to create %s", golden)
+			Expect(string(actual)).To(Equal(string(expected)), "run the tests with -args -update to accept the new result")
+		})
+	}
+})
+
+// runScenario reconciles the monitor of a scenario once and collects the result
+func runScenario(dir string) scenarioResult {
+	ctx := context.Background()
+	scheme := newFakeScheme()
+
+	objects := loadScenarioObjects(scheme, filepath.Join(dir, "objects.yaml"))
+	var monitor *volsyncv1alpha1.VolSyncMonitor
+	var failedJobs []client.Object
+	for _, obj := range objects {
+		switch typed := obj.(type) {
+		case *volsyncv1alpha1.VolSyncMonitor:
+			monitor = typed
+		case *batchv1.Job:
+			failedJobs = append(failedJobs, typed)
+		}
+	}
+	Expect(monitor).NotTo(BeNil(), "%s has no VolSyncMonitor", dir)
+
+	// The fake client leaves creation timestamps unset, which would make
+	// the record retention prune every record right away
+	setCreationTimestamp := interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			obj.SetCreationTimestamp(metav1.Now())
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+	recorder := record.NewFakeRecorder(100)
+	r := &VolSyncMonitorReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+			WithStatusSubresource(&volsyncv1alpha1.VolSyncMonitor{}, &volsyncv1alpha1.UnlockRecord{}, &volsyncv1alpha1.FailurePatternSet{}, &batchv1.Job{}).
+			WithInterceptorFuncs(setCreationTimestamp).
+			Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+		Logs:     scenarioLogs{dir: dir},
+	}
+
+	_, err := r.reconcileMonitor(ctx, monitor)
+	Expect(err).NotTo(HaveOccurred())
+
+	result := scenarioResult{
+		QueuedUnlocks:          monitor.Status.QueuedUnlocks,
+		TotalUnlocksCreated:    monitor.Status.TotalUnlocksCreated,
+		TotalFailedJobsRemoved: monitor.Status.TotalFailedJobsRemoved,
+		ActiveUnlocks:          len(monitor.Status.ActiveUnlocks),
+	}
+	for _, processed := range monitor.Status.ProcessedJobs {
+		result.ProcessedJobs = append(result.ProcessedJobs, scenarioProcessedJob{
+			Job:            processed.Namespace + "/" + processed.JobName,
+			App:            processed.AppName,
+			ObjectName:     processed.ObjectName,
+			Direction:      processed.Direction,
+			Provider:       processed.Provider,
+			MoverType:      processed.MoverType,
+			LockError:      processed.LockError,
+			DetectedBy:     processed.DetectedBy,
+			ExitCode:       processed.ExitCode,
+			MessageType:    processed.MessageType,
+			Classification: processed.Classification,
+			Pattern:        processed.Pattern,
+			Severity:       processed.Severity,
+			Remediation:    processed.Remediation,
+			UnlockJob:      processed.UnlockJobName,
+			Removed:        processed.Removed,
+			RemovalPending: processed.RemovalPending,
+		})
+	}
+
+	var jobList batchv1.JobList
+	Expect(r.List(ctx, &jobList, client.MatchingLabels{MonitorLabel: monitor.Name})).To(Succeed())
+	for _, job := range jobList.Items {
+		result.UnlockJobs = append(result.UnlockJobs, summarizeUnlockJob(job))
+	}
+
+	for _, job := range failedJobs {
+		err := r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		if apierrors.IsNotFound(err) {
+			result.RemovedJobs = append(result.RemovedJobs, job.GetNamespace()+"/"+job.GetName())
+			continue
+		}
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	close(recorder.Events)
+	for event := range recorder.Events {
+		result.Events = append(result.Events, event)
+	}
+	return result
+}
+
+// loadScenarioObjects decodes the manifests of a scenario into the types of
+// the scheme. Objects without a UID get one derived from their name, so that
+// names derived from UIDs are stable, and missing namespaces are created.
+func loadScenarioObjects(scheme *runtime.Scheme, path string) []client.Object {
+	data, err := os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+
+	var objects []client.Object
+	namespaces := map[string]bool{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred(), "failed to decode %s", path)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: raw}
+		if u.GetUID() == "" {
+			u.SetUID(types.UID(strings.ToLower(u.GetKind()) + "-" + u.GetName()))
+		}
+		typed, err := scheme.New(u.GroupVersionKind())
+		Expect(err).NotTo(HaveOccurred(), "unknown kind %s in %s", u.GroupVersionKind(), path)
+		if _, ok := typed.(*unstructured.Unstructured); ok {
+			typed = u
+		} else {
+			Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed)).To(Succeed())
+		}
+
+		obj := typed.(client.Object)
+		if _, ok := obj.(*corev1.Namespace); ok {
+			namespaces[obj.GetName()] = true
+		}
+		objects = append(objects, obj)
+	}
+
+	for _, obj := range objects {
+		if namespace := obj.GetNamespace(); namespace != "" && !namespaces[namespace] {
+			namespaces[namespace] = true
+			objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		}
+	}
+	return objects
+}
+
+// summarizeUnlockJob returns the checked part of an unlock job
+func summarizeUnlockJob(job batchv1.Job) scenarioUnlockJob {
+	podSpec := job.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	summary := scenarioUnlockJob{
+		Name:        job.Name,
+		Namespace:   job.Namespace,
+		Image:       container.Image,
+		ImageSource: job.Annotations[unlockImageSourceAnnotation],
+		Command:     container.Command,
+		Args:        container.Args,
+	}
+	for _, secret := range podSpec.ImagePullSecrets {
+		summary.ImagePullSecrets = append(summary.ImagePullSecrets, secret.Name)
+	}
+	for _, env := range container.Env {
+		switch {
+		case env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil:
+			summary.Env = append(summary.Env, fmt.Sprintf("%s from secret %s/%s", env.Name, env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Key))
+		default:
+			summary.Env = append(summary.Env, env.Name+"="+env.Value)
+		}
+	}
+	for _, source := range container.EnvFrom {
+		switch {
+		case source.SecretRef != nil:
+			summary.EnvFrom = append(summary.EnvFrom, "secret/"+source.SecretRef.Name)
+		case source.ConfigMapRef != nil:
+			summary.EnvFrom = append(summary.EnvFrom, "configmap/"+source.ConfigMapRef.Name)
+		}
+	}
+	for _, mount := range container.VolumeMounts {
+		summary.VolumeMounts = append(summary.VolumeMounts, mount.Name+":"+mount.MountPath)
+	}
+	sort.Strings(summary.VolumeMounts)
+	return summary
+}
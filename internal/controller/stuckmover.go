@@ -0,0 +1,402 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+)
+
+const (
+	// defaultStuckPendingTimeout is how long a mover pod may stay Pending
+	defaultStuckPendingTimeout = 15 * time.Minute
+	// stuckMoverKillPollInterval is how often a killed job is checked for
+	// remaining pods, which do not trigger a reconcile
+	stuckMoverKillPollInterval = 15 * time.Second
+	// stuckReasonMaxRunDuration is the reason of hung movers
+	stuckReasonMaxRunDuration = "MaxRunDurationExceeded"
+)
+
+// defaultStuckPendingReasons are the pod events that make a Pending mover pod
+// stuck. Multi-Attach errors are FailedAttachVolume events.
+var defaultStuckPendingReasons = []string{"FailedAttachVolume", "FailedMount"}
+
+// Event reasons for stuck movers
+const (
+	reasonMoverStuck         = "MoverStuck"
+	reasonStuckMoverUnlocked = "StuckMoverUnlocked"
+)
+
+// stuckPendingTimeout returns how long a mover pod may stay Pending
+func stuckPendingTimeout(spec *volsyncv1alpha1.StuckMoverSpec) time.Duration {
+	if spec.PendingTimeout != nil {
+		return spec.PendingTimeout.Duration
+	}
+	return defaultStuckPendingTimeout
+}
+
+// stuckPendingReasons returns the pod events that make a Pending pod stuck
+func stuckPendingReasons(spec *volsyncv1alpha1.StuckMoverSpec) []string {
+	if len(spec.PendingReasons) > 0 {
+		return spec.PendingReasons
+	}
+	return defaultStuckPendingReasons
+}
+
+// stuckMoverAction returns the action taken on stuck movers
+func stuckMoverAction(spec *volsyncv1alpha1.StuckMoverSpec) volsyncv1alpha1.StuckMoverAction {
+	if spec.Action != "" {
+		return spec.Action
+	}
+	return volsyncv1alpha1.StuckMoverActionNotify
+}
+
+// findStuckMover returns the entry of a job found stuck before, if any
+func findStuckMover(monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) *volsyncv1alpha1.StuckMover {
+	for i := range monitor.Status.StuckMovers {
+		stuck := &monitor.Status.StuckMovers[i]
+		if stuck.Namespace == job.Namespace && stuck.JobName == job.Name && stuck.JobUID == job.UID {
+			return stuck
+		}
+	}
+	return nil
+}
+
+// reconcileStuckMovers looks for running mover jobs that are hung or whose
+// pods cannot start, and acts on the ones not handled yet. It returns when
+// the next job or pod runs into its threshold.
+func (r *VolSyncMonitorReconciler) reconcileStuckMovers(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, others []volsyncv1alpha1.VolSyncMonitor) (time.Duration, error) {
+	spec := monitor.Spec.StuckMovers
+	if spec == nil {
+		monitor.Status.StuckMovers = nil
+		return 0, nil
+	}
+	logger := log.FromContext(ctx)
+
+	jobs, err := r.findVolSyncJobs(ctx, monitor, func(job *batchv1.Job) bool {
+		return !r.isJobFailed(job) && !r.isJobSucceeded(*job)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find running VolSync jobs: %w", err)
+	}
+
+	var requeueAfter time.Duration
+	wait := func(d time.Duration) {
+		if d > 0 && (requeueAfter == 0 || d < requeueAfter) {
+			requeueAfter = d
+		}
+	}
+
+	// Entries of jobs that finished, were deleted or are no longer stuck are dropped
+	var stuckMovers []volsyncv1alpha1.StuckMover
+	for _, job := range jobs {
+		if r.higherPriorityMonitor(monitor, others, job) != nil {
+			continue
+		}
+
+		// Killed jobs are unlocked and deleted once their pods are gone
+		entry := findStuckMover(monitor, job)
+		if entry != nil && entry.Action == volsyncv1alpha1.StuckMoverActionKillAndUnlock {
+			stuck := *entry
+			done, err := r.unlockKilledJob(ctx, monitor, job, &stuck)
+			if err != nil {
+				logger.Error(err, "Failed to unlock killed mover job", "job", job.Name, "namespace", job.Namespace)
+			}
+			if !done {
+				wait(stuckMoverKillPollInterval)
+			}
+			stuckMovers = append(stuckMovers, stuck)
+			continue
+		}
+
+		detected, next, err := r.detectStuckMover(ctx, spec, job)
+		if err != nil {
+			logger.Error(err, "Failed to check mover job", "job", job.Name, "namespace", job.Namespace)
+			if entry != nil {
+				stuckMovers = append(stuckMovers, *entry)
+			}
+			continue
+		}
+		wait(next)
+		if detected == nil {
+			continue
+		}
+
+		// Hung jobs are handled once, stuck pods once per pod
+		if entry != nil && entry.Kind == detected.Kind && entry.PodName == detected.PodName {
+			stuckMovers = append(stuckMovers, *entry)
+			continue
+		}
+		handled, err := r.handleStuckMover(ctx, monitor, job, detected)
+		if err != nil {
+			logger.Error(err, "Failed to handle stuck mover job", "job", job.Name, "namespace", job.Namespace)
+		}
+		if !handled {
+			continue
+		}
+		if detected.Action == volsyncv1alpha1.StuckMoverActionKillAndUnlock {
+			wait(stuckMoverKillPollInterval)
+		}
+		stuckMovers = append(stuckMovers, *detected)
+	}
+
+	monitor.Status.StuckMovers = stuckMovers
+	return requeueAfter, nil
+}
+
+// detectStuckMover reports a running mover job whose pod is stuck Pending on
+// one of the configured events, or that is active longer than its maximum
+// run duration. When it is not stuck, it returns when it can become stuck.
+func (r *VolSyncMonitorReconciler) detectStuckMover(ctx context.Context, spec *volsyncv1alpha1.StuckMoverSpec, job batchv1.Job) (*volsyncv1alpha1.StuckMover, time.Duration, error) {
+	now := time.Now()
+	var next time.Duration
+	later := func(d time.Duration) {
+		if next == 0 || d < next {
+			next = d
+		}
+	}
+
+	pods, err := r.jobPods(ctx, job)
+	if err != nil {
+		return nil, 0, err
+	}
+	timeout := stuckPendingTimeout(spec)
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if age := now.Sub(pod.CreationTimestamp.Time); age < timeout {
+			later(timeout - age)
+			continue
+		}
+		event, err := r.stuckPodEvent(ctx, pod, stuckPendingReasons(spec))
+		if err != nil {
+			return nil, 0, err
+		}
+		if event != nil {
+			return &volsyncv1alpha1.StuckMover{
+				Kind:    volsyncv1alpha1.StuckMoverKindPending,
+				PodName: pod.Name,
+				Reason:  event.Reason,
+				Message: fmt.Sprintf("Pod %s pending for %s: %s", pod.Name, now.Sub(pod.CreationTimestamp.Time).Round(time.Second), event.Message),
+			}, 0, nil
+		}
+	}
+
+	if spec.MaxRunDuration != nil && job.Status.StartTime != nil && r.isJobActive(job) {
+		maxRunDuration := spec.MaxRunDuration.Duration
+		running := now.Sub(job.Status.StartTime.Time)
+		if running >= maxRunDuration {
+			return &volsyncv1alpha1.StuckMover{
+				Kind:    volsyncv1alpha1.StuckMoverKindHung,
+				Reason:  stuckReasonMaxRunDuration,
+				Message: fmt.Sprintf("Job active for %s, longer than %s", running.Round(time.Second), maxRunDuration),
+			}, 0, nil
+		}
+		later(maxRunDuration - running)
+	}
+	return nil, next, nil
+}
+
+// jobPods returns the pods of a job
+func (r *VolSyncMonitorReconciler) jobPods(ctx context.Context, job batchv1.Job) ([]corev1.Pod, error) {
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list pods for job %s: %w", job.Name, err)
+	}
+
+	// Pods of an earlier job with the same name may still be terminating
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && job.UID != "" && owner.UID != job.UID {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// stuckPodEvent returns the latest warning event of a pod with one of reasons, if any
+func (r *VolSyncMonitorReconciler) stuckPodEvent(ctx context.Context, pod corev1.Pod, reasons []string) (*corev1.Event, error) {
+	var eventList corev1.EventList
+	if err := r.List(ctx, &eventList, client.InNamespace(pod.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list events in namespace %s: %w", pod.Namespace, err)
+	}
+
+	var latest *corev1.Event
+	for i := range eventList.Items {
+		event := &eventList.Items[i]
+		involved := event.InvolvedObject
+		if event.Type != corev1.EventTypeWarning || involved.Kind != "Pod" || involved.Name != pod.Name {
+			continue
+		}
+		if involved.UID != "" && involved.UID != pod.UID {
+			continue
+		}
+		matches := false
+		for _, reason := range reasons {
+			matches = matches || event.Reason == reason
+		}
+		if !matches {
+			continue
+		}
+		if latest == nil || eventTime(*event).After(eventTime(*latest)) {
+			latest = event
+		}
+	}
+	return latest, nil
+}
+
+// eventTime returns when an event was last seen
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// handleStuckMover publishes a stuck mover and takes the configured action.
+// It reports false for jobs the policy of their objects leaves alone.
+func (r *VolSyncMonitorReconciler) handleStuckMover(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, stuck *volsyncv1alpha1.StuckMover) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	_, policy, err := r.jobMoverSettings(ctx, monitor, job)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve monitoring policy: %w", err)
+	}
+	if policy.Mode == MonitorModeDisabled {
+		return false, nil
+	}
+	action := stuckMoverAction(monitor.Spec.StuckMovers)
+	if policy.Mode == MonitorModeObserve {
+		action = volsyncv1alpha1.StuckMoverActionNotify
+	}
+
+	identity := r.resolveJobIdentity(ctx, &job)
+	stuck.JobName = job.Name
+	stuck.Namespace = job.Namespace
+	stuck.JobUID = job.UID
+	stuck.AppName = identity.App
+	stuck.ObjectName = identity.ObjectName
+	stuck.DetectedTime = metav1.Now()
+	stuck.Action = action
+
+	helpers.RecordStuckMoverDetected(job.Namespace, identity.App, identity.ObjectName, string(stuck.Kind), stuck.Reason)
+	logger.Info("Mover job is stuck", "job", job.Name, "namespace", job.Namespace, "kind", stuck.Kind, "reason", stuck.Reason, "pod", stuck.PodName, "action", action)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonMoverStuck,
+			"Mover job %s/%s is stuck (%s, %s), action %s: %s", job.Namespace, job.Name, stuck.Kind, stuck.Reason, action, stuck.Message)
+	}
+
+	switch action {
+	case volsyncv1alpha1.StuckMoverActionDeletePod:
+		// The job controller replaces the deleted pods
+		pods, err := r.jobPods(ctx, job)
+		if err != nil {
+			return true, err
+		}
+		for i := range pods {
+			pod := &pods[i]
+			if stuck.PodName != "" && pod.Name != stuck.PodName {
+				continue
+			}
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+				return true, fmt.Errorf("failed to delete pod %s: %w", pod.Name, err)
+			}
+			logger.Info("Deleted stuck mover pod", "pod", pod.Name, "namespace", pod.Namespace, "job", job.Name)
+		}
+	case volsyncv1alpha1.StuckMoverActionKillAndUnlock:
+		// Suspending the job terminates its pods but keeps the job, whose
+		// spec the unlock job is built from
+		patch := client.MergeFrom(job.DeepCopy())
+		suspend := true
+		job.Spec.Suspend = &suspend
+		if err := r.Patch(ctx, &job, patch); err != nil {
+			return true, fmt.Errorf("failed to suspend job %s: %w", job.Name, err)
+		}
+		logger.Info("Suspended stuck mover job", "job", job.Name, "namespace", job.Namespace)
+	}
+	return true, nil
+}
+
+// unlockKilledJob unlocks the repository of a suspended mover job once its
+// pods are gone, then deletes the job once the unlock finished so that
+// VolSync starts the mover again. It reports whether the job was deleted.
+func (r *VolSyncMonitorReconciler) unlockKilledJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, stuck *volsyncv1alpha1.StuckMover) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	pods, err := r.jobPods(ctx, job)
+	if err != nil {
+		return false, err
+	}
+	if len(pods) > 0 {
+		logger.V(1).Info("Waiting for the pods of the killed mover job to terminate", "job", job.Name, "namespace", job.Namespace, "pods", len(pods))
+		return false, nil
+	}
+
+	// Only movers remediated by an unlock hold a lock a killed pod can leave behind
+	mover, _, err := r.jobMoverSettings(ctx, monitor, job)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve mover settings: %w", err)
+	}
+	if stuck.UnlockJobName == "" && mover.Remediation == volsyncv1alpha1.RemediationActionUnlock {
+		if !r.canCreateUnlockJob(*monitor) {
+			logger.Info("Maximum concurrent unlocks reached, deferring unlock of killed job", "job", job.Name, "namespace", job.Namespace)
+			return false, nil
+		}
+		unlockJob, err := r.createUnlockJob(ctx, monitor, job, stuck.Message, mover, job.UID)
+		if err != nil {
+			return false, err
+		}
+		stuck.UnlockJobName = unlockJob.Name
+		monitor.Status.ActiveUnlocks = append(monitor.Status.ActiveUnlocks, volsyncv1alpha1.ActiveUnlock{
+			AppName:          stuck.AppName,
+			Namespace:        unlockJob.Namespace,
+			ObjectName:       stuck.ObjectName,
+			JobName:          unlockJob.Name,
+			StartTime:        metav1.Now(),
+			AlertFingerprint: fmt.Sprintf("%s-%s", unlockJob.Namespace, unlockJob.Name),
+		})
+		monitor.Status.TotalUnlocksCreated++
+		monitor.Status.LastUnlockTime = &metav1.Time{Time: time.Now()}
+		if r.Recorder != nil {
+			r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonStuckMoverUnlocked,
+				"Created unlock job %s for killed mover job %s/%s", unlockJob.Name, job.Namespace, job.Name)
+		}
+	}
+
+	// VolSync starts the mover again once the job is gone, so the job is kept
+	// until the unlock finished rather than racing it
+	if stuck.UnlockJobName != "" {
+		unlockJob, err := r.findUnlockJob(ctx, monitor, job)
+		if err != nil {
+			return false, err
+		}
+		if unlockJob != nil && !r.isJobSucceeded(*unlockJob) && !r.isJobFailed(unlockJob) {
+			logger.V(1).Info("Waiting for the unlock of the killed mover job", "job", job.Name, "namespace", job.Namespace, "unlockJob", unlockJob.Name)
+			return false, nil
+		}
+	}
+
+	// Deleting the job makes VolSync start the mover again
+	if err := r.removeFailedJob(ctx, job); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to delete killed job %s: %w", job.Name, err)
+	}
+	logger.Info("Deleted killed mover job", "job", job.Name, "namespace", job.Namespace, "unlockJob", stuck.UnlockJobName)
+	return true, nil
+}
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Stuck movers", func() {
+	var (
+		ctx      context.Context
+		monitor  *volsyncv1alpha1.VolSyncMonitor
+		moverJob *batchv1.Job
+		moverPod *corev1.Pod
+		event    *corev1.Event
+		recorder *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:           true,
+				UnlockJobTemplate: volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+				JobSelector:       &volsyncv1alpha1.JobSelector{Namespaces: []string{"media"}},
+				StuckMovers: &volsyncv1alpha1.StuckMoverSpec{
+					MaxRunDuration: &metav1.Duration{Duration: 6 * time.Hour},
+				},
+			},
+		}
+		started := metav1.NewTime(time.Now().Add(-time.Hour))
+		moverJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "restic",
+							EnvFrom: []corev1.EnvFromSource{{
+								SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "plex-restic"}},
+							}},
+						}},
+					},
+				},
+			},
+			Status: batchv1.JobStatus{Active: 1, StartTime: &started},
+		}
+		moverPod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "volsync-src-plex-abcde",
+				Namespace:         "media",
+				UID:               "pod-uid",
+				Labels:            map[string]string{"job-name": moverJob.Name},
+				CreationTimestamp: started,
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "restic",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				}},
+			},
+		}
+		event = &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "volsync-src-plex-abcde.1", Namespace: "media"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: moverPod.Name, Namespace: "media", UID: moverPod.UID},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedAttachVolume",
+			Message:        `Multi-Attach error for volume "pvc-1234" Volume is already exclusively attached to one node and can't be attached to another`,
+			LastTimestamp:  metav1.Now(),
+		}
+		recorder = record.NewFakeRecorder(20)
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncMonitorReconciler {
+		r := newFakeReconciler(append(objects, moverJob, newReplicationSource("media", "plex", "plex-restic"))...)
+		r.Recorder = recorder
+		return r
+	}
+
+	events := func() []string {
+		var received []string
+		for len(recorder.Events) > 0 {
+			received = append(received, <-recorder.Events)
+		}
+		return received
+	}
+
+	It("should report a pod stuck on a Multi-Attach error once", func() {
+		r := newReconciler(moverPod, event)
+		_, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(monitor.Status.StuckMovers).To(HaveLen(1))
+		stuck := monitor.Status.StuckMovers[0]
+		Expect(stuck.Kind).To(Equal(volsyncv1alpha1.StuckMoverKindPending))
+		Expect(stuck.PodName).To(Equal(moverPod.Name))
+		Expect(stuck.Reason).To(Equal("FailedAttachVolume"))
+		Expect(stuck.Message).To(ContainSubstring("Multi-Attach error"))
+		Expect(stuck.Action).To(Equal(volsyncv1alpha1.StuckMoverActionNotify))
+		Expect(stuck.AppName).To(Equal("plex"))
+		Expect(events()).To(ConsistOf(ContainSubstring(reasonMoverStuck)))
+
+		By("not reporting it again")
+		_, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.StuckMovers).To(HaveLen(1))
+		Expect(events()).To(BeEmpty())
+	})
+
+	It("should wait for the pending timeout", func() {
+		moverPod.CreationTimestamp = metav1.NewTime(time.Now().Add(-5 * time.Minute))
+		r := newReconciler(moverPod, event)
+		next, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(BeNumerically("~", 10*time.Minute, 5*time.Second))
+		Expect(monitor.Status.StuckMovers).To(BeEmpty())
+	})
+
+	It("should leave pending pods without a known reason alone", func() {
+		event.Reason = "Scheduled"
+		r := newReconciler(moverPod, event)
+		_, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.StuckMovers).To(BeEmpty())
+	})
+
+	It("should delete the pods of a hung job", func() {
+		monitor.Spec.StuckMovers.MaxRunDuration = &metav1.Duration{Duration: 30 * time.Minute}
+		monitor.Spec.StuckMovers.Action = volsyncv1alpha1.StuckMoverActionDeletePod
+		moverPod.Status = corev1.PodStatus{Phase: corev1.PodRunning}
+		r := newReconciler(moverPod)
+		_, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(monitor.Status.StuckMovers).To(HaveLen(1))
+		Expect(monitor.Status.StuckMovers[0].Kind).To(Equal(volsyncv1alpha1.StuckMoverKindHung))
+		Expect(monitor.Status.StuckMovers[0].Reason).To(Equal(stuckReasonMaxRunDuration))
+		err = r.Get(ctx, client.ObjectKeyFromObject(moverPod), &corev1.Pod{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should kill a hung job and unlock its repository once its pods are gone", func() {
+		monitor.Spec.StuckMovers.MaxRunDuration = &metav1.Duration{Duration: 30 * time.Minute}
+		monitor.Spec.StuckMovers.Action = volsyncv1alpha1.StuckMoverActionKillAndUnlock
+		moverPod.Status = corev1.PodStatus{Phase: corev1.PodRunning}
+		r := newReconciler(moverPod)
+		next, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(Equal(stuckMoverKillPollInterval))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(moverJob), &job)).To(Succeed())
+		Expect(job.Spec.Suspend).To(HaveValue(BeTrue()))
+
+		By("waiting for the pods to terminate")
+		_, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.StuckMovers[0].UnlockJobName).To(BeEmpty())
+
+		By("unlocking the repository")
+		Expect(r.Delete(ctx, moverPod)).To(Succeed())
+		_, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		unlockJobName := monitor.Status.StuckMovers[0].UnlockJobName
+		Expect(unlockJobName).To(HavePrefix("volsync-unlock-volsync-src-plex-"))
+		var unlockJob batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKey{Namespace: "media", Name: unlockJobName}, &unlockJob)).To(Succeed())
+		Expect(monitor.Status.ActiveUnlocks).To(HaveLen(1))
+
+		By("keeping the job while the unlock job runs")
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(moverJob), &batchv1.Job{})).To(Succeed())
+		unlockJob.Status.Active = 1
+		Expect(r.Status().Update(ctx, &unlockJob)).To(Succeed())
+		next, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(Equal(stuckMoverKillPollInterval))
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(moverJob), &batchv1.Job{})).To(Succeed())
+
+		By("deleting the job once the unlock finished")
+		unlockJob.Status.Active = 0
+		unlockJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &unlockJob)).To(Succeed())
+		_, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		err = r.Get(ctx, client.ObjectKeyFromObject(moverJob), &batchv1.Job{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("forgetting the job once it is gone")
+		_, err = r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.StuckMovers).To(BeEmpty())
+	})
+
+	It("should only notify in observe mode", func() {
+		monitor.Spec.StuckMovers.Action = volsyncv1alpha1.StuckMoverActionDeletePod
+		moverJob.Annotations = map[string]string{MonitorModeAnnotation: string(MonitorModeObserve)}
+		r := newReconciler(moverPod, event)
+		_, err := r.reconcileStuckMovers(ctx, monitor, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(monitor.Status.StuckMovers[0].Action).To(Equal(volsyncv1alpha1.StuckMoverActionNotify))
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(moverPod), &corev1.Pod{})).To(Succeed())
+	})
+})
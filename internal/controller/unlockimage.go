@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+const (
+	// unlockImageAnnotation records the image an unlock job runs
+	unlockImageAnnotation = "homelab.rafaribe.com/unlock-image"
+
+	// unlockImageSourceAnnotation records where that image was taken from
+	unlockImageSourceAnnotation = "homelab.rafaribe.com/unlock-image-source"
+)
+
+// Sources of the unlock image
+const (
+	unlockImageFromOverride = "override"
+	unlockImageFromTemplate = "template"
+	unlockImageFromMover    = "mover"
+)
+
+// errNoUnlockImage is returned for repositories whose unlock job has no image
+// to run. Retrying does not help until the template or the mover job changes.
+var errNoUnlockImage = errors.New("no unlock image: the mover image is unknown and the unlock job template sets no image")
+
+// unlockImage is the image an unlock job runs
+type unlockImage struct {
+	Image string
+	// Source is where the image was taken from: override, template or mover
+	Source string
+	// PullSecrets are the image pull secrets of the mover, used for images
+	// that were not configured explicitly or were picked by the mover image
+	PullSecrets []corev1.LocalObjectReference
+}
+
+// resolveUnlockImage picks the image of an unlock job: an override matching
+// the mover image or its tag, then the image of the template, and finally the
+// mover image itself
+func resolveUnlockImage(template volsyncv1alpha1.UnlockJobTemplate, target *unlockTarget) (unlockImage, error) {
+	var moverImage string
+	var pullSecrets []corev1.LocalObjectReference
+	if target != nil {
+		moverImage = target.Image
+		pullSecrets = target.ImagePullSecrets
+	}
+
+	if moverImage != "" {
+		for _, key := range []string{moverImage, imageTag(moverImage)} {
+			if image := template.ImageOverrides[key]; key != "" && image != "" {
+				return unlockImage{Image: image, Source: unlockImageFromOverride, PullSecrets: pullSecrets}, nil
+			}
+		}
+	}
+	if template.Image != "" {
+		return unlockImage{Image: template.Image, Source: unlockImageFromTemplate}, nil
+	}
+	if moverImage != "" {
+		return unlockImage{Image: moverImage, Source: unlockImageFromMover, PullSecrets: pullSecrets}, nil
+	}
+	return unlockImage{}, errNoUnlockImage
+}
+
+// imageTag returns the tag of an image reference, without its digest
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Unlock image", func() {
+	var target *unlockTarget
+
+	BeforeEach(func() {
+		target = &unlockTarget{
+			Image:            "quay.io/backube/volsync:0.9.1",
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		}
+	})
+
+	It("should run the mover image when no image is configured", func() {
+		image, err := resolveUnlockImage(volsyncv1alpha1.UnlockJobTemplate{}, target)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal(unlockImage{
+			Image:       "quay.io/backube/volsync:0.9.1",
+			Source:      unlockImageFromMover,
+			PullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+		}))
+	})
+
+	It("should prefer an override of the mover version over the configured image", func() {
+		template := volsyncv1alpha1.UnlockJobTemplate{
+			Image:          "restic/restic:latest",
+			ImageOverrides: map[string]string{"0.9.1": "quay.io/backube/volsync:0.10.0"},
+		}
+		image, err := resolveUnlockImage(template, target)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image.Image).To(Equal("quay.io/backube/volsync:0.10.0"))
+		Expect(image.Source).To(Equal(unlockImageFromOverride))
+
+		target.Image = "quay.io/backube/volsync:0.8.0"
+		image, err = resolveUnlockImage(template, target)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal(unlockImage{Image: "restic/restic:latest", Source: unlockImageFromTemplate}))
+	})
+
+	It("should fail without a mover image or a configured image", func() {
+		_, err := resolveUnlockImage(volsyncv1alpha1.UnlockJobTemplate{}, &unlockTarget{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should read the tag of image references", func() {
+		Expect(imageTag("quay.io/backube/volsync:0.9.1")).To(Equal("0.9.1"))
+		Expect(imageTag("registry:5000/volsync")).To(BeEmpty())
+		Expect(imageTag("volsync:0.9.1@sha256:abc")).To(Equal("0.9.1"))
+	})
+
+	It("should record the chosen image on the unlock job", func() {
+		monitor := &volsyncv1alpha1.VolSyncMonitor{ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"}}
+		r := &VolSyncMonitorReconciler{Scheme: newFakeScheme()}
+		mover := r.resolveMoverSettings(monitor, volsyncv1alpha1.MoverTypeRestic)
+		failedJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"}}
+
+		unlockJob, err := r.newUnlockJob(monitor, failedJob, jobIdentity{}, "locked", mover, target, "job-uid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unlockJob.Spec.Template.Spec.Containers[0].Image).To(Equal("quay.io/backube/volsync:0.9.1"))
+		Expect(unlockJob.Spec.Template.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry"}))
+		Expect(unlockJob.Annotations).To(HaveKeyWithValue(unlockImageAnnotation, "quay.io/backube/volsync:0.9.1"))
+		Expect(unlockJob.Annotations).To(HaveKeyWithValue(unlockImageSourceAnnotation, unlockImageFromMover))
+	})
+})
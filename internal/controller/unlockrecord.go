@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+)
+
+const (
+	// defaultRecordMaxAge is how long UnlockRecords are kept when no retention is configured
+	defaultRecordMaxAge = 30 * 24 * time.Hour
+	// defaultRecordMaxRecords is how many UnlockRecords are kept per monitor when no retention is configured
+	defaultRecordMaxRecords = 100
+
+	// logsExcerptLines and logsExcerptBytes bound the log excerpts stored in records
+	logsExcerptLines = 20
+	logsExcerptBytes = 4096
+
+	// Labels set on UnlockRecords
+	recordMonitorLabel          = MonitorLabel
+	recordMonitorNamespaceLabel = "homelab.rafaribe.com/monitor-namespace"
+	recordFailedJobLabel        = "homelab.rafaribe.com/failed-job"
+	recordUnlockJobLabel        = "homelab.rafaribe.com/unlock-job"
+
+	// failedJobUIDLabel is set on unlock jobs to find them again by the failure they handle
+	failedJobUIDLabel = "homelab.rafaribe.com/failed-job-uid"
+)
+
+// Retrigger results recorded on UnlockRecords
+const (
+	retriggerFailedJobRemoved = "Failed job removed, VolSync will run the mover again"
+	retriggerUnlocked         = "Failed job kept, VolSync runs the mover again on its own"
+	retriggerUnlockFailed     = "Not retriggered, the unlock job failed"
+)
+
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=unlockrecords,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=unlockrecords/status,verbs=get;update;patch
+
+// createUnlockRecord stores the durable history entry for a processed failed job.
+// Records are not owned by the monitor so that they survive its deletion.
+func (r *VolSyncMonitorReconciler) createUnlockRecord(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job, match *lockErrorMatch, processed volsyncv1alpha1.ProcessedJob) (*volsyncv1alpha1.UnlockRecord, error) {
+	labels := map[string]string{
+		recordMonitorLabel:          monitor.Name,
+		recordMonitorNamespaceLabel: monitor.Namespace,
+		recordFailedJobLabel:        job.Name,
+	}
+	if processed.UnlockJobName != "" {
+		labels[recordUnlockJobLabel] = processed.UnlockJobName
+	}
+
+	record := &volsyncv1alpha1.UnlockRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      unlockRecordName(job, processed),
+			Namespace: job.Namespace,
+			Labels:    labels,
+		},
+		Spec: volsyncv1alpha1.UnlockRecordSpec{
+			MonitorRef: volsyncv1alpha1.MonitorReference{
+				Name:      monitor.Name,
+				Namespace: monitor.Namespace,
+			},
+			FailedJob: volsyncv1alpha1.FailedJobReference{
+				Name:        job.Name,
+				Namespace:   job.Namespace,
+				UID:         job.UID,
+				FailureTime: jobFailureTime(job),
+			},
+			AppName:        processed.AppName,
+			ObjectName:     processed.ObjectName,
+			Direction:      processed.Direction,
+			MoverType:      processed.MoverType,
+			LockError:      processed.LockError,
+			Classification: processed.Classification,
+			Pattern:        processed.Pattern,
+			Severity:       processed.Severity,
+			DetectedBy:     processed.DetectedBy,
+			ExitCode:       processed.ExitCode,
+			Remediation:    processed.Remediation,
+			UnlockJobName:  processed.UnlockJobName,
+		},
+	}
+
+	if err := r.Create(ctx, record); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create unlock record: %w", err)
+		}
+		// The failure was recorded before a restart; only write a status
+		// that was not written then
+		if err := r.Get(ctx, client.ObjectKeyFromObject(record), record); err != nil {
+			return nil, fmt.Errorf("failed to get unlock record %s: %w", record.Name, err)
+		}
+		if record.Status.Outcome != "" {
+			return record, nil
+		}
+	}
+
+	// Status is a subresource and has to be written separately
+	now := metav1.Now()
+	record.Status = volsyncv1alpha1.UnlockRecordStatus{
+		StartTime:   &now,
+		LogsExcerpt: match.LogsExcerpt,
+	}
+	switch processed.Remediation {
+	case volsyncv1alpha1.RemediationActionUnlock:
+		record.Status.Outcome = volsyncv1alpha1.UnlockOutcomeRunning
+		record.Status.Message = fmt.Sprintf("Unlock job %s created", processed.UnlockJobName)
+		if processed.Removed {
+			record.Status.RetriggerResult = retriggerFailedJobRemoved
+		}
+	case volsyncv1alpha1.RemediationActionRetry:
+		record.Status.Outcome = volsyncv1alpha1.UnlockOutcomeSucceeded
+		record.Status.CompletionTime = &now
+		record.Status.RetriggerResult = retriggerFailedJobRemoved
+		record.Status.Message = "Retried without unlocking"
+	case volsyncv1alpha1.RemediationActionRecreateCache:
+		record.Status.Outcome = volsyncv1alpha1.UnlockOutcomeSucceeded
+		record.Status.CompletionTime = &now
+		record.Status.RetriggerResult = "Failed job removed, VolSync will run the mover again with a new cache"
+		record.Status.Message = "Cache volume recreated"
+	default:
+		record.Status.Outcome = volsyncv1alpha1.UnlockOutcomeSkipped
+		record.Status.CompletionTime = &now
+		record.Status.Message = "No remediation configured for this mover"
+	}
+	if err := r.Status().Update(ctx, record); err != nil {
+		return record, fmt.Errorf("failed to update unlock record status: %w", err)
+	}
+
+	return record, nil
+}
+
+// unlockRecordName returns the name of the record of a processed failure.
+// Names are derived from the failure, so that a failure processed again
+// after a restart finds its record instead of recording it twice. Jobs
+// without a UID, such as the placeholders of lock sweeps, get a unique name.
+func unlockRecordName(job batchv1.Job, processed volsyncv1alpha1.ProcessedJob) string {
+	key := ""
+	if job.UID != "" {
+		failureTime := ""
+		if t := jobFailureTime(job); t != nil {
+			failureTime = t.UTC().Format(time.RFC3339)
+		}
+		key = strings.Join([]string{string(job.UID), failureTime, string(processed.DetectedBy), processed.UnlockJobName}, "/")
+	}
+	return boundedName("", job.Name, nameSuffix(key))
+}
+
+// finishUnlockRecords records the outcome of a finished unlock job on its UnlockRecords
+func (r *VolSyncMonitorReconciler) finishUnlockRecords(ctx context.Context, unlockJob batchv1.Job, outcome volsyncv1alpha1.UnlockOutcome) error {
+	var records volsyncv1alpha1.UnlockRecordList
+	if err := r.List(ctx, &records,
+		client.InNamespace(unlockJob.Namespace),
+		client.MatchingLabels{recordUnlockJobLabel: unlockJob.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list unlock records for job %s: %w", unlockJob.Name, err)
+	}
+
+	for i := range records.Items {
+		record := &records.Items[i]
+		if record.Status.Outcome != volsyncv1alpha1.UnlockOutcomeRunning {
+			continue
+		}
+
+		completionTime := metav1.Now()
+		if unlockJob.Status.CompletionTime != nil {
+			completionTime = *unlockJob.Status.CompletionTime
+		}
+		record.Status.Outcome = outcome
+		record.Status.CompletionTime = &completionTime
+		record.Status.UnlockLogsExcerpt = r.unlockJobLogsExcerpt(ctx, unlockJob)
+		record.Status.Message = fmt.Sprintf("Unlock job %s %s", unlockJob.Name, strings.ToLower(string(outcome)))
+		// Failed jobs removed along with the unlock keep their result
+		if record.Status.RetriggerResult == "" {
+			record.Status.RetriggerResult = retriggerUnlocked
+			if outcome == volsyncv1alpha1.UnlockOutcomeFailed {
+				record.Status.RetriggerResult = retriggerUnlockFailed
+			}
+		}
+
+		if err := r.Status().Update(ctx, record); err != nil {
+			return fmt.Errorf("failed to update unlock record %s: %w", record.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// recordFailedJobRemoved records on an UnlockRecord that its failed job was
+// removed after the unlock finished
+func (r *VolSyncMonitorReconciler) recordFailedJobRemoved(ctx context.Context, namespace, recordName string) error {
+	var record volsyncv1alpha1.UnlockRecord
+	if err := r.Get(ctx, types.NamespacedName{Name: recordName, Namespace: namespace}, &record); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get unlock record %s: %w", recordName, err)
+	}
+	record.Status.RetriggerResult = retriggerFailedJobRemoved
+	if err := r.Status().Update(ctx, &record); err != nil {
+		return fmt.Errorf("failed to update unlock record %s: %w", recordName, err)
+	}
+	return nil
+}
+
+// unlockJobLogsExcerpt returns the tail of the logs of an unlock job's most recent pod
+func (r *VolSyncMonitorReconciler) unlockJobLogsExcerpt(ctx context.Context, unlockJob batchv1.Job) string {
+	logs, err := r.latestJobPodLogs(ctx, unlockJob)
+	if err != nil {
+		return ""
+	}
+	return helpers.TailLines(logs, logsExcerptLines, logsExcerptBytes)
+}
+
+// latestJobPodLogs returns the logs of the most recent pod of a job
+func (r *VolSyncMonitorReconciler) latestJobPodLogs(ctx context.Context, job batchv1.Job) (string, error) {
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name},
+	); err != nil {
+		return "", fmt.Errorf("failed to list pods for job %s: %w", job.Name, err)
+	}
+	if len(podList.Items) == 0 {
+		return "", fmt.Errorf("job %s has no pods", job.Name)
+	}
+
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[j].CreationTimestamp.Before(&podList.Items[i].CreationTimestamp)
+	})
+	pod := podList.Items[0]
+	return r.podLogs(ctx, pod)
+}
+
+// recordMaxAge returns how long the history of a monitor is kept
+func recordMaxAge(monitor *volsyncv1alpha1.VolSyncMonitor) time.Duration {
+	if retention := monitor.Spec.RecordRetention; retention != nil && retention.MaxAge != nil {
+		return retention.MaxAge.Duration
+	}
+	return defaultRecordMaxAge
+}
+
+// pruneUnlockRecords deletes the records of a monitor that exceed its retention settings
+func (r *VolSyncMonitorReconciler) pruneUnlockRecords(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) error {
+	logger := log.FromContext(ctx)
+
+	maxAge := recordMaxAge(monitor)
+	maxRecords := defaultRecordMaxRecords
+	if retention := monitor.Spec.RecordRetention; retention != nil && retention.MaxRecords > 0 {
+		maxRecords = int(retention.MaxRecords)
+	}
+
+	var records volsyncv1alpha1.UnlockRecordList
+	if err := r.List(ctx, &records, client.MatchingLabels{
+		recordMonitorLabel:          monitor.Name,
+		recordMonitorNamespaceLabel: monitor.Namespace,
+	}); err != nil {
+		return fmt.Errorf("failed to list unlock records: %w", err)
+	}
+
+	// Newest records first
+	sort.Slice(records.Items, func(i, j int) bool {
+		return records.Items[j].CreationTimestamp.Before(&records.Items[i].CreationTimestamp)
+	})
+
+	cutoff := time.Now().Add(-maxAge)
+	for i := range records.Items {
+		record := &records.Items[i]
+		if i < maxRecords && record.CreationTimestamp.Time.After(cutoff) {
+			continue
+		}
+		if err := r.Delete(ctx, record); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete unlock record %s/%s: %w", record.Namespace, record.Name, err)
+		}
+		logger.V(1).Info("Pruned unlock record", "record", record.Name, "namespace", record.Namespace)
+	}
+
+	return nil
+}
+
+// jobFailureTime returns when a job was marked as failed
+func jobFailureTime(job batchv1.Job) *metav1.Time {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			failureTime := condition.LastTransitionTime
+			return &failureTime
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+var _ = Describe("Unlock records", func() {
+	var (
+		ctx     context.Context
+		monitor *volsyncv1alpha1.VolSyncMonitor
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+		}
+	})
+
+	newRecord := func(name string, age time.Duration, labels map[string]string) *volsyncv1alpha1.UnlockRecord {
+		recordLabels := map[string]string{
+			recordMonitorLabel:          monitor.Name,
+			recordMonitorNamespaceLabel: monitor.Namespace,
+		}
+		for key, value := range labels {
+			recordLabels[key] = value
+		}
+		return &volsyncv1alpha1.UnlockRecord{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "media",
+			Labels:            recordLabels,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}}
+	}
+
+	listRecords := func(r *VolSyncMonitorReconciler) []string {
+		var records volsyncv1alpha1.UnlockRecordList
+		Expect(r.List(ctx, &records)).To(Succeed())
+		names := []string{}
+		for _, record := range records.Items {
+			names = append(names, record.Name)
+		}
+		return names
+	}
+
+	It("should record a failure processed again only once", func() {
+		failedJob := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media", UID: "job-uid"},
+			Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now(),
+			}}},
+		}
+		processed := volsyncv1alpha1.ProcessedJob{
+			JobName:       failedJob.Name,
+			Remediation:   volsyncv1alpha1.RemediationActionUnlock,
+			UnlockJobName: "volsync-unlock-volsync-src-plex-3f2a9c1b7e",
+		}
+		r := newFakeReconciler()
+
+		first, err := r.createUnlockRecord(ctx, monitor, failedJob, &lockErrorMatch{}, processed)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := r.createUnlockRecord(ctx, monitor, failedJob, &lockErrorMatch{}, processed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Name).To(Equal(first.Name))
+		Expect(second.Status.Outcome).To(Equal(volsyncv1alpha1.UnlockOutcomeRunning))
+		Expect(listRecords(r)).To(HaveLen(1))
+
+		By("recording a later failure of a reused job name separately")
+		failedJob.UID = "recreated-job-uid"
+		_, err = r.createUnlockRecord(ctx, monitor, failedJob, &lockErrorMatch{}, processed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listRecords(r)).To(HaveLen(2))
+	})
+
+	It("should keep record names of long job names within 63 characters", func() {
+		failedJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: "volsync-src-a-very-long-replication-source-name-for-an-app", Namespace: "media", UID: "job-uid",
+		}}
+		name := unlockRecordName(failedJob, volsyncv1alpha1.ProcessedJob{})
+		Expect(len(name)).To(BeNumerically("<=", 63))
+		Expect(name).To(HavePrefix("volsync-src-a-very-long"))
+	})
+
+	It("should prune records beyond MaxRecords", func() {
+		monitor.Spec.RecordRetention = &volsyncv1alpha1.RecordRetention{MaxRecords: 2}
+		var objects []client.Object
+		for i := 0; i < 4; i++ {
+			objects = append(objects, newRecord(fmt.Sprintf("record-%d", i), time.Duration(i)*time.Hour, nil))
+		}
+		other := newRecord("other-monitor", 5*time.Hour, map[string]string{recordMonitorNamespaceLabel: "other"})
+		r := newFakeReconciler(append(objects, other)...)
+
+		Expect(r.pruneUnlockRecords(ctx, monitor)).To(Succeed())
+		Expect(listRecords(r)).To(ConsistOf("record-0", "record-1", "other-monitor"))
+	})
+
+	It("should prune records older than MaxAge", func() {
+		monitor.Spec.RecordRetention = &volsyncv1alpha1.RecordRetention{MaxAge: &metav1.Duration{Duration: 24 * time.Hour}}
+		r := newFakeReconciler(
+			newRecord("recent", time.Hour, nil),
+			newRecord("old", 48*time.Hour, nil),
+		)
+
+		Expect(r.pruneUnlockRecords(ctx, monitor)).To(Succeed())
+		Expect(listRecords(r)).To(ConsistOf("recent"))
+	})
+
+	It("should record the outcome of finished unlock jobs on running records", func() {
+		running := newRecord("running", time.Hour, map[string]string{recordUnlockJobLabel: "volsync-unlock-plex"})
+		running.Status.Outcome = volsyncv1alpha1.UnlockOutcomeRunning
+		skipped := newRecord("skipped", time.Hour, map[string]string{recordUnlockJobLabel: "volsync-unlock-plex"})
+		skipped.Status.Outcome = volsyncv1alpha1.UnlockOutcomeSkipped
+		other := newRecord("other", time.Hour, map[string]string{recordUnlockJobLabel: "volsync-unlock-sonarr"})
+		other.Status.Outcome = volsyncv1alpha1.UnlockOutcomeRunning
+		r := newFakeReconciler(running, skipped, other)
+
+		completionTime := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+		unlockJob := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-unlock-plex", Namespace: "media"},
+			Status:     batchv1.JobStatus{CompletionTime: &completionTime},
+		}
+		Expect(r.finishUnlockRecords(ctx, unlockJob, volsyncv1alpha1.UnlockOutcomeSucceeded)).To(Succeed())
+
+		var record volsyncv1alpha1.UnlockRecord
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(running), &record)).To(Succeed())
+		Expect(record.Status.Outcome).To(Equal(volsyncv1alpha1.UnlockOutcomeSucceeded))
+		Expect(record.Status.CompletionTime.Time).To(BeTemporally("==", completionTime.Time))
+		Expect(record.Status.Message).To(Equal("Unlock job volsync-unlock-plex succeeded"))
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerUnlocked))
+
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(skipped), &record)).To(Succeed())
+		Expect(record.Status.Outcome).To(Equal(volsyncv1alpha1.UnlockOutcomeSkipped))
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(other), &record)).To(Succeed())
+		Expect(record.Status.Outcome).To(Equal(volsyncv1alpha1.UnlockOutcomeRunning))
+	})
+
+	It("should record failed unlocks as not retriggered", func() {
+		running := newRecord("running", time.Hour, map[string]string{recordUnlockJobLabel: "volsync-unlock-plex"})
+		running.Status.Outcome = volsyncv1alpha1.UnlockOutcomeRunning
+		removed := newRecord("removed", time.Hour, map[string]string{recordUnlockJobLabel: "volsync-unlock-plex"})
+		removed.Status.Outcome = volsyncv1alpha1.UnlockOutcomeRunning
+		removed.Status.RetriggerResult = retriggerFailedJobRemoved
+		r := newFakeReconciler(running, removed)
+
+		unlockJob := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "volsync-unlock-plex", Namespace: "media"}}
+		Expect(r.finishUnlockRecords(ctx, unlockJob, volsyncv1alpha1.UnlockOutcomeFailed)).To(Succeed())
+
+		var record volsyncv1alpha1.UnlockRecord
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(running), &record)).To(Succeed())
+		Expect(record.Status.Outcome).To(Equal(volsyncv1alpha1.UnlockOutcomeFailed))
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerUnlockFailed))
+
+		By("keeping the result of a failed job removed along with the unlock")
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(removed), &record)).To(Succeed())
+		Expect(record.Status.RetriggerResult).To(Equal(retriggerFailedJobRemoved))
+	})
+})
@@ -2,8 +2,8 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -12,22 +12,50 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
 	"github.com/rafaribe/homelab-assistant/internal/helpers"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
 )
 
 // VolSyncMonitorReconciler reconciles a VolSyncMonitor object
 type VolSyncMonitorReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder publishes events on monitors; events are dropped when nil
+	Recorder record.EventRecorder
+
+	// Config holds the controller tunables; the defaults are used when nil
+	Config *config.Store
+
+	// Logs reads the logs of mover and unlock pods; they are read from the
+	// API server when nil
+	Logs helpers.LogSource
+}
+
+// podLogs returns the logs of a pod
+func (r *VolSyncMonitorReconciler) podLogs(ctx context.Context, pod corev1.Pod) (string, error) {
+	if r.Logs != nil {
+		return r.Logs.GetPodLogs(ctx, pod.Namespace, pod.Name, "")
+	}
+	return helpers.GetPodLogs(ctx, r.Client, pod.Namespace, pod.Name, "")
+}
+
+// settings returns the current VolSyncMonitor controller configuration
+func (r *VolSyncMonitorReconciler) settings() config.VolSyncMonitorConfig {
+	return r.Config.Get().VolSyncMonitor
 }
 
 //+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=volsyncmonitors,verbs=get;list;watch;create;update;patch;delete
@@ -35,10 +63,11 @@ type VolSyncMonitorReconciler struct {
 //+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=volsyncmonitors/finalizers,verbs=update
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
 //+kubebuilder:rbac:groups="",resources=pods/log,verbs=get;list
-//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;patch
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=volsync.backube,resources=replicationsources;replicationdestinations,verbs=get;list;watch;update;patch
 
 func (r *VolSyncMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -48,37 +77,54 @@ func (r *VolSyncMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	if err := r.Get(ctx, req.NamespacedName, &monitor); err != nil {
 		if errors.IsNotFound(err) {
 			logger.Info("VolSyncMonitor resource not found. Ignoring since object must be deleted")
+			patternSets.forget(req.NamespacedName.String())
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to get VolSyncMonitor")
 		return ctrl.Result{}, err
 	}
 
+	// A deleted monitor releases the VolSync objects it holds before it goes away
+	if !monitor.DeletionTimestamp.IsZero() {
+		if err := r.finalizeMonitor(ctx, &monitor); err != nil {
+			logger.Error(err, "Failed to finalize VolSyncMonitor")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if holdsReplication(&monitor) && controllerutil.AddFinalizer(&monitor, replicationHoldFinalizer) {
+		if err := r.Update(ctx, &monitor); err != nil {
+			logger.Error(err, "Failed to add finalizer to VolSyncMonitor")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Keep the original to compute a status patch that does not conflict with other writers
+	original := monitor.DeepCopy()
+
 	// Check if monitor is enabled
 	if !monitor.Spec.Enabled {
 		logger.Info("VolSyncMonitor is disabled, skipping reconciliation")
-		return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
-	}
-
-	// Update status phase
-	if monitor.Status.Phase == "" {
-		monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseActive
+		if err := r.releaseReplicationHolds(ctx, &monitor); err != nil {
+			logger.Error(err, "Failed to release held VolSync objects")
+		}
+		setPausedStatus(&monitor)
+		if err := r.patchStatus(ctx, &monitor, original); err != nil {
+			logger.Error(err, "Failed to update VolSyncMonitor status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.settings().DisabledRequeueInterval.Duration}, nil
 	}
 
 	// Main reconciliation logic
 	result, err := r.reconcileMonitor(ctx, &monitor)
 	if err != nil {
-		monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseError
-		monitor.Status.LastError = err.Error()
 		logger.Error(err, "Failed to reconcile VolSyncMonitor")
-	} else {
-		monitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhaseActive
-		monitor.Status.LastError = ""
 	}
+	setReconcileStatus(&monitor, err)
 
 	// Update status
-	monitor.Status.ObservedGeneration = monitor.Generation
-	if err := r.Status().Update(ctx, &monitor); err != nil {
+	if err := r.patchStatus(ctx, &monitor, original); err != nil {
 		logger.Error(err, "Failed to update VolSyncMonitor status")
 		return ctrl.Result{}, err
 	}
@@ -86,82 +132,297 @@ func (r *VolSyncMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	return result, err
 }
 
+// patchStatus writes the monitor status as a merge patch against original
+func (r *VolSyncMonitorReconciler) patchStatus(ctx context.Context, monitor, original *volsyncv1alpha1.VolSyncMonitor) error {
+	monitor.Status.ObservedGeneration = monitor.Generation
+	return r.Status().Patch(ctx, monitor, client.MergeFrom(original))
+}
+
 func (r *VolSyncMonitorReconciler) reconcileMonitor(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	// Step 1: Find failed VolSync jobs
+	// Step 1: Update active unlocks status, used for the concurrency limit
+	if err := r.updateActiveUnlocks(ctx, monitor); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update active unlocks: %w", err)
+	}
+	if err := r.releaseReplicationHolds(ctx, monitor); err != nil {
+		logger.Error(err, "Failed to release held VolSync objects")
+	}
+	if err := r.updatePatternSetsCondition(ctx, monitor); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Step 2: Find failed VolSync jobs and the monitors competing for them
 	failedJobs, err := r.findFailedVolSyncJobs(ctx, monitor)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to find failed VolSync jobs: %w", err)
 	}
+	others, err := r.listCompetingMonitors(ctx, monitor)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	updateOverlaps(monitor, others)
 
-	// Step 2: Process each failed job
+	// Step 3: Process each failed job
+	monitor.Status.QueuedUnlocks = nil
 	for _, job := range failedJobs {
-		// Skip if already processed
+		// Skip if already processed, keeping the claim so other monitors leave it alone
 		if r.isJobAlreadyProcessed(monitor, job) {
+			if _, _, err := r.claimJob(ctx, monitor, &job); err != nil {
+				logger.Error(err, "Failed to renew job claim", "job", job.Name, "namespace", job.Namespace)
+			}
+			continue
+		}
+
+		// Leave jobs to monitors with a higher priority or an unexpired claim
+		if other := r.higherPriorityMonitor(monitor, others, job); other != nil {
+			logger.V(1).Info("Job is handled by a monitor with a higher priority", "job", job.Name, "namespace", job.Namespace, "monitor", monitorKey(other))
+			continue
+		}
+		if holder, _ := jobClaim(job, time.Now()); holder != "" && holder != monitorKey(monitor) {
+			logger.V(1).Info("Job is claimed by another monitor", "job", job.Name, "namespace", job.Namespace, "monitor", holder)
+			continue
+		}
+
+		// Resolve detection and remediation settings for the job's mover and
+		// the annotations of the objects above it
+		mover, policy, err := r.jobMoverSettings(ctx, monitor, job)
+		if err != nil {
+			logger.Error(err, "Failed to resolve monitoring policy", "job", job.Name, "namespace", job.Namespace)
 			continue
 		}
+		if policy.Mode == MonitorModeDisabled {
+			continue
+		}
+		if policy.Mode == MonitorModeObserve {
+			mover.Remediation = volsyncv1alpha1.RemediationActionNone
+		}
 
 		// Check if job has lock errors
-		hasLockError, lockError, err := r.checkJobForLockErrors(ctx, job, monitor.Spec.LockErrorPatterns)
+		match, err := r.checkJobForLockErrors(ctx, job, mover)
 		if err != nil {
 			logger.Error(err, "Failed to check job for lock errors", "job", job.Name, "namespace", job.Namespace)
 			continue
 		}
 
-		if hasLockError {
-			logger.Info("Lock error detected in failed job", "job", job.Name, "namespace", job.Namespace, "error", lockError)
-			
-			// Create unlock job
-			unlockJob, err := r.createUnlockJob(ctx, monitor, job, lockError)
+		if match != nil {
+			claimed, holder, err := r.claimJob(ctx, monitor, &job)
 			if err != nil {
-				logger.Error(err, "Failed to create unlock job", "job", job.Name)
+				logger.Error(err, "Failed to claim job", "job", job.Name, "namespace", job.Namespace)
+				continue
+			}
+			if !claimed {
+				logger.Info("Job was claimed by another monitor", "job", job.Name, "namespace", job.Namespace, "monitor", holder)
 				continue
 			}
 
-			// Remove failed job if configured to do so
-			if monitor.Spec.RemoveFailedJobs {
-				if err := r.removeFailedJob(ctx, job); err != nil {
-					logger.Error(err, "Failed to remove failed job", "job", job.Name)
-					// Continue anyway - we still want to track the unlock job
+			// Cache failures have their own remediation
+			mover.Remediation = classRemediation(monitor, mover.Remediation, match.Class)
+
+			lockError := match.Message
+			logger.Info("Lock error detected in failed job", "job", job.Name, "namespace", job.Namespace, "error", lockError, "detectedBy", match.DetectedBy, "mover", mover.Type, "mode", policy.Mode)
+			if policy.Mode == MonitorModeObserve && r.Recorder != nil {
+				r.Recorder.Eventf(monitor, corev1.EventTypeNormal, reasonObserveOnly,
+					"Lock error in job %s/%s not remediated, observe mode set by %s", job.Namespace, job.Name, policy.ModeSource)
+			}
+			if mover.Remediation == volsyncv1alpha1.RemediationActionNone && mover.UnlockUnavailable != "" && r.Recorder != nil {
+				r.Recorder.Eventf(monitor, corev1.EventTypeWarning, reasonUnlockUnavailable,
+					"Lock error in job %s/%s not remediated, %s", job.Namespace, job.Name, mover.UnlockUnavailable)
+			}
+
+			// Track the processed job
+			identity := r.resolveJobIdentity(ctx, &job)
+			processedJob := volsyncv1alpha1.ProcessedJob{
+				JobName:        job.Name,
+				Namespace:      job.Namespace,
+				JobUID:         job.UID,
+				AppName:        identity.App,
+				ObjectName:     identity.ObjectName,
+				Direction:      identity.Direction,
+				Provider:       r.providerOf(&job).name(),
+				FailureTime:    jobFailureTime(job),
+				ProcessedTime:  metav1.Now(),
+				LockError:      lockError,
+				DetectedBy:     match.DetectedBy,
+				ExitCode:       match.ExitCode,
+				MessageType:    match.MessageType,
+				MoverType:      mover.Type,
+				Remediation:    mover.Remediation,
+				Classification: match.Class,
+				Pattern:        match.Pattern,
+				Severity:       match.Severity,
+			}
+			helpers.RecordLockErrorDetected(job.Namespace, identity.App, identity.ObjectName, string(match.DetectedBy))
+
+			// Keep the state of the failed pod before the job is removed
+			var snapshot *volsyncv1alpha1.PodSnapshot
+			if removesFailedJob(monitor, mover.Remediation) {
+				snapshot = r.snapshotFailedPod(ctx, monitor, job)
+			}
+
+			switch mover.Remediation {
+			case volsyncv1alpha1.RemediationActionUnlock:
+				// An unlock job that exists already was created before the
+				// status update recording it was lost, for example on a restart
+				unlockJob, err := r.findUnlockJob(ctx, monitor, job)
+				if err != nil {
+					logger.Error(err, "Failed to look up unlock job", "job", job.Name)
+					continue
+				}
+				if unlockJob != nil {
+					logger.Info("Recovered unlock job of unrecorded failure", "job", job.Name, "namespace", job.Namespace, "unlockJob", unlockJob.Name)
 				} else {
+					// Jobs that are not unlocked yet stay unprocessed and are retried later
+					if !r.canCreateUnlockJob(*monitor) {
+						logger.Info("Maximum concurrent unlocks reached, deferring unlock", "job", job.Name, "namespace", job.Namespace)
+						queueUnlock(monitor, job, identity, fmt.Sprintf("all %d concurrent unlocks in use", maxConcurrentUnlocks(monitor)))
+						continue
+					}
+					reason, err := r.verifyStaleLock(ctx, monitor, job, match)
+					if err != nil {
+						logger.Error(err, "Failed to verify lock", "job", job.Name)
+						continue
+					}
+					if reason != "" {
+						logger.Info("Lock may still be held, deferring unlock", "job", job.Name, "namespace", job.Namespace, "reason", reason)
+						queueUnlock(monitor, job, identity, reason)
+						continue
+					}
+
+					// Create unlock job
+					unlockJob, err = r.createUnlockJob(ctx, monitor, job, lockError, mover, job.UID)
+					if err != nil {
+						logger.Error(err, "Failed to create unlock job", "job", job.Name)
+						continue
+					}
+					monitor.Status.ActiveUnlocks = append(monitor.Status.ActiveUnlocks, volsyncv1alpha1.ActiveUnlock{
+						AppName:          identity.App,
+						Namespace:        unlockJob.Namespace,
+						ObjectName:       identity.ObjectName,
+						Direction:        identity.Direction,
+						JobName:          unlockJob.Name,
+						StartTime:        metav1.Now(),
+						AlertFingerprint: fmt.Sprintf("%s-%s", unlockJob.Namespace, unlockJob.Name),
+					})
+				}
+				processedJob.UnlockJobName = unlockJob.Name
+				monitor.Status.TotalUnlocksCreated++
+				monitor.Status.LastUnlockTime = &metav1.Time{Time: time.Now()}
+
+				// Remove failed job if configured to do so, right away or once
+				// the unlock job finished
+				if monitor.Spec.RemoveFailedJobs && monitor.Spec.FailedJobRemoval != nil {
+					processedJob.RemovalPending = true
+				} else if monitor.Spec.RemoveFailedJobs {
+					if err := r.removeFailedJob(ctx, job); err != nil {
+						logger.Error(err, "Failed to remove failed job", "job", job.Name)
+						// Continue anyway - we still want to track the unlock job
+					} else {
+						processedJob.Removed = true
+						monitor.Status.TotalFailedJobsRemoved++
+						logger.Info("Removed failed job", "job", job.Name, "namespace", job.Namespace)
+					}
+				}
+			case volsyncv1alpha1.RemediationActionRetry:
+				// Removing the failed job makes VolSync run the mover again
+				if err := r.removeFailedJob(ctx, job); err != nil {
+					logger.Error(err, "Failed to remove failed job for retry", "job", job.Name)
+					continue
+				}
+				processedJob.Removed = true
+				monitor.Status.TotalFailedJobsRemoved++
+				logger.Info("Removed failed job so VolSync retries it", "job", job.Name, "namespace", job.Namespace)
+			case volsyncv1alpha1.RemediationActionRecreateCache:
+				// Jobs whose cache is still in use stay unprocessed and are retried later
+				reason, err := r.recreateCache(ctx, monitor, job, &processedJob)
+				if err != nil {
+					logger.Error(err, "Failed to recreate cache volume", "job", job.Name)
+					continue
+				}
+				if reason != "" {
+					logger.Info("Deferring cache volume recreation", "job", job.Name, "namespace", job.Namespace, "reason", reason)
+					queueUnlock(monitor, job, identity, reason)
+					continue
+				}
+				if processedJob.Removed {
 					monitor.Status.TotalFailedJobsRemoved++
-					logger.Info("Removed failed job", "job", job.Name, "namespace", job.Namespace)
 				}
 			}
 
-			// Track the processed job
-			processedJob := volsyncv1alpha1.ProcessedJob{
-				JobName:       job.Name,
-				Namespace:     job.Namespace,
-				ProcessedTime: metav1.Now(),
-				UnlockJobName: unlockJob.Name,
-				Removed:       monitor.Spec.RemoveFailedJobs,
-				LockError:     lockError,
+			// Keep a durable history entry for the failure
+			record, err := r.createUnlockRecord(ctx, monitor, job, match, processedJob)
+			if err != nil {
+				logger.Error(err, "Failed to record unlock history", "job", job.Name)
 			}
+			if record != nil {
+				processedJob.RecordName = record.Name
+				if snapshot != nil {
+					if err := r.recordFailedPod(ctx, monitor, record, snapshot); err != nil {
+						logger.Error(err, "Failed to record failed pod", "job", job.Name)
+					}
+				}
+			}
+
 			monitor.Status.ProcessedJobs = append(monitor.Status.ProcessedJobs, processedJob)
 
 			// Update counters
 			monitor.Status.TotalLockErrorsDetected++
-			monitor.Status.TotalUnlocksCreated++
-			monitor.Status.LastUnlockTime = &metav1.Time{Time: time.Now()}
 		}
 	}
 
-	// Step 3: Update active unlocks status
-	if err := r.updateActiveUnlocks(ctx, monitor); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to update active unlocks: %w", err)
+	// Step 4: Detect hung mover jobs and mover pods that cannot start
+	requeueAfter := r.settings().RequeueInterval.Duration
+	nextStuck, err := r.reconcileStuckMovers(ctx, monitor, others)
+	if err != nil {
+		logger.Error(err, "Failed to detect stuck mover jobs")
+	}
+	if nextStuck > 0 && nextStuck < requeueAfter {
+		requeueAfter = nextStuck
 	}
 
-	// Step 4: Clean up old processed jobs (keep last 50)
+	// Step 5: Remove failed jobs whose removal waited for their unlock
+	nextRemoval, err := r.removePendingFailedJobs(ctx, monitor)
+	if err != nil {
+		logger.Error(err, "Failed to remove pending failed jobs")
+	}
+	if nextRemoval > 0 && nextRemoval < requeueAfter {
+		requeueAfter = nextRemoval
+	}
+
+	// Step 6: Escalate the failures whose unlock failed
+	if nextAttempt := r.reconcileEscalations(ctx, monitor); nextAttempt > 0 && nextAttempt < requeueAfter {
+		requeueAfter = nextAttempt
+	}
+
+	// Step 7: Clean up old processed jobs
 	r.cleanupProcessedJobs(monitor)
 
-	// Requeue after 30 seconds to continuously monitor
-	return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	// Step 8: Apply the retention settings to the unlock history
+	if err := r.pruneUnlockRecords(ctx, monitor); err != nil {
+		logger.Error(err, "Failed to prune unlock records")
+	}
+
+	// Step 9: Run the scheduled lock sweeps
+	if err := r.reconcileLockSweep(ctx, monitor); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to sweep repository locks: %w", err)
+	}
+
+	// Step 10: Check the health of the repositories
+	if err := r.reconcileHealthChecks(ctx, monitor); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to check repository health: %w", err)
+	}
+
+	// Requeue to continuously monitor
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *VolSyncMonitorReconciler) findFailedVolSyncJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor) ([]batchv1.Job, error) {
-	var failedJobs []batchv1.Job
+	return r.findVolSyncJobs(ctx, monitor, r.isJobFailed)
+}
+
+// findVolSyncJobs returns the jobs selected by the monitor that match filter
+func (r *VolSyncMonitorReconciler) findVolSyncJobs(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, filter func(*batchv1.Job) bool) ([]batchv1.Job, error) {
+	var jobs []batchv1.Job
 
 	// Determine namespaces to search
 	namespaces := []string{}
@@ -192,27 +453,35 @@ func (r *VolSyncMonitorReconciler) findFailedVolSyncJobs(ctx context.Context, mo
 
 		// Filter jobs based on selector
 		for _, job := range jobList.Items {
-			if r.matchesJobSelector(job, monitor.Spec.JobSelector) && r.isJobFailed(job) {
-				failedJobs = append(failedJobs, job)
+			if r.matchesJobSelector(job, monitor.Spec.JobSelector, monitorProviders(monitor)) && filter(&job) {
+				jobs = append(jobs, job)
 			}
 		}
 	}
 
-	return failedJobs, nil
+	return jobs, nil
 }
 
-func (r *VolSyncMonitorReconciler) matchesJobSelector(job batchv1.Job, selector *volsyncv1alpha1.JobSelector) bool {
-	if selector == nil {
-		// Default: match jobs with "volsync-" prefix
-		return strings.HasPrefix(job.Name, "volsync-")
+// matchesJobSelector reports whether a job is a mover job of one of the
+// providers that the selector selects
+func (r *VolSyncMonitorReconciler) matchesJobSelector(job batchv1.Job, selector *volsyncv1alpha1.JobSelector, providers []volsyncv1alpha1.BackupProvider) bool {
+	// Jobs that opt out themselves are never selected; opt-outs of their
+	// Namespace or ReplicationSource are resolved when the job is processed
+	if jobDisabled(job) {
+		return false
 	}
 
-	// Check name prefix
-	namePrefix := selector.NamePrefix
-	if namePrefix == "" {
-		namePrefix = "volsync-"
+	if selector == nil {
+		// Default: match the mover jobs of the providers
+		return r.jobProvider(&job, providers) != nil
 	}
-	if !strings.HasPrefix(job.Name, namePrefix) {
+
+	// Check name prefix, or that it is a mover job without one
+	if selector.NamePrefix != "" {
+		if !strings.HasPrefix(job.Name, selector.NamePrefix) {
+			return false
+		}
+	} else if r.jobProvider(&job, providers) == nil {
 		return false
 	}
 
@@ -228,46 +497,66 @@ func (r *VolSyncMonitorReconciler) matchesJobSelector(job batchv1.Job, selector
 	return true
 }
 
-func (r *VolSyncMonitorReconciler) isJobFailed(job batchv1.Job) bool {
-	for _, condition := range job.Status.Conditions {
-		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+// isJobAlreadyProcessed reports whether this specific failure of a job was handled.
+// VolSync reuses mover job names, so jobs are matched by UID and failure time rather
+// than by name; a recreated job that fails again is handled again.
+func (r *VolSyncMonitorReconciler) isJobAlreadyProcessed(monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) bool {
+	failureTime := jobFailureTime(job)
+	for _, processed := range monitor.Status.ProcessedJobs {
+		if processed.JobName != job.Name || processed.Namespace != job.Namespace {
+			continue
+		}
+
+		if processed.JobUID != "" {
+			if processed.JobUID != job.UID {
+				continue
+			}
+			if processed.FailureTime != nil && failureTime != nil && !processed.FailureTime.Equal(failureTime) {
+				continue
+			}
 			return true
 		}
-	}
-	return false
-}
 
-func (r *VolSyncMonitorReconciler) isJobAlreadyProcessed(monitor *volsyncv1alpha1.VolSyncMonitor, job batchv1.Job) bool {
-	for _, processed := range monitor.Status.ProcessedJobs {
-		if processed.JobName == job.Name && processed.Namespace == job.Namespace {
+		// Entries recorded before UIDs were tracked: it is the same job if it
+		// already existed when the entry was processed
+		if !job.CreationTimestamp.After(processed.ProcessedTime.Time) {
 			return true
 		}
 	}
 	return false
 }
 
-func (r *VolSyncMonitorReconciler) checkJobForLockErrors(ctx context.Context, job batchv1.Job, patterns []string) (bool, string, error) {
-	// Default lock error patterns if none specified
-	defaultPatterns := []string{
-		"repository is already locked",
-		"unable to create lock",
-		"repository.*locked",
-		"lock.*already exists",
-	}
+// lockErrorMatch describes a lock error found in a failed job
+type lockErrorMatch struct {
+	// Message is the log line or restic message describing the error
+	Message string
+	// DetectedBy records which detector recognised the error
+	DetectedBy volsyncv1alpha1.LockErrorSource
+	// ExitCode is the restic exit code, when known
+	ExitCode *int32
+	// MessageType is the restic JSON message type, when parsed from JSON output
+	MessageType string
+	// LogsExcerpt is the tail of the logs of the pod the error was found in
+	LogsExcerpt string
+	// LockAge is the age of the lock reported by restic when the job failed, when known
+	LockAge *time.Duration
+	// Pattern is the name of the FailurePatternSet pattern that matched, when any
+	Pattern string
+	// Class and Severity classify the error
+	Class    volsyncv1alpha1.FailureClass
+	Severity volsyncv1alpha1.FailureSeverity
+}
 
-	if len(patterns) == 0 {
-		patterns = defaultPatterns
-	}
+// checkJobLogsForLockErrors reports whether a job failed on a lock error,
+// using the patterns the monitor configures for the job's mover
+func (r *VolSyncMonitorReconciler) checkJobLogsForLockErrors(ctx context.Context, job *batchv1.Job, monitor volsyncv1alpha1.VolSyncMonitor) (bool, error) {
+	mover := r.resolveMoverSettings(&monitor, moverTypeFromJob(*job))
+	match, err := r.checkJobForLockErrors(ctx, *job, mover)
+	return match != nil, err
+}
 
-	// Compile regex patterns
-	var regexes []*regexp.Regexp
-	for _, pattern := range patterns {
-		regex, err := regexp.Compile("(?i)" + pattern) // Case insensitive
-		if err != nil {
-			return false, "", fmt.Errorf("invalid regex pattern %s: %w", pattern, err)
-		}
-		regexes = append(regexes, regex)
-	}
+func (r *VolSyncMonitorReconciler) checkJobForLockErrors(ctx context.Context, job batchv1.Job, mover moverSettings) (*lockErrorMatch, error) {
+	patterns := mover.failurePatterns(ctx)
 
 	// Get pods for this job
 	var podList corev1.PodList
@@ -277,75 +566,228 @@ func (r *VolSyncMonitorReconciler) checkJobForLockErrors(ctx context.Context, jo
 	}
 
 	if err := r.List(ctx, &podList, listOpts...); err != nil {
-		return false, "", fmt.Errorf("failed to list pods for job %s: %w", job.Name, err)
+		return nil, fmt.Errorf("failed to list pods for job %s: %w", job.Name, err)
 	}
 
-	// Check logs of each pod
+	// Check logs and termination state of each pod
 	for _, pod := range podList.Items {
-		logs, err := helpers.GetPodLogs(ctx, r.Client, pod.Namespace, pod.Name, "")
+		logs, err := r.podLogs(ctx, pod)
 		if err != nil {
-			continue // Skip pods we can't get logs from
+			logs = "" // Fall back to the container status for pods we can't get logs from
 		}
 
-		// Check each line against patterns
-		lines := strings.Split(logs, "\n")
+		// Restic JSON output and exit codes only apply to the restic mover
+		structured := mover.Type == volsyncv1alpha1.MoverTypeRestic
+		if match := r.findLockErrorInPod(pod, logs, patterns, structured); match != nil {
+			if match.Class == "" {
+				match.Class = mover.Class
+			}
+			match.LogsExcerpt = helpers.TailLines(logs, logsExcerptLines, logsExcerptBytes)
+			if age, ok := restic.LockAge(logs + "\n" + match.Message); ok {
+				match.LockAge = &age
+			}
+			return match, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findLockErrorInPod looks for a lock error in a pod, preferring restic JSON
+// output and exit codes over the free text regex patterns when structured is set
+func (r *VolSyncMonitorReconciler) findLockErrorInPod(pod corev1.Pod, logs string, patterns []failurePattern, structured bool) *lockErrorMatch {
+	// Structured restic output is the most reliable source
+	if structured {
+		if msg := restic.FindLockError(logs); msg != nil {
+			match := &lockErrorMatch{
+				Message:     strings.TrimSpace(msg.Text()),
+				DetectedBy:  volsyncv1alpha1.LockErrorSourceJSON,
+				MessageType: msg.MessageType,
+			}
+			if msg.MessageType == restic.MessageTypeExitError {
+				match.ExitCode = helpers.Int32Ptr(msg.Code)
+			}
+			return match
+		}
+	}
+
+	// Then the exit code of the terminated containers
+	var terminationMessages []string
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if structured && restic.IsLockExitCode(terminated.ExitCode) {
+			message := strings.TrimSpace(terminated.Message)
+			if message == "" {
+				message = fmt.Sprintf("container %s exited with code %d", status.Name, terminated.ExitCode)
+			}
+			return &lockErrorMatch{
+				Message:    message,
+				DetectedBy: volsyncv1alpha1.LockErrorSourceExitCode,
+				ExitCode:   helpers.Int32Ptr(terminated.ExitCode),
+			}
+		}
+		if terminated.Message != "" {
+			terminationMessages = append(terminationMessages, terminated.Message)
+		}
+	}
+
+	// Fall back to the regex patterns on every log line and termination message
+	lines := strings.Split(logs, "\n")
+	for _, message := range terminationMessages {
+		lines = append(lines, strings.Split(message, "\n")...)
+	}
+	for _, pattern := range patterns {
 		for _, line := range lines {
-			for _, regex := range regexes {
-				if regex.MatchString(line) {
-					return true, strings.TrimSpace(line), nil
+			if pattern.Regex.MatchString(line) {
+				return &lockErrorMatch{
+					Message:    strings.TrimSpace(line),
+					DetectedBy: volsyncv1alpha1.LockErrorSourcePattern,
+					Pattern:    pattern.Name,
+					Class:      pattern.Class,
+					Severity:   pattern.Severity,
 				}
 			}
 		}
 	}
 
-	return false, "", nil
+	return nil
 }
 
-func (r *VolSyncMonitorReconciler) createUnlockJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, failedJob batchv1.Job, lockError string) (*batchv1.Job, error) {
+// createUnlockJob creates the unlock job for a failed job. The job is named
+// after key, so creating it again for the same key returns the existing job.
+func (r *VolSyncMonitorReconciler) createUnlockJob(ctx context.Context, monitor *volsyncv1alpha1.VolSyncMonitor, failedJob batchv1.Job, lockError string, mover moverSettings, key types.UID) (*batchv1.Job, error) {
 	logger := log.FromContext(ctx)
 
-	// Generate unique name for unlock job
-	unlockJobName := fmt.Sprintf("volsync-unlock-%s-%d", failedJob.Name, time.Now().Unix())
+	// Discover the repository environment and volumes of the failed job
+	target, err := r.discoverUnlockTarget(ctx, failedJob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover repository of job %s: %w", failedJob.Name, err)
+	}
+
+	identity := r.resolveJobIdentity(ctx, &failedJob)
+	unlockJob, err := r.newUnlockJob(monitor, failedJob, identity, lockError, mover, target, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep VolSync from starting the mover while the lock is removed
+	if err := r.holdReplication(ctx, monitor, identity, unlockJob.Namespace, unlockJob.Name); err != nil {
+		logger.Error(err, "Failed to hold VolSync object during unlock", "job", unlockJob.Name, "namespace", unlockJob.Namespace)
+	}
+
+	// Create the job. It already exists when an earlier reconciliation created
+	// it but did not get to record it.
+	if err := r.Create(ctx, unlockJob); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to create unlock job: %w", err)
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(unlockJob), unlockJob); err != nil {
+			return nil, fmt.Errorf("failed to get existing unlock job: %w", err)
+		}
+		logger.Info("Unlock job already exists", "job", unlockJob.Name, "namespace", failedJob.Namespace, "failedJob", failedJob.Name)
+		return unlockJob, nil
+	}
+
+	logger.Info("Created unlock job", "job", unlockJob.Name, "namespace", failedJob.Namespace, "failedJob", failedJob.Name)
+	helpers.RecordUnlockJobCreated(failedJob.Namespace, identity.App, identity.ObjectName)
+	return unlockJob, nil
+}
+
+// unlockJobName returns the name of the unlock job of a failed job. Names
+// derived from a key are deterministic, so that an unlock job that was created
+// but not recorded is found again instead of being created twice. Without a
+// key the name is unique.
+func unlockJobName(failedJobName string, key types.UID) string {
+	return boundedName("volsync-unlock-", failedJobName, nameSuffix(string(key)))
+}
+
+// nameSuffix returns a short hash of key, or the current time when key is empty
+func nameSuffix(key string) string {
+	if key == "" {
+		return fmt.Sprintf("%d", time.Now().Unix())
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:10]
+}
+
+// boundedName joins prefix, name and suffix, shortening name so that the
+// result fits the 63 characters of a label value. Job names are used as pod
+// labels.
+func boundedName(prefix, name, suffix string) string {
+	if maxLength := 63 - len(prefix) - len(suffix) - 1; len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-.")
+	}
+	return prefix + name + "-" + suffix
+}
+
+// newUnlockJob builds the unlock job for a failed job without creating it
+func (r *VolSyncMonitorReconciler) newUnlockJob(monitor *volsyncv1alpha1.VolSyncMonitor, failedJob batchv1.Job, identity jobIdentity, lockError string, mover moverSettings, target *unlockTarget, key types.UID) (*batchv1.Job, error) {
+	unlockJobName := unlockJobName(failedJob.Name, key)
+
+	// Run the restic version of the failed mover unless an image is configured
+	image, err := resolveUnlockImage(mover.Template, target)
+	if err != nil {
+		return nil, err
+	}
+	template := mover.Template
+	template.Image = image.Image
 
 	// Build job spec from template
-	jobSpec := r.buildUnlockJobSpec(monitor, failedJob, unlockJobName, lockError)
+	jobSpec := r.buildUnlockJobSpec(monitor, template, failedJob, unlockJobName, lockError, target)
+	jobSpec.Template.Spec.ImagePullSecrets = image.PullSecrets
 
 	unlockJob := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      unlockJobName,
 			Namespace: failedJob.Namespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/name":       "homelab-assistant",
-				"app.kubernetes.io/component":  "volsync-unlock",
-				"app.kubernetes.io/created-by": "volsync-monitor",
-				"homelab.rafaribe.com/monitor": monitor.Name,
+				"app.kubernetes.io/name":          "homelab-assistant",
+				"app.kubernetes.io/component":     "volsync-unlock",
+				"app.kubernetes.io/created-by":    "volsync-monitor",
+				MonitorLabel:                      monitor.Name,
 				"homelab.rafaribe.com/failed-job": failedJob.Name,
+				"homelab.rafaribe.com/mover":      string(mover.Type),
 			},
 			Annotations: map[string]string{
 				"homelab.rafaribe.com/lock-error": lockError,
 				"homelab.rafaribe.com/failed-job": fmt.Sprintf("%s/%s", failedJob.Namespace, failedJob.Name),
+				appAnnotation:                     identity.App,
+				objectAnnotation:                  identity.ObjectName,
+				directionAnnotation:               string(identity.Direction),
+				unlockImageAnnotation:             image.Image,
+				unlockImageSourceAnnotation:       image.Source,
 			},
 		},
 		Spec: *jobSpec,
 	}
-
-	// Set owner reference to the monitor
-	if err := controllerutil.SetControllerReference(monitor, unlockJob, r.Scheme); err != nil {
-		return nil, fmt.Errorf("failed to set controller reference: %w", err)
+	if failedJob.UID != "" {
+		unlockJob.Labels[failedJobUIDLabel] = string(failedJob.UID)
 	}
 
-	// Create the job
-	if err := r.Create(ctx, unlockJob); err != nil {
-		return nil, fmt.Errorf("failed to create unlock job: %w", err)
+	if err := r.setMonitorOwner(monitor, unlockJob); err != nil {
+		return nil, err
 	}
 
-	logger.Info("Created unlock job", "job", unlockJobName, "namespace", failedJob.Namespace, "failedJob", failedJob.Name)
 	return unlockJob, nil
 }
 
-func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.VolSyncMonitor, failedJob batchv1.Job, unlockJobName, lockError string) *batchv1.JobSpec {
-	template := monitor.Spec.UnlockJobTemplate
-	
+// setMonitorOwner ties a job to the monitor that created it. Owner references
+// cannot cross namespaces, so jobs in other namespaces are tied to the monitor
+// by their labels only.
+func (r *VolSyncMonitorReconciler) setMonitorOwner(monitor *volsyncv1alpha1.VolSyncMonitor, job *batchv1.Job) error {
+	if job.Namespace == monitor.Namespace {
+		if err := controllerutil.SetControllerReference(monitor, job, r.Scheme); err != nil {
+			return fmt.Errorf("failed to set controller reference: %w", err)
+		}
+		return nil
+	}
+	job.Labels[recordMonitorNamespaceLabel] = monitor.Namespace
+	return nil
+}
+
+func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.VolSyncMonitor, template volsyncv1alpha1.UnlockJobTemplate, failedJob batchv1.Job, unlockJobName, lockError string, target *unlockTarget) *batchv1.JobSpec {
 	// Default values
 	if len(template.Command) == 0 {
 		template.Command = []string{"/bin/sh"}
@@ -372,20 +814,18 @@ func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.V
 		},
 	}
 
+	// Run against the same repository as the failed job
+	if target != nil {
+		container.Env = append(container.Env, target.Env...)
+		container.EnvFrom = target.EnvFrom
+		container.VolumeMounts = target.VolumeMounts
+	}
+
 	// Add resource requirements if specified
 	if template.Resources != nil {
-		container.Resources = corev1.ResourceRequirements{}
-		if template.Resources.Limits != nil {
-			container.Resources.Limits = make(corev1.ResourceList)
-			for k, v := range template.Resources.Limits {
-				container.Resources.Limits[corev1.ResourceName(k)] = resource.MustParse(v)
-			}
-		}
-		if template.Resources.Requests != nil {
-			container.Resources.Requests = make(corev1.ResourceList)
-			for k, v := range template.Resources.Requests {
-				container.Resources.Requests[corev1.ResourceName(k)] = resource.MustParse(v)
-			}
+		container.Resources = corev1.ResourceRequirements{
+			Limits:   r.convertResources(r.getResourceLimits(template.Resources)),
+			Requests: r.convertResources(r.getResourceRequests(template.Resources)),
 		}
 	}
 
@@ -394,6 +834,9 @@ func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.V
 		RestartPolicy: corev1.RestartPolicyNever,
 		Containers:    []corev1.Container{container},
 	}
+	if target != nil {
+		podSpec.Volumes = target.Volumes
+	}
 
 	// Add service account if specified
 	if template.ServiceAccount != "" {
@@ -414,14 +857,14 @@ func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.V
 		Template: corev1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels: map[string]string{
-					"app.kubernetes.io/name":      "homelab-assistant",
-					"app.kubernetes.io/component": "volsync-unlock",
+					"app.kubernetes.io/name":          "homelab-assistant",
+					"app.kubernetes.io/component":     "volsync-unlock",
 					"homelab.rafaribe.com/unlock-job": unlockJobName,
 				},
 			},
 			Spec: podSpec,
 		},
-		BackoffLimit: helpers.Int32Ptr(3),
+		BackoffLimit: helpers.Int32Ptr(*r.settings().UnlockJobBackoffLimit),
 	}
 
 	// Add TTL if specified
@@ -432,6 +875,39 @@ func (r *VolSyncMonitorReconciler) buildUnlockJobSpec(monitor *volsyncv1alpha1.V
 	return jobSpec
 }
 
+// getResourceLimits returns the resource limits of an unlock job template
+func (r *VolSyncMonitorReconciler) getResourceLimits(resources *volsyncv1alpha1.ResourceRequirements) map[string]string {
+	if resources == nil {
+		return nil
+	}
+	return resources.Limits
+}
+
+// getResourceRequests returns the resource requests of an unlock job template
+func (r *VolSyncMonitorReconciler) getResourceRequests(resources *volsyncv1alpha1.ResourceRequirements) map[string]string {
+	if resources == nil {
+		return nil
+	}
+	return resources.Requests
+}
+
+// convertResources parses resource quantities. Invalid quantities are left out
+// rather than failing the unlock job.
+func (r *VolSyncMonitorReconciler) convertResources(resources map[string]string) corev1.ResourceList {
+	if len(resources) == 0 {
+		return nil
+	}
+	resourceList := make(corev1.ResourceList, len(resources))
+	for name, value := range resources {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			continue
+		}
+		resourceList[corev1.ResourceName(name)] = quantity
+	}
+	return resourceList
+}
+
 func (r *VolSyncMonitorReconciler) removeFailedJob(ctx context.Context, job batchv1.Job) error {
 	// Delete the job with propagation policy to clean up pods
 	deletePolicy := metav1.DeletePropagationForeground
@@ -448,7 +924,7 @@ func (r *VolSyncMonitorReconciler) updateActiveUnlocks(ctx context.Context, moni
 	// Find all unlock jobs created by this monitor
 	var jobList batchv1.JobList
 	listOpts := []client.ListOption{
-		client.MatchingLabels{"homelab.rafaribe.com/monitor": monitor.Name},
+		client.MatchingLabels{MonitorLabel: monitor.Name},
 	}
 
 	if err := r.List(ctx, &jobList, listOpts...); err != nil {
@@ -463,10 +939,12 @@ func (r *VolSyncMonitorReconciler) updateActiveUnlocks(ctx context.Context, moni
 				failedJobName = job.Annotations["homelab.rafaribe.com/failed-job"]
 			}
 
+			identity := r.unlockJobIdentity(job, failedJobName)
 			activeUnlock := volsyncv1alpha1.ActiveUnlock{
-				AppName:          r.extractAppName(failedJobName),
+				AppName:          identity.App,
 				Namespace:        job.Namespace,
-				ObjectName:       failedJobName,
+				ObjectName:       identity.ObjectName,
+				Direction:        identity.Direction,
 				JobName:          job.Name,
 				StartTime:        job.CreationTimestamp,
 				AlertFingerprint: fmt.Sprintf("%s-%s", job.Namespace, job.Name),
@@ -474,8 +952,17 @@ func (r *VolSyncMonitorReconciler) updateActiveUnlocks(ctx context.Context, moni
 			activeUnlocks = append(activeUnlocks, activeUnlock)
 		} else if r.isJobSucceeded(job) {
 			monitor.Status.TotalUnlocksSucceeded++
-		} else if r.isJobFailed(job) {
+			if err := r.finishUnlockRecords(ctx, job, volsyncv1alpha1.UnlockOutcomeSucceeded); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to update unlock records", "job", job.Name)
+			}
+		} else if r.isJobFailed(&job) {
 			monitor.Status.TotalUnlocksFailed++
+			if err := r.finishUnlockRecords(ctx, job, volsyncv1alpha1.UnlockOutcomeFailed); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to update unlock records", "job", job.Name)
+			}
+			if err := r.startEscalation(ctx, monitor, job); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to escalate failed unlock", "job", job.Name)
+			}
 		}
 	}
 
@@ -496,33 +983,37 @@ func (r *VolSyncMonitorReconciler) isJobSucceeded(job batchv1.Job) bool {
 	return false
 }
 
-func (r *VolSyncMonitorReconciler) extractAppName(jobName string) string {
-	// Extract app name from job name (e.g., "volsync-prowlarr-backup" -> "prowlarr")
-	parts := strings.Split(jobName, "-")
-	if len(parts) >= 2 && parts[0] == "volsync" {
-		return parts[1]
-	}
-	return jobName
-}
-
 func (r *VolSyncMonitorReconciler) cleanupProcessedJobs(monitor *volsyncv1alpha1.VolSyncMonitor) {
-	// Keep only the last 50 processed jobs
-	if len(monitor.Status.ProcessedJobs) > 50 {
-		monitor.Status.ProcessedJobs = monitor.Status.ProcessedJobs[len(monitor.Status.ProcessedJobs)-50:]
+	// Keep only the most recent processed jobs
+	limit := r.settings().ProcessedJobsHistoryLimit
+	if len(monitor.Status.ProcessedJobs) > limit {
+		monitor.Status.ProcessedJobs = monitor.Status.ProcessedJobs[len(monitor.Status.ProcessedJobs)-limit:]
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *VolSyncMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&volsyncv1alpha1.VolSyncMonitor{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.settings().MaxConcurrentReconciles}).
 		Watches(
 			&batchv1.Job{},
 			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
 				return r.findVolSyncMonitorsForJob(ctx, obj)
 			}),
 		).
-		Complete(r)
+		Watches(
+			&volsyncv1alpha1.FailurePatternSet{},
+			handler.EnqueueRequestsFromMapFunc(r.findVolSyncMonitorsForPatternSet),
+		)
+
+	// Failed k8up objects trigger the monitors handling k8up jobs
+	for _, gvk := range k8upWatchKinds(mgr) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		builder = builder.Watches(obj, handler.EnqueueRequestsFromMapFunc(r.findVolSyncMonitorsForK8upObject))
+	}
+	return builder.Complete(r)
 }
 
 // findVolSyncMonitorsForJob finds VolSyncMonitors that should be triggered by job events
@@ -532,8 +1023,8 @@ func (r *VolSyncMonitorReconciler) findVolSyncMonitorsForJob(ctx context.Context
 		return nil
 	}
 
-	// Only trigger for failed VolSync jobs
-	if !strings.HasPrefix(job.Name, "volsync-") || !r.isJobFailed(*job) {
+	// Only trigger for failed mover jobs
+	if r.jobProvider(job, nil) == nil || !r.isJobFailed(job) {
 		return nil
 	}
 
@@ -565,5 +1056,5 @@ func (r *VolSyncMonitorReconciler) shouldMonitorHandleJob(monitor volsyncv1alpha
 	}
 
 	// Check if job matches the monitor's selector
-	return r.matchesJobSelector(job, monitor.Spec.JobSelector)
+	return r.matchesJobSelector(job, monitor.Spec.JobSelector, monitorProviders(&monitor))
 }
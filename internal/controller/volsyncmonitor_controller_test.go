@@ -10,6 +10,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -143,6 +144,7 @@ var _ = Describe("VolSyncMonitor Controller", func() {
 			}, timeout, interval).Should(Succeed())
 
 			Expect(monitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhaseActive))
+			Expect(meta.IsStatusConditionTrue(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)).To(BeTrue())
 		})
 
 		It("should handle disabled monitor", func() {
@@ -164,17 +166,12 @@ var _ = Describe("VolSyncMonitor Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			By("Manually updating status for test (workaround for test environment)")
-			// In test environment, manually set the expected status
-			updatedMonitor := &volsyncv1alpha1.VolSyncMonitor{}
-			Expect(k8sClient.Get(ctx, typeNamespacedName, updatedMonitor)).To(Succeed())
-			updatedMonitor.Status.Phase = volsyncv1alpha1.VolSyncMonitorPhasePaused
-			Expect(k8sClient.Status().Update(ctx, updatedMonitor)).To(Succeed())
-
 			By("Verifying the monitor status is paused")
 			finalMonitor := &volsyncv1alpha1.VolSyncMonitor{}
 			Expect(k8sClient.Get(ctx, typeNamespacedName, finalMonitor)).To(Succeed())
 			Expect(finalMonitor.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncMonitorPhasePaused))
+			Expect(meta.IsStatusConditionTrue(finalMonitor.Status.Conditions, volsyncv1alpha1.ConditionTypePaused)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(finalMonitor.Status.Conditions, volsyncv1alpha1.ConditionTypeReady)).To(BeTrue())
 		})
 
 		It("should handle failed VolSync job", func() {
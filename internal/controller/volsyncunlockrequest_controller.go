@@ -0,0 +1,488 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+	"github.com/rafaribe/homelab-assistant/internal/config"
+	"github.com/rafaribe/homelab-assistant/internal/helpers"
+	"github.com/rafaribe/homelab-assistant/internal/restic"
+)
+
+const (
+	// Labels linking unlock jobs to the request they were created for
+	unlockRequestLabel          = "homelab.rafaribe.com/unlock-request"
+	unlockRequestNamespaceLabel = "homelab.rafaribe.com/unlock-request-namespace"
+
+	// lockListLabel is set on the jobs listing the locks of a repository
+	// before an unlock request removes them, to the name of the monitor
+	lockListLabel = "homelab.rafaribe.com/lock-list"
+
+	// unlockRequestRequeue is how often waiting and running requests are checked
+	unlockRequestRequeue = 30 * time.Second
+)
+
+// VolSyncUnlockRequestReconciler reconciles a VolSyncUnlockRequest object
+type VolSyncUnlockRequestReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Config holds the controller tunables; the defaults are used when nil
+	Config *config.Store
+
+	// Logs reads the logs of lock list pods; they are read from the API
+	// server when nil
+	Logs helpers.LogSource
+}
+
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=volsyncunlockrequests,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=volsyncunlockrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=homelab.rafaribe.com,resources=volsyncunlockrequests/finalizers,verbs=update
+
+func (r *VolSyncUnlockRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var request volsyncv1alpha1.VolSyncUnlockRequest
+	if err := r.Get(ctx, req.NamespacedName, &request); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get VolSyncUnlockRequest")
+		return ctrl.Result{}, err
+	}
+
+	// Requests run once
+	if request.IsFinished() {
+		return ctrl.Result{}, nil
+	}
+
+	original := request.DeepCopy()
+	result, err := r.reconcileRequest(ctx, &request)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile VolSyncUnlockRequest")
+	}
+
+	request.Status.ObservedGeneration = request.Generation
+	if err := r.Status().Patch(ctx, &request, client.MergeFrom(original)); err != nil {
+		logger.Error(err, "Failed to update VolSyncUnlockRequest status")
+		return ctrl.Result{}, err
+	}
+
+	return result, err
+}
+
+// pipeline returns the monitor reconciler whose discovery, verification and
+// unlock job builder are shared with requests
+func (r *VolSyncUnlockRequestReconciler) pipeline() *VolSyncMonitorReconciler {
+	return &VolSyncMonitorReconciler{Client: r.Client, Scheme: r.Scheme, Config: r.Config, Logs: r.Logs}
+}
+
+func (r *VolSyncUnlockRequestReconciler) reconcileRequest(ctx context.Context, request *volsyncv1alpha1.VolSyncUnlockRequest) (ctrl.Result, error) {
+	if request.Status.UnlockJobName != "" {
+		return r.trackUnlockJob(ctx, request)
+	}
+
+	pipeline := r.pipeline()
+	namespace := request.TargetNamespace()
+
+	// The unlock job exists already when the status update recording it was
+	// lost, for example because the manager restarted
+	var existing batchv1.JobList
+	if err := r.List(ctx, &existing, client.InNamespace(namespace),
+		client.MatchingLabels{unlockRequestLabel: request.Name, unlockRequestNamespaceLabel: request.Namespace}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list unlock jobs: %w", err)
+	}
+	for _, unlockJob := range existing.Items {
+		if _, isLockList := unlockJob.Labels[lockListLabel]; isLockList {
+			continue
+		}
+		request.Status.UnlockJobName = unlockJob.Name
+		request.Status.StartTime = &unlockJob.CreationTimestamp
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseRunning, fmt.Sprintf("Unlock job %s created", unlockJob.Name))
+		return r.trackUnlockJob(ctx, request)
+	}
+
+	// Step 1: Resolve the monitor providing the unlock job template and limits
+	monitor, message, err := r.resolveMonitor(ctx, request)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if monitor == nil {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, message)
+		return ctrl.Result{}, nil
+	}
+	request.Status.MonitorRef = &volsyncv1alpha1.MonitorReference{Name: monitor.Name, Namespace: monitor.Namespace}
+	if !monitor.Spec.Enabled {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting,
+			fmt.Sprintf("VolSyncMonitor %s/%s is paused", monitor.Namespace, monitor.Name))
+		return ctrl.Result{RequeueAfter: unlockRequestRequeue}, nil
+	}
+
+	// Step 2: Discover the repository
+	var target *unlockTarget
+	var failedJob batchv1.Job
+	if request.Spec.ReplicationSource != "" {
+		target, err = pipeline.discoverReplicationSourceTarget(ctx, namespace, request.Spec.ReplicationSource)
+		failedJob = replicationSourceJob(namespace, request.Spec.ReplicationSource)
+	} else {
+		target, err = pipeline.discoverSecretTarget(ctx, namespace, request.Spec.SecretName)
+		failedJob = batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: request.Spec.SecretName, Namespace: namespace}}
+	}
+	if err != nil {
+		if errors.IsNotFound(err) {
+			setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, err.Error())
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	moverType := target.MoverType
+	if moverType == "" {
+		moverType = volsyncv1alpha1.MoverTypeRestic
+	}
+	request.Status.MoverType = moverType
+	mover := pipeline.resolveMoverSettings(monitor, moverType)
+	if mover.Remediation != volsyncv1alpha1.RemediationActionUnlock {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed,
+			fmt.Sprintf("%s mover does not support unlocking", moverType))
+		return ctrl.Result{}, nil
+	}
+
+	// Step 3: Never remove a lock held by a running mover
+	if !request.Spec.Force {
+		running, err := r.runningRepositoryJob(ctx, pipeline, request)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if running != "" {
+			setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting,
+				fmt.Sprintf("Job %s is using the repository", running))
+			return ctrl.Result{RequeueAfter: unlockRequestRequeue}, nil
+		}
+	}
+
+	// Step 4: Respect the concurrency limit of the monitor
+	active, err := pipeline.countActiveUnlockJobs(ctx, monitor)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if limit := maxConcurrentUnlocks(monitor); active >= limit {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting,
+			fmt.Sprintf("%d of %d concurrent unlocks in use", active, limit))
+		return ctrl.Result{RequeueAfter: unlockRequestRequeue}, nil
+	}
+
+	// Step 5: Leave locks younger than spec.minLockAge alone
+	if monitor.Spec.MinLockAge != nil && !request.Spec.Force && moverType == volsyncv1alpha1.MoverTypeRestic {
+		result, stale, err := r.verifyLockAge(ctx, pipeline, request, monitor, target)
+		if err != nil || !stale {
+			return result, err
+		}
+	}
+
+	// Step 6: Create the unlock job
+	reason := request.Spec.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("unlock requested by VolSyncUnlockRequest %s/%s", request.Namespace, request.Name)
+	}
+	identity := pipeline.resolveJobIdentity(ctx, &failedJob)
+	unlockJob, err := pipeline.newUnlockJob(monitor, failedJob, identity, reason, mover, target, request.UID)
+	if err == errNoUnlockImage {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	unlockJob.Labels[unlockRequestLabel] = request.Name
+	unlockJob.Labels[unlockRequestNamespaceLabel] = request.Namespace
+	if unlockJob.Namespace == request.Namespace {
+		if err := controllerutil.SetOwnerReference(request, unlockJob, r.Scheme); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set owner reference: %w", err)
+		}
+	}
+	if err := pipeline.holdReplication(ctx, monitor, identity, unlockJob.Namespace, unlockJob.Name); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to hold VolSync object during unlock", "job", unlockJob.Name, "namespace", unlockJob.Namespace)
+	}
+	if err := r.Create(ctx, unlockJob); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to create unlock job: %w", err)
+	}
+	log.FromContext(ctx).Info("Created unlock job for request", "job", unlockJob.Name, "namespace", unlockJob.Namespace)
+
+	now := metav1.Now()
+	request.Status.UnlockJobName = unlockJob.Name
+	request.Status.StartTime = &now
+	setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseRunning, fmt.Sprintf("Unlock job %s created", unlockJob.Name))
+
+	// Keep the request in the unlock history like any other unlock
+	processed := volsyncv1alpha1.ProcessedJob{
+		JobName:        failedJob.Name,
+		Namespace:      namespace,
+		AppName:        identity.App,
+		ObjectName:     identity.ObjectName,
+		Direction:      identity.Direction,
+		ProcessedTime:  now,
+		LockError:      reason,
+		DetectedBy:     volsyncv1alpha1.LockErrorSourceManual,
+		MoverType:      moverType,
+		Remediation:    volsyncv1alpha1.RemediationActionUnlock,
+		Classification: mover.Class,
+		UnlockJobName:  unlockJob.Name,
+	}
+	match := &lockErrorMatch{Message: reason, DetectedBy: volsyncv1alpha1.LockErrorSourceManual}
+	record, err := pipeline.createUnlockRecord(ctx, monitor, failedJob, match, processed)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record unlock history", "request", request.Name)
+	}
+	if record != nil {
+		request.Status.RecordName = record.Name
+	}
+
+	return ctrl.Result{RequeueAfter: unlockRequestRequeue}, nil
+}
+
+// runningRepositoryJob returns the name of a running job that uses the
+// repository of a request, if any. Repositories named by their secret are
+// matched through the ReplicationSources and ReplicationDestinations using it.
+func (r *VolSyncUnlockRequestReconciler) runningRepositoryJob(ctx context.Context, pipeline *VolSyncMonitorReconciler, request *volsyncv1alpha1.VolSyncUnlockRequest) (string, error) {
+	namespace := request.TargetNamespace()
+	objects := []jobIdentity{{ObjectName: request.Spec.ReplicationSource, Direction: volsyncv1alpha1.VolSyncDirectionSource}}
+	if request.Spec.ReplicationSource == "" {
+		var err error
+		if objects, err = pipeline.replicationObjectsUsingSecret(ctx, namespace, request.Spec.SecretName); err != nil {
+			return "", err
+		}
+	}
+
+	for _, object := range objects {
+		running, err := pipeline.runningVolSyncJobs(ctx, namespace, object.ObjectName, object.Direction, "")
+		if err != nil {
+			return "", err
+		}
+		for _, job := range running {
+			// The lock listing of the request itself
+			if job.Name != request.Status.LockListJobName {
+				return job.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// verifyLockAge lists the locks of the repository with a lock list job and
+// reports whether they are all older than spec.minLockAge of the monitor. The
+// request waits while the job runs and while younger locks exist, and
+// succeeds without an unlock when the repository has no locks.
+func (r *VolSyncUnlockRequestReconciler) verifyLockAge(ctx context.Context, pipeline *VolSyncMonitorReconciler, request *volsyncv1alpha1.VolSyncUnlockRequest, monitor *volsyncv1alpha1.VolSyncMonitor, target *unlockTarget) (ctrl.Result, bool, error) {
+	namespace := request.TargetNamespace()
+	if request.Status.LockListJobName == "" {
+		listJob, err := pipeline.newRepositoryJob(monitor, lockListJobKind, namespace, request.Spec.ReplicationSource, target, restic.ListLocksScript)
+		if err == errNoUnlockImage {
+			setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, err.Error())
+			return ctrl.Result{}, false, nil
+		}
+		if err != nil {
+			return ctrl.Result{}, false, err
+		}
+		listJob.Labels[unlockRequestLabel] = request.Name
+		listJob.Labels[unlockRequestNamespaceLabel] = request.Namespace
+		if err := r.Create(ctx, listJob); err != nil {
+			return ctrl.Result{}, false, fmt.Errorf("failed to create lock list job: %w", err)
+		}
+		request.Status.LockListJobName = listJob.Name
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting,
+			fmt.Sprintf("Listing the locks of the repository with job %s", listJob.Name))
+		return ctrl.Result{RequeueAfter: unlockRequestRequeue}, false, nil
+	}
+
+	var listJob batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: request.Status.LockListJobName}, &listJob); err != nil {
+		if errors.IsNotFound(err) {
+			// Removed before it finished; the locks are listed again
+			request.Status.LockListJobName = ""
+			return ctrl.Result{Requeue: true}, false, nil
+		}
+		return ctrl.Result{}, false, err
+	}
+	succeeded := pipeline.isJobSucceeded(listJob)
+	if !succeeded && !pipeline.isJobFailed(&listJob) {
+		return ctrl.Result{RequeueAfter: unlockRequestRequeue}, false, nil
+	}
+
+	var output string
+	var err error
+	if succeeded {
+		output, err = pipeline.latestJobPodLogs(ctx, listJob)
+	}
+	if deleteErr := pipeline.deleteRepositoryJob(ctx, listJob); deleteErr != nil {
+		log.FromContext(ctx).Error(deleteErr, "Failed to delete lock list job", "job", listJob.Name, "namespace", listJob.Namespace)
+	}
+	request.Status.LockListJobName = ""
+	if !succeeded {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, fmt.Sprintf("Lock list job %s failed", listJob.Name))
+		return ctrl.Result{}, false, nil
+	}
+	if err != nil {
+		setRequestPhase(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, fmt.Sprintf("Failed to read lock list logs: %v", err))
+		return ctrl.Result{}, false, nil
+	}
+
+	phase, message, wait := lockAgeVerdict(output, monitor.Spec.MinLockAge.Duration)
+	switch phase {
+	case "":
+		return ctrl.Result{}, true, nil
+	case volsyncv1alpha1.VolSyncUnlockRequestPhaseSucceeded:
+		finishRequest(request, phase, message)
+	default:
+		setRequestPhase(request, phase, message)
+	}
+	return ctrl.Result{RequeueAfter: wait}, false, nil
+}
+
+// lockAgeVerdict decides on the output of a lock list job. An empty phase
+// means every lock is older than minLockAge. Otherwise the request succeeds
+// when there are no locks, waits until the youngest lock is old enough, or
+// fails when the locks could not be listed.
+func lockAgeVerdict(output string, minLockAge time.Duration) (volsyncv1alpha1.VolSyncUnlockRequestPhase, string, time.Duration) {
+	locks, err := restic.ParseLocks(output)
+	if err != nil {
+		return volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed, fmt.Sprintf("Failed to list locks: %v", err), 0
+	}
+	if len(locks) == 0 {
+		return volsyncv1alpha1.VolSyncUnlockRequestPhaseSucceeded, "Repository has no locks", 0
+	}
+	// Locks are sorted oldest first
+	if age := time.Since(locks[len(locks)-1].Time); age < minLockAge {
+		return volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting,
+			fmt.Sprintf("Lock is %s old, younger than minLockAge %s", age.Round(time.Second), minLockAge), minLockAge - age
+	}
+	return "", "", 0
+}
+
+// trackUnlockJob follows the unlock job of a request until it finishes
+func (r *VolSyncUnlockRequestReconciler) trackUnlockJob(ctx context.Context, request *volsyncv1alpha1.VolSyncUnlockRequest) (ctrl.Result, error) {
+	pipeline := r.pipeline()
+
+	var job batchv1.Job
+	if err := r.Get(ctx, types.NamespacedName{Namespace: request.TargetNamespace(), Name: request.Status.UnlockJobName}, &job); err != nil {
+		if errors.IsNotFound(err) {
+			finishRequest(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed,
+				fmt.Sprintf("Unlock job %s was deleted before it finished", request.Status.UnlockJobName))
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case pipeline.isJobSucceeded(job):
+		finishRequest(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseSucceeded, "Repository unlocked")
+		return ctrl.Result{}, nil
+	case pipeline.isJobFailed(&job):
+		finishRequest(request, volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed,
+			fmt.Sprintf("Unlock job %s failed", job.Name))
+		return ctrl.Result{}, nil
+	default:
+		return ctrl.Result{RequeueAfter: unlockRequestRequeue}, nil
+	}
+}
+
+// resolveMonitor returns the monitor named by the request, or the only enabled
+// monitor watching the target namespace. A nil monitor comes with the reason.
+func (r *VolSyncUnlockRequestReconciler) resolveMonitor(ctx context.Context, request *volsyncv1alpha1.VolSyncUnlockRequest) (*volsyncv1alpha1.VolSyncMonitor, string, error) {
+	if ref := request.Spec.MonitorRef; ref != nil {
+		var monitor volsyncv1alpha1.VolSyncMonitor
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &monitor); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, fmt.Sprintf("VolSyncMonitor %s/%s not found", ref.Namespace, ref.Name), nil
+			}
+			return nil, "", err
+		}
+		return &monitor, "", nil
+	}
+
+	var monitors volsyncv1alpha1.VolSyncMonitorList
+	if err := r.List(ctx, &monitors); err != nil {
+		return nil, "", fmt.Errorf("failed to list monitors: %w", err)
+	}
+
+	var candidates []*volsyncv1alpha1.VolSyncMonitor
+	for i := range monitors.Items {
+		monitor := &monitors.Items[i]
+		if monitor.Spec.Enabled && monitorWatchesNamespace(monitor, request.TargetNamespace()) {
+			candidates = append(candidates, monitor)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Sprintf("No enabled VolSyncMonitor watches namespace %s", request.TargetNamespace()), nil
+	case 1:
+		return candidates[0], "", nil
+	default:
+		var names []string
+		for _, monitor := range candidates {
+			names = append(names, monitor.Namespace+"/"+monitor.Name)
+		}
+		return nil, fmt.Sprintf("Several VolSyncMonitors watch namespace %s (%s), set spec.monitorRef",
+			request.TargetNamespace(), strings.Join(names, ", ")), nil
+	}
+}
+
+// setRequestPhase sets the phase of a request that is still in progress
+func setRequestPhase(request *volsyncv1alpha1.VolSyncUnlockRequest, phase volsyncv1alpha1.VolSyncUnlockRequestPhase, message string) {
+	request.Status.Phase = phase
+	request.Status.Message = message
+	if phase == volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed && request.Status.CompletionTime == nil {
+		now := metav1.Now()
+		request.Status.CompletionTime = &now
+	}
+}
+
+// finishRequest records the final phase of a request
+func finishRequest(request *volsyncv1alpha1.VolSyncUnlockRequest, phase volsyncv1alpha1.VolSyncUnlockRequestPhase, message string) {
+	now := metav1.Now()
+	request.Status.Phase = phase
+	request.Status.Message = message
+	request.Status.CompletionTime = &now
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VolSyncUnlockRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&volsyncv1alpha1.VolSyncUnlockRequest{}).
+		Watches(
+			&batchv1.Job{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+				return requestsForUnlockJob(obj)
+			}),
+		).
+		Complete(r)
+}
+
+// requestsForUnlockJob maps an unlock job to the request it was created for
+func requestsForUnlockJob(obj client.Object) []ctrl.Request {
+	labels := obj.GetLabels()
+	name := labels[unlockRequestLabel]
+	if name == "" {
+		return nil
+	}
+	namespace := labels[unlockRequestNamespaceLabel]
+	if namespace == "" {
+		namespace = obj.GetNamespace()
+	}
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// staticLogs serves the same logs for every pod
+type staticLogs string
+
+func (l staticLogs) GetPodLogs(context.Context, string, string, string) (string, error) {
+	return string(l), nil
+}
+
+var _ = Describe("VolSyncUnlockRequest Controller", func() {
+	var (
+		ctx     context.Context
+		monitor *volsyncv1alpha1.VolSyncMonitor
+		request *volsyncv1alpha1.VolSyncUnlockRequest
+		key     types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		monitor = &volsyncv1alpha1.VolSyncMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system", UID: "monitor-uid"},
+			Spec: volsyncv1alpha1.VolSyncMonitorSpec{
+				Enabled:              true,
+				MaxConcurrentUnlocks: 1,
+				UnlockJobTemplate:    volsyncv1alpha1.UnlockJobTemplate{Image: "restic/restic:latest"},
+			},
+		}
+		request = &volsyncv1alpha1.VolSyncUnlockRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "unlock-plex", Namespace: "media", UID: "request-uid"},
+			Spec:       volsyncv1alpha1.VolSyncUnlockRequestSpec{ReplicationSource: "plex"},
+		}
+		key = types.NamespacedName{Namespace: "media", Name: "unlock-plex"}
+	})
+
+	newReconciler := func(objects ...client.Object) *VolSyncUnlockRequestReconciler {
+		c := newFakeClient(objects...)
+		return &VolSyncUnlockRequestReconciler{Client: c, Scheme: c.Scheme()}
+	}
+
+	reconcileRequest := func(r *VolSyncUnlockRequestReconciler) *volsyncv1alpha1.VolSyncUnlockRequest {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		var updated volsyncv1alpha1.VolSyncUnlockRequest
+		Expect(r.Get(ctx, key, &updated)).To(Succeed())
+		return &updated
+	}
+
+	It("should create an unlock job for the discovered repository", func() {
+		r := newReconciler(monitor, request, newReplicationSource("media", "plex", "plex-restic"))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseRunning))
+		Expect(updated.Status.MonitorRef.Name).To(Equal("monitor"))
+		Expect(updated.Status.MoverType).To(Equal(volsyncv1alpha1.MoverTypeRestic))
+		Expect(updated.Status.RecordName).NotTo(BeEmpty())
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "media", Name: updated.Status.UnlockJobName}, &job)).To(Succeed())
+		Expect(job.Labels).To(HaveKeyWithValue(MonitorLabel, "monitor"))
+		Expect(job.Labels).To(HaveKeyWithValue(unlockRequestLabel, "unlock-plex"))
+		Expect(job.OwnerReferences).To(HaveLen(1))
+		Expect(job.OwnerReferences[0].Kind).To(Equal("VolSyncUnlockRequest"))
+		Expect(job.Spec.Template.Spec.Containers[0].EnvFrom[0].SecretRef.Name).To(Equal("plex-restic"))
+
+		By("reporting the result of the unlock job")
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &job)).To(Succeed())
+
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseSucceeded))
+		Expect(updated.Status.CompletionTime).NotTo(BeNil())
+	})
+
+	It("should pick up an unlock job whose status update was lost", func() {
+		unlock := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      unlockJobName("volsync-src-plex", request.UID),
+				Namespace: "media",
+				Labels:    map[string]string{MonitorLabel: "monitor", unlockRequestLabel: "unlock-plex", unlockRequestNamespaceLabel: "media"},
+			},
+			Status: batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(monitor, request, unlock, newReplicationSource("media", "plex", "plex-restic"))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseRunning))
+		Expect(updated.Status.UnlockJobName).To(Equal(unlock.Name))
+
+		var jobs batchv1.JobList
+		Expect(r.List(ctx, &jobs, client.InNamespace("media"))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+	})
+
+	It("should wait while a mover of the ReplicationSource is running", func() {
+		mover := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"},
+			Status:     batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(monitor, request, mover, newReplicationSource("media", "plex", "plex-restic"))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+	})
+
+	It("should wait while a mover using the secret of the request is running", func() {
+		request.Spec = volsyncv1alpha1.VolSyncUnlockRequestSpec{SecretName: "plex-restic"}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "plex-restic", Namespace: "media"}}
+		mover := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "volsync-src-plex", Namespace: "media"},
+			Status:     batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(monitor, request, secret, mover, newReplicationSource("media", "plex", "plex-restic"))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(updated.Status.Message).To(Equal("Job volsync-src-plex is using the repository"))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+	})
+
+	It("should list the locks and only unlock those older than minLockAge", func() {
+		monitor.Spec.MinLockAge = &metav1.Duration{Duration: time.Hour}
+		r := newReconciler(monitor, request, newReplicationSource("media", "plex", "plex-restic"))
+		r.Logs = staticLogs(lockSweepOutput(2 * time.Hour))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+		var listJob batchv1.Job
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "media", Name: updated.Status.LockListJobName}, &listJob)).To(Succeed())
+		Expect(listJob.Labels).To(HaveKeyWithValue(lockListLabel, "monitor"))
+		Expect(listJob.Labels).To(HaveKeyWithValue(unlockRequestLabel, "unlock-plex"))
+
+		By("waiting while the lock list job runs")
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(updated.Status.LockListJobName).To(Equal(listJob.Name))
+
+		By("unlocking once the locks are old enough")
+		listJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &listJob)).To(Succeed())
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: listJob.Name + "-abc12", Namespace: "media", Labels: map[string]string{"job-name": listJob.Name}}}
+		Expect(r.Create(ctx, pod)).To(Succeed())
+
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseRunning))
+		Expect(updated.Status.UnlockJobName).NotTo(BeEmpty())
+		Expect(updated.Status.LockListJobName).To(BeEmpty())
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(&listJob), &listJob)).NotTo(Succeed())
+	})
+
+	It("should fail when the locks of the repository cannot be listed", func() {
+		monitor.Spec.MinLockAge = &metav1.Duration{Duration: time.Hour}
+		r := newReconciler(monitor, request, newReplicationSource("media", "plex", "plex-restic"))
+		r.Logs = staticLogs("Fatal: wrong password or no key found\n")
+
+		updated := reconcileRequest(r)
+		var listJob batchv1.Job
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "media", Name: updated.Status.LockListJobName}, &listJob)).To(Succeed())
+		listJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &listJob)).To(Succeed())
+
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(Equal(fmt.Sprintf("Lock list job %s failed", listJob.Name)))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+
+		By("failing on a listing that did not complete")
+		request.Status = volsyncv1alpha1.VolSyncUnlockRequestStatus{}
+		r = newReconciler(monitor, request, newReplicationSource("media", "plex", "plex-restic"))
+		r.Logs = staticLogs("Fatal: wrong password or no key found\n")
+		updated = reconcileRequest(r)
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "media", Name: updated.Status.LockListJobName}, &listJob)).To(Succeed())
+		listJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(r.Status().Update(ctx, &listJob)).To(Succeed())
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: listJob.Name + "-abc12", Namespace: "media", Labels: map[string]string{"job-name": listJob.Name}}}
+		Expect(r.Create(ctx, pod)).To(Succeed())
+
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(ContainSubstring("lock listing did not complete"))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+	})
+
+	It("should decide on the listed locks", func() {
+		phase, message, wait := lockAgeVerdict(lockSweepOutput(10*time.Minute, 3*time.Hour), time.Hour)
+		Expect(phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(message).To(ContainSubstring("younger than minLockAge 1h0m0s"))
+		Expect(wait).To(BeNumerically("~", 50*time.Minute, time.Minute))
+
+		phase, _, _ = lockAgeVerdict(lockSweepOutput(), time.Hour)
+		Expect(phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseSucceeded))
+		phase, _, _ = lockAgeVerdict("Fatal: wrong password or no key found\n", time.Hour)
+		Expect(phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		phase, _, _ = lockAgeVerdict(lockSweepOutput(2*time.Hour), time.Hour)
+		Expect(phase).To(BeEmpty())
+	})
+
+	It("should wait while the monitor is at its concurrency limit", func() {
+		unlock := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "volsync-unlock-other",
+				Namespace: "system",
+				Labels:    map[string]string{MonitorLabel: "monitor"},
+			},
+			Status: batchv1.JobStatus{Active: 1},
+		}
+		r := newReconciler(monitor, request, unlock, newReplicationSource("media", "plex", "plex-restic"))
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseWaiting))
+		Expect(updated.Status.Message).To(ContainSubstring("1 of 1 concurrent unlocks"))
+	})
+
+	It("should fail when the target does not exist", func() {
+		request.Spec = volsyncv1alpha1.VolSyncUnlockRequestSpec{SecretName: "missing"}
+		r := newReconciler(monitor, request)
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(ContainSubstring("missing"))
+	})
+
+	It("should fail when the unlock job has no image to run", func() {
+		monitor.Spec.UnlockJobTemplate.Image = ""
+		request.Spec = volsyncv1alpha1.VolSyncUnlockRequestSpec{SecretName: "plex-restic"}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "plex-restic", Namespace: "media"}}
+		r := newReconciler(monitor, request, secret)
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(Equal(errNoUnlockImage.Error()))
+		Expect(updated.Status.UnlockJobName).To(BeEmpty())
+
+		By("failing before listing the locks as well")
+		monitor.Spec.MinLockAge = &metav1.Duration{Duration: time.Hour}
+		request.Status = volsyncv1alpha1.VolSyncUnlockRequestStatus{}
+		r = newReconciler(monitor, request, secret)
+
+		updated = reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(Equal(errNoUnlockImage.Error()))
+		Expect(updated.Status.LockListJobName).To(BeEmpty())
+	})
+
+	It("should fail when no monitor watches the namespace", func() {
+		monitor.Spec.JobSelector = &volsyncv1alpha1.JobSelector{Namespaces: []string{"downloads"}}
+		r := newReconciler(monitor, request)
+
+		updated := reconcileRequest(r)
+		Expect(updated.Status.Phase).To(Equal(volsyncv1alpha1.VolSyncUnlockRequestPhaseFailed))
+		Expect(updated.Status.Message).To(ContainSubstring("No enabled VolSyncMonitor"))
+	})
+})
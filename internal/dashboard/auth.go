@@ -0,0 +1,114 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Authorizer decides whether the bearer token of a request may read the dashboard
+type Authorizer interface {
+	Authorize(ctx context.Context, token string) (bool, error)
+}
+
+// TokenAuthorizer accepts a single static token
+type TokenAuthorizer struct {
+	Token string
+}
+
+// Authorize compares the token in constant time
+func (a *TokenAuthorizer) Authorize(_ context.Context, token string) (bool, error) {
+	if a.Token == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1, nil
+}
+
+// SubjectAccessReviewAuthorizer authenticates the token with a TokenReview and
+// allows users that may list VolSyncMonitors in every namespace, the same
+// check kube-rbac-proxy makes for a resource
+type SubjectAccessReviewAuthorizer struct {
+	Client client.Client
+}
+
+// Authorize reviews the token and then the access of the user behind it
+func (a *SubjectAccessReviewAuthorizer) Authorize(ctx context.Context, token string) (bool, error) {
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return false, nil
+	}
+
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	access := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    volsyncv1alpha1.GroupVersion.Group,
+				Version:  volsyncv1alpha1.GroupVersion.Version,
+				Resource: "volsyncmonitors",
+				Verb:     "list",
+			},
+		},
+	}
+	if err := a.Client.Create(ctx, access); err != nil {
+		return false, fmt.Errorf("failed to review access of %s: %w", user.Username, err)
+	}
+	return access.Status.Allowed, nil
+}
+
+// requestToken returns the bearer token of a request. Browsers can send the
+// token as the password of basic authentication.
+func requestToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if _, password, ok := req.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// authorize wraps a handler with the authorizer
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := requestToken(req)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="homelab-assistant"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		allowed, err := s.Authorizer.Authorize(req.Context(), token)
+		if err != nil {
+			s.Log.Error(err, "Failed to authorize dashboard request", "path", req.URL.Path)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,169 @@
+// Package dashboard serves a read-only view of the monitors and the VolSync
+// apps they watch, as HTML for people and JSON for scripts.
+package dashboard
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:embed index.html
+var indexHTML string
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{"ago": ago}).Parse(indexHTML))
+
+// ago formats how long ago a time was for the HTML page
+func ago(value interface{}) string {
+	var t metav1.Time
+	switch v := value.(type) {
+	case metav1.Time:
+		t = v
+	case *metav1.Time:
+		if v != nil {
+			t = *v
+		}
+	}
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t.Time)) + " ago"
+}
+
+// Server serves the dashboard. It implements manager.Runnable.
+type Server struct {
+	// Addr is the address the dashboard binds to
+	Addr string
+	// Reader reads the monitors and ReplicationSources, normally the manager's cache
+	Reader client.Reader
+	// Authorizer decides which requests may read the dashboard
+	Authorizer Authorizer
+	Log        logr.Logger
+}
+
+// NeedLeaderElection is false so that every replica serves the dashboard
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the dashboard until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Serving dashboard", "address", s.Addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// Handler returns the routes of the dashboard behind the authorizer
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveIndex)
+	mux.HandleFunc("/api/v1/monitors", s.serveMonitors)
+	mux.HandleFunc("/api/v1/apps", s.serveApps)
+	mux.HandleFunc("/api/v1/apps/", s.serveApp)
+	return s.authorize(mux)
+}
+
+// serveIndex renders the HTML page
+func (s *Server) serveIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	state, ok := s.state(w, req)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, state); err != nil {
+		s.Log.Error(err, "Failed to render dashboard")
+	}
+}
+
+// serveMonitors lists the monitors
+func (s *Server) serveMonitors(w http.ResponseWriter, req *http.Request) {
+	if state, ok := s.state(w, req); ok {
+		s.writeJSON(w, state.Monitors)
+	}
+}
+
+// serveApps lists the apps
+func (s *Server) serveApps(w http.ResponseWriter, req *http.Request) {
+	if state, ok := s.state(w, req); ok {
+		s.writeJSON(w, state.Apps)
+	}
+}
+
+// serveApp returns the app at /api/v1/apps/{namespace}/{name}
+func (s *Server) serveApp(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/apps/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, req)
+		return
+	}
+	state, ok := s.state(w, req)
+	if !ok {
+		return
+	}
+	app := state.app(parts[0], parts[1])
+	if app == nil {
+		http.NotFound(w, req)
+		return
+	}
+	s.writeJSON(w, app)
+}
+
+// state collects the dashboard state, answering the request itself on errors
+func (s *Server) state(w http.ResponseWriter, req *http.Request) (*State, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	state, err := s.collect(req.Context())
+	if err != nil {
+		s.Log.Error(err, "Failed to collect dashboard state")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return state, true
+}
+
+// writeJSON writes a JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		s.Log.Error(err, "Failed to write dashboard response")
+	}
+}
@@ -0,0 +1,231 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := volsyncv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	gv := replicationSourceListKind.GroupVersion()
+	scheme.AddKnownTypeWithName(gv.WithKind("ReplicationSource"), &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(replicationSourceListKind, &unstructured.UnstructuredList{})
+	return scheme
+}
+
+func newServer(t *testing.T) *Server {
+	t.Helper()
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+
+	source := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"lastSyncTime":      earlier.UTC().Format(time.RFC3339),
+			"lastSyncDuration":  "1m30s",
+			"latestMoverStatus": map[string]interface{}{"result": "Failed"},
+		},
+	}}
+	source.SetGroupVersionKind(replicationSourceListKind.GroupVersion().WithKind("ReplicationSource"))
+	source.SetNamespace("media")
+	source.SetName("plex")
+
+	monitor := &volsyncv1alpha1.VolSyncMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "monitor", Namespace: "system"},
+		Spec:       volsyncv1alpha1.VolSyncMonitorSpec{Enabled: true},
+		Status: volsyncv1alpha1.VolSyncMonitorStatus{
+			Phase: volsyncv1alpha1.VolSyncMonitorPhaseActive,
+			ActiveUnlocks: []volsyncv1alpha1.ActiveUnlock{{
+				AppName: "plex", Namespace: "media", ObjectName: "plex", JobName: "volsync-unlock-plex-1", StartTime: now,
+			}},
+			QueuedUnlocks: []volsyncv1alpha1.QueuedUnlock{{
+				JobName: "volsync-src-sonarr", Namespace: "media", ObjectName: "sonarr", Reason: "all 1 concurrent unlocks in use",
+			}},
+			ProcessedJobs: []volsyncv1alpha1.ProcessedJob{
+				{JobName: "volsync-src-plex", Namespace: "media", ObjectName: "plex", ProcessedTime: earlier, Classification: volsyncv1alpha1.FailureClassStaleLock},
+				{JobName: "volsync-src-plex", Namespace: "media", ObjectName: "plex", ProcessedTime: now, FailureTime: &now, Classification: volsyncv1alpha1.FailureClassStaleLock},
+				{JobName: "volsync-rclone-src-photos", Namespace: "media", ObjectName: "photos", ProcessedTime: now, Classification: volsyncv1alpha1.FailureClassTransient},
+			},
+			Conditions: []metav1.Condition{{
+				Type: volsyncv1alpha1.ConditionTypeQueueSaturated, Status: metav1.ConditionTrue, Message: "1 of 1 concurrent unlocks in use",
+			}},
+		},
+	}
+
+	return &Server{
+		Reader:     fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(source, monitor).Build(),
+		Authorizer: &TokenAuthorizer{Token: "secret"},
+		Log:        logr.Discard(),
+	}
+}
+
+func get(t *testing.T, handler http.Handler, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAuthorization(t *testing.T) {
+	handler := newServer(t).Handler()
+
+	if code := get(t, handler, "/api/v1/monitors", "").Code; code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := get(t, handler, "/api/v1/monitors", "wrong").Code; code != http.StatusForbidden {
+		t.Errorf("wrong token: status = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := get(t, handler, "/api/v1/monitors", "secret").Code; code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want %d", code, http.StatusOK)
+	}
+
+	// Browsers send the token as the basic auth password
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("admin", "secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("basic auth: status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	recorder := get(t, newServer(t).Handler(), "/api/v1/monitors", "secret")
+
+	var monitors []Monitor
+	if err := json.Unmarshal(recorder.Body.Bytes(), &monitors); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(monitors) != 1 {
+		t.Fatalf("got %d monitors, want 1", len(monitors))
+	}
+	if !monitors[0].Breaker.Open {
+		t.Errorf("Breaker.Open = false, want true")
+	}
+	if len(monitors[0].Status.QueuedUnlocks) != 1 {
+		t.Errorf("QueuedUnlocks = %v", monitors[0].Status.QueuedUnlocks)
+	}
+}
+
+func TestApp(t *testing.T) {
+	handler := newServer(t).Handler()
+	recorder := get(t, handler, "/api/v1/apps/media/plex", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	var app App
+	if err := json.Unmarshal(recorder.Body.Bytes(), &app); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !app.Found || app.LastResult != "Failed" || app.LastSyncDuration != "1m30s" || app.LastSyncTime == nil {
+		t.Errorf("sync status not read from the ReplicationSource: %+v", app)
+	}
+	if len(app.ProcessedJobs) != 2 || app.ProcessedJobs[0].FailureTime == nil {
+		t.Errorf("ProcessedJobs not sorted newest first: %+v", app.ProcessedJobs)
+	}
+	if app.LastFailure == nil || app.LastFailure.Monitor != "system/monitor" || app.LastFailure.Classification != volsyncv1alpha1.FailureClassStaleLock {
+		t.Errorf("LastFailure = %+v", app.LastFailure)
+	}
+	if len(app.ActiveUnlocks) != 1 || len(app.QueuedUnlocks) != 0 {
+		t.Errorf("ActiveUnlocks = %v, QueuedUnlocks = %v", app.ActiveUnlocks, app.QueuedUnlocks)
+	}
+
+	// Apps only known from the monitor history are listed too
+	recorder = get(t, handler, "/api/v1/apps/media/sonarr", "secret")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &app); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if app.Found || len(app.QueuedUnlocks) != 1 {
+		t.Errorf("sonarr = %+v", app)
+	}
+
+	// Apps are grouped by their VolSync object, whatever the mover
+	recorder = get(t, handler, "/api/v1/apps/media/photos", "secret")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &app); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(app.ProcessedJobs) != 1 || app.ProcessedJobs[0].JobName != "volsync-rclone-src-photos" {
+		t.Errorf("photos = %+v", app)
+	}
+
+	for _, path := range []string{"/api/v1/apps/media/radarr", "/api/v1/apps/media/volsync-rclone-src-photos", "/api/v1/apps/media", "/api/v1/apps/media/plex/extra"} {
+		if code := get(t, handler, path, "secret").Code; code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	recorder := get(t, newServer(t).Handler(), "/", "secret")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{"system/monitor", "media/plex", "volsync-unlock-plex-1", "all 1 concurrent unlocks in use"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+func TestSubjectAccessReviewAuthorizer(t *testing.T) {
+	var reviewed authorizationv1.SubjectAccessReviewSpec
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			switch review := obj.(type) {
+			case *authenticationv1.TokenReview:
+				review.Status.Authenticated = review.Spec.Token == "valid"
+				review.Status.User = authenticationv1.UserInfo{Username: "alice", Groups: []string{"admins"}}
+			case *authorizationv1.SubjectAccessReview:
+				reviewed = review.Spec
+				review.Status.Allowed = review.Spec.User == "alice"
+			}
+			return nil
+		},
+	}).Build()
+	authorizer := &SubjectAccessReviewAuthorizer{Client: c}
+
+	allowed, err := authorizer.Authorize(context.Background(), "invalid")
+	if err != nil || allowed {
+		t.Errorf("invalid token: allowed = %t, err = %v", allowed, err)
+	}
+	allowed, err = authorizer.Authorize(context.Background(), "valid")
+	if err != nil || !allowed {
+		t.Errorf("valid token: allowed = %t, err = %v", allowed, err)
+	}
+	if reviewed.ResourceAttributes == nil || reviewed.ResourceAttributes.Resource != "volsyncmonitors" || reviewed.ResourceAttributes.Verb != "list" {
+		t.Errorf("reviewed access = %+v", reviewed.ResourceAttributes)
+	}
+	if len(reviewed.Groups) != 1 || reviewed.Groups[0] != "admins" {
+		t.Errorf("reviewed groups = %v", reviewed.Groups)
+	}
+}
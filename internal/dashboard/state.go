@@ -0,0 +1,192 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	volsyncv1alpha1 "github.com/rafaribe/homelab-assistant/api/v1alpha1"
+)
+
+// recentJobsLimit is the number of processed jobs shown per app
+const recentJobsLimit = 10
+
+// replicationSourceListKind is the list kind of VolSync ReplicationSources
+var replicationSourceListKind = schema.GroupVersionKind{Group: "volsync.backube", Version: "v1alpha1", Kind: "ReplicationSourceList"}
+
+// Monitor is the dashboard view of a VolSyncMonitor
+type Monitor struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Enabled   bool   `json:"enabled"`
+	Priority  int32  `json:"priority"`
+
+	// Breaker is open while the monitor defers unlocks at its concurrency limit
+	Breaker Breaker `json:"breaker"`
+
+	Status volsyncv1alpha1.VolSyncMonitorStatus `json:"status"`
+}
+
+// Breaker is the state of the unlock concurrency limit of a monitor
+type Breaker struct {
+	Open    bool   `json:"open"`
+	Message string `json:"message,omitempty"`
+}
+
+// App is the dashboard view of a VolSync ReplicationSource
+type App struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Found is false for apps only known from the history of a monitor
+	Found bool `json:"found"`
+
+	LastSyncTime     *metav1.Time `json:"lastSyncTime,omitempty"`
+	LastSyncDuration string       `json:"lastSyncDuration,omitempty"`
+	NextSyncTime     *metav1.Time `json:"nextSyncTime,omitempty"`
+	// LastResult is the result of the latest mover run reported by VolSync
+	LastResult string `json:"lastResult,omitempty"`
+
+	// LastFailure is the most recent failed job handled by a monitor
+	LastFailure *Failure `json:"lastFailure,omitempty"`
+
+	ActiveUnlocks []volsyncv1alpha1.ActiveUnlock `json:"activeUnlocks,omitempty"`
+	QueuedUnlocks []volsyncv1alpha1.QueuedUnlock `json:"queuedUnlocks,omitempty"`
+	// ProcessedJobs are the most recent failed jobs of the app, newest first
+	ProcessedJobs []Failure `json:"processedJobs,omitempty"`
+}
+
+// Failure is a processed job along with the monitor that handled it
+type Failure struct {
+	volsyncv1alpha1.ProcessedJob `json:",inline"`
+	Monitor                      string `json:"monitor"`
+}
+
+// State is everything the dashboard shows
+type State struct {
+	Monitors []Monitor `json:"monitors"`
+	Apps     []App     `json:"apps"`
+}
+
+// collect reads the monitors and ReplicationSources from the cache
+func (s *Server) collect(ctx context.Context) (*State, error) {
+	var monitorList volsyncv1alpha1.VolSyncMonitorList
+	if err := s.Reader.List(ctx, &monitorList); err != nil {
+		return nil, fmt.Errorf("failed to list VolSyncMonitors: %w", err)
+	}
+
+	sourceList := &unstructured.UnstructuredList{}
+	sourceList.SetGroupVersionKind(replicationSourceListKind)
+	if err := s.Reader.List(ctx, sourceList); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("failed to list ReplicationSources: %w", err)
+	}
+
+	state := &State{Monitors: []Monitor{}, Apps: []App{}}
+	apps := map[string]*App{}
+	app := func(namespace, name string) *App {
+		key := namespace + "/" + name
+		if apps[key] == nil {
+			apps[key] = &App{Name: name, Namespace: namespace}
+		}
+		return apps[key]
+	}
+
+	for _, source := range sourceList.Items {
+		a := app(source.GetNamespace(), source.GetName())
+		a.Found = true
+		a.LastSyncTime = statusTime(source, "lastSyncTime")
+		a.NextSyncTime = statusTime(source, "nextSyncTime")
+		a.LastSyncDuration, _, _ = unstructured.NestedString(source.Object, "status", "lastSyncDuration")
+		a.LastResult, _, _ = unstructured.NestedString(source.Object, "status", "latestMoverStatus", "result")
+	}
+
+	for _, monitor := range monitorList.Items {
+		state.Monitors = append(state.Monitors, newMonitor(monitor))
+		key := monitor.Namespace + "/" + monitor.Name
+
+		for _, processed := range monitor.Status.ProcessedJobs {
+			a := app(processed.Namespace, objectName(processed.ObjectName, processed.JobName))
+			a.ProcessedJobs = append(a.ProcessedJobs, Failure{ProcessedJob: processed, Monitor: key})
+		}
+		for _, unlock := range monitor.Status.ActiveUnlocks {
+			a := app(unlock.Namespace, objectName(unlock.ObjectName, unlock.JobName))
+			a.ActiveUnlocks = append(a.ActiveUnlocks, unlock)
+		}
+		for _, queued := range monitor.Status.QueuedUnlocks {
+			a := app(queued.Namespace, objectName(queued.ObjectName, queued.JobName))
+			a.QueuedUnlocks = append(a.QueuedUnlocks, queued)
+		}
+	}
+
+	for _, a := range apps {
+		sort.SliceStable(a.ProcessedJobs, func(i, j int) bool {
+			return a.ProcessedJobs[j].ProcessedTime.Before(&a.ProcessedJobs[i].ProcessedTime)
+		})
+		if len(a.ProcessedJobs) > recentJobsLimit {
+			a.ProcessedJobs = a.ProcessedJobs[:recentJobsLimit]
+		}
+		if len(a.ProcessedJobs) > 0 {
+			a.LastFailure = &a.ProcessedJobs[0]
+		}
+		state.Apps = append(state.Apps, *a)
+	}
+	sort.Slice(state.Apps, func(i, j int) bool {
+		if state.Apps[i].Namespace != state.Apps[j].Namespace {
+			return state.Apps[i].Namespace < state.Apps[j].Namespace
+		}
+		return state.Apps[i].Name < state.Apps[j].Name
+	})
+	return state, nil
+}
+
+// newMonitor returns the dashboard view of a monitor
+func newMonitor(monitor volsyncv1alpha1.VolSyncMonitor) Monitor {
+	view := Monitor{
+		Name:      monitor.Name,
+		Namespace: monitor.Namespace,
+		Enabled:   monitor.Spec.Enabled,
+		Priority:  monitor.Spec.Priority,
+		Status:    monitor.Status,
+	}
+	if condition := meta.FindStatusCondition(monitor.Status.Conditions, volsyncv1alpha1.ConditionTypeQueueSaturated); condition != nil {
+		view.Breaker = Breaker{Open: condition.Status == metav1.ConditionTrue, Message: condition.Message}
+	}
+	return view
+}
+
+// app returns the app of a namespace, if any
+func (st *State) app(namespace, name string) *App {
+	for i := range st.Apps {
+		if st.Apps[i].Namespace == namespace && st.Apps[i].Name == name {
+			return &st.Apps[i]
+		}
+	}
+	return nil
+}
+
+// objectName returns the VolSync object a status entry belongs to. Entries
+// recorded before the object was resolved are listed under their job name.
+func objectName(objectName, jobName string) string {
+	if objectName != "" {
+		return objectName
+	}
+	return jobName
+}
+
+// statusTime reads a timestamp from the status of a VolSync object
+func statusTime(obj unstructured.Unstructured, field string) *metav1.Time {
+	value, ok, _ := unstructured.NestedString(obj.Object, "status", field)
+	if !ok {
+		return nil
+	}
+	var t metav1.Time
+	if err := t.UnmarshalQueryParameter(value); err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -1,6 +1,8 @@
 package helpers
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -59,6 +61,69 @@ var (
 		},
 		[]string{"namespace", "monitor", "result"},
 	)
+
+	// repositoryLocks tracks the number of locks found by the last lock sweep
+	repositoryLocks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "volsync_repository_locks",
+			Help: "Number of locks in the VolSync repository found by the last lock sweep",
+		},
+		[]string{"namespace", "replication_source"},
+	)
+
+	// repositoryOldestLockAge tracks the age of the oldest lock found by the last lock sweep
+	repositoryOldestLockAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "volsync_repository_oldest_lock_age_seconds",
+			Help: "Age of the oldest lock in the VolSync repository when the last lock sweep ran",
+		},
+		[]string{"namespace", "replication_source"},
+	)
+
+	// lockSweepsTotal tracks the total number of lock sweeps per repository
+	lockSweepsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "volsync_lock_sweeps_total",
+			Help: "Total number of VolSync repository lock sweeps",
+		},
+		[]string{"namespace", "replication_source", "result"},
+	)
+
+	// repositoryHealthy tracks the outcome of the last completed repository health check
+	repositoryHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "volsync_repository_healthy",
+			Help: "Whether the last restic check of the VolSync repository found no errors (1) or errors (0)",
+		},
+		[]string{"namespace", "replication_source"},
+	)
+
+	// repositoryChecksTotal tracks the total number of repository health checks
+	repositoryChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "volsync_repository_checks_total",
+			Help: "Total number of VolSync repository health checks",
+		},
+		[]string{"namespace", "replication_source", "result"},
+	)
+
+	// escalationStepsTotal tracks the escalation steps failures reached
+	escalationStepsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "volsync_escalation_steps_total",
+			Help: "Total number of times a failed VolSync unlock was escalated to a step",
+		},
+		[]string{"namespace", "app", "object", "step"},
+	)
+
+	// stuckMoversDetectedTotal tracks the mover jobs found stuck
+	stuckMoversDetectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "volsync_stuck_movers_detected_total",
+			Help: "Total number of VolSync mover jobs found hung or stuck Pending",
+		},
+		[]string{"namespace", "app", "object", "kind", "reason"},
+	)
 )
 
 func init() {
@@ -70,6 +135,13 @@ func init() {
 		activeUnlockJobs,
 		lockErrorsDetectedTotal,
 		monitorReconciliationsTotal,
+		repositoryLocks,
+		repositoryOldestLockAge,
+		lockSweepsTotal,
+		repositoryHealthy,
+		repositoryChecksTotal,
+		escalationStepsTotal,
+		stuckMoversDetectedTotal,
 	)
 }
 
@@ -104,3 +176,38 @@ func RecordLockErrorDetected(namespace, app, object, errorPattern string) {
 func RecordMonitorReconciliation(namespace, monitor, result string) {
 	monitorReconciliationsTotal.WithLabelValues(namespace, monitor, result).Inc()
 }
+
+// RecordRepositoryLocks sets the lock gauges of a repository
+func RecordRepositoryLocks(namespace, replicationSource string, locks int, oldestAge time.Duration) {
+	repositoryLocks.WithLabelValues(namespace, replicationSource).Set(float64(locks))
+	repositoryOldestLockAge.WithLabelValues(namespace, replicationSource).Set(oldestAge.Seconds())
+}
+
+// RecordLockSweep increments the counter for lock sweeps
+func RecordLockSweep(namespace, replicationSource, result string) {
+	lockSweepsTotal.WithLabelValues(namespace, replicationSource, result).Inc()
+}
+
+// RecordRepositoryCheck increments the counter for repository health checks
+func RecordRepositoryCheck(namespace, replicationSource, result string) {
+	repositoryChecksTotal.WithLabelValues(namespace, replicationSource, result).Inc()
+}
+
+// SetRepositoryHealthy sets the health gauge of a repository
+func SetRepositoryHealthy(namespace, replicationSource string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	repositoryHealthy.WithLabelValues(namespace, replicationSource).Set(value)
+}
+
+// RecordEscalationStep increments the counter for escalation steps
+func RecordEscalationStep(namespace, app, object, step string) {
+	escalationStepsTotal.WithLabelValues(namespace, app, object, step).Inc()
+}
+
+// RecordStuckMoverDetected increments the counter for stuck mover jobs
+func RecordStuckMoverDetected(namespace, app, object, kind, reason string) {
+	stuckMoversDetectedTotal.WithLabelValues(namespace, app, object, kind, reason).Inc()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -26,6 +27,11 @@ func StringPtr(s string) *string {
 	return &s
 }
 
+// LogSource reads the logs of pod containers
+type LogSource interface {
+	GetPodLogs(ctx context.Context, namespace, podName, containerName string) (string, error)
+}
+
 // GetPodLogs retrieves logs from a pod container
 func GetPodLogs(ctx context.Context, c client.Client, namespace, podName, containerName string) (string, error) {
 	// Get the rest config
@@ -62,3 +68,16 @@ func GetPodLogs(ctx context.Context, c client.Client, namespace, podName, contai
 
 	return string(logs), nil
 }
+
+// TailLines returns at most the last n lines of text, capped to maxBytes
+func TailLines(text string, n, maxBytes int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	tail := strings.Join(lines, "\n")
+	if len(tail) > maxBytes {
+		tail = tail[len(tail)-maxBytes:]
+	}
+	return tail
+}
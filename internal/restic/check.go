@@ -0,0 +1,37 @@
+package restic
+
+import "strings"
+
+// checkErrorsFound is printed by restic check when it found damage in the
+// repository, as opposed to failing to run at all
+const checkErrorsFound = "repository contains errors"
+
+// CheckScript returns the shell script that checks the repository configured
+// in the environment. A non-empty readDataSubset also verifies that part of
+// the pack files.
+func CheckScript(readDataSubset string) string {
+	script := "restic --no-cache check"
+	if readDataSubset != "" {
+		script += " --read-data-subset='" + strings.ReplaceAll(readDataSubset, "'", "") + "'"
+	}
+	return script
+}
+
+// CheckFoundErrors reports whether restic check output describes a damaged repository
+func CheckFoundErrors(text string) bool {
+	return strings.Contains(strings.ToLower(text), checkErrorsFound)
+}
+
+// CheckWithoutLockScript returns the shell script that checks the repository
+// configured in the environment without locking it, so that it runs while a
+// stale lock is still in place
+func CheckWithoutLockScript() string {
+	return "restic --no-lock --no-cache check"
+}
+
+// RepairIndexScript returns the shell script that rebuilds the index of the
+// repository configured in the environment. restic before 0.16 only knows the
+// rebuild-index command.
+func RepairIndexScript() string {
+	return "if restic repair index --help >/dev/null 2>&1; then restic repair index; else restic rebuild-index; fi"
+}
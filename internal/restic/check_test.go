@@ -0,0 +1,56 @@
+package restic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckScript(t *testing.T) {
+	if got := CheckScript(""); got != "restic --no-cache check" {
+		t.Errorf("CheckScript(\"\") = %q", got)
+	}
+	if got := CheckScript("5%"); got != "restic --no-cache check --read-data-subset='5%'" {
+		t.Errorf("CheckScript(\"5%%\") = %q", got)
+	}
+}
+
+func TestRepairScripts(t *testing.T) {
+	if got := CheckWithoutLockScript(); got != "restic --no-lock --no-cache check" {
+		t.Errorf("CheckWithoutLockScript() = %q", got)
+	}
+	if got := RepairIndexScript(); !strings.Contains(got, "restic repair index") || !strings.Contains(got, "restic rebuild-index") {
+		t.Errorf("RepairIndexScript() = %q", got)
+	}
+}
+
+func TestCheckFoundErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{
+			name: "healthy repository",
+			text: "using temporary cache in /tmp/restic-check-cache-1\nload indexes\ncheck all packs\ncheck snapshots, trees and blobs\nno errors were found\n",
+			want: false,
+		},
+		{
+			name: "damaged repository",
+			text: "check all packs\npack 4e1f2a3b: not referenced in any index\nerror for tree 9c8d7e6f:\n  tree 9c8d7e6f: file \"config\" blob 0 size could not be found\nFatal: repository contains errors\n",
+			want: true,
+		},
+		{
+			name: "locked repository",
+			text: "unable to create lock in backend: repository is already locked exclusively by PID 7 on volsync-src-app\n",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckFoundErrors(tt.text); got != tt.want {
+				t.Errorf("CheckFoundErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
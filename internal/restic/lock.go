@@ -0,0 +1,101 @@
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListLocksScript prints one line per lock of the repository configured in
+// the environment, holding the lock ID and the JSON content of the lock file.
+// Locks removed between listing and reading them are skipped. The IDs are
+// captured before the loop, since the shell ignores a failing command in the
+// word list of a for loop even with set -e.
+const ListLocksScript = `set -e
+ids="$(restic --no-cache list locks --no-lock)"
+for id in $ids; do
+  content="$(restic --no-cache cat lock "$id" --no-lock 2>/dev/null | tr -d '\n')" || continue
+  printf '` + lockLinePrefix + `%s %s\n' "$id" "$content"
+done
+echo "` + locksDoneLine + `"
+`
+
+const (
+	// lockLinePrefix starts every lock line printed by ListLocksScript
+	lockLinePrefix = "lock "
+	// locksDoneLine is printed by ListLocksScript once all locks were listed
+	locksDoneLine = "locks listed"
+)
+
+// Lock is a lock file of a restic repository
+type Lock struct {
+	// ID is the storage ID of the lock file
+	ID string `json:"-"`
+	// Time is when the lock was created or last refreshed
+	Time time.Time `json:"time"`
+	// Exclusive is set for locks taken by prune, check and similar commands
+	Exclusive bool `json:"exclusive"`
+	// Hostname is the host of the process holding the lock
+	Hostname string `json:"hostname"`
+	// Username is the user running the process holding the lock
+	Username string `json:"username"`
+	// PID is the process ID holding the lock
+	PID int `json:"pid"`
+}
+
+// ParseLocks parses the output of ListLocksScript, oldest lock first. It
+// fails when the output is incomplete, for example because restic could not
+// open the repository.
+func ParseLocks(text string) ([]Lock, error) {
+	var locks []Lock
+	complete := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == locksDoneLine {
+			complete = true
+			continue
+		}
+		if !strings.HasPrefix(line, lockLinePrefix) {
+			continue
+		}
+		id, content, _ := strings.Cut(strings.TrimPrefix(line, lockLinePrefix), " ")
+		if content == "" {
+			continue
+		}
+		lock := Lock{ID: id}
+		if err := json.Unmarshal([]byte(content), &lock); err != nil {
+			return nil, fmt.Errorf("failed to parse lock %s: %w", id, err)
+		}
+		locks = append(locks, lock)
+	}
+	if !complete {
+		return nil, fmt.Errorf("lock listing did not complete")
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Time.Before(locks[j].Time)
+	})
+	return locks, nil
+}
+
+// lockCreatedPattern matches the lock description restic prints when a
+// repository is already locked, for example
+// "lock was created at 2024-01-02 03:04:05 (2h13m5.2s ago)"
+var lockCreatedPattern = regexp.MustCompile(`lock was created at [0-9-]+ [0-9:]+ \(([0-9a-zµ.]+) ago\)`)
+
+// LockAge returns the age of the lock described in restic output at the time
+// the output was written. The second value is false when no age is reported.
+func LockAge(text string) (time.Duration, bool) {
+	match := lockCreatedPattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, false
+	}
+	age, err := time.ParseDuration(match[1])
+	if err != nil {
+		return 0, false
+	}
+	return age, true
+}
@@ -0,0 +1,135 @@
+package restic
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockAge(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   time.Duration
+		wantOK bool
+	}{
+		{
+			name: "restic lock description",
+			text: "unable to create lock in backend: repository is already locked by PID 12 on volsync-src-app by root (UID 0, GID 0)\n" +
+				"lock was created at 2024-01-02 03:04:05 (2h13m5.2s ago)\nstorage ID 1a2b3c4d",
+			want:   2*time.Hour + 13*time.Minute + 5200*time.Millisecond,
+			wantOK: true,
+		},
+		{
+			name:   "sub-second lock",
+			text:   "lock was created at 2024-01-02 03:04:05 (512.3µs ago)",
+			want:   512300 * time.Nanosecond,
+			wantOK: true,
+		},
+		{
+			name: "no lock description",
+			text: "repository is already locked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LockAge(tt.text)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("LockAge() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseLocks(t *testing.T) {
+	output := "lock 5f3c9a {\"time\":\"2024-01-02T03:04:05.5Z\",\"exclusive\":false,\"hostname\":\"volsync-src-app-abcde\",\"username\":\"root\",\"pid\":12}\n" +
+		"lock 1a2b3c {\"time\":\"2024-01-01T00:00:00Z\",\"exclusive\":true,\"hostname\":\"volsync-src-app-fghij\",\"username\":\"root\",\"pid\":7}\n" +
+		"lock 9d8e7f \n" +
+		"locks listed\n"
+
+	locks, err := ParseLocks(output)
+	if err != nil {
+		t.Fatalf("ParseLocks() error = %v", err)
+	}
+	if len(locks) != 2 {
+		t.Fatalf("ParseLocks() returned %d locks, want 2", len(locks))
+	}
+	if locks[0].ID != "1a2b3c" || !locks[0].Exclusive || locks[0].PID != 7 {
+		t.Errorf("ParseLocks() oldest lock = %+v", locks[0])
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC); !locks[1].Time.Equal(want) {
+		t.Errorf("ParseLocks() lock time = %v, want %v", locks[1].Time, want)
+	}
+	if locks[1].Hostname != "volsync-src-app-abcde" {
+		t.Errorf("ParseLocks() hostname = %q", locks[1].Hostname)
+	}
+}
+
+func TestParseLocks_Empty(t *testing.T) {
+	locks, err := ParseLocks("locks listed\n")
+	if err != nil {
+		t.Fatalf("ParseLocks() error = %v", err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("ParseLocks() returned %d locks, want 0", len(locks))
+	}
+}
+
+func TestParseLocks_Incomplete(t *testing.T) {
+	output := "Fatal: unable to open config file: Stat: The specified key does not exist.\n"
+	if _, err := ParseLocks(output); err == nil {
+		t.Error("ParseLocks() expected an error for incomplete output")
+	}
+
+	if _, err := ParseLocks("lock 1a2b3c {not json}\nlocks listed\n"); err == nil {
+		t.Error("ParseLocks() expected an error for invalid lock content")
+	}
+}
+
+// runListLocksScript runs ListLocksScript with a fake restic that runs body
+func runListLocksScript(t *testing.T, body string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "restic"), []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", ListLocksScript)
+	cmd.Env = append(os.Environ(), "PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func TestListLocksScript(t *testing.T) {
+	output, err := runListLocksScript(t, `case "$1 $2 $3" in
+  "--no-cache list locks") echo 1a2b3c ;;
+  *) echo '{"time":"2024-01-02T03:04:05Z","hostname":"host","pid":1}' ;;
+esac
+`)
+	if err != nil {
+		t.Fatalf("ListLocksScript failed: %v\n%s", err, output)
+	}
+	locks, err := ParseLocks(output)
+	if err != nil {
+		t.Fatalf("ParseLocks() error = %v", err)
+	}
+	if len(locks) != 1 || locks[0].ID != "1a2b3c" || locks[0].Hostname != "host" {
+		t.Errorf("ParseLocks() = %+v", locks)
+	}
+}
+
+func TestListLocksScript_RepositoryError(t *testing.T) {
+	output, err := runListLocksScript(t, "echo 'Fatal: wrong password or no key found' >&2\nexit 1\n")
+	if err == nil {
+		t.Errorf("ListLocksScript succeeded for a repository restic cannot open:\n%s", output)
+	}
+	if strings.Contains(output, locksDoneLine) {
+		t.Errorf("ListLocksScript printed %q after a failed listing", locksDoneLine)
+	}
+	if _, err := ParseLocks(output); err == nil {
+		t.Error("ParseLocks() expected an error for a failed listing")
+	}
+}
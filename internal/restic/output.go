@@ -0,0 +1,139 @@
+package restic
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Exit codes used by restic to report well-known failures
+const (
+	// ExitCodeFatal is returned for any fatal error without a more specific code
+	ExitCodeFatal int32 = 1
+	// ExitCodeRepositoryMissing is returned when the repository does not exist
+	ExitCodeRepositoryMissing int32 = 10
+	// ExitCodeRepositoryLocked is returned when restic failed to lock the repository
+	ExitCodeRepositoryLocked int32 = 11
+	// ExitCodeWrongPassword is returned when the repository password is wrong
+	ExitCodeWrongPassword int32 = 12
+)
+
+// Message types emitted by restic when run with --json
+const (
+	// MessageTypeExitError is the final message emitted before restic exits with an error
+	MessageTypeExitError = "exit_error"
+	// MessageTypeError reports a non-fatal error while processing an item
+	MessageTypeError = "error"
+)
+
+// lockErrorFragments are the lock related messages restic has used across versions
+var lockErrorFragments = []string{
+	"repository is already locked",
+	"unable to create lock",
+	"failed to lock repository",
+	"unable to refresh lock",
+}
+
+// Message is a single JSON object written by restic to stdout or stderr
+type Message struct {
+	// MessageType identifies the kind of message (status, summary, error, exit_error, ...)
+	MessageType string `json:"message_type"`
+
+	// Code is the exit code carried by exit_error messages
+	Code int32 `json:"code,omitempty"`
+
+	// Message is the human readable text of exit_error messages
+	Message string `json:"message,omitempty"`
+
+	// Error holds the details of error messages
+	Error *ErrorDetail `json:"error,omitempty"`
+
+	// During describes the operation that failed for error messages
+	During string `json:"during,omitempty"`
+
+	// Item is the file or object the error refers to
+	Item string `json:"item,omitempty"`
+}
+
+// ErrorDetail is the nested error object of error messages
+type ErrorDetail struct {
+	// Message is the error text
+	Message string `json:"message"`
+}
+
+// ParseMessage parses a single log line as a restic JSON message.
+// It returns false when the line is not a restic JSON object.
+func ParseMessage(line string) (*Message, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+		return nil, false
+	}
+
+	var msg Message
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return nil, false
+	}
+	if msg.MessageType == "" {
+		return nil, false
+	}
+
+	return &msg, true
+}
+
+// Text returns the error text carried by the message
+func (m *Message) Text() string {
+	if m.Message != "" {
+		return m.Message
+	}
+	if m.Error != nil {
+		return m.Error.Message
+	}
+	return ""
+}
+
+// IsError reports whether the message describes an error
+func (m *Message) IsError() bool {
+	return m.MessageType == MessageTypeExitError || m.MessageType == MessageTypeError
+}
+
+// IsLockError reports whether the message describes a repository lock failure,
+// either through the dedicated exit code or through a known lock error text
+func (m *Message) IsLockError() bool {
+	if !m.IsError() {
+		return false
+	}
+	if m.MessageType == MessageTypeExitError && m.Code == ExitCodeRepositoryLocked {
+		return true
+	}
+	return IsLockErrorText(m.Text())
+}
+
+// IsLockErrorText reports whether text contains one of the lock errors known to restic
+func IsLockErrorText(text string) bool {
+	text = strings.ToLower(text)
+	for _, fragment := range lockErrorFragments {
+		if strings.Contains(text, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLockExitCode reports whether a container exit code means the repository was locked
+func IsLockExitCode(code int32) bool {
+	return code == ExitCodeRepositoryLocked
+}
+
+// FindLockError scans logs for restic JSON messages and returns the first one
+// describing a lock failure, or nil when none is found
+func FindLockError(logs string) *Message {
+	for _, line := range strings.Split(logs, "\n") {
+		msg, ok := ParseMessage(line)
+		if !ok {
+			continue
+		}
+		if msg.IsLockError() {
+			return msg
+		}
+	}
+	return nil
+}
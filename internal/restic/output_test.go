@@ -0,0 +1,133 @@
+package restic
+
+import (
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		expectOK    bool
+		messageType string
+		text        string
+	}{
+		{
+			name:        "exit error",
+			line:        `{"message_type":"exit_error","code":11,"message":"Fatal: failed to lock repository"}`,
+			expectOK:    true,
+			messageType: MessageTypeExitError,
+			text:        "Fatal: failed to lock repository",
+		},
+		{
+			name:        "nested error",
+			line:        `  {"message_type":"error","error":{"message":"unable to create lock in backend"},"during":"archival","item":"/data"}  `,
+			expectOK:    true,
+			messageType: MessageTypeError,
+			text:        "unable to create lock in backend",
+		},
+		{
+			name:     "plain text",
+			line:     "Fatal: unable to create lock in backend: repository is already locked",
+			expectOK: false,
+		},
+		{
+			name:     "invalid json",
+			line:     `{"message_type":`,
+			expectOK: false,
+		},
+		{
+			name:     "json without message type",
+			line:     `{"level":"info","msg":"starting"}`,
+			expectOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, ok := ParseMessage(tt.line)
+			if ok != tt.expectOK {
+				t.Fatalf("Expected ok=%v, got %v", tt.expectOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if msg.MessageType != tt.messageType {
+				t.Errorf("Expected message type %q, got %q", tt.messageType, msg.MessageType)
+			}
+			if msg.Text() != tt.text {
+				t.Errorf("Expected text %q, got %q", tt.text, msg.Text())
+			}
+		})
+	}
+}
+
+func TestMessage_IsLockError(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      Message
+		expected bool
+	}{
+		{
+			name:     "exit code 11",
+			msg:      Message{MessageType: MessageTypeExitError, Code: ExitCodeRepositoryLocked, Message: "Fatal: something localized"},
+			expected: true,
+		},
+		{
+			name:     "older restic with lock text",
+			msg:      Message{MessageType: MessageTypeExitError, Code: ExitCodeFatal, Message: "Fatal: unable to create lock in backend: repository is already locked by PID 42"},
+			expected: true,
+		},
+		{
+			name:     "error message with lock text",
+			msg:      Message{MessageType: MessageTypeError, Error: &ErrorDetail{Message: "unable to refresh lock"}},
+			expected: true,
+		},
+		{
+			name:     "wrong password",
+			msg:      Message{MessageType: MessageTypeExitError, Code: ExitCodeWrongPassword, Message: "Fatal: wrong password or no key found"},
+			expected: false,
+		},
+		{
+			name:     "status message",
+			msg:      Message{MessageType: "status", Message: "repository is already locked"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.IsLockError(); got != tt.expected {
+				t.Errorf("Expected IsLockError=%v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindLockError(t *testing.T) {
+	logs := `Starting container
+{"message_type":"status","percent_done":0.5}
+{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/data/x"}
+{"message_type":"exit_error","code":11,"message":"Fatal: failed to lock repository"}
+`
+	msg := FindLockError(logs)
+	if msg == nil {
+		t.Fatal("Expected a lock error to be found")
+	}
+	if msg.Code != ExitCodeRepositoryLocked {
+		t.Errorf("Expected code %d, got %d", ExitCodeRepositoryLocked, msg.Code)
+	}
+
+	if FindLockError("Fatal: repository is already locked") != nil {
+		t.Error("Expected plain text logs to be left to pattern matching")
+	}
+}
+
+func TestIsLockExitCode(t *testing.T) {
+	if !IsLockExitCode(11) {
+		t.Error("Expected exit code 11 to be a lock error")
+	}
+	if IsLockExitCode(1) {
+		t.Error("Expected exit code 1 not to be a lock error")
+	}
+}